@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/tracing"
+)
+
+// batchManifest lists the projects a batch migrates
+type batchManifest struct {
+	Projects []batchProject `json:"projects"`
+}
+
+// batchProject is a project of a batch: an export migrated with migrate, or
+// else a project fetched through the Scrapbox API and pushed with sync
+type batchProject struct {
+	// Name tells the project apart in the logs and the report, defaulting to
+	// the project or the input
+	Name string `json:"name,omitempty"`
+	// Input is the path of the export, empty to sync the project
+	Input string `json:"input,omitempty"`
+	// Project is the name of the Scrapbox project, passed as -project
+	Project string `json:"project,omitempty"`
+	// Parent is the Notion page or database the project goes under,
+	// overriding NOTION_PARENT_PAGE_ID
+	Parent string `json:"parent,omitempty"`
+	// Args are more flags of the command, such as ["-tag-mode", "relation"]
+	Args []string `json:"args,omitempty"`
+}
+
+// batchReport records the outcome of every project of a batch
+type batchReport struct {
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Projects []batchResult `json:"projects"`
+}
+
+// batchResult is the outcome of a project of a batch, with the report of its
+// migration
+type batchResult struct {
+	Name     string     `json:"name"`
+	ExitCode int        `json:"exit_code"`
+	Error    string     `json:"error,omitempty"`
+	Report   *runReport `json:"report,omitempty"`
+}
+
+// runBatch migrates every project of a manifest, each in a run of its own, and
+// writes a report combining their reports. It returns a non-zero exit code if
+// any project failed.
+func runBatch(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion batch", flag.ExitOnError)
+	manifestFile := fs.String("manifest", "", "JSON file listing the projects to migrate, each with its input or Scrapbox project, Notion parent and flags")
+	parallel := fs.Int("parallel", 1, "Number of projects migrated at the same time")
+	reportFile := fs.String("report", "", "Write a JSON report combining the outcome and run report of every project to this file (optional)")
+	logFormat := addLogFormatFlag(fs)
+	fs.Parse(args)
+
+	if *manifestFile == "" {
+		fmt.Println("Error: manifest file is required")
+		fs.Usage()
+		return 2
+	}
+	if *parallel < 1 {
+		fmt.Println("Error: -parallel must be at least 1")
+		fs.Usage()
+		return 2
+	}
+	manifest, err := readManifest(*manifestFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+	if _, err := initLogger(*logFormat); err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		return 2
+	}
+
+	// Every project runs in a process of its own, so their settings and
+	// failures stay apart
+	executable, err := os.Executable()
+	if err != nil {
+		logger.Error("Failed to find the executable to run projects with", err, nil)
+		return 2
+	}
+	reportDir, err := os.MkdirTemp("", "scrapbox2notion-batch-")
+	if err != nil {
+		logger.Error("Failed to create report directory", err, nil)
+		return 2
+	}
+	defer os.RemoveAll(reportDir)
+
+	// Trace the batch when an OpenTelemetry collector is configured, with the
+	// run of every project continuing its trace
+	tracing.InitFromEnv()
+	ctx, batchSpan := tracing.Start(tracing.WithRemoteParent(context.Background(), os.Getenv("TRACEPARENT")), "batch", tracing.KindInternal, map[string]string{
+		"manifest": *manifestFile,
+	})
+	defer func() {
+		batchSpan.End(nil)
+		if err := tracing.Shutdown(context.Background()); err != nil {
+			logger.Error("Failed to export traces", err, nil)
+		}
+	}()
+
+	report := &batchReport{Started: time.Now(), Projects: make([]batchResult, len(manifest.Projects))}
+	projects := make(chan int)
+	var wg sync.WaitGroup
+	for range *parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range projects {
+				runPath := filepath.Join(reportDir, fmt.Sprintf("%d.json", i))
+				report.Projects[i] = runBatchProject(ctx, executable, manifest.Projects[i], runPath, *logFormat)
+			}
+		}()
+	}
+	for i := range manifest.Projects {
+		projects <- i
+	}
+	close(projects)
+	wg.Wait()
+	report.Finished = time.Now()
+
+	var failed []string
+	for _, result := range report.Projects {
+		if result.ExitCode != 0 {
+			failed = append(failed, result.Name)
+		}
+	}
+	summary := map[string]interface{}{
+		"project_count": len(report.Projects),
+		"failure_count": len(failed),
+	}
+	if len(failed) > 0 {
+		summary["failed_projects"] = failed
+	}
+	if *reportFile != "" {
+		if err := writeBatchReport(*reportFile, report); err != nil {
+			logger.Error("Failed to write batch report", err, map[string]interface{}{
+				"report": *reportFile,
+			})
+		} else {
+			summary["report"] = *reportFile
+		}
+	}
+	logger.Info("Batch completed", summary)
+
+	if len(failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runBatchProject migrates a project of a batch with the executable, writing the
+// run report of a migration to reportPath, and returns its outcome
+func runBatchProject(ctx context.Context, executable string, project batchProject, reportPath, logFormat string) batchResult {
+	_, span := tracing.Start(ctx, "project", tracing.KindInternal, map[string]string{
+		"project": project.Name,
+	})
+
+	var args []string
+	if project.Input != "" {
+		args = []string{"migrate", "-input", project.Input, "-report", reportPath}
+	} else {
+		args = []string{"sync"}
+	}
+	if project.Project != "" {
+		args = append(args, "-project", project.Project)
+	}
+	if logFormat != "" {
+		args = append(args, "-log-format", logFormat)
+	}
+	args = append(args, project.Args...)
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	if project.Parent != "" {
+		cmd.Env = append(cmd.Env, "NOTION_PARENT_PAGE_ID="+project.Parent)
+	}
+	if traceparent := span.Traceparent(); traceparent != "" {
+		cmd.Env = append(cmd.Env, "TRACEPARENT="+traceparent)
+	}
+
+	log := logger.With(map[string]interface{}{"project": project.Name})
+	log.Info("Migrating project", nil)
+	result := batchResult{Name: project.Name}
+	err := cmd.Run()
+	span.End(err)
+	if err != nil {
+		result.ExitCode = 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		}
+		result.Error = err.Error()
+		log.Error("Failed to migrate project", err, nil)
+	} else {
+		log.Info("Migrated project", nil)
+	}
+	if project.Input != "" {
+		if run, err := readReport(reportPath); err == nil {
+			result.Report = run
+		}
+	}
+	return result
+}
+
+// readManifest reads a batch manifest, naming the projects without a name
+func readManifest(path string) (*batchManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest batchManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if len(manifest.Projects) == 0 {
+		return nil, fmt.Errorf("invalid manifest %q: must list at least one project", path)
+	}
+	for i := range manifest.Projects {
+		project := &manifest.Projects[i]
+		if project.Input == "" && project.Project == "" {
+			return nil, fmt.Errorf("invalid manifest %q: project #%d must have an input or a project", path, i+1)
+		}
+		if project.Name == "" {
+			project.Name = project.Project
+		}
+		if project.Name == "" {
+			project.Name = project.Input
+		}
+	}
+	return &manifest, nil
+}
+
+// writeBatchReport writes a batch report as JSON
+func writeBatchReport(path string, report *batchReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commands are the subcommands offered by shell completion
+var commands = []string{"migrate", "validate", "graph", "tags", "rollback", "dedupe", "verify", "sync", "serve", "batch", "completion", "version", "help"}
+
+// flagsCommand lists the flags of a subcommand, taken from its -h output so the
+// completions never fall behind the flags
+const flagsCommand = `scrapbox2notion "$cmd" -h 2>&1 | sed -n 's/^  \(-[a-z-]*\).*/\1/p'`
+
+const bashCompletion = `_scrapbox2notion() {
+    local cur=${COMP_WORDS[COMP_CWORD]} cmd=migrate
+    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then
+        COMPREPLY=($(compgen -W "%[1]s" -- "$cur"))
+        return
+    fi
+    [[ ${COMP_WORDS[1]} != -* ]] && cmd=${COMP_WORDS[1]}
+    if [[ $cmd == completion ]]; then
+        COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+    elif [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$(%[2]s)" -- "$cur"))
+    else
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+complete -o filenames -F _scrapbox2notion scrapbox2notion
+`
+
+const zshCompletion = `#compdef scrapbox2notion
+_scrapbox2notion() {
+    local cmd=migrate
+    if (( CURRENT == 2 )) && [[ $PREFIX != -* ]]; then
+        compadd -- %[1]s
+        return
+    fi
+    [[ $words[2] != -* ]] && cmd=$words[2]
+    if [[ $cmd == completion ]]; then
+        compadd -- bash zsh fish
+    elif [[ $PREFIX == -* ]]; then
+        compadd -- ${(f)"$(%[2]s)"}
+    else
+        _files
+    fi
+}
+compdef _scrapbox2notion scrapbox2notion
+`
+
+const fishCompletion = `function __scrapbox2notion_flags
+    set -l cmd migrate
+    set -l tokens (commandline -opc)
+    if test (count $tokens) -gt 1; and not string match -q -- '-*' $tokens[2]
+        set cmd $tokens[2]
+    end
+    %[2]s
+end
+complete -c scrapbox2notion -n __fish_use_subcommand -f -a "%[1]s"
+complete -c scrapbox2notion -n "__fish_seen_subcommand_from completion" -f -a "bash zsh fish"
+complete -c scrapbox2notion -n "string match -q -- '-*' (commandline -ct)" -f -a "(__scrapbox2notion_flags)"
+`
+
+// runCompletion prints the completion script of a shell
+func runCompletion(args []string) int {
+	scripts := map[string]string{
+		"bash": bashCompletion,
+		"zsh":  zshCompletion,
+		"fish": fishCompletion,
+	}
+	if len(args) != 1 || scripts[args[0]] == "" {
+		fmt.Println("Usage: scrapbox2notion completion bash|zsh|fish")
+		return 2
+	}
+	fmt.Printf(scripts[args[0]], strings.Join(commands, " "), flagsCommand)
+	return 0
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+)
+
+// conversionFlags are the flags deciding how pages convert to Notion blocks,
+// shared by the commands that need to convert pages the way migrate does
+type conversionFlags struct {
+	urlStyle        *string
+	toggleDepth     *int
+	ignoreTagCase   *bool
+	tagMode         *string
+	tagHierarchy    *string
+	defaultTag      *string
+	untaggedDB      *string
+	bracketTags     *bool
+	tagLines        *string
+	embeds          *string
+	defaultCallouts *bool
+	callouts        stringList
+	attachments     *bool
+	attachmentRules stringList
+	emoji           *bool
+	emojiMap        *string
+	onDuplicate     *string
+	onConflict      *string
+	titleMap        *string
+	unicodeForm     *string
+	fullWidthIndent *bool
+	linkUnlisted    *bool
+	probeImages     *bool
+	force           *bool
+	missingLinks    *string
+	history         *string
+	toc             *int
+	columns         *string
+	footer          *bool
+	// pageExists reports whether the input has the page of a title, set by the
+	// command once it has read the pages
+	pageExists func(title string) bool
+}
+
+// addConversionFlags defines the conversion flags on a flag set
+func addConversionFlags(fs *flag.FlagSet) *conversionFlags {
+	f := &conversionFlags{}
+	f.urlStyle = fs.String("url-style", "plain", "How to upload lines consisting of a single URL: bookmark, link or plain")
+	f.toggleDepth = fs.Int("toggle-depth", 0, "Collapse outlines nested at or beyond this depth into Notion toggle blocks (0 disables)")
+	f.tagMode = fs.String("tag-mode", "databases", "How tags are modeled in Notion: databases (a copy of the page per tag database), canonical (the page in the first tag database, links in the others), synced (the content in a synced block of the page in the first tag database, shown in the others) or relation (a Pages database related to a Tags database)")
+	f.tagHierarchy = fs.String("tag-hierarchy", "flat", "How tags with levels such as #work/projectX are organized in Notion: flat (a tag database per whole tag), nested (the projectX database in a work page) or property (a Parent tag multi-select of the pages, or relation of the Tags rows in relation mode)")
+	f.defaultTag = fs.String("default-tag", "", "Tag given to pages without tags, in Notion and every output format (optional)")
+	f.untaggedDB = fs.String("untagged-database", "", "Title of the Notion tag database pages without tags are created in, instead of directly under the parent page (optional)")
+	f.ignoreTagCase = fs.Bool("ignore-tag-case", false, "Reuse Notion tag databases whose title differs from the tag only in case")
+	f.bracketTags = fs.Bool("bracket-tags", false, "Also take the [page links] on the last lines of a page as its tags")
+	f.tagLines = fs.String("tag-lines", "strip", "What to do with lines consisting only of hashtags: strip, keep or keep-and-link")
+	f.embeds = fs.String("embeds", "off", "Embed lines consisting of a YouTube, Vimeo or Twitter URL as videos and embeds: on or off")
+	f.defaultCallouts = fs.Bool("callouts", false, "Turn lines starting with NOTE:, TIP:, WARN:, WARNING:, IMPORTANT: or ⚠️ into callouts")
+	fs.Var(&f.callouts, "callout", "Turn lines starting with a marker into callouts, as MARKER=ICON or MARKER=ICON,COLOR, repeatable")
+	f.attachments = fs.Bool("attachments", false, "Show lines consisting of a link to an audio, video, PDF, zip, csv or Office file as Notion audio, video, PDF and file blocks")
+	fs.Var(&f.attachmentRules, "attachment", "Show lines consisting of a link to a file with an extension as an attachment, as EXT=KIND with KIND one of audio, video, pdf, file or bookmark, repeatable and overriding -attachments")
+	f.emoji = fs.Bool("emoji", false, "Replace common emoji shortcodes such as :smile: in text with their emoji")
+	f.emojiMap = fs.String("emoji-map", "", "CSV file of SHORTCODE,EMOJI rows of emoji shortcodes to replace in text, overriding -emoji (optional)")
+	f.onDuplicate = fs.String("on-duplicate", "newest", "How to merge pages with the same title across inputs: newest, first or rename")
+	f.onConflict = fs.String("on-conflict", "skip", "What to do with a page created directly under the Notion parent when a page with its title is already there: skip, rename (add a number to the title) or update (replace its content)")
+	f.titleMap = fs.String("title-map", "", "CSV file of SCRAPBOX_TITLE,NOTION_TITLE[,DATABASE] rows renaming pages and the links to them (optional)")
+	f.unicodeForm = fs.String("unicode-form", "none", "Unicode normalization of titles and text, so titles typed on different systems match: nfc, nfd or none")
+	f.fullWidthIndent = fs.Bool("fullwidth-indent", false, "Treat full-width spaces (U+3000) at the start of lines as indentation")
+	f.linkUnlisted = fs.Bool("link-unlisted", false, "Link page links missing from the linksLc of their page when the export has the page")
+	f.probeImages = fs.Bool("probe-images", false, "Ask the server for the content type of bracketed URLs that don't look like images, to show images without an extension")
+	f.history = fs.String("history", "off", "Show who last edited each line and when: off, comment (a comment after the line) or footnote (a footnote referred to from the line)")
+	f.force = fs.Bool("force", false, "Convert input that doesn't look like a Scrapbox export anyway, as far as it can be decoded")
+	f.columns = fs.String("columns", "", "Text of a line, such as columns:, whose following list items are laid out side by side in Notion, a column per top level item (empty disables)")
+	f.toc = fs.Int("toc", 0, "Lead pages with at least this many headings with a table of contents, in Notion and the markdown output (0 disables)")
+	f.footer = fs.Bool("footer", false, "End pages with a footer of the Scrapbox project and page they come from, the time of the export and the version of the tool, as a callout in Notion")
+	f.missingLinks = fs.String("missing-links", "plain", "What links to pages missing from the input become in Notion: plain (text), link (a mention of the page of the title found under the parent) or stub (a mention of an empty page created when none is found)")
+	return f
+}
+
+// parserOptions returns the parser options of the flags
+func (f *conversionFlags) parserOptions() ([]parser.Option, error) {
+	duplicatePolicy, err := parser.ParseDuplicatePolicy(*f.onDuplicate)
+	if err != nil {
+		return nil, err
+	}
+
+	tagLineMode, err := parser.ParseTagLineMode(*f.tagLines)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := parser.ParseHistoryMode(*f.history)
+	if err != nil {
+		return nil, err
+	}
+
+	unicodeForm, err := parser.ParseUnicodeForm(*f.unicodeForm)
+	if err != nil {
+		return nil, err
+	}
+
+	if *f.toc < 0 {
+		return nil, fmt.Errorf("invalid toc setting %d: must not be negative", *f.toc)
+	}
+
+	if *f.embeds != "on" && *f.embeds != "off" {
+		return nil, fmt.Errorf("invalid embeds setting %q: must be on or off", *f.embeds)
+	}
+
+	var calloutRules []parser.CalloutRule
+	for _, spec := range f.callouts {
+		rule, err := parser.ParseCalloutRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		calloutRules = append(calloutRules, rule)
+	}
+	if *f.defaultCallouts {
+		calloutRules = append(calloutRules, parser.DefaultCalloutRules...)
+	}
+
+	var attachmentRules []parser.AttachmentRule
+	if *f.attachments {
+		attachmentRules = append(attachmentRules, parser.DefaultAttachmentRules...)
+	}
+	for _, spec := range f.attachmentRules {
+		rule, err := parser.ParseAttachmentRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		attachmentRules = append(attachmentRules, rule)
+	}
+
+	opts := []parser.Option{
+		parser.WithDuplicatePolicy(duplicatePolicy),
+		parser.WithTagLineMode(tagLineMode),
+		parser.WithUnicodeForm(unicodeForm),
+	}
+	if *f.fullWidthIndent {
+		opts = append(opts, parser.WithFullWidthIndent())
+	}
+	if history != "" {
+		opts = append(opts, parser.WithLineHistory(history))
+	}
+	if *f.linkUnlisted {
+		opts = append(opts, parser.WithUnlistedLinks())
+	}
+	if *f.toc > 0 {
+		opts = append(opts, parser.WithTableOfContents(*f.toc))
+	}
+	if *f.force {
+		opts = append(opts, parser.WithForce())
+	}
+	if *f.probeImages {
+		opts = append(opts, parser.WithImageProbe(parser.ContentTypeProbe(&http.Client{Timeout: 10 * time.Second})))
+	}
+	if *f.bracketTags {
+		opts = append(opts, parser.WithBracketTags())
+	}
+	if *f.defaultTag != "" {
+		opts = append(opts, parser.WithDefaultTag(*f.defaultTag))
+	}
+	if *f.embeds == "on" {
+		opts = append(opts, parser.WithEmbeds())
+	}
+	if len(calloutRules) > 0 {
+		opts = append(opts, parser.WithCalloutRules(calloutRules...))
+	}
+	if len(attachmentRules) > 0 {
+		opts = append(opts, parser.WithAttachmentRules(attachmentRules...))
+	}
+	if *f.titleMap != "" {
+		mappings, err := parser.LoadTitleMapping(*f.titleMap)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parser.WithTitleMapping(mappings))
+	}
+	if *f.footer {
+		ver, _, _ := buildInfo()
+		opts = append(opts, parser.WithMetadataFooter(ver))
+	}
+	if *f.emoji {
+		opts = append(opts, parser.WithEmojiShortcodes(parser.DefaultEmojiShortcodes))
+	}
+	if *f.emojiMap != "" {
+		shortcodes, err := parser.LoadEmojiShortcodes(*f.emojiMap)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parser.WithEmojiShortcodes(shortcodes))
+	}
+	return opts, nil
+}
+
+// notionOptions returns the Notion client options of the flags
+func (f *conversionFlags) notionOptions() ([]notion.Option, error) {
+	style, err := notion.ParseURLStyle(*f.urlStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	tagMode, err := notion.ParseTagMode(*f.tagMode)
+	if err != nil {
+		return nil, err
+	}
+
+	tagHierarchy, err := notion.ParseTagHierarchy(*f.tagHierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	onConflict, err := notion.ParseConflictPolicy(*f.onConflict)
+	if err != nil {
+		return nil, err
+	}
+
+	missingLinks, err := notion.ParseMissingLinkMode(*f.missingLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []notion.Option{
+		notion.WithURLStyle(style),
+		notion.WithToggleDepth(*f.toggleDepth),
+		notion.WithTagMode(tagMode),
+		notion.WithTagHierarchy(tagHierarchy),
+		notion.WithConflictPolicy(onConflict),
+		notion.WithMissingLinks(missingLinks, f.hasPage),
+	}
+	if *f.ignoreTagCase {
+		opts = append(opts, notion.WithCaseInsensitiveTags())
+	}
+	if *f.columns != "" {
+		opts = append(opts, notion.WithColumns(*f.columns))
+	}
+	if *f.untaggedDB != "" {
+		opts = append(opts, notion.WithUntaggedDatabase(*f.untaggedDB))
+	}
+	return opts, nil
+}
+
+// hasPage reports whether the input has the page of the title, taking every
+// page as present until the command sets pageExists
+func (f *conversionFlags) hasPage(title string) bool {
+	return f.pageExists == nil || f.pageExists(title)
+}
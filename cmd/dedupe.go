@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+)
+
+// runDedupe archives pages with the same title in the Notion databases under
+// the parent page, keeping the newest of each. Without -apply it only lists
+// what would be archived. It returns a non-zero exit code on failure.
+func runDedupe(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion dedupe", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "Archive the duplicates instead of only listing them")
+	logFormat := addLogFormatFlag(fs)
+	fs.Parse(args)
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Printf("Error loading .env file: %v\n", err)
+		return 2
+	}
+	if _, err := initLogger(*logFormat); err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		return 2
+	}
+
+	client, err := notion.New()
+	if err != nil {
+		logger.Error("Failed to initialize Notion client", err, nil)
+		return 2
+	}
+
+	ctx := context.Background()
+	if err := client.Preflight(ctx); err != nil {
+		logger.Error("Failed to access Notion parent", err, nil)
+		return 2
+	}
+
+	groups, err := client.FindDuplicates(ctx)
+	if err != nil {
+		logger.Error("Failed to find duplicate pages", err, nil)
+		return 1
+	}
+
+	duplicates := 0
+	for _, group := range groups {
+		fmt.Printf("%s / %s\n", group.Database, group.Title)
+		fmt.Printf("  keep     %s  %s\n", group.Keep.ID, group.Keep.Created.Format(time.RFC3339))
+		for _, page := range group.Duplicates {
+			fmt.Printf("  archive  %s  %s\n", page.ID, page.Created.Format(time.RFC3339))
+		}
+		duplicates += len(group.Duplicates)
+	}
+	fmt.Printf("%d duplicate pages in %d groups\n", duplicates, len(groups))
+
+	if !*apply {
+		if duplicates > 0 {
+			fmt.Println("Run again with -apply to archive them")
+		}
+		return 0
+	}
+
+	failures := 0
+	for _, group := range groups {
+		for _, page := range group.Duplicates {
+			object := notion.CreatedObject{Type: notion.ObjectPage, ID: page.ID, Title: group.Title}
+			if err := client.Archive(ctx, object); err != nil {
+				logger.Error("Failed to archive duplicate page", err, map[string]interface{}{
+					"database": group.Database,
+					"title":    group.Title,
+					"id":       page.ID,
+				})
+				failures++
+			}
+		}
+	}
+
+	logger.Info("Dedupe completed", map[string]interface{}{
+		"archived_count": duplicates - failures,
+		"failure_count":  failures,
+	})
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
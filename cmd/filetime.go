@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// setFileTimes sets the modification time of a written page to when the page was
+// last updated, and its creation time to when it was created where the platform
+// supports it. Zero timestamps are left alone.
+func setFileTimes(path string, created, updated int64) error {
+	if updated > 0 {
+		mtime := time.Unix(updated, 0)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			return fmt.Errorf("failed to set modification time: %w", err)
+		}
+	}
+	if created > 0 {
+		if err := setCreationTime(path, time.Unix(created, 0)); err != nil {
+			return fmt.Errorf("failed to set creation time: %w", err)
+		}
+	}
+	return nil
+}
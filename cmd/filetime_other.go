@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "time"
+
+// setCreationTime does nothing as the creation time can't be set on this platform
+func setCreationTime(path string, created time.Time) error {
+	return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// setCreationTime sets the creation time of the file at path
+func setCreationTime(path string, created time.Time) error {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	h, err := syscall.CreateFile(name, syscall.FILE_WRITE_ATTRIBUTES, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(h)
+
+	ctime := syscall.NsecToFiletime(created.UnixNano())
+	return syscall.SetFileTime(h, &ctime, nil, nil)
+}
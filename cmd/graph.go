@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/takak2166/scrapbox2notion/internal/graph"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+)
+
+// runGraph writes the link graph of a Scrapbox export as DOT or JSON.
+// It returns a non-zero exit code on failure.
+func runGraph(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion graph", flag.ExitOnError)
+	var inputPatterns stringList
+	fs.Var(&inputPatterns, "input", "Path or glob pattern of Scrapbox JSON export files, repeatable (- to read from stdin)")
+	format := fs.String("format", "dot", "Graph format: dot or json")
+	outputFile := fs.String("output", "", "File to write the graph to (defaults to stdout)")
+	fs.Parse(args)
+
+	if len(inputPatterns) == 0 {
+		fmt.Println("Error: input file is required")
+		fs.Usage()
+		return 2
+	}
+	if *format != "dot" && *format != "json" {
+		fmt.Printf("Error: invalid format %q: must be one of dot, json\n", *format)
+		fs.Usage()
+		return 2
+	}
+
+	inputFiles, err := expandInputs(inputPatterns)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+
+	p := parser.New()
+	for _, inputFile := range inputFiles {
+		if inputFile == "-" {
+			err = p.Parse(os.Stdin)
+		} else {
+			err = p.ParseFile(inputFile)
+		}
+		if err != nil {
+			fmt.Printf("Error reading input: %v\n", err)
+			return 2
+		}
+	}
+
+	pages := p.GetPages()
+	docs := make([]*models.Document, 0, len(pages))
+	for i := range pages {
+		docs = append(docs, p.ParseDocument(&pages[i]))
+	}
+	g := graph.Build(docs)
+
+	var w io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			fmt.Printf("Error creating output: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "json" {
+		err = g.WriteJSON(w)
+	} else {
+		err = g.WriteDOT(w)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
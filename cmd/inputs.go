@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// stringList is a flag.Value that collects every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// expandInputs expands glob patterns in the input paths.
+// Paths without glob characters and "-" (stdin) are kept as they are.
+func expandInputs(patterns []string) ([]string, error) {
+	var inputs []string
+	for _, pattern := range patterns {
+		if pattern == "-" || !strings.ContainsAny(pattern, "*?[") {
+			inputs = append(inputs, pattern)
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match input pattern %q", pattern)
+		}
+		inputs = append(inputs, matches...)
+	}
+	return inputs, nil
+}
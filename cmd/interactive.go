@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/render/markdown"
+)
+
+// reviewDecision is what to do with a reviewed page
+type reviewDecision int
+
+const (
+	// reviewCreate migrates the page
+	reviewCreate reviewDecision = iota
+	// reviewSkip leaves the page out
+	reviewSkip
+	// reviewQuit leaves out the page and every page after it
+	reviewQuit
+)
+
+// pageReviewer shows each page before it is migrated and asks what to do with it
+type pageReviewer struct {
+	in  *bufio.Reader
+	out io.Writer
+	// pager is the command showing the page, such as less
+	pager string
+}
+
+// newPageReviewer creates a reviewer prompting on the terminal, showing pages
+// with the PAGER command, or less by default
+func newPageReviewer() *pageReviewer {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+	return &pageReviewer{in: bufio.NewReader(os.Stdin), out: os.Stdout, pager: pager}
+}
+
+// review shows the page as markdown and asks whether to create it, skip it or
+// quit. Editing the title renames the document and asks again.
+func (r *pageReviewer) review(doc *models.Document, index, total int) (reviewDecision, error) {
+	r.show(markdown.Render(doc))
+	for {
+		fmt.Fprintf(r.out, "[%d/%d] %s: [c]reate, [s]kip, [e]dit title, [v]iew again or [q]uit? ", index, total, doc.Title)
+		answer, err := r.readLine()
+		if err != nil {
+			return reviewQuit, err
+		}
+		switch strings.ToLower(answer) {
+		case "c", "create":
+			return reviewCreate, nil
+		case "s", "skip":
+			return reviewSkip, nil
+		case "q", "quit":
+			return reviewQuit, nil
+		case "v", "view":
+			r.show(markdown.Render(doc))
+		case "e", "edit":
+			fmt.Fprint(r.out, "New title: ")
+			title, err := r.readLine()
+			if err != nil {
+				return reviewQuit, err
+			}
+			if title != "" {
+				doc.Title = title
+			}
+		default:
+			fmt.Fprintf(r.out, "Unknown answer %q\n", answer)
+		}
+	}
+}
+
+// show pages through the text, or prints it if the pager can't run
+func (r *pageReviewer) show(text string) {
+	args := strings.Fields(r.pager)
+	if len(args) > 0 {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err == nil {
+			return
+		}
+	}
+	fmt.Fprintln(r.out, text)
+}
+
+// readLine reads an answer, trimmed of surrounding white space
+func (r *pageReviewer) readLine() (string, error) {
+	line, err := r.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
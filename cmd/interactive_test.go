@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func TestReview(t *testing.T) {
+	tests := map[string]struct {
+		answers          string
+		expectedDecision reviewDecision
+		expectedTitle    string
+		expectedOutput   []string
+	}{
+		"Create": {
+			answers:          "c\n",
+			expectedDecision: reviewCreate,
+			expectedTitle:    "Page",
+			expectedOutput:   []string{"# Page", "[2/5] Page: [c]reate"},
+		},
+		"Skip": {
+			answers:          "skip\n",
+			expectedDecision: reviewSkip,
+			expectedTitle:    "Page",
+		},
+		"Unknown answer asks again": {
+			answers:          "x\nq\n",
+			expectedDecision: reviewQuit,
+			expectedTitle:    "Page",
+			expectedOutput:   []string{`Unknown answer "x"`},
+		},
+		"Edit title": {
+			answers:          "e\nNew Page\nc\n",
+			expectedDecision: reviewCreate,
+			expectedTitle:    "New Page",
+			expectedOutput:   []string{"New title: ", "[2/5] New Page: [c]reate"},
+		},
+		"Empty title keeps the title": {
+			answers:          "e\n\nc\n",
+			expectedDecision: reviewCreate,
+			expectedTitle:    "Page",
+		},
+		"View again": {
+			answers:          "v\nc\n",
+			expectedDecision: reviewCreate,
+			expectedTitle:    "Page",
+			expectedOutput:   []string{"# Page\n\nbody\n\n[2/5] Page: [c]reate, [s]kip, [e]dit title, [v]iew again or [q]uit? # Page"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out strings.Builder
+			r := &pageReviewer{in: bufio.NewReader(strings.NewReader(tt.answers)), out: &out}
+			doc := &models.Document{Title: "Page", Blocks: []models.Block{
+				{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineText, Text: "body"}}},
+			}}
+
+			decision, err := r.review(doc, 2, 5)
+			if err != nil {
+				t.Fatalf("review() error = %v", err)
+			}
+			if decision != tt.expectedDecision {
+				t.Errorf("Expected decision %d, got %d", tt.expectedDecision, decision)
+			}
+			if doc.Title != tt.expectedTitle {
+				t.Errorf("Expected title '%s', got '%s'", tt.expectedTitle, doc.Title)
+			}
+			for _, expected := range tt.expectedOutput {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("Expected output to contain %q, got %q", expected, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestReviewEndOfInput(t *testing.T) {
+	var out strings.Builder
+	r := &pageReviewer{in: bufio.NewReader(strings.NewReader("")), out: &out}
+	decision, err := r.review(&models.Document{Title: "Page"}, 1, 1)
+	if err == nil {
+		t.Error("Expected error at the end of input, got nil")
+	}
+	if decision != reviewQuit {
+		t.Errorf("Expected to quit at the end of input, got %d", decision)
+	}
+}
+
+func TestStateTitleMapping(t *testing.T) {
+	state := &syncState{Pages: map[string]syncedPage{
+		"Page":  {Hash: "a", Title: "Edited"},
+		"Other": {Hash: "b"},
+	}}
+	mappings := state.titleMapping()
+	if len(mappings) != 1 || mappings["Page"].Title != "Edited" {
+		t.Errorf("Expected only the edited title mapped, got %v", mappings)
+	}
+
+	var none *syncState
+	if mappings := none.titleMapping(); len(mappings) != 0 {
+		t.Errorf("Expected no mapping without a state, got %v", mappings)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// addLogFormatFlag defines the flag choosing the format of the logs on a flag set
+func addLogFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("log-format", "", "Format of the logs: text or json (defaults to LOG_FORMAT, or text)")
+}
+
+// initLogger initializes the logger with the level of LOG_LEVEL and the format,
+// or the format of LOG_FORMAT if it is empty. Every entry is tagged with a new
+// run ID, which is returned so the run can be told apart in collected logs.
+func initLogger(format string) (string, error) {
+	logLevel := os.Getenv("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	if err := logger.Init(logLevel); err != nil {
+		return "", err
+	}
+
+	if format == "" {
+		format = os.Getenv("LOG_FORMAT")
+	}
+	if err := logger.SetFormat(format); err != nil {
+		return "", err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	runID := hex.EncodeToString(id)
+	logger.SetField("run_id", runID)
+	return runID, nil
+}
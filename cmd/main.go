@@ -1,112 +1,64 @@
 package main
 
 import (
-	"context"
-	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-
-	"github.com/joho/godotenv"
-	"github.com/takak2166/scrapbox2notion/internal/logger"
-	"github.com/takak2166/scrapbox2notion/internal/notion"
-	"github.com/takak2166/scrapbox2notion/internal/parser"
 )
 
 func main() {
-	// Parse command line flags
-	inputFile := flag.String("input", "", "Path to Scrapbox JSON export file")
-	outputDir := flag.String("output", "", "Directory to save markdown files (optional)")
-	flag.Parse()
-
-	if *inputFile == "" {
-		fmt.Println("Error: input file is required")
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		fmt.Printf("Error loading .env file: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Initialize logger
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logLevel = "info"
-	}
-	if err := logger.Init(logLevel); err != nil {
-		fmt.Printf("Error initializing logger: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Get output directory from environment if not specified
-	if *outputDir == "" {
-		*outputDir = os.Getenv("OUTPUT_DIR")
-		if *outputDir == "" {
-			*outputDir = "output"
-		}
-	}
-
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(*outputDir, 0755); err != nil {
-		logger.Error("Failed to create output directory", err, nil)
-		os.Exit(1)
-	}
-
-	// Initialize parser
-	p := parser.New()
-
-	// Parse Scrapbox JSON file
-	if err := p.ParseFile(*inputFile); err != nil {
-		logger.Error("Failed to parse input file", err, nil)
-		os.Exit(1)
-	}
-
-	// Initialize Notion client
-	notionClient, err := notion.New()
-	if err != nil {
-		logger.Error("Failed to initialize Notion client", err, nil)
-		os.Exit(1)
-	}
-
-	// Process each page
-	pages := p.GetPages()
-	logger.Info(fmt.Sprintf("Found %d pages to process", len(pages)), nil)
-
-	ctx := context.Background()
-	successCount := 0
-
-	for _, page := range pages {
-		// Convert to markdown
-		markdown := p.ConvertToMarkdown(&page)
-
-		// Save markdown file
-		mdFilePath := filepath.Join(*outputDir, page.Title+".md")
-		if err := os.WriteFile(mdFilePath, []byte(markdown), 0644); err != nil {
-			logger.Error("Failed to save markdown file", err, map[string]interface{}{
-				"page":     page.Title,
-				"filepath": mdFilePath,
-			})
-			continue
-		}
-
-		// Upload to Notion with tags
-		if err := notionClient.CreatePage(ctx, page.Title, markdown, page.Tags); err != nil {
-			logger.Error("Failed to create Notion page", err, map[string]interface{}{
-				"page": page.Title,
-			})
-			continue
+	// Dispatch subcommands, falling back to the migration for plain flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "graph":
+			os.Exit(runGraph(os.Args[2:]))
+		case "tags":
+			os.Exit(runTags(os.Args[2:]))
+		case "rollback":
+			os.Exit(runRollback(os.Args[2:]))
+		case "dedupe":
+			os.Exit(runDedupe(os.Args[2:]))
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "sync":
+			os.Exit(runSync(os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		case "batch":
+			os.Exit(runBatch(os.Args[2:]))
+		case "completion":
+			os.Exit(runCompletion(os.Args[2:]))
+		case "version", "-version", "--version":
+			os.Exit(runVersion())
+		case "help", "-h", "-help", "--help":
+			printUsage()
+			return
 		}
-
-		successCount++
 	}
+	runMigrate(os.Args[1:])
+}
 
-	logger.Info("Migration completed", map[string]interface{}{
-		"total_pages":     len(pages),
-		"success_count":   successCount,
-		"failure_count":   len(pages) - successCount,
-		"markdown_output": *outputDir,
-	})
+// printUsage prints the available subcommands
+func printUsage() {
+	fmt.Println(`Usage: scrapbox2notion [command] [flags]
+
+Commands:
+  migrate     Convert the export to markdown and upload it to Notion (default)
+  validate    Check the export for problems before migrating
+  graph       Write the page link graph as Graphviz DOT or JSON
+  tags        Print every tag with its page count and the tags used with it
+  rollback    Archive the Notion pages and databases created by a migration
+  dedupe      Archive duplicate pages in the Notion databases, keeping the newest
+  verify      Compare the migrated Notion pages with the export
+  sync        Push the pages of a Scrapbox project updated since the last sync to Notion
+  serve       Serve migrations to Notion over HTTP, streaming their progress
+  batch       Migrate every project of a manifest, each under its own Notion parent
+  completion  Print the shell completion script of bash, zsh or fish
+  version     Print the version and build information
+
+Run "scrapbox2notion <command> -h" for the flags of each command.`)
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+)
+
+// runMetrics are the counters and durations of a migration, written for
+// scheduled runs to be monitored
+type runMetrics struct {
+	RunID           string  `json:"run_id,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	PagesProcessed  int     `json:"pages_processed"`
+	PagesFailed     int     `json:"pages_failed"`
+	LineWarnings    int     `json:"line_warnings"`
+	// UploadSecondsTotal and UploadSecondsMax are the time spent uploading pages
+	// to Notion, in total and on the slowest page
+	UploadSecondsTotal float64 `json:"upload_seconds_total"`
+	UploadSecondsMax   float64 `json:"upload_seconds_max"`
+	APICalls           int     `json:"api_calls"`
+	APIRetries         int     `json:"api_retries"`
+	APIFailures        int     `json:"api_failures"`
+	APIDurationSeconds float64 `json:"api_duration_seconds"`
+}
+
+// recordUpload adds the time spent uploading a page
+func (m *runMetrics) recordUpload(d time.Duration) {
+	m.UploadSecondsTotal += d.Seconds()
+	m.UploadSecondsMax = max(m.UploadSecondsMax, d.Seconds())
+}
+
+// recordAPIStats sets the counts of the Notion API requests
+func (m *runMetrics) recordAPIStats(stats notion.APIStats) {
+	m.APICalls = stats.Calls
+	m.APIRetries = stats.Retries
+	m.APIFailures = stats.Failures
+	m.APIDurationSeconds = stats.Duration.Seconds()
+}
+
+// writeMetrics writes run metrics as JSON
+func writeMetrics(path string, metrics *runMetrics) error {
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,873 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/takak2166/scrapbox2notion/internal/graph"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+	"github.com/takak2166/scrapbox2notion/internal/pathsafe"
+	"github.com/takak2166/scrapbox2notion/internal/tracing"
+)
+
+// runMigrate converts the Scrapbox export to markdown and uploads it to Notion
+func runMigrate(args []string) {
+	// Parse command line flags
+	fs := flag.NewFlagSet("scrapbox2notion", flag.ExitOnError)
+	var inputPatterns stringList
+	fs.Var(&inputPatterns, "input", "Path or glob pattern of Scrapbox JSON export files, repeatable (- to read from stdin)")
+	outputDir := fs.String("output", "", "Directory to save markdown files (optional)")
+	project := fs.String("project", "", "Name of the Scrapbox project in its URLs, overriding the name of the export, for source URLs, links to the project written as [/project/page], the report and the default output directory (optional)")
+	layout := fs.String("layout", "flat", "Folder layout of markdown files: flat, or tags to write pages into a folder per tag")
+	tagCopies := fs.String("tag-copies", "primary", "How the tags layout writes pages with several tags: primary, duplicate or symlink")
+	outputArchive := fs.String("output-archive", "", "Zip archive to write the output files into instead of the output directory (optional)")
+	format := fs.String("format", "markdown", "Format of the files written to the output directory: markdown, obsidian, hugo, html, logseq or org")
+	skipNotion := fs.Bool("skip-notion", false, "Only write markdown files, do not upload to Notion")
+	skipMarkdown := fs.Bool("skip-markdown", false, "Only upload to Notion, do not write markdown files")
+	maxNameBytes := fs.Int("max-name-bytes", pathsafe.MaxNameBytes, "Longest output file name in bytes, longer titles are shortened and given a hash to tell them apart (at most 255)")
+	skipUnchanged := fs.Bool("skip-unchanged", false, "Leave output files that already hold the same content untouched, for cheap re-runs into the same directory")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of pages converted and written at the same time (-interactive takes pages one at a time)")
+	pageIcon := fs.Bool("icon", false, "Set the Notion page icon to the first emoji in the title")
+	defaultIcon := fs.String("default-icon", "", "Emoji to use as the page icon when the title has none (implies -icon)")
+	pageCover := fs.Bool("cover", false, "Set the Notion page cover to the first image in the page")
+	pageMetrics := fs.Bool("metrics", false, "Fill the Views and Backlinks number properties of Notion pages with their Scrapbox views and the count of pages linking to them")
+	pageSummary := fs.Bool("summary", false, "Fill the Summary text property of Notion pages with the description Scrapbox shows for them, from exports with metadata")
+	sourceURL := fs.Bool("source-url", false, "Fill the Scrapbox URL property of Notion pages and the source of the front matter of markdown files with the URL of the page on Scrapbox")
+	interactive := fs.Bool("interactive", false, "Show each page as markdown and ask whether to create, skip or retitle it before migrating it")
+	notionIndex := fs.Bool("notion-index", false, "Create an Index page in Notion listing every migrated page grouped by tag")
+	order := fs.String("order", "none", "Order to process pages in, so they show in that order in Notion views sorted by creation: created, updated, title, views, pinned or none (export order)")
+	hierarchy := fs.Bool("hierarchy", false, "Create the pages linked from hub pages as child pages of the hub in Notion, mirroring the Scrapbox link graph, instead of under the parent or in tag databases")
+	hubMinLinks := fs.Int("hub-min-links", 10, "Number of pages of the input a page must link to to be a hub with -hierarchy (0 for only the pages of -hub)")
+	var hubs stringList
+	fs.Var(&hubs, "hub", "Title of a page that is a hub with -hierarchy however many pages it links to, repeatable")
+	limit := fs.Int("limit", 0, "Only process this many pages, to try the settings on a few pages first (0 processes every page)")
+	offset := fs.Int("offset", 0, "Skip this many pages before processing, such as to try the settings on another slice with -limit")
+	retries := fs.Int("retries", 1, "Passes retrying the pages that failed to upload to Notion once every page was tried, as failures such as rate limits may be transient (0 disables)")
+	retryDelay := fs.Duration("retry-delay", 30*time.Second, "How long to wait before the first retry pass, doubling for each next pass")
+	pageTimeout := fs.Duration("page-timeout", 5*time.Minute, "Give up uploading a page to Notion after this long, so a hung request doesn't stall the run (0 for no limit)")
+	maxFailures := fs.Int("max-failures", 10, "Stop uploading after this many pages in a row fail with Notion authentication, permission or server errors, as every later page would too (0 never stops)")
+	conversion := addConversionFlags(fs)
+	usersFile := fs.String("users", "", "JSON file mapping Scrapbox user IDs to Notion user emails or IDs, to fill the Created by property (optional)")
+	reportFile := fs.String("report", "", "Write a JSON report of the run, including the Notion objects created, for rollback (optional)")
+	stateFile := fs.String("state", "", "File recording a content hash of each page uploaded to Notion; later runs skip unchanged pages and update changed ones (optional)")
+	metricsFile := fs.String("metrics-file", "", "Write JSON metrics of the run, such as page and Notion API request counts and durations, for monitoring (optional)")
+	logFormat := addLogFormatFlag(fs)
+	fs.Parse(args)
+
+	if len(inputPatterns) == 0 {
+		fmt.Println("Error: input file is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	pageOrder, err := parser.ParsePageOrder(*order)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *maxNameBytes < 32 || *maxNameBytes > pathsafe.MaxNameBytes {
+		fmt.Printf("Error: -max-name-bytes must be between 32 and %d\n", pathsafe.MaxNameBytes)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *hubMinLinks < 0 {
+		fmt.Println("Error: -hub-min-links must not be negative")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *limit < 0 || *offset < 0 {
+		fmt.Println("Error: -limit and -offset must not be negative")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *maxFailures < 0 || *pageTimeout < 0 {
+		fmt.Println("Error: -max-failures and -page-timeout must not be negative")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *retries < 0 || *retryDelay < 0 {
+		fmt.Println("Error: -retries and -retry-delay must not be negative")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	inputFiles, err := expandInputs(inputPatterns)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *workers < 1 {
+		fmt.Println("Error: -workers must be at least 1")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *interactive && slices.Contains(inputFiles, "-") {
+		fmt.Println("Error: -interactive reads answers from standard input, which cannot also be the input")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	parserOpts, err := conversion.parserOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *sourceURL {
+		parserOpts = append(parserOpts, parser.WithSourceURLs())
+	}
+	if *project != "" {
+		parserOpts = append(parserOpts, parser.WithProject(*project))
+	}
+
+	notionOpts, err := conversion.notionOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *skipNotion && *skipMarkdown {
+		fmt.Println("Error: -skip-notion and -skip-markdown cannot be used together")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Load .env file (optional when Notion credentials are not needed)
+	if err := godotenv.Load(); err != nil && !*skipNotion {
+		fmt.Printf("Error loading .env file: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize logger
+	runID, err := initLogger(*logFormat)
+	if err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Read the hashes of the pages uploaded by earlier runs
+	var state *syncState
+	if *stateFile != "" && !*skipNotion {
+		state, err = readState(*stateFile, "")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Trace the run when an OpenTelemetry collector is configured
+	tracing.InitFromEnv()
+	// A run started by batch continues its trace
+	ctx, runSpan := tracing.Start(tracing.WithRemoteParent(context.Background(), os.Getenv("TRACEPARENT")), "migrate", tracing.KindInternal, map[string]string{
+		"run.id": runID,
+	})
+
+	// Initialize parser
+	// Titles edited in the review of earlier runs are kept
+	p := parser.New(append(parserOpts, parser.WithTitleMapping(state.titleMapping()))...)
+
+	// Parse Scrapbox JSON files, or standard input when the path is "-"
+	for _, inputFile := range inputFiles {
+		_, parseSpan := tracing.Start(ctx, "parse", tracing.KindInternal, map[string]string{
+			"input": inputFile,
+		})
+		var parseErr error
+		if inputFile == "-" {
+			parseErr = p.Parse(os.Stdin)
+		} else {
+			parseErr = p.ParseFile(inputFile)
+		}
+		parseSpan.End(parseErr)
+		if parseErr != nil {
+			logger.Error("Failed to parse input file", parseErr, map[string]interface{}{
+				"input": inputFile,
+			})
+			os.Exit(1)
+		}
+	}
+	conversion.pageExists = p.HasPage
+
+	// Write into a temporary directory that is zipped at the end when archiving
+	if *outputArchive != "" && !*skipMarkdown {
+		tmpDir, err := os.MkdirTemp("", "scrapbox2notion-")
+		if err != nil {
+			fmt.Printf("Error creating temporary directory: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(tmpDir)
+		*outputDir = tmpDir
+	}
+
+	// Get output directory from environment if not specified, keeping the
+	// output of each project apart
+	if *outputDir == "" {
+		*outputDir = os.Getenv("OUTPUT_DIR")
+		if *outputDir == "" {
+			*outputDir = projectOutputDir(*project, p)
+		}
+	}
+
+	// Create output directory if it doesn't exist
+	var writer pageWriter
+	if !*skipMarkdown {
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			logger.Error("Failed to create output directory", err, nil)
+			os.Exit(1)
+		}
+		l, err := parseLayout(*layout, *tagCopies)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fs.Usage()
+			os.Exit(1)
+		}
+		w, err := newPageWriter(*format, *outputDir, l, *skipUnchanged, *maxNameBytes)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fs.Usage()
+			os.Exit(1)
+		}
+		writer = w
+	}
+
+	// Find the hub each page linked from a hub goes under
+	var hubPages map[string]string
+	if *hierarchy && !*skipNotion {
+		all := p.GetPages()
+		docs := make([]*models.Document, 0, len(all))
+		for i := range all {
+			docs = append(docs, p.ParseDocument(&all[i]))
+		}
+		hubPages = graph.Build(docs).Hierarchy(graph.HierarchyRules{MinLinks: *hubMinLinks, Hubs: hubs})
+		logger.Info(fmt.Sprintf("Found %d pages to create under hub pages", len(hubPages)), nil)
+	}
+
+	// Initialize Notion client
+	var notionClient *notion.Client
+	notionUnavailable := false
+	if !*skipNotion {
+		opts := notionOpts
+		if *pageIcon || *defaultIcon != "" {
+			opts = append(opts, notion.WithPageIcon(*defaultIcon))
+		}
+		if *pageCover {
+			opts = append(opts, notion.WithPageCover())
+		}
+		if *pageMetrics {
+			opts = append(opts, notion.WithPageMetrics())
+		}
+		if *pageSummary {
+			opts = append(opts, notion.WithPageSummary())
+		}
+		if *sourceURL {
+			opts = append(opts, notion.WithSourceURL())
+		}
+		if hubPages != nil {
+			opts = append(opts, notion.WithHierarchy(hubPages))
+		}
+		if *usersFile != "" {
+			users, err := notion.LoadUserMapping(*usersFile)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts = append(opts, notion.WithUserMapping(users))
+		}
+
+		c, err := notion.New(opts...)
+		if err != nil {
+			logger.Error("Failed to initialize Notion client", err, nil)
+			os.Exit(1)
+		}
+		// Fail early on a parent the integration can't use, before processing any
+		// page, but still write the output as it doesn't need Notion
+		if err := c.Preflight(ctx); err != nil {
+			logger.Error("Failed to access Notion parent", err, nil)
+			if writer == nil {
+				os.Exit(1)
+			}
+			logger.Warn("Writing the output without uploading to Notion", nil)
+			notionUnavailable = true
+		} else {
+			notionClient = c
+		}
+	}
+
+	// Process each page
+	pages := parser.SortPages(p.GetPages(), pageOrder)
+	if hubPages != nil {
+		// Hubs are created before the pages under them
+		slices.SortStableFunc(pages, func(a, b models.Page) int {
+			return cmp.Compare(graph.Depth(hubPages, a.Title), graph.Depth(hubPages, b.Title))
+		})
+	}
+	logger.Info(fmt.Sprintf("Found %d pages to process", len(pages)), nil)
+	if *offset > 0 || *limit > 0 {
+		found := len(pages)
+		pages = pages[min(*offset, found):]
+		if *limit > 0 {
+			pages = pages[:min(*limit, len(pages))]
+		}
+		logger.Info(fmt.Sprintf("Processing %d of %d pages", len(pages), found), map[string]interface{}{
+			"offset": *offset,
+			"limit":  *limit,
+		})
+	}
+
+	started := time.Now()
+	successCount := 0
+	unchangedCount := 0
+	existingCount := 0
+	renamedCount := 0
+	skippedCount := 0
+	warningCount := 0
+	var warnedPages []string
+	var migrated []*models.Document
+	metrics := &runMetrics{RunID: runID}
+	var timings []*pageTiming
+
+	// uploadPage uploads a written page to Notion with its tags, skipping pages
+	// unchanged since the last run
+	uploadPage := func(item *convertedPage) error {
+		var hash string
+		if state != nil {
+			hash = item.doc.Hash()
+		}
+		if state.unchanged(item.page.Title, hash) {
+			item.log.Debug("Skipping page unchanged since the last run", nil)
+			unchangedCount++
+			// States written before the Scrapbox IDs and tags of pages were
+			// recorded learn them here, to find the pages once renamed
+			synced := state.Pages[item.page.Title]
+			learned := false
+			if synced.ID == "" && item.page.ID != "" {
+				synced.ID, learned = item.page.ID, true
+			}
+			if synced.Tags == nil && len(item.doc.Tags) > 0 {
+				synced.Tags, learned = item.doc.Tags, true
+			}
+			if learned {
+				state.Pages[item.page.Title] = synced
+				if err := writeState(*stateFile, state); err != nil {
+					item.log.Error("Failed to write page hashes", err, map[string]interface{}{
+						"state": *stateFile,
+					})
+				}
+			}
+			return nil
+		}
+
+		uploadCtx, uploadSpan := tracing.Start(item.ctx, "upload", tracing.KindInternal, nil)
+		if *pageTimeout > 0 {
+			var cancel context.CancelFunc
+			uploadCtx, cancel = context.WithTimeout(uploadCtx, *pageTimeout)
+			defer cancel()
+		}
+		uploadStarted := time.Now()
+		var err error
+		oldTitle, renamed := state.renamedFrom(item.page.ID, item.page.Title)
+		if renamed {
+			// Keep the pages of a page renamed on Scrapbox instead of creating
+			// them again under the new title. The pages are in the databases
+			// of the tags they were pushed with.
+			err = notionClient.RenamePage(uploadCtx, oldTitle, item.doc, state.Pages[oldTitle].Tags)
+		}
+		if item.editedFrom != "" && state.pushed(item.page.Title) {
+			// Rename the pages pushed before under the title edited in the
+			// review, instead of creating them again under the new title
+			err = notionClient.RenamePage(uploadCtx, item.editedFrom, item.doc, state.Pages[item.page.Title].Tags)
+		}
+		if err == nil && (renamed || state.pushed(item.page.Title)) {
+			// Replace the content of the pages uploaded with an earlier version
+			err = notionClient.UpdatePage(uploadCtx, item.doc, item.doc.Tags)
+		} else if err == nil {
+			err = notionClient.CreatePage(uploadCtx, item.doc, item.doc.Tags)
+		}
+		metrics.recordUpload(time.Since(uploadStarted))
+		item.timing.UploadSeconds += time.Since(uploadStarted).Seconds()
+		if errors.Is(err, notion.ErrAlreadyExists) {
+			// Pages migrated by an earlier run are left as they are
+			existingCount++
+			err = nil
+		}
+		uploadSpan.End(err)
+		if err != nil {
+			return err
+		}
+
+		if renamed {
+			item.log.Info("Renamed Notion page of a page renamed on Scrapbox", map[string]interface{}{
+				"old_title": oldTitle,
+			})
+			renamedCount++
+		}
+		if state != nil {
+			// The title edited in the review, now or in an earlier run, is
+			// recorded for later runs to keep
+			editedTitle := state.Pages[item.page.Title].Title
+			if item.editedFrom != "" {
+				editedTitle = item.doc.Title
+			}
+			if renamed {
+				delete(state.Pages, oldTitle)
+			}
+			state.Pages[item.page.Title] = syncedPage{Updated: item.page.Updated, Hash: hash, ID: item.page.ID, Tags: item.doc.Tags, Title: editedTitle}
+			if err := writeState(*stateFile, state); err != nil {
+				item.log.Error("Failed to write page hashes", err, map[string]interface{}{
+					"state": *stateFile,
+				})
+			}
+		}
+		return nil
+	}
+
+	var failedPages, timedOutPages []string
+	// finishPage ends the processing of a page once it is uploaded or has
+	// failed for the last time
+	finishPage := func(item *convertedPage, err error) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			item.log.Error("Timed out creating Notion page", err, map[string]interface{}{
+				"timeout": pageTimeout.String(),
+			})
+			item.span.End(err)
+			timedOutPages = append(timedOutPages, item.page.Title)
+			return
+		}
+		if err != nil {
+			var fields map[string]interface{}
+			if hint := uploadFailureHint(err); hint != "" {
+				fields = map[string]interface{}{"hint": hint}
+			}
+			item.log.Error("Failed to create Notion page", err, fields)
+			item.span.End(err)
+			failedPages = append(failedPages, item.page.Title)
+			return
+		}
+		item.span.End(nil)
+		successCount++
+		migrated = append(migrated, &models.Document{Title: item.doc.Title, Tags: item.doc.Tags})
+	}
+
+	// Pages are converted and written in one stage and uploaded in another, so
+	// the output is written in full however slow or broken uploads are. The
+	// channel holds every page so writing never waits for uploads.
+	written := make(chan *convertedPage, len(pages))
+	uploaded := make(chan struct{})
+	// pending counts the pages handed over to uploads and not uploaded yet
+	var pending sync.WaitGroup
+	var failed []*convertedPage
+	// Uploads stop once maxFailures pages in a row fail with hard failures,
+	// leaving the rest of the pages not uploaded
+	var consecutiveFailures, notUploaded int
+	var abortErr error
+	go func() {
+		defer close(uploaded)
+		for item := range written {
+			if notionClient != nil {
+				if abortErr != nil {
+					item.span.End(abortErr)
+					notUploaded++
+					pending.Done()
+					continue
+				}
+				err := uploadPage(item)
+				consecutiveFailures++
+				if err == nil || !notion.HardFailure(err) {
+					consecutiveFailures = 0
+				}
+				if *maxFailures > 0 && consecutiveFailures >= *maxFailures {
+					abortErr = err
+					logger.Error(fmt.Sprintf("Stopping uploads after %d pages in a row failed, fix the error and run again", consecutiveFailures), err, nil)
+				}
+				if err != nil {
+					item.err = err
+					// Notion rejects a page too large however often it is sent
+					if *retries > 0 && !errors.Is(err, notion.ErrPayloadTooLarge) {
+						item.log.Warn("Failed to create Notion page, retrying once every page was tried", map[string]interface{}{
+							"error": err.Error(),
+						})
+						failed = append(failed, item)
+						pending.Done()
+						continue
+					}
+					finishPage(item, err)
+					pending.Done()
+					continue
+				}
+			}
+			finishPage(item, nil)
+			pending.Done()
+		}
+	}()
+
+	// convertPage converts the page to the intermediate document shared by every output
+	convertPage := func(page *models.Page) *convertedPage {
+		// Tag every entry logged while processing the page with its title
+		log := logger.With(map[string]interface{}{"page": page.Title})
+		pageCtx, pageSpan := tracing.Start(ctx, "page", tracing.KindInternal, map[string]string{
+			"page.title": page.Title,
+		})
+
+		_, convertSpan := tracing.Start(pageCtx, "convert", tracing.KindInternal, nil)
+		parseStarted := time.Now()
+		doc := p.ParseDocument(page)
+		timing := &pageTiming{Title: page.Title, ParseSeconds: time.Since(parseStarted).Seconds()}
+		convertSpan.End(nil)
+		return &convertedPage{page: page, doc: doc, ctx: pageCtx, span: pageSpan, log: log, timing: timing}
+	}
+
+	// writePage saves the page in the output format, ending the span of the
+	// page if it fails
+	writePage := func(item *convertedPage) error {
+		// Report lines that may not have converted as written so they can be fixed
+		for _, warning := range item.doc.Warnings {
+			item.log.Warn("Line may not have converted as written", map[string]interface{}{
+				"line":   warning.Line,
+				"text":   warning.Text,
+				"reason": warning.Reason,
+			})
+		}
+		if writer == nil {
+			return nil
+		}
+
+		writeStarted := time.Now()
+		path, err := writer.Write(item.doc)
+		item.timing.ConvertSeconds = time.Since(writeStarted).Seconds()
+		if err != nil {
+			item.log.Error("Failed to save output file", err, map[string]interface{}{
+				"format": *format,
+			})
+			item.span.End(err)
+			return err
+		}
+
+		// Keep the Scrapbox timestamps so tools sorting by date keep the original order
+		if err := setFileTimes(path, item.page.Created, item.page.Updated); err != nil {
+			item.log.Error("Failed to set file times", err, map[string]interface{}{
+				"filepath": path,
+			})
+		}
+		return nil
+	}
+
+	// queuePage counts the warnings of a page and hands it over to uploads
+	// unless writing it failed. Pages are queued in order, as hubs are created
+	// before the pages under them.
+	queuePage := func(item *convertedPage, writeErr error) {
+		if len(item.doc.Warnings) > 0 {
+			warningCount += len(item.doc.Warnings)
+			warnedPages = append(warnedPages, item.page.Title)
+		}
+		if writeErr != nil {
+			return
+		}
+		timings = append(timings, item.timing)
+		pending.Add(1)
+		written <- item
+	}
+
+	if *interactive {
+		// Let the user look at each page before migrating it, one page at a time
+		reviewer := newPageReviewer()
+		for i := range pages {
+			item := convertPage(&pages[i])
+			// Uploads log as they go, which would mix with the pager and the prompt
+			pending.Wait()
+			title := item.doc.Title
+			decision, err := reviewer.review(item.doc, i+1, len(pages))
+			if err != nil {
+				item.log.Error("Failed to review page", err, nil)
+				decision = reviewQuit
+			}
+			if decision == reviewQuit {
+				skippedCount += len(pages) - i
+				item.span.End(nil)
+				break
+			}
+			if decision == reviewSkip {
+				item.log.Info("Skipping page on request", nil)
+				skippedCount++
+				item.span.End(nil)
+				continue
+			}
+			if item.doc.Title != title {
+				// Links in the pages after follow the new title
+				item.editedFrom = title
+				p.RenameTitle(item.page.Title, item.doc.Title)
+			}
+			queuePage(item, writePage(item))
+		}
+	} else {
+		// Workers convert and write pages at the same time, and their results
+		// are queued in the order of the pages
+		if reserver, ok := writer.(titleReserver); ok {
+			titles := make([]string, len(pages))
+			for i := range pages {
+				titles[i] = p.DocumentTitle(pages[i].Title)
+			}
+			reserver.Reserve(titles)
+		}
+		results := make([]chan *convertedPage, len(pages))
+		for i := range results {
+			results[i] = make(chan *convertedPage, 1)
+		}
+		writeErrs := make([]error, len(pages))
+		next := make(chan int)
+		for range min(*workers, len(pages)) {
+			go func() {
+				for i := range next {
+					item := convertPage(&pages[i])
+					writeErrs[i] = writePage(item)
+					results[i] <- item
+				}
+			}()
+		}
+		go func() {
+			for i := range pages {
+				next <- i
+			}
+			close(next)
+		}()
+		for i := range results {
+			item := <-results[i]
+			queuePage(item, writeErrs[i])
+		}
+	}
+	close(written)
+
+	// Finish outputs that are written after every page, such as indexes
+	if closer, ok := writer.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("Failed to finish output", err, map[string]interface{}{
+				"format": *format,
+			})
+		}
+	}
+
+	if *outputArchive != "" && !*skipMarkdown {
+		if err := writeArchive(*outputDir, *outputArchive); err != nil {
+			logger.Error("Failed to write output archive", err, map[string]interface{}{
+				"archive": *outputArchive,
+			})
+		}
+	}
+	if writer != nil && notionClient != nil {
+		logger.Info("Finished writing output, waiting for uploads", nil)
+	}
+
+	<-uploaded
+
+	// Retry the pages that failed, after a pause for failures such as rate
+	// limits to clear
+	retried := len(failed)
+	delay := *retryDelay
+	if abortErr != nil {
+		// Retrying would only fail again
+		for _, item := range failed {
+			finishPage(item, item.err)
+		}
+		failed, retried = nil, 0
+	}
+	for pass := 1; pass <= *retries && len(failed) > 0; pass++ {
+		logger.Info("Retrying pages that failed to upload", map[string]interface{}{
+			"pages": len(failed),
+			"pass":  pass,
+			"delay": delay.String(),
+		})
+		time.Sleep(delay)
+		delay *= 2
+
+		var again []*convertedPage
+		for _, item := range failed {
+			err := uploadPage(item)
+			if err != nil && pass < *retries {
+				item.log.Warn("Failed to create Notion page again", map[string]interface{}{
+					"error": err.Error(),
+					"pass":  pass,
+				})
+				again = append(again, item)
+				continue
+			}
+			finishPage(item, err)
+		}
+		failed = again
+	}
+	failureCount := len(pages) - successCount - skippedCount
+
+	// Create the Notion index page linking to the migrated pages
+	if notionClient != nil && *notionIndex {
+		if err := notionClient.CreateIndexPage(ctx, "Index", models.GroupByTag(migrated)); err != nil {
+			logger.Error("Failed to create Notion index page", err, nil)
+		}
+	}
+
+	summary := map[string]interface{}{
+		"total_pages":   len(pages),
+		"success_count": successCount,
+		"failure_count": failureCount,
+		"warning_count": warningCount,
+		"notion_upload": notionClient != nil,
+	}
+	if name := p.Project(); name != "" {
+		summary["project"] = name
+	}
+	if state != nil {
+		summary["unchanged_count"] = unchangedCount
+	}
+	if renamedCount > 0 {
+		summary["renamed_count"] = renamedCount
+	}
+	if *interactive {
+		summary["skipped_count"] = skippedCount
+	}
+	if existingCount > 0 {
+		summary["existing_count"] = existingCount
+	}
+	if *outputArchive != "" && !*skipMarkdown {
+		summary["markdown_output"] = *outputArchive
+	} else if !*skipMarkdown {
+		summary["markdown_output"] = *outputDir
+	}
+	if len(warnedPages) > 0 {
+		summary["pages_with_warnings"] = warnedPages
+	}
+	if retried > 0 {
+		summary["retried_count"] = retried
+	}
+	if len(failedPages) > 0 {
+		summary["failed_pages"] = failedPages
+	}
+	if len(timedOutPages) > 0 {
+		summary["timed_out_pages"] = timedOutPages
+	}
+	if abortErr != nil {
+		summary["aborted"] = true
+		summary["abort_error"] = abortErr.Error()
+		summary["not_uploaded_count"] = notUploaded
+	}
+
+	if *reportFile != "" {
+		report := &runReport{
+			RunID:         runID,
+			Project:       p.Project(),
+			DisplayName:   p.DisplayName(),
+			Started:       started,
+			Finished:      time.Now(),
+			TotalPages:    len(pages),
+			SuccessCount:  successCount,
+			FailureCount:  failureCount,
+			WarningCount:  warningCount,
+			FailedPages:   failedPages,
+			TimedOutPages: timedOutPages,
+			PageTimings:   timings,
+			Percentiles:   timingPercentiles(timings),
+		}
+		if notionClient != nil {
+			report.Created = notionClient.Created()
+		}
+		// Reports of several projects can be kept apart by naming them after the project
+		reportPath := strings.ReplaceAll(*reportFile, "{project}", cmp.Or(projectFileName(*project, p), "export"))
+		if err := writeReport(reportPath, report); err != nil {
+			logger.Error("Failed to write run report", err, map[string]interface{}{
+				"report": reportPath,
+			})
+		} else {
+			summary["report"] = reportPath
+		}
+	}
+	if *metricsFile != "" {
+		metrics.DurationSeconds = time.Since(started).Seconds()
+		metrics.PagesProcessed = successCount
+		metrics.PagesFailed = failureCount
+		metrics.LineWarnings = warningCount
+		if notionClient != nil {
+			metrics.recordAPIStats(notionClient.APIStats())
+		}
+		if err := writeMetrics(*metricsFile, metrics); err != nil {
+			logger.Error("Failed to write run metrics", err, map[string]interface{}{
+				"metrics": *metricsFile,
+			})
+		} else {
+			summary["metrics"] = *metricsFile
+		}
+	}
+	logger.Info("Migration completed", summary)
+
+	runSpan.End(nil)
+	if err := tracing.Shutdown(ctx); err != nil {
+		logger.Error("Failed to export traces", err, nil)
+	}
+	if notionUnavailable || abortErr != nil {
+		os.Exit(1)
+	}
+}
+
+// convertedPage is a page passed from the stage converting and writing pages to
+// the stage uploading them
+type convertedPage struct {
+	page *models.Page
+	doc  *models.Document
+	// ctx holds the span of the page, ended once the page is uploaded
+	ctx  context.Context
+	span *tracing.Span
+	log  *logger.Logger
+	// err is the error of the last failed upload of the page
+	err error
+	// timing is the time spent on the page so far
+	timing *pageTiming
+	// editedFrom is the title of the document before it was edited in the
+	// review of -interactive, empty if it wasn't
+	editedFrom string
+}
+
+// projectOutputDir returns the default output directory, a directory under
+// output named after the project, or output itself when the project is unknown
+func projectOutputDir(project string, p *parser.Parser) string {
+	if name := projectFileName(project, p); name != "" {
+		return filepath.Join("output", name)
+	}
+	return "output"
+}
+
+// projectFileName returns the name of the project set by -project, or else the
+// display name or the name of the export, made safe as a file name. It returns
+// an empty string when the project is unknown.
+func projectFileName(project string, p *parser.Parser) string {
+	for _, name := range []string{project, p.DisplayName(), p.Project()} {
+		if strings.TrimSpace(name) != "" {
+			return pathsafe.Name(name, "")
+		}
+	}
+	return ""
+}
+
+// uploadFailureHint suggests how to fix the failure of an upload to Notion, or
+// returns an empty string when there is nothing to suggest
+func uploadFailureHint(err error) string {
+	switch {
+	case errors.Is(err, notion.ErrUnauthorized):
+		return "check NOTION_API_KEY and that the parent page is shared with the integration"
+	case errors.Is(err, notion.ErrRateLimited):
+		return "lower NOTION_RATE_LIMIT to send fewer requests per second"
+	case errors.Is(err, notion.ErrPayloadTooLarge):
+		return "the page is too large for Notion, split it in Scrapbox"
+	}
+	return ""
+}
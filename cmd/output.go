@@ -0,0 +1,277 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/pathsafe"
+	"github.com/takak2166/scrapbox2notion/internal/render/html"
+	"github.com/takak2166/scrapbox2notion/internal/render/hugo"
+	"github.com/takak2166/scrapbox2notion/internal/render/logseq"
+	"github.com/takak2166/scrapbox2notion/internal/render/markdown"
+	"github.com/takak2166/scrapbox2notion/internal/render/obsidian"
+	"github.com/takak2166/scrapbox2notion/internal/render/org"
+)
+
+// pageWriter writes converted pages to the output directory. Pages are written
+// at the same time by the workers of migrate.
+// Writers that also implement io.Closer are closed after the last page.
+type pageWriter interface {
+	// Write writes the document and returns the path of the written file
+	Write(doc *models.Document) (string, error)
+}
+
+// titleReserver is implemented by writers that name the files of titles in the
+// order they are written, so the names can be given in page order before pages
+// are written at the same time
+type titleReserver interface {
+	// Reserve gives the titles their file names in order
+	Reserve(titles []string)
+}
+
+// Ways of writing pages with several tags in the tags layout
+const (
+	// tagCopiesPrimary writes the page into the folder of its first tag only
+	tagCopiesPrimary = "primary"
+	// tagCopiesDuplicate writes a copy of the page into the folder of every tag
+	tagCopiesDuplicate = "duplicate"
+	// tagCopiesSymlink writes the page into the folder of its first tag and links to it from the others
+	tagCopiesSymlink = "symlink"
+)
+
+// markdownLayout decides which folders markdown files are written to
+type markdownLayout struct {
+	// byTag writes pages into a folder named after their tag, mirroring the Notion tag databases
+	byTag bool
+	// tagCopies decides how pages with several tags are written when byTag is set
+	tagCopies string
+}
+
+// markdownWriter writes each page as a markdown file named after its title,
+// and an index.md listing every page by tag when closed
+type markdownWriter struct {
+	dir    string
+	layout markdownLayout
+	// mu guards pages and primary
+	mu sync.Mutex
+	// pages holds the title and tags of every page written
+	pages []*models.Document
+	// primary maps titles to the folder their file was written to first
+	primary map[string]string
+	// fileOpts are the options markdown files and the index are written with
+	fileOpts []atomicfile.Option
+	// names shortens the file and folder names of long titles and tags
+	names pathsafe.Namer
+}
+
+func (w *markdownWriter) Write(doc *models.Document) (string, error) {
+	content := []byte(markdown.Render(doc))
+	folders := w.folders(doc.Tags)
+	name := w.names.Name(doc.Title, ".md")
+
+	path := filepath.Join(w.dir, folders[0], name)
+	for i, folder := range folders {
+		file := filepath.Join(w.dir, folder, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return "", fmt.Errorf("failed to create tag folder: %w", err)
+		}
+
+		if i > 0 && w.layout.tagCopies == tagCopiesSymlink {
+			target, err := filepath.Rel(filepath.Dir(file), path)
+			if err != nil {
+				return "", fmt.Errorf("failed to link markdown file: %w", err)
+			}
+			os.Remove(file)
+			if err := os.Symlink(target, file); err != nil {
+				return "", fmt.Errorf("failed to link markdown file: %w", err)
+			}
+			continue
+		}
+
+		if err := atomicfile.WriteFile(file, content, 0644, w.fileOpts...); err != nil {
+			return "", fmt.Errorf("failed to write markdown file: %w", err)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.primary == nil {
+		w.primary = make(map[string]string)
+	}
+	w.primary[doc.Title] = folders[0]
+	w.pages = append(w.pages, &models.Document{Title: doc.Title, Tags: doc.Tags})
+	return path, nil
+}
+
+// folders returns the folders a page with the tags is written to, relative to the output directory
+func (w *markdownWriter) folders(tags []string) []string {
+	if !w.layout.byTag || len(tags) == 0 {
+		return []string{""}
+	}
+	if w.layout.tagCopies == tagCopiesPrimary {
+		tags = tags[:1]
+	}
+	folders := make([]string, len(tags))
+	for i, tag := range tags {
+		folders[i] = w.names.Name(tag, "")
+	}
+	return folders
+}
+
+// indexPath returns the path of the file of a page listed under a tag in the index
+func (w *markdownWriter) indexPath(title, tag string) string {
+	name := w.names.Name(title, ".md")
+	if w.layout.tagCopies == tagCopiesPrimary {
+		tag = w.primary[title]
+	} else if tag != "" {
+		tag = w.names.Name(tag, "")
+	}
+	if !w.layout.byTag || tag == "" {
+		return name
+	}
+	return tag + "/" + name
+}
+
+func (w *markdownWriter) Close() error {
+	for _, page := range w.pages {
+		if strings.EqualFold(page.Title, "index") {
+			// Don't overwrite a page that is itself called index
+			logger.Info("Skipping index.md as a page is named index", nil)
+			return nil
+		}
+	}
+
+	// Pages are written in the order they finish, so the index lists them by title
+	slices.SortFunc(w.pages, func(a, b *models.Document) int {
+		return strings.Compare(a.Title, b.Title)
+	})
+	index := markdown.RenderIndex(models.GroupByTag(w.pages), w.indexPath)
+	if err := atomicfile.WriteFile(filepath.Join(w.dir, "index.md"), []byte(index), 0644, w.fileOpts...); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// parseLayout parses the -layout and -tag-copies flags
+func parseLayout(layout, tagCopies string) (markdownLayout, error) {
+	var l markdownLayout
+	switch layout {
+	case "flat":
+	case "tags":
+		l.byTag = true
+	default:
+		return l, fmt.Errorf("invalid layout %q: must be one of flat, tags", layout)
+	}
+
+	switch tagCopies {
+	case tagCopiesPrimary, tagCopiesDuplicate, tagCopiesSymlink:
+		l.tagCopies = tagCopies
+	default:
+		return l, fmt.Errorf("invalid tag copies %q: must be one of primary, duplicate, symlink", tagCopies)
+	}
+	return l, nil
+}
+
+// newPageWriter returns the writer for an output format. The layout only
+// applies to markdown. With skipUnchanged, files that already hold the same
+// content are left untouched. Names longer than maxNameBytes are shortened.
+func newPageWriter(format, dir string, layout markdownLayout, skipUnchanged bool, maxNameBytes int) (pageWriter, error) {
+	if layout.byTag && format != "markdown" {
+		return nil, fmt.Errorf("layout tags is only supported by the markdown format")
+	}
+
+	var fileOpts []atomicfile.Option
+	if skipUnchanged {
+		fileOpts = append(fileOpts, atomicfile.SkipUnchanged())
+	}
+
+	switch format {
+	case "markdown":
+		return &markdownWriter{dir: dir, layout: layout, fileOpts: fileOpts, names: pathsafe.NewNamer(maxNameBytes)}, nil
+	case "obsidian":
+		return obsidian.NewVault(dir, obsidian.WithFileOptions(fileOpts...), obsidian.WithMaxNameBytes(maxNameBytes)), nil
+	case "hugo":
+		return hugo.NewSite(dir, hugo.WithFileOptions(fileOpts...), hugo.WithMaxNameBytes(maxNameBytes)), nil
+	case "html":
+		return html.NewArchive(dir, html.WithFileOptions(fileOpts...), html.WithMaxNameBytes(maxNameBytes)), nil
+	case "logseq":
+		return logseq.NewGraph(dir, logseq.WithFileOptions(fileOpts...), logseq.WithMaxNameBytes(maxNameBytes)), nil
+	case "org":
+		return org.NewWriter(dir, org.WithFileOptions(fileOpts...), org.WithMaxNameBytes(maxNameBytes)), nil
+	}
+	return nil, fmt.Errorf("invalid format %q: must be one of markdown, obsidian, hugo, html, logseq, org", format)
+}
+
+// writeArchive writes every file under dir into a zip archive at path
+func writeArchive(dir, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	zw := zip.NewWriter(f)
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		header.Method = zip.Deflate
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		// Symbolic links are stored with their target as content
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, filepath.ToSlash(target))
+			return err
+		}
+
+		src, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(w, src)
+		return err
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	// The archive may only be complete on disk once the file is closed
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+)
+
+// runReport records the outcome of a migration and the Notion objects it
+// created, so that the run can be rolled back
+type runReport struct {
+	RunID         string                 `json:"run_id,omitempty"`
+	Project       string                 `json:"project,omitempty"`
+	DisplayName   string                 `json:"display_name,omitempty"`
+	Started       time.Time              `json:"started"`
+	Finished      time.Time              `json:"finished"`
+	TotalPages    int                    `json:"total_pages"`
+	SuccessCount  int                    `json:"success_count"`
+	FailureCount  int                    `json:"failure_count"`
+	WarningCount  int                    `json:"warning_count"`
+	FailedPages   []string               `json:"failed_pages,omitempty"`
+	TimedOutPages []string               `json:"timed_out_pages,omitempty"`
+	PageTimings   []*pageTiming          `json:"page_timings,omitempty"`
+	Percentiles   *stagePercentiles      `json:"timing_percentiles,omitempty"`
+	Created       []notion.CreatedObject `json:"created"`
+}
+
+// pageTiming is the time spent on each stage of a page: parsing it into a
+// document, converting the document to the output format and writing it, and
+// uploading it to Notion, retries included
+type pageTiming struct {
+	Title          string  `json:"title"`
+	ParseSeconds   float64 `json:"parse_seconds"`
+	ConvertSeconds float64 `json:"convert_seconds"`
+	UploadSeconds  float64 `json:"upload_seconds"`
+}
+
+// stagePercentiles are the percentiles of the time spent on each stage across
+// pages, leaving out the pages that skipped the stage
+type stagePercentiles struct {
+	Parse   *percentiles `json:"parse,omitempty"`
+	Convert *percentiles `json:"convert,omitempty"`
+	Upload  *percentiles `json:"upload,omitempty"`
+}
+
+// percentiles are the percentiles of durations in seconds
+type percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// timingPercentiles returns the percentiles of the stages of the page timings,
+// or nil if there are none
+func timingPercentiles(timings []*pageTiming) *stagePercentiles {
+	if len(timings) == 0 {
+		return nil
+	}
+	stage := func(seconds func(*pageTiming) float64) *percentiles {
+		var values []float64
+		for _, timing := range timings {
+			if s := seconds(timing); s > 0 {
+				values = append(values, s)
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		slices.Sort(values)
+		// rank returns the nearest-rank percentile
+		rank := func(p int) float64 {
+			return values[max((p*len(values)+99)/100, 1)-1]
+		}
+		return &percentiles{P50: rank(50), P90: rank(90), P99: rank(99), Max: values[len(values)-1]}
+	}
+	return &stagePercentiles{
+		Parse:   stage(func(t *pageTiming) float64 { return t.ParseSeconds }),
+		Convert: stage(func(t *pageTiming) float64 { return t.ConvertSeconds }),
+		Upload:  stage(func(t *pageTiming) float64 { return t.UploadSeconds }),
+	}
+}
+
+// writeReport writes a run report as JSON
+func writeReport(path string, report *runReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// readReport reads a run report written by writeReport
+func readReport(path string) (*runReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/joho/godotenv"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+)
+
+// runRollback archives the Notion pages and databases created by a migration,
+// as recorded in its run report. It returns a non-zero exit code on failure.
+func runRollback(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion rollback", flag.ExitOnError)
+	reportFile := fs.String("report", "", "Run report written by migrate -report")
+	dryRun := fs.Bool("dry-run", false, "Only list the objects that would be archived")
+	logFormat := addLogFormatFlag(fs)
+	fs.Parse(args)
+
+	if *reportFile == "" {
+		fmt.Println("Error: report file is required")
+		fs.Usage()
+		return 2
+	}
+
+	report, err := readReport(*reportFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+
+	if *dryRun {
+		for i := len(report.Created) - 1; i >= 0; i-- {
+			object := report.Created[i]
+			fmt.Printf("%s\t%s\t%s\n", object.Type, object.ID, object.Title)
+		}
+		fmt.Printf("%d objects would be archived\n", len(report.Created))
+		return 0
+	}
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Printf("Error loading .env file: %v\n", err)
+		return 2
+	}
+	if _, err := initLogger(*logFormat); err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		return 2
+	}
+
+	client, err := notion.New()
+	if err != nil {
+		logger.Error("Failed to initialize Notion client", err, nil)
+		return 2
+	}
+
+	// Archive in reverse order so pages go before the databases holding them
+	ctx := context.Background()
+	failures := 0
+	for i := len(report.Created) - 1; i >= 0; i-- {
+		object := report.Created[i]
+		if err := client.Archive(ctx, object); err != nil {
+			logger.Error("Failed to archive Notion object", err, map[string]interface{}{
+				"type":  object.Type,
+				"id":    object.ID,
+				"title": object.Title,
+			})
+			failures++
+			continue
+		}
+		logger.Info("Archived Notion object", map[string]interface{}{
+			"type":  object.Type,
+			"id":    object.ID,
+			"title": object.Title,
+		})
+	}
+
+	logger.Info("Rollback completed", map[string]interface{}{
+		"archived_count": len(report.Created) - failures,
+		"failure_count":  failures,
+	})
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+	"github.com/takak2166/scrapbox2notion/internal/scrapboxapi"
+	"github.com/takak2166/scrapbox2notion/internal/tracing"
+)
+
+// maxUploadBytes is the largest export accepted by the server
+const maxUploadBytes = 256 << 20
+
+// runServe serves migrations over HTTP until interrupted. It returns a non-zero
+// exit code on failure.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on; other than a loopback address, SERVE_TOKEN is required")
+	conversion := addConversionFlags(fs)
+	logFormat := addLogFormatFlag(fs)
+	fs.Parse(args)
+
+	parserOpts, err := conversion.parserOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	notionOpts, err := conversion.notionOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Printf("Error loading .env file: %v\n", err)
+		return 2
+	}
+	if _, err := initLogger(*logFormat); err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		return 2
+	}
+
+	// Without a token anyone reaching the server could migrate into Notion, so
+	// only this machine may
+	token := os.Getenv("SERVE_TOKEN")
+	if token == "" && !isLoopback(*addr) {
+		fmt.Printf("Error: SERVE_TOKEN is required to listen on %s, which is not a loopback address\n", *addr)
+		return 2
+	}
+	logger.AddSecret(token)
+
+	s := &migrateServer{
+		conversion: conversion,
+		parserOpts: parserOpts,
+		notionOpts: notionOpts,
+		token:      token,
+		sessionID:  os.Getenv("SCRAPBOX_SID"),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/migrate", s.handleMigrate)
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Stop accepting migrations on Ctrl-C or SIGTERM and let running ones finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		logger.Info("Shutting down server", nil)
+		server.Shutdown(context.Background())
+	}()
+
+	// Trace every migration when an OpenTelemetry collector is configured
+	tracing.InitFromEnv()
+	defer func() {
+		if err := tracing.Shutdown(context.Background()); err != nil {
+			logger.Error("Failed to export traces", err, nil)
+		}
+	}()
+
+	logger.Info("Serving migrations", map[string]interface{}{
+		"addr": *addr,
+	})
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Failed to serve", err, nil)
+		return 1
+	}
+	return 0
+}
+
+// migrateServer migrates exports to Notion on request, one at a time so
+// migrations don't compete for the Notion rate limit
+type migrateServer struct {
+	conversion *conversionFlags
+	parserOpts []parser.Option
+	notionOpts []notion.Option
+	// token is the bearer token requests must have, empty to allow any request
+	token string
+	// sessionID is the Scrapbox session cookie for fetching private projects
+	sessionID string
+
+	mu sync.Mutex
+}
+
+// progressEvent is an event streamed while migrating
+type progressEvent struct {
+	// Event is started, page or done
+	Event     string `json:"event"`
+	Title     string `json:"title,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Total     int    `json:"total,omitempty"`
+	Succeeded int    `json:"succeeded,omitempty"`
+	Failed    int    `json:"failed,omitempty"`
+}
+
+// progressStream writes events to the response as they happen, as server-sent
+// events or newline delimited JSON
+type progressStream struct {
+	w   http.ResponseWriter
+	sse bool
+}
+
+// send writes the event and flushes it to the client
+func (s *progressStream) send(event progressEvent) {
+	data, _ := json.Marshal(event)
+	if s.sse {
+		fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event.Event, data)
+	} else {
+		fmt.Fprintf(s.w, "%s\n", data)
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// handleMigrate migrates the export in the request body, uploaded as JSON or as
+// the export field of a form, or the Scrapbox project of the project query
+// parameter, streaming the progress back
+func (s *migrateServer) handleMigrate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	opts := s.parserOpts
+	if project := r.URL.Query().Get("project"); project != "" {
+		// Pages read through the API have no export name to take the project from
+		opts = append(slices.Clip(opts), parser.WithProject(project))
+	}
+	p := parser.New(opts...)
+	if err := s.readPages(w, r, p); err != nil {
+		logger.Error("Failed to read migration request", err, nil)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversion.pageExists = p.HasPage
+	// A client sending a traceparent header gets the migration in its trace
+	ctx, span := tracing.Start(tracing.WithRemoteParent(r.Context(), r.Header.Get("traceparent")), "migrate", tracing.KindInternal, map[string]string{
+		"project": r.URL.Query().Get("project"),
+	})
+	defer span.End(nil)
+	client, err := notion.New(s.notionOpts...)
+	if err != nil {
+		logger.Error("Failed to initialize Notion client", err, nil)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := client.Preflight(ctx); err != nil {
+		logger.Error("Failed to access Notion parent", err, nil)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	stream := &progressStream{w: w, sse: r.Header.Get("Accept") == "text/event-stream"}
+	if stream.sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	pages := p.GetPages()
+	stream.send(progressEvent{Event: "started", Total: len(pages)})
+	logger.Info(fmt.Sprintf("Found %d pages to process", len(pages)), nil)
+
+	done := progressEvent{Event: "done", Total: len(pages)}
+	for i := range pages {
+		if ctx.Err() != nil {
+			break
+		}
+		page := &pages[i]
+		event := progressEvent{Event: "page", Title: page.Title}
+		pageCtx, pageSpan := tracing.Start(ctx, "page", tracing.KindInternal, map[string]string{
+			"page.title": page.Title,
+		})
+		doc := p.ParseDocument(page)
+		err := client.CreatePage(pageCtx, doc, doc.Tags)
+		if errors.Is(err, notion.ErrAlreadyExists) {
+			err = nil
+		}
+		pageSpan.End(err)
+		if err != nil {
+			logger.Error("Failed to create Notion page", err, map[string]interface{}{
+				"page": page.Title,
+			})
+			event.Error = err.Error()
+			done.Failed++
+		} else {
+			done.Succeeded++
+		}
+		stream.send(event)
+	}
+	stream.send(done)
+
+	logger.Info("Migration completed", map[string]interface{}{
+		"total_pages":   done.Total,
+		"success_count": done.Succeeded,
+		"failure_count": done.Failed,
+	})
+}
+
+// readPages adds the pages of the request to the parser
+func (s *migrateServer) readPages(w http.ResponseWriter, r *http.Request, p *parser.Parser) error {
+	if project := r.URL.Query().Get("project"); project != "" {
+		var opts []scrapboxapi.Option
+		if s.sessionID != "" {
+			opts = append(opts, scrapboxapi.WithSessionID(s.sessionID))
+		}
+		source := scrapboxapi.New(project, opts...)
+		summaries, err := source.ListPages(r.Context())
+		if err != nil {
+			return err
+		}
+		for _, summary := range summaries {
+			page, err := source.GetPage(r.Context(), summary.Title)
+			if err != nil {
+				return err
+			}
+			p.AddPages(*page)
+		}
+		return nil
+	}
+
+	// The response writer lets the server close the connection of a body too large
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("export")
+		if err != nil {
+			return fmt.Errorf("failed to read export field: %w", err)
+		}
+		defer file.Close()
+		body = file
+	}
+	return p.Parse(body)
+}
+
+// isLoopback reports whether the listen address is on a loopback interface only
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+)
+
+// syncState records the pages pushed to Notion, so the next sync or migration
+// only pushes the pages changed since
+type syncState struct {
+	// Project is the Scrapbox project synced, empty for a migration
+	Project string                `json:"project"`
+	Pages   map[string]syncedPage `json:"pages"`
+}
+
+// syncedPage is the version of a page last pushed to Notion
+type syncedPage struct {
+	// Updated is the Unix time the page was updated on Scrapbox
+	Updated int64 `json:"updated"`
+	// Hash is the hash of the content of the page, see models.Document.Hash
+	Hash string `json:"hash,omitempty"`
+	// ID is the Scrapbox ID of the page, which stays the same when the page is
+	// renamed
+	ID string `json:"id,omitempty"`
+	// Tags are the tags the page was pushed with, to find its Notion pages
+	// once it is deleted on Scrapbox
+	Tags []string `json:"tags,omitempty"`
+	// Title is the title of the Notion pages when it was edited in the review
+	// of -interactive, which later runs rename the page to
+	Title string `json:"title,omitempty"`
+}
+
+// pushed reports whether the page was pushed, false without a state
+func (s *syncState) pushed(title string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.Pages[title]
+	return ok
+}
+
+// unchanged reports whether the page was pushed with the same content, false
+// without a state
+func (s *syncState) unchanged(title, hash string) bool {
+	return s.pushed(title) && s.Pages[title].Hash == hash
+}
+
+// titleMapping returns the titles edited in the review of -interactive as a
+// title mapping, so later runs keep them and the links to the pages follow
+func (s *syncState) titleMapping() map[string]parser.TitleMapping {
+	mappings := make(map[string]parser.TitleMapping)
+	if s == nil {
+		return mappings
+	}
+	for title, page := range s.Pages {
+		if page.Title != "" {
+			mappings[title] = parser.TitleMapping{Title: page.Title}
+		}
+	}
+	return mappings
+}
+
+// renamedFrom returns the title the page with the Scrapbox ID was pushed with,
+// if that is another title, as when the page was renamed on Scrapbox since
+func (s *syncState) renamedFrom(id, title string) (string, bool) {
+	if s == nil || id == "" || s.pushed(title) {
+		return "", false
+	}
+	for old, page := range s.Pages {
+		if page.ID == id {
+			return old, true
+		}
+	}
+	return "", false
+}
+
+// readState reads a sync state, or returns an empty state for the project if
+// the file does not exist yet. The state of a migration has no project.
+func readState(path, project string) (*syncState, error) {
+	state := &syncState{Project: project, Pages: make(map[string]syncedPage)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state %s: %w", path, err)
+	}
+	if state.Project != project {
+		return nil, fmt.Errorf("sync state %s is of project %q, not %q", path, state.Project, project)
+	}
+	if state.Pages == nil {
+		state.Pages = make(map[string]syncedPage)
+	}
+	return state, nil
+}
+
+// writeState writes a sync state, replacing the file at once so an interrupted
+// write leaves the previous state
+func writeState(path string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync state: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+	"github.com/takak2166/scrapbox2notion/internal/scrapboxapi"
+	"github.com/takak2166/scrapbox2notion/internal/tracing"
+)
+
+// runSync pushes the pages of a Scrapbox project updated since the last sync to
+// Notion, fetching them through the Scrapbox API. With -interval it keeps
+// syncing until interrupted. It returns a non-zero exit code on failure.
+func runSync(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion sync", flag.ExitOnError)
+	project := fs.String("project", "", "Name of the Scrapbox project to sync")
+	interval := fs.Duration("interval", 0, "Sync again after this long, such as 1h, until interrupted (0 syncs once)")
+	stateFile := fs.String("state", "scrapbox2notion-sync.json", "File recording the pages pushed to Notion, so only the pages changed since are pushed")
+	prune := fs.Bool("prune", false, "Archive the Notion pages of pages deleted on Scrapbox since the last sync")
+	conversion := addConversionFlags(fs)
+	logFormat := addLogFormatFlag(fs)
+	fs.Parse(args)
+
+	if *project == "" {
+		fmt.Println("Error: project is required")
+		fs.Usage()
+		return 2
+	}
+	if *interval < 0 {
+		fmt.Println("Error: interval must not be negative")
+		fs.Usage()
+		return 2
+	}
+
+	parserOpts, err := conversion.parserOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	// Pages read through the API have no export name to take the project from
+	parserOpts = append(parserOpts, parser.WithProject(*project))
+	notionOpts, err := conversion.notionOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Printf("Error loading .env file: %v\n", err)
+		return 2
+	}
+	if _, err := initLogger(*logFormat); err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		return 2
+	}
+
+	state, err := readState(*stateFile, *project)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+
+	var scrapboxOpts []scrapboxapi.Option
+	if sid := os.Getenv("SCRAPBOX_SID"); sid != "" {
+		scrapboxOpts = append(scrapboxOpts, scrapboxapi.WithSessionID(sid))
+	}
+	source := scrapboxapi.New(*project, scrapboxOpts...)
+
+	client, err := notion.New(notionOpts...)
+	if err != nil {
+		logger.Error("Failed to initialize Notion client", err, nil)
+		return 2
+	}
+
+	// Finish the page being pushed and stop on Ctrl-C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := client.Preflight(ctx); err != nil {
+		logger.Error("Failed to access Notion parent", err, nil)
+		return 2
+	}
+
+	// Trace every sync when an OpenTelemetry collector is configured
+	tracing.InitFromEnv()
+	defer func() {
+		if err := tracing.Shutdown(context.Background()); err != nil {
+			logger.Error("Failed to export traces", err, nil)
+		}
+	}()
+	parentCtx := tracing.WithRemoteParent(ctx, os.Getenv("TRACEPARENT"))
+
+	for {
+		syncCtx, syncSpan := tracing.Start(parentCtx, "sync", tracing.KindInternal, map[string]string{
+			"project": *project,
+		})
+		failures, err := syncOnce(syncCtx, source, client, conversion, parserOpts, state, *stateFile, *prune)
+		syncSpan.End(err)
+		if err != nil {
+			logger.Error("Failed to sync project", err, map[string]interface{}{
+				"project": *project,
+			})
+		}
+		if *interval == 0 {
+			if err != nil || failures > 0 {
+				return 1
+			}
+			return 0
+		}
+
+		logger.Info("Waiting for the next sync", map[string]interface{}{
+			"interval": interval.String(),
+		})
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// syncOnce pushes the pages updated since they were last pushed, recording each
+// pushed page in the state. Pages updated without their content changing are
+// only recorded. Pages that fail are left for the next sync, and their number
+// is returned. With prune, the Notion pages of the pages in the state that are
+// gone from the project are archived.
+func syncOnce(ctx context.Context, source *scrapboxapi.Client, client *notion.Client, conversion *conversionFlags, parserOpts []parser.Option, state *syncState, stateFile string, prune bool) (int, error) {
+	summaries, err := source.ListPages(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only updated pages are fetched, so links are checked against every title
+	titles := make(map[string]bool, len(summaries))
+	for _, summary := range summaries {
+		titles[parser.TitleLc(summary.Title)] = true
+	}
+	conversion.pageExists = func(title string) bool { return titles[parser.TitleLc(title)] }
+
+	p := parser.New(parserOpts...)
+	for _, summary := range summaries {
+		if synced, ok := state.Pages[summary.Title]; ok && synced.Updated >= summary.Updated {
+			continue
+		}
+		page, err := source.GetPage(ctx, summary.Title)
+		if err != nil {
+			return 0, err
+		}
+		p.AddPages(*page)
+	}
+
+	pages := p.GetPages()
+	pushed, renamedCount, failures := 0, 0, 0
+	for i := range pages {
+		if ctx.Err() != nil {
+			break
+		}
+		page := &pages[i]
+		logger.SetField("page", page.Title)
+		pageCtx, pageSpan := tracing.Start(ctx, "page", tracing.KindInternal, map[string]string{
+			"page.title": page.Title,
+		})
+		_, convertSpan := tracing.Start(pageCtx, "convert", tracing.KindInternal, nil)
+		doc := p.ParseDocument(page)
+		convertSpan.End(nil)
+		hash := doc.Hash()
+		// A page renamed on Scrapbox keeps its Notion pages, renamed before
+		// they are updated. They are in the databases of the tags they were
+		// pushed with.
+		oldTitle, renamed := state.renamedFrom(page.ID, page.Title)
+		if renamed {
+			if err := client.RenamePage(pageCtx, oldTitle, doc, state.Pages[oldTitle].Tags); err != nil {
+				logger.Error("Failed to rename page in Notion", err, map[string]interface{}{
+					"old_title": oldTitle,
+				})
+				pageSpan.End(err)
+				failures++
+				continue
+			}
+			renamedCount++
+		}
+		if !state.unchanged(page.Title, hash) {
+			uploadCtx, uploadSpan := tracing.Start(pageCtx, "upload", tracing.KindInternal, nil)
+			err := client.UpdatePage(uploadCtx, doc, doc.Tags)
+			uploadSpan.End(err)
+			if err != nil {
+				logger.Error("Failed to push page to Notion", err, nil)
+				pageSpan.End(err)
+				failures++
+				continue
+			}
+			pushed++
+		}
+		if renamed {
+			delete(state.Pages, oldTitle)
+		}
+		state.Pages[page.Title] = syncedPage{Updated: page.Updated, Hash: hash, ID: page.ID, Tags: doc.Tags}
+		pageSpan.End(nil)
+		if err := writeState(stateFile, state); err != nil {
+			logger.RemoveField("page")
+			return failures, err
+		}
+	}
+	logger.RemoveField("page")
+
+	prunedCount := 0
+	if prune && ctx.Err() == nil {
+		pruned, pruneFailures, err := pruneDeleted(ctx, client, summaries, state, stateFile)
+		prunedCount = pruned
+		failures += pruneFailures
+		if err != nil {
+			return failures, err
+		}
+	}
+
+	logger.Info("Sync completed", map[string]interface{}{
+		"total_pages":   len(summaries),
+		"updated_pages": len(pages),
+		"pushed_pages":  pushed,
+		"renamed_pages": renamedCount,
+		"pruned_pages":  prunedCount,
+		"failure_count": failures,
+	})
+	return failures, nil
+}
+
+// pruneDeleted archives the Notion pages of the pages in the state that are not
+// among the pages of the project, as they were deleted on Scrapbox, and drops
+// them from the state. Pages are matched by ID, or by title when the state has
+// no ID for them. It returns the numbers of pages pruned and of pages that
+// failed, which are left in the state for the next sync.
+func pruneDeleted(ctx context.Context, client *notion.Client, summaries []scrapboxapi.PageSummary, state *syncState, stateFile string) (int, int, error) {
+	// A project listing no pages more likely means a wrong project or session
+	// than every page deleted
+	if len(summaries) == 0 {
+		logger.Warn("Project has no pages, skipping pruning", nil)
+		return 0, 0, nil
+	}
+
+	ids := make(map[string]bool, len(summaries))
+	titles := make(map[string]bool, len(summaries))
+	for _, summary := range summaries {
+		ids[summary.ID] = true
+		titles[summary.Title] = true
+	}
+
+	pruned, failures := 0, 0
+	for title, synced := range state.Pages {
+		if ctx.Err() != nil {
+			break
+		}
+		if (synced.ID != "" && ids[synced.ID]) || (synced.ID == "" && titles[title]) {
+			continue
+		}
+		// Pages pushed before their tags were recorded are only looked for
+		// directly under the parent
+		archived, err := client.ArchivePage(ctx, title, synced.Tags)
+		if err != nil {
+			logger.Error("Failed to archive deleted page in Notion", err, map[string]interface{}{
+				"title": title,
+			})
+			failures++
+			continue
+		}
+		logger.Info("Archived Notion pages of page deleted on Scrapbox", map[string]interface{}{
+			"title":  title,
+			"copies": archived,
+		})
+		delete(state.Pages, title)
+		if err := writeState(stateFile, state); err != nil {
+			return pruned, failures, err
+		}
+		pruned++
+	}
+	return pruned, failures, nil
+}
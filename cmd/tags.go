@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+)
+
+// tagsReport is the JSON output of the tags command
+type tagsReport struct {
+	Pages    int              `json:"pages"`
+	Untagged int              `json:"untagged"`
+	Tags     []models.TagStat `json:"tags"`
+}
+
+// runTags prints every tag of the export with the number of pages having it and
+// the tags used together with it, to plan tag filters and the database layout
+// before migrating. It returns a non-zero exit code on failure.
+func runTags(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion tags", flag.ExitOnError)
+	var inputPatterns stringList
+	fs.Var(&inputPatterns, "input", "Path or glob pattern of Scrapbox JSON export files, repeatable (- to read from stdin)")
+	format := fs.String("format", "text", "Output format: text or json")
+	top := fs.Int("top", 3, "Number of tags used together with each tag to print in the text format, the others being counted (0 counts them all)")
+	conversion := addConversionFlags(fs)
+	fs.Parse(args)
+
+	if len(inputPatterns) == 0 {
+		fmt.Println("Error: input file is required")
+		fs.Usage()
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Printf("Error: invalid format %q: must be one of text, json\n", *format)
+		fs.Usage()
+		return 2
+	}
+	if *top < 0 {
+		fmt.Printf("Error: invalid top %d: must not be negative\n", *top)
+		fs.Usage()
+		return 2
+	}
+
+	inputFiles, err := expandInputs(inputPatterns)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+	// Tags come out of the same parser options as in migrate, such as
+	// -bracket-tags, so the counts match what would be migrated
+	parserOpts, err := conversion.parserOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	p := parser.New(parserOpts...)
+	for _, inputFile := range inputFiles {
+		if inputFile == "-" {
+			err = p.Parse(os.Stdin)
+		} else {
+			err = p.ParseFile(inputFile)
+		}
+		if err != nil {
+			fmt.Printf("Error reading input: %v\n", err)
+			return 2
+		}
+	}
+
+	pages := p.GetPages()
+	docs := make([]*models.Document, 0, len(pages))
+	report := tagsReport{Pages: len(pages)}
+	for i := range pages {
+		doc := p.ParseDocument(&pages[i])
+		if len(doc.Tags) == 0 {
+			report.Untagged++
+		}
+		docs = append(docs, doc)
+	}
+	report.Tags = models.CountTags(docs)
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(report.Tags) > 0 {
+		fmt.Fprintln(w, "TAG\tPAGES\tUSED WITH")
+	}
+	for _, stat := range report.Tags {
+		var with []string
+		for i, co := range stat.CoTags {
+			if i == *top {
+				with = append(with, fmt.Sprintf("+%d more", len(stat.CoTags)-i))
+				break
+			}
+			with = append(with, fmt.Sprintf("%s (%d)", co.Tag, co.Pages))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", stat.Tag, stat.Pages, strings.Join(with, ", "))
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d pages, %d tags, %d pages without tags\n", report.Pages, len(report.Tags), report.Untagged)
+	return 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/takak2166/scrapbox2notion/internal/validator"
+)
+
+// runValidate checks a Scrapbox export and prints the problems found.
+// It returns a non-zero exit code if the export has errors.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion validate", flag.ExitOnError)
+	inputFile := fs.String("input", "", "Path to Scrapbox JSON export file (- to read from stdin)")
+	showPages := fs.Bool("pages", false, "Print the estimated Notion block count of every page")
+	fs.Parse(args)
+
+	if *inputFile == "" {
+		fmt.Println("Error: input file is required")
+		fs.Usage()
+		return 2
+	}
+
+	var data []byte
+	var err error
+	if *inputFile == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*inputFile)
+	}
+	if err != nil {
+		fmt.Printf("Error reading input: %v\n", err)
+		return 2
+	}
+
+	report, err := validator.Validate(data)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(report.Issues) > 0 {
+		fmt.Fprintln(w, "SEVERITY\tPAGE\tLINE\tMESSAGE")
+		for _, issue := range report.Issues {
+			line := ""
+			if issue.Line > 0 {
+				line = fmt.Sprint(issue.Line)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Severity, issue.Page, line, issue.Message)
+		}
+		fmt.Fprintln(w)
+	}
+
+	totalBlocks := 0
+	if *showPages {
+		fmt.Fprintln(w, "PAGE\tLINES\tBLOCKS")
+	}
+	for _, page := range report.Pages {
+		totalBlocks += page.Blocks
+		if *showPages {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", page.Title, page.Lines, page.Blocks)
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d pages, %d estimated Notion blocks, %d issues\n", len(report.Pages), totalBlocks, len(report.Issues))
+	if report.HasErrors() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+)
+
+// runVerify compares the migrated Notion pages with the pages of the export,
+// reporting pages that are missing, stop short or differ. It returns a non-zero
+// exit code if any page does not match.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("scrapbox2notion verify", flag.ExitOnError)
+	var inputPatterns stringList
+	fs.Var(&inputPatterns, "input", "Path or glob pattern of Scrapbox JSON export files, repeatable (- to read from stdin)")
+	conversion := addConversionFlags(fs)
+	logFormat := addLogFormatFlag(fs)
+	fs.Parse(args)
+
+	if len(inputPatterns) == 0 {
+		fmt.Println("Error: input file is required")
+		fs.Usage()
+		return 2
+	}
+
+	inputFiles, err := expandInputs(inputPatterns)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+
+	parserOpts, err := conversion.parserOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	notionOpts, err := conversion.notionOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Printf("Error loading .env file: %v\n", err)
+		return 2
+	}
+	if _, err := initLogger(*logFormat); err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		return 2
+	}
+
+	p := parser.New(parserOpts...)
+	for _, inputFile := range inputFiles {
+		if inputFile == "-" {
+			err = p.Parse(os.Stdin)
+		} else {
+			err = p.ParseFile(inputFile)
+		}
+		if err != nil {
+			fmt.Printf("Error reading input: %v\n", err)
+			return 2
+		}
+	}
+	conversion.pageExists = p.HasPage
+
+	client, err := notion.New(notionOpts...)
+	if err != nil {
+		logger.Error("Failed to initialize Notion client", err, nil)
+		return 2
+	}
+
+	ctx := context.Background()
+	if err := client.Preflight(ctx); err != nil {
+		logger.Error("Failed to access Notion parent", err, nil)
+		return 2
+	}
+
+	counts := make(map[notion.VerifyStatus]int)
+	failures := 0
+	pages := p.GetPages()
+	for i := range pages {
+		page := &pages[i]
+		logger.SetField("page", page.Title)
+		doc := p.ParseDocument(page)
+		result, err := client.VerifyPage(ctx, doc, doc.Tags)
+		if err != nil {
+			logger.Error("Failed to verify Notion page", err, map[string]interface{}{
+				"page": page.Title,
+			})
+			failures++
+			continue
+		}
+		counts[result.Status]++
+		if result.Status == notion.VerifyOK {
+			continue
+		}
+
+		fmt.Printf("%-8s %s\n", result.Status, result.Title)
+		for _, line := range result.Diff {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	logger.RemoveField("page")
+
+	fmt.Printf("%d pages: %d ok, %d partial, %d drifted, %d missing, %d failed\n",
+		len(pages), counts[notion.VerifyOK], counts[notion.VerifyPartial],
+		counts[notion.VerifyDrifted], counts[notion.VerifyMissing], failures)
+	if counts[notion.VerifyOK] != len(pages) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+// Package atomicfile writes files so that they are either written in full or
+// left as they were, never truncated by a crash or an interrupted run.
+package atomicfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// Option configures optional behavior of WriteFile
+type Option func(*options)
+
+type options struct {
+	skipUnchanged bool
+}
+
+// SkipUnchanged leaves a file that already holds the data untouched, so
+// re-runs only write the files that changed
+func SkipUnchanged() Option {
+	return func(o *options) {
+		o.skipUnchanged = true
+	}
+}
+
+// WriteFile writes data to a temporary file next to path and renames it to
+// path, replacing the file at once
+func WriteFile(path string, data []byte, perm os.FileMode, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.skipUnchanged {
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+			return nil
+		}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
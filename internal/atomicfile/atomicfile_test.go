@@ -0,0 +1,63 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.md")
+
+	if err := WriteFile(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := WriteFile(path, []byte("second"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second" {
+		t.Errorf("Expected the file replaced, got %q", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %d files", len(entries))
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "page.md"), []byte("x"), 0644); err == nil {
+		t.Errorf("Expected an error writing into a missing folder")
+	}
+}
+
+func TestWriteFileSkipUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.md")
+	if err := WriteFile(path, []byte("content"), 0644, SkipUnchanged()); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	if err := WriteFile(path, []byte("content"), 0644, SkipUnchanged()); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if info, _ := os.Stat(path); !info.ModTime().Equal(old) {
+		t.Errorf("Expected an unchanged file left untouched")
+	}
+	if err := WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if info, _ := os.Stat(path); info.ModTime().Equal(old) {
+		t.Errorf("Expected an unchanged file written without SkipUnchanged")
+	}
+
+	if err := WriteFile(path, []byte("changed"), 0644, SkipUnchanged()); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "changed" {
+		t.Errorf("Expected a changed file written, got %q", data)
+	}
+}
@@ -0,0 +1,154 @@
+// Package graph builds the link graph of Scrapbox pages.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// EdgeKind is the relation an edge stands for
+type EdgeKind string
+
+const (
+	// EdgeLink is a link from a page to another page
+	EdgeLink EdgeKind = "link"
+	// EdgeTag is a tag of a page, pointing at the page of the tag
+	EdgeTag EdgeKind = "tag"
+)
+
+// Node is a page of the graph
+type Node struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Missing is set for linked pages that are not in the export
+	Missing bool `json:"missing,omitempty"`
+}
+
+// Edge is a link or tag from one page to another
+type Edge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// Graph is the link graph of the pages of an export
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Build builds the graph of the documents. Pages are identified the way Scrapbox
+// links them: by the lower case title with underscores for spaces.
+func Build(docs []*models.Document) *Graph {
+	g := &Graph{}
+	nodes := make(map[string]int)
+	edges := make(map[Edge]bool)
+
+	addNode := func(title string, missing bool) string {
+		id := NodeID(title)
+		if idx, ok := nodes[id]; ok {
+			if !missing {
+				g.Nodes[idx].Title = title
+				g.Nodes[idx].Missing = false
+			}
+			return id
+		}
+		nodes[id] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, Node{ID: id, Title: title, Missing: missing})
+		return id
+	}
+	addEdge := func(edge Edge) {
+		if edge.From != edge.To && !edges[edge] {
+			edges[edge] = true
+			g.Edges = append(g.Edges, edge)
+		}
+	}
+
+	for _, doc := range docs {
+		addNode(doc.Title, false)
+	}
+
+	for _, doc := range docs {
+		from := NodeID(doc.Title)
+		for _, link := range pageLinks(doc) {
+			to := addNode(link, true)
+			addEdge(Edge{From: from, To: to, Kind: EdgeLink})
+		}
+		for _, tag := range doc.Tags {
+			to := addNode(tag, true)
+			addEdge(Edge{From: from, To: to, Kind: EdgeTag})
+		}
+	}
+
+	return g
+}
+
+// pageLinks returns the titles of the pages the document links to
+func pageLinks(doc *models.Document) []string {
+	var links []string
+	var walk func(inlines []models.Inline)
+	walk = func(inlines []models.Inline) {
+		for _, inline := range inlines {
+			if inline.Type == models.InlinePageLink {
+				links = append(links, inline.Text)
+			}
+			walk(inline.Children)
+		}
+	}
+	for _, block := range doc.Blocks {
+		walk(block.Inline)
+	}
+	return links
+}
+
+// NodeID returns the node ID of a page title
+func NodeID(title string) string {
+	return strings.ToLower(strings.ReplaceAll(title, " ", "_"))
+}
+
+// WriteJSON writes the graph as JSON
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	return nil
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. Missing pages are
+// drawn dashed and tag edges dotted.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var dot strings.Builder
+
+	dot.WriteString("digraph scrapbox {\n")
+	for _, node := range g.Nodes {
+		attrs := fmt.Sprintf("label=%s", quote(node.Title))
+		if node.Missing {
+			attrs += ", style=dashed"
+		}
+		dot.WriteString(fmt.Sprintf("  %s [%s];\n", quote(node.ID), attrs))
+	}
+	for _, edge := range g.Edges {
+		attrs := ""
+		if edge.Kind == EdgeTag {
+			attrs = " [style=dotted]"
+		}
+		dot.WriteString(fmt.Sprintf("  %s -> %s%s;\n", quote(edge.From), quote(edge.To), attrs))
+	}
+	dot.WriteString("}\n")
+
+	if _, err := io.WriteString(w, dot.String()); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	return nil
+}
+
+// quote quotes a DOT identifier
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func testDocs() []*models.Document {
+	link := func(title string) models.Inline {
+		return models.Inline{Type: models.InlinePageLink, Text: title}
+	}
+	return []*models.Document{
+		{
+			Title: "Go Tips",
+			Tags:  []string{"go"},
+			Blocks: []models.Block{
+				{Type: models.BlockParagraph, Inline: []models.Inline{link("Generics"), link("generics")}},
+				{Type: models.BlockBullet, Inline: []models.Inline{{Type: models.InlineBold, Children: []models.Inline{link("Missing Page")}}}},
+			},
+		},
+		{Title: "Generics"},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(testDocs())
+
+	expectedNodes := []Node{
+		{ID: "go_tips", Title: "Go Tips"},
+		{ID: "generics", Title: "Generics"},
+		{ID: "missing_page", Title: "Missing Page", Missing: true},
+		{ID: "go", Title: "go", Missing: true},
+	}
+	if len(g.Nodes) != len(expectedNodes) {
+		t.Fatalf("Expected %d nodes, got %+v", len(expectedNodes), g.Nodes)
+	}
+	for i, node := range g.Nodes {
+		if node != expectedNodes[i] {
+			t.Errorf("Expected node %+v, got %+v", expectedNodes[i], node)
+		}
+	}
+
+	expectedEdges := []Edge{
+		{From: "go_tips", To: "generics", Kind: EdgeLink},
+		{From: "go_tips", To: "missing_page", Kind: EdgeLink},
+		{From: "go_tips", To: "go", Kind: EdgeTag},
+	}
+	if len(g.Edges) != len(expectedEdges) {
+		t.Fatalf("Expected %d edges, got %+v", len(expectedEdges), g.Edges)
+	}
+	for i, edge := range g.Edges {
+		if edge != expectedEdges[i] {
+			t.Errorf("Expected edge %+v, got %+v", expectedEdges[i], edge)
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Build(testDocs()).WriteDOT(&buf); err != nil {
+		t.Fatalf("WriteDOT() error = %v", err)
+	}
+
+	expected := `digraph scrapbox {
+  "go_tips" [label="Go Tips"];
+  "generics" [label="Generics"];
+  "missing_page" [label="Missing Page", style=dashed];
+  "go" [label="go", style=dashed];
+  "go_tips" -> "generics";
+  "go_tips" -> "missing_page";
+  "go_tips" -> "go" [style=dotted];
+}
+`
+	if buf.String() != expected {
+		t.Errorf("WriteDOT() = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Build(testDocs()).WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	var g Graph
+	if err := json.Unmarshal(buf.Bytes(), &g); err != nil {
+		t.Fatalf("Failed to decode graph: %v", err)
+	}
+	if len(g.Nodes) != 4 || len(g.Edges) != 3 {
+		t.Errorf("Expected 4 nodes and 3 edges, got %+v", g)
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	links := func(titles ...string) []models.Block {
+		var inlines []models.Inline
+		for _, title := range titles {
+			inlines = append(inlines, models.Inline{Type: models.InlinePageLink, Text: title})
+		}
+		return []models.Block{{Type: models.BlockParagraph, Inline: inlines}}
+	}
+	docs := []*models.Document{
+		{Title: "Topic", Blocks: links("C", "D")},
+		{Title: "Index", Blocks: links("A", "B", "C", "Topic", "Missing")},
+		{Title: "A", Blocks: links("Index")},
+		{Title: "B"},
+		{Title: "C"},
+		{Title: "D"},
+	}
+
+	hierarchy := Build(docs).Hierarchy(HierarchyRules{MinLinks: 2, Hubs: []string{"a"}})
+	expected := map[string]string{"A": "Index", "B": "Index", "C": "Index", "Topic": "Index", "D": "Topic"}
+	if !maps.Equal(hierarchy, expected) {
+		t.Errorf("Expected hierarchy %v, got %v", expected, hierarchy)
+	}
+	if depth := Depth(hierarchy, "D"); depth != 2 {
+		t.Errorf("Expected D two hubs deep, got %d", depth)
+	}
+	if depth := Depth(hierarchy, "Index"); depth != 0 {
+		t.Errorf("Expected the top hub at depth 0, got %d", depth)
+	}
+
+	if hierarchy := Build(docs).Hierarchy(HierarchyRules{}); len(hierarchy) != 0 {
+		t.Errorf("Expected no hierarchy without hubs, got %v", hierarchy)
+	}
+}
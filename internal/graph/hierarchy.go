@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+)
+
+// HierarchyRules decides which pages become child pages of the hub pages
+// linking to them
+type HierarchyRules struct {
+	// MinLinks is the number of pages of the export a page must link to for it
+	// to be a hub, 0 for only the pages of Hubs
+	MinLinks int
+	// Hubs are the titles of pages that are hubs however many pages they link to
+	Hubs []string
+}
+
+// Hierarchy returns the title of the hub page each page linked from a hub is a
+// child of. Hubs linking to more pages take their pages first, so a page
+// linked from several hubs goes under the largest. Hubs may be children of
+// other hubs, but never of one of their own descendants.
+func (g *Graph) Hierarchy(rules HierarchyRules) map[string]string {
+	titles := make(map[string]string)
+	for _, node := range g.Nodes {
+		if !node.Missing {
+			titles[node.ID] = node.Title
+		}
+	}
+	links := make(map[string][]string)
+	for _, edge := range g.Edges {
+		if _, ok := titles[edge.To]; ok && edge.Kind == EdgeLink {
+			links[edge.From] = append(links[edge.From], edge.To)
+		}
+	}
+
+	var hubs []string
+	for _, node := range g.Nodes {
+		if _, ok := titles[node.ID]; !ok {
+			continue
+		}
+		explicit := slices.ContainsFunc(rules.Hubs, func(hub string) bool { return NodeID(hub) == node.ID })
+		if explicit || (rules.MinLinks > 0 && len(links[node.ID]) >= rules.MinLinks) {
+			hubs = append(hubs, node.ID)
+		}
+	}
+	slices.SortStableFunc(hubs, func(a, b string) int { return cmp.Compare(len(links[b]), len(links[a])) })
+
+	parents := make(map[string]string)
+	descends := func(id, ancestor string) bool {
+		for ; id != ""; id = parents[id] {
+			if id == ancestor {
+				return true
+			}
+		}
+		return false
+	}
+	for _, hub := range hubs {
+		for _, child := range links[hub] {
+			if _, ok := parents[child]; ok || descends(hub, child) {
+				continue
+			}
+			parents[child] = hub
+		}
+	}
+
+	hierarchy := make(map[string]string, len(parents))
+	for child, hub := range parents {
+		hierarchy[titles[child]] = titles[hub]
+	}
+	return hierarchy
+}
+
+// Depth returns the number of hubs above the page of the title in a hierarchy
+func Depth(hierarchy map[string]string, title string) int {
+	depth := 0
+	for hub, ok := hierarchy[title]; ok; hub, ok = hierarchy[hub] {
+		depth++
+	}
+	return depth
+}
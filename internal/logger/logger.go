@@ -1,11 +1,20 @@
 package logger
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/sirupsen/logrus"
 )
 
 var log = logrus.New()
 
+var (
+	mu sync.RWMutex
+	// defaults are the fields added to every entry
+	defaults = logrus.Fields{}
+)
+
 // Init initializes the logger with the specified level
 func Init(level string) error {
 	// Set formatter
@@ -23,29 +32,124 @@ func Init(level string) error {
 	return nil
 }
 
-// Debug logs a debug message
-func Debug(msg string, fields ...map[string]interface{}) {
+// SetFormat sets the output format: text (default) for people, or json for one
+// JSON object per line, to be ingested by log collectors
+func SetFormat(format string) error {
+	switch format {
+	case "", "text":
+		log.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp: true,
+		})
+	case "json":
+		log.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format %q: must be text or json", format)
+	}
+	return nil
+}
+
+// SetField adds a field to every following entry, such as the ID of the run.
+// Fields given to an entry take precedence.
+func SetField(key string, value interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaults[key] = value
+}
+
+// RemoveField stops adding a field set by SetField
+func RemoveField(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(defaults, key)
+}
+
+// Logger logs entries with a set of fields, such as the title of the page
+// being processed, added to the fields of each call
+type Logger struct {
+	fields map[string]interface{}
+}
+
+// With returns a logger adding the fields to every entry it logs
+func With(fields map[string]interface{}) *Logger {
+	return (&Logger{}).With(fields)
+}
+
+// With returns a logger adding the fields to those of l
+func (l *Logger) With(fields map[string]interface{}) *Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{fields: merged}
+}
+
+// entry returns an entry with the default fields, the fields of the logger and
+// the given fields
+func (l *Logger) entry(fields []map[string]interface{}) *logrus.Entry {
+	mu.RLock()
+	e := log.WithFields(defaults)
+	mu.RUnlock()
+	if len(l.fields) > 0 {
+		e = e.WithFields(l.fields)
+	}
 	if len(fields) > 0 {
-		log.WithFields(fields[0]).Debug(msg)
-	} else {
-		log.Debug(msg)
+		e = e.WithFields(fields[0])
 	}
+	return e
+}
+
+// Debug logs a debug message
+func (l *Logger) Debug(msg string, fields ...map[string]interface{}) {
+	l.entry(fields).Debug(msg)
+}
+
+// Info logs an info message
+func (l *Logger) Info(msg string, fields ...map[string]interface{}) {
+	l.entry(fields).Info(msg)
+}
+
+// Warn logs a warning message
+func (l *Logger) Warn(msg string, fields ...map[string]interface{}) {
+	l.entry(fields).Warn(msg)
+}
+
+// Error logs an error message
+func (l *Logger) Error(msg string, err error, fields ...map[string]interface{}) {
+	l.entry(fields).WithError(err).Error(msg)
+}
+
+// Fatal logs an error message and exits with status 1
+func (l *Logger) Fatal(msg string, err error, fields ...map[string]interface{}) {
+	l.entry(fields).WithError(err).Fatal(msg)
+}
+
+// root is the logger without fields of its own used by the package functions
+var root = &Logger{}
+
+// Debug logs a debug message
+func Debug(msg string, fields ...map[string]interface{}) {
+	root.Debug(msg, fields...)
 }
 
 // Info logs an info message
 func Info(msg string, fields ...map[string]interface{}) {
-	if len(fields) > 0 {
-		log.WithFields(fields[0]).Info(msg)
-	} else {
-		log.Info(msg)
-	}
+	root.Info(msg, fields...)
+}
+
+// Warn logs a warning message
+func Warn(msg string, fields ...map[string]interface{}) {
+	root.Warn(msg, fields...)
 }
 
 // Error logs an error message
 func Error(msg string, err error, fields ...map[string]interface{}) {
-	if len(fields) > 0 {
-		log.WithFields(fields[0]).WithError(err).Error(msg)
-	} else {
-		log.WithError(err).Error(msg)
-	}
+	root.Error(msg, err, fields...)
+}
+
+// Fatal logs an error message and exits with status 1
+func Fatal(msg string, err error, fields ...map[string]interface{}) {
+	root.Fatal(msg, err, fields...)
 }
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// redacted replaces secrets in log entries
+const redacted = "[REDACTED]"
+
+// minSecretLength is the shortest secret replaced, as shorter values, such as
+// placeholders in a test setup, turn up within ordinary words
+const minSecretLength = 8
+
+var (
+	secretsMu sync.RWMutex
+	// secrets are the values never written to the logs, such as API keys
+	secrets []string
+)
+
+// urlPattern matches the URLs in log entries, to strip their credentials
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+func init() {
+	log.AddHook(redactHook{})
+}
+
+// AddSecret makes the logger replace the value, such as an API key or session
+// cookie, wherever it appears in an entry
+func AddSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact returns s with the secrets replaced and the user info and query of
+// URLs removed, as they may hold credentials
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	secretsMu.RUnlock()
+
+	return urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || (u.User == nil && u.RawQuery == "") {
+			return raw
+		}
+		if u.RawQuery != "" {
+			u.RawQuery = redacted
+		}
+		if u.User == nil {
+			return u.String()
+		}
+		u.User = nil
+		return strings.Replace(u.String(), "://", "://"+redacted+"@", 1)
+	})
+}
+
+// redactHook redacts the message and the fields of every entry
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = Redact(v)
+		case error:
+			entry.Data[key] = Redact(v.Error())
+		case fmt.Stringer:
+			entry.Data[key] = Redact(v.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Document is the format independent representation of a converted page.
+// The parser builds it from Scrapbox lines and each output format renders it.
+type Document struct {
+	Title string
+	Tags  []string
+	// Created and Updated are the Unix times of the page
+	Created int64
+	Updated int64
+	// Authors are the Scrapbox user IDs of the writers of the lines, in order of
+	// their first line, so the author of the title comes first
+	Authors []string
+	// Views is the number of times the page was viewed on Scrapbox
+	Views int
+	// Backlinks is the number of pages linking to the page
+	Backlinks int
+	Blocks    []Block
+	// Warnings lists the lines that may not have converted as written
+	Warnings []Warning
+	// History is how the history of the blocks is shown, empty to leave it out
+	History HistoryStyle `json:",omitempty"`
+	// Image is the thumbnail Scrapbox shows for the page, empty if unknown
+	Image string `json:",omitempty"`
+	// Summary is the description Scrapbox shows for the page, empty if unknown
+	Summary string `json:",omitempty"`
+	// TableOfContents leads the document with a table of contents of its headings
+	TableOfContents bool `json:",omitempty"`
+	// Footer ends the document with where it was migrated from, nil for none
+	Footer *Footer `json:",omitempty"`
+	// SourceURL is the page on Scrapbox, empty if unknown
+	SourceURL string `json:",omitempty"`
+}
+
+// Hash returns a hash of the content of the document, to tell whether a page
+// changed since it was uploaded. The update time, views and warnings are left
+// out, as they change without the content changing, and so is the footer,
+// which changes with every export and version of the tool.
+func (d *Document) Hash() string {
+	content := *d
+	content.Updated = 0
+	content.Views = 0
+	content.Warnings = nil
+	content.Footer = nil
+	// Documents hold only plain values, which always encode
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Footer is the metadata of where a document was migrated from
+type Footer struct {
+	// Project is the name of the Scrapbox project, empty if unknown
+	Project string
+	// URL is the page on Scrapbox, empty if the project is unknown
+	URL string
+	// Exported is the Unix time the export was made, 0 if unknown
+	Exported int64
+	// Version is the version of the tool migrating the page
+	Version string
+}
+
+// Inline returns the footer as a line of text, linking to the page on Scrapbox
+func (f *Footer) Inline() []Inline {
+	var parts [][]Inline
+	switch {
+	case f.URL != "":
+		parts = append(parts, []Inline{{Type: InlineText, Text: "Source: "}, {Type: InlineLink, Text: f.Project, URL: f.URL}})
+	case f.Project != "":
+		parts = append(parts, []Inline{{Type: InlineText, Text: "Source: " + f.Project}})
+	}
+	if f.Exported > 0 {
+		exported := time.Unix(f.Exported, 0).UTC().Format("2006-01-02 15:04 UTC")
+		parts = append(parts, []Inline{{Type: InlineText, Text: "Exported: " + exported}})
+	}
+	parts = append(parts, []Inline{{Type: InlineText, Text: "Migrated with scrapbox2notion " + f.Version}})
+
+	var inlines []Inline
+	for i, part := range parts {
+		if i > 0 {
+			inlines = append(inlines, Inline{Type: InlineText, Text: " · "})
+		}
+		inlines = append(inlines, part...)
+	}
+	return []Inline{{Type: InlineItalic, Children: inlines}}
+}
+
+// Warning is a line of a page that may not have converted as written
+type Warning struct {
+	// Line is the 1-based number of the line in the page, the title being line 1
+	Line int
+	// Text is the original text of the line
+	Text string
+	// Reason describes what could not be interpreted
+	Reason string
+}
+
+// HistoryStyle decides how the author and edit time of each line are shown
+type HistoryStyle string
+
+const (
+	// HistoryComment shows the history of a line in a comment after it
+	HistoryComment HistoryStyle = "comment"
+	// HistoryFootnote shows the history of a line in a footnote referred to
+	// from the end of the line
+	HistoryFootnote HistoryStyle = "footnote"
+)
+
+// LineHistory is who last edited a line of a page and when
+type LineHistory struct {
+	// Author is the Scrapbox user ID of the writer of the line
+	Author string
+	// Updated is the Unix time the line was last edited
+	Updated int64
+}
+
+// String returns the author and the edit time in UTC
+func (h *LineHistory) String() string {
+	updated := time.Unix(h.Updated, 0).UTC().Format("2006-01-02 15:04 UTC")
+	if h.Author == "" {
+		return updated
+	}
+	return h.Author + ", " + updated
+}
+
+// BlockType identifies the kind of a Block
+type BlockType string
+
+const (
+	BlockParagraph BlockType = "paragraph"
+	BlockHeading   BlockType = "heading"
+	BlockBullet    BlockType = "bullet"
+	BlockNumbered  BlockType = "numbered"
+	BlockToDo      BlockType = "todo"
+	BlockCode      BlockType = "code"
+	BlockEquation  BlockType = "equation"
+	BlockDivider   BlockType = "divider"
+	BlockEmbed     BlockType = "embed"
+	BlockCallout   BlockType = "callout"
+	BlockQuote     BlockType = "quote"
+	// BlockAttachment is a file linked on a line of its own, such as audio or a PDF
+	BlockAttachment BlockType = "attachment"
+)
+
+// Block is a line level element of a Document
+type Block struct {
+	Type BlockType
+	// Level is the heading level, 1 being the largest
+	Level int
+	// Indent is the nesting depth of list items, 0 for the top level
+	Indent int
+	// Number is the number of a numbered list item
+	Number int
+	// Checked is the state of a to-do item
+	Checked bool
+	// Language is the language of a code block
+	Language string
+	// Text is the content of a code block or the expression of an equation
+	Text string
+	// Embed is the media shown by an embed block
+	Embed *Embed
+	// Attachment is the file shown by an attachment block
+	Attachment *Attachment `json:",omitempty"`
+	// Icon is the emoji of a callout
+	Icon string
+	// Color is the Notion color of a callout, empty for the default
+	Color string
+	// Inline is the formatted text of paragraphs, headings and list items.
+	// Embed blocks hold a link to the media for formats that can't embed it.
+	Inline []Inline
+	// History is who last edited the line of the block and when, in history
+	// mode. Code blocks have the history of their most recently edited line.
+	History *LineHistory `json:",omitempty"`
+}
+
+// EmbedProvider identifies the site of embedded media
+type EmbedProvider string
+
+const (
+	EmbedYouTube EmbedProvider = "youtube"
+	EmbedVimeo   EmbedProvider = "vimeo"
+	EmbedTwitter EmbedProvider = "twitter"
+)
+
+// Embed is media from another site shown within a page
+type Embed struct {
+	Provider EmbedProvider
+	// ID is the ID of the video or post on the site
+	ID string
+	// URL is the URL the media was linked with
+	URL string
+}
+
+// AttachmentKind decides how a linked file is shown
+type AttachmentKind string
+
+const (
+	// AttachmentAudio shows the file in an audio player
+	AttachmentAudio AttachmentKind = "audio"
+	// AttachmentVideo shows the file in a video player
+	AttachmentVideo AttachmentKind = "video"
+	// AttachmentPDF shows the file in a PDF viewer
+	AttachmentPDF AttachmentKind = "pdf"
+	// AttachmentFile shows the file as a download
+	AttachmentFile AttachmentKind = "file"
+	// AttachmentBookmark shows the file as a bookmark of its URL
+	AttachmentBookmark AttachmentKind = "bookmark"
+)
+
+// Attachment is a file linked on a line of its own
+type Attachment struct {
+	Kind AttachmentKind
+	URL  string
+	// Name is the label of the link, or else the file name of the URL
+	Name string
+}
+
+// PlayerURL returns the URL of the player to show a video in an iframe, or an
+// empty string for media without a player such as posts
+func (e *Embed) PlayerURL() string {
+	switch e.Provider {
+	case EmbedYouTube:
+		return "https://www.youtube.com/embed/" + e.ID
+	case EmbedVimeo:
+		return "https://player.vimeo.com/video/" + e.ID
+	}
+	return ""
+}
+
+// InlineType identifies the kind of an Inline
+type InlineType string
+
+const (
+	InlineText     InlineType = "text"
+	InlineBold     InlineType = "bold"
+	InlineItalic   InlineType = "italic"
+	InlineStrike   InlineType = "strike"
+	InlineCode     InlineType = "code"
+	InlineMath     InlineType = "math"
+	InlinePageLink InlineType = "page_link"
+	InlineLink     InlineType = "link"
+	InlineImage    InlineType = "image"
+	// InlineAnchor links to a heading of the same page
+	InlineAnchor InlineType = "anchor"
+)
+
+// Inline is a span of formatted text within a Block
+type Inline struct {
+	Type InlineType
+	// Text is the content of text, code and math spans and the label of links
+	Text string
+	// URL is the target of links and images. For page links it is the
+	// linksLc entry of the linked page, or empty if the page is unknown. For
+	// anchors it is the text of the heading linked to.
+	URL string
+	// Children holds the content of bold, italic and strike spans, and the
+	// image of a link made of an image
+	Children []Inline
+}
+
+// PlainText returns the text of the inline spans without formatting
+func PlainText(inlines []Inline) string {
+	var text string
+	for _, inline := range inlines {
+		switch inline.Type {
+		case InlineBold, InlineItalic, InlineStrike:
+			text += PlainText(inline.Children)
+		case InlineImage:
+			text += inline.URL
+		case InlineLink:
+			if inline.Text != "" {
+				text += inline.Text
+			} else {
+				text += inline.URL
+			}
+		default:
+			text += inline.Text
+		}
+	}
+	return text
+}
+
+// TagGroup is a tag and the titles of the documents tagged with it
+type TagGroup struct {
+	// Tag is the tag name, empty for documents without tags
+	Tag    string
+	Titles []string
+}
+
+// GroupByTag groups the titles of documents by tag. Groups are sorted by tag
+// with untagged documents last, and titles keep the order of docs.
+func GroupByTag(docs []*Document) []TagGroup {
+	titles := make(map[string][]string)
+	var tags []string
+	for _, doc := range docs {
+		docTags := doc.Tags
+		if len(docTags) == 0 {
+			docTags = []string{""}
+		}
+		for _, tag := range docTags {
+			if _, ok := titles[tag]; !ok {
+				tags = append(tags, tag)
+			}
+			titles[tag] = append(titles[tag], doc.Title)
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i] == "" || tags[j] == "" {
+			return tags[j] == "" && tags[i] != ""
+		}
+		return tags[i] < tags[j]
+	})
+
+	groups := make([]TagGroup, 0, len(tags))
+	for _, tag := range tags {
+		groups = append(groups, TagGroup{Tag: tag, Titles: titles[tag]})
+	}
+	return groups
+}
+
+// TagStat is the number of documents with a tag and the tags they have besides
+type TagStat struct {
+	Tag   string `json:"tag"`
+	Pages int    `json:"pages"`
+	// CoTags are the other tags of the documents with the tag, with the number
+	// of documents having both, most frequent first
+	CoTags []TagCount `json:"co_tags,omitempty"`
+}
+
+// TagCount is a tag and a number of documents with it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Pages int    `json:"pages"`
+}
+
+// CountTags counts the documents of every tag and the tags used together with
+// it. Stats are sorted by the number of documents, most frequent first, then by
+// tag, and documents without tags are left out.
+func CountTags(docs []*Document) []TagStat {
+	pages := make(map[string]int)
+	together := make(map[string]map[string]int)
+	for _, doc := range docs {
+		tags := uniqueTags(doc.Tags)
+		for _, tag := range tags {
+			pages[tag]++
+			for _, other := range tags {
+				if other == tag {
+					continue
+				}
+				if together[tag] == nil {
+					together[tag] = make(map[string]int)
+				}
+				together[tag][other]++
+			}
+		}
+	}
+
+	stats := make([]TagStat, 0, len(pages))
+	for tag, n := range pages {
+		stat := TagStat{Tag: tag, Pages: n}
+		for other, m := range together[tag] {
+			stat.CoTags = append(stat.CoTags, TagCount{Tag: other, Pages: m})
+		}
+		sort.Slice(stat.CoTags, func(i, j int) bool {
+			return byCount(stat.CoTags[i].Tag, stat.CoTags[i].Pages, stat.CoTags[j].Tag, stat.CoTags[j].Pages)
+		})
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return byCount(stats[i].Tag, stats[i].Pages, stats[j].Tag, stats[j].Pages)
+	})
+	return stats
+}
+
+// uniqueTags returns the tags without repeats, as a page may have a tag twice
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	unique := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// byCount orders tags by their count, highest first, then by name
+func byCount(a string, m int, b string, n int) bool {
+	if m != n {
+		return m > n
+	}
+	return a < b
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // ScrapboxExport represents the root structure of the Scrapbox export JSON
 type ScrapboxExport struct {
 	Name        string `json:"name"`
@@ -16,8 +22,57 @@ type Page struct {
 	ID      string   `json:"id"`
 	Views   int      `json:"views"`
 	Lines   []Line   `json:"lines"`
-	LinksLc []string `json:"linksLc,omitempty"` // Changed to []string to handle direct string values
+	LinksLc []string `json:"linksLc,omitempty"` // Decoded from strings or link objects by UnmarshalJSON
 	Tags    []string // Extracted from lines starting with #
+	// Image is the thumbnail of the page, in exports with metadata
+	Image string `json:"image,omitempty"`
+	// Descriptions are the first lines of text of the page, in exports with metadata
+	Descriptions []string `json:"descriptions,omitempty"`
+	// Pin orders the pages pinned to the top of the project, 0 for pages not pinned
+	Pin int64 `json:"pin,omitempty"`
+	// Persistent is false for pages holding only their title
+	Persistent bool `json:"persistent,omitempty"`
+	// Linked is the number of pages of the project linking to the page
+	Linked int `json:"linked,omitempty"`
+}
+
+// UnmarshalJSON decodes a page, accepting linksLc as a list of strings or, as
+// some export versions write it, of objects holding the titleLc or title of
+// the linked page
+func (p *Page) UnmarshalJSON(data []byte) error {
+	// page has the fields of Page without its methods, so decoding it doesn't recurse
+	type page Page
+	var raw struct {
+		page
+		LinksLc []json.RawMessage `json:"linksLc,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Page(raw.page)
+	p.LinksLc = nil
+
+	for _, link := range raw.LinksLc {
+		var title string
+		if err := json.Unmarshal(link, &title); err == nil {
+			p.LinksLc = append(p.LinksLc, title)
+			continue
+		}
+		var object struct {
+			TitleLc string `json:"titleLc"`
+			Title   string `json:"title"`
+		}
+		if err := json.Unmarshal(link, &object); err != nil {
+			return fmt.Errorf("invalid linksLc entry %s: %w", link, err)
+		}
+		switch {
+		case object.TitleLc != "":
+			p.LinksLc = append(p.LinksLc, object.TitleLc)
+		case object.Title != "":
+			p.LinksLc = append(p.LinksLc, strings.ToLower(strings.ReplaceAll(object.Title, " ", "_")))
+		}
+	}
+	return nil
 }
 
 // Line represents a line of text in a Scrapbox page
@@ -28,6 +83,17 @@ type Line struct {
 	UserID  string `json:"userId"`
 }
 
+// UnmarshalJSON decodes a line, accepting the plain strings exports without
+// metadata have as lines
+func (l *Line) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &l.Text); err == nil {
+		return nil
+	}
+	// line has the fields of Line without its methods, so decoding it doesn't recurse
+	type line Line
+	return json.Unmarshal(data, (*line)(l))
+}
+
 // NotionIDs holds Notion page and database IDs
 type NotionIDs struct {
 	TagsDatabaseID string
@@ -0,0 +1,165 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// linkAnchors links the anchors of the document to the heading blocks they
+// point at, once the blocks of the document are the children of parent in the
+// page. Blocks only get their IDs when created, so the anchors are created as
+// text and the blocks holding them updated after. Only anchors in top level
+// blocks are linked, and none if the children don't match the document, such
+// as in the pages of other tags linking to the page of the first tag.
+func (c *Client) linkAnchors(ctx context.Context, page notionapi.PageID, parent notionapi.BlockID, doc *models.Document) error {
+	if !hasAnchors(doc.Blocks) {
+		return nil
+	}
+	blocks := c.convertDocumentToBlocks(doc)
+
+	var children []notionapi.Block
+	var cursor notionapi.Cursor
+	for {
+		resp, err := c.client.Block().GetChildren(ctx, parent, &notionapi.Pagination{
+			StartCursor: cursor,
+			PageSize:    maxPageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to link anchors: %w", err)
+		}
+		children = append(children, resp.Results...)
+		if !resp.HasMore {
+			break
+		}
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+	if len(children) != len(blocks) {
+		logger.Debug("Page content differs from the document, skip linking anchors", map[string]interface{}{
+			"title": doc.Title,
+		})
+		return nil
+	}
+	for i, block := range blocks {
+		if children[i].GetType() != block.GetType() {
+			logger.Debug("Page content differs from the document, skip linking anchors", map[string]interface{}{
+				"title": doc.Title,
+			})
+			return nil
+		}
+	}
+
+	// Anchors link to the first heading with their text, as in the document
+	c.anchorTargets = make(map[string]string)
+	pageURL := "https://www.notion.so/" + strings.ReplaceAll(string(page), "-", "")
+	for i, block := range blocks {
+		if text, ok := headingText(block); ok {
+			if _, seen := c.anchorTargets[text]; !seen {
+				c.anchorTargets[text] = pageURL + "#" + strings.ReplaceAll(string(children[i].GetID()), "-", "")
+			}
+		}
+	}
+	linked := c.convertDocumentToBlocks(doc)
+	c.anchorTargets = nil
+
+	for i, block := range linked {
+		before, _ := json.Marshal(blocks[i])
+		after, _ := json.Marshal(block)
+		if string(before) == string(after) {
+			continue
+		}
+		req, ok := blockUpdate(block)
+		if !ok {
+			continue
+		}
+		if _, err := c.client.Block().Update(ctx, children[i].GetID(), req); err != nil {
+			return fmt.Errorf("failed to link anchors: %w", err)
+		}
+	}
+	return nil
+}
+
+// hasAnchors reports whether any of the blocks has an anchor
+func hasAnchors(blocks []models.Block) bool {
+	var anchors func(inlines []models.Inline) bool
+	anchors = func(inlines []models.Inline) bool {
+		for _, inline := range inlines {
+			if inline.Type == models.InlineAnchor || anchors(inline.Children) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, block := range blocks {
+		if anchors(block.Inline) {
+			return true
+		}
+	}
+	return false
+}
+
+// headingText returns the text of a heading block
+func headingText(block notionapi.Block) (string, bool) {
+	switch b := block.(type) {
+	case *notionapi.Heading1Block:
+		return plainText(b.Heading1.RichText), true
+	case *notionapi.Heading2Block:
+		return plainText(b.Heading2.RichText), true
+	case *notionapi.Heading3Block:
+		return plainText(b.Heading3.RichText), true
+	}
+	return "", false
+}
+
+// blockUpdate returns the request updating a block to the text of the given
+// one, leaving its children alone
+func blockUpdate(block notionapi.Block) (*notionapi.BlockUpdateRequest, bool) {
+	switch b := block.(type) {
+	case *notionapi.ParagraphBlock:
+		p := b.Paragraph
+		p.Children = nil
+		return &notionapi.BlockUpdateRequest{Paragraph: &p}, true
+	case *notionapi.Heading1Block:
+		h := b.Heading1
+		h.Children = nil
+		return &notionapi.BlockUpdateRequest{Heading1: &h}, true
+	case *notionapi.Heading2Block:
+		h := b.Heading2
+		h.Children = nil
+		return &notionapi.BlockUpdateRequest{Heading2: &h}, true
+	case *notionapi.Heading3Block:
+		h := b.Heading3
+		h.Children = nil
+		return &notionapi.BlockUpdateRequest{Heading3: &h}, true
+	case *notionapi.BulletedListItemBlock:
+		l := b.BulletedListItem
+		l.Children = nil
+		return &notionapi.BlockUpdateRequest{BulletedListItem: &l}, true
+	case *notionapi.NumberedListItemBlock:
+		l := b.NumberedListItem
+		l.Children = nil
+		return &notionapi.BlockUpdateRequest{NumberedListItem: &l}, true
+	case *notionapi.ToDoBlock:
+		t := b.ToDo
+		t.Children = nil
+		return &notionapi.BlockUpdateRequest{ToDo: &t}, true
+	case *notionapi.ToggleBlock:
+		t := b.Toggle
+		t.Children = nil
+		return &notionapi.BlockUpdateRequest{Toggle: &t}, true
+	case *notionapi.QuoteBlock:
+		q := b.Quote
+		q.Children = nil
+		return &notionapi.BlockUpdateRequest{Quote: &q}, true
+	case *notionapi.CalloutBlock:
+		co := b.Callout
+		co.Children = nil
+		return &notionapi.BlockUpdateRequest{Callout: &co}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,114 @@
+package notion
+
+import (
+	"unicode"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// pageIcon returns the icon for a page: the first emoji in the title,
+// or the configured default emoji if the title has none
+func (c *Client) pageIcon(title string) *notionapi.Icon {
+	if !c.icon {
+		return nil
+	}
+
+	emoji := firstEmoji(title)
+	if emoji == "" {
+		emoji = c.defaultIcon
+	}
+	if emoji == "" {
+		return nil
+	}
+
+	e := notionapi.Emoji(emoji)
+	return &notionapi.Icon{
+		Type:  "emoji",
+		Emoji: &e,
+	}
+}
+
+// pageCover returns the cover for a page: the thumbnail Scrapbox shows for it,
+// or the first image found in the document
+func (c *Client) pageCover(doc *models.Document) *notionapi.Image {
+	if !c.cover {
+		return nil
+	}
+
+	url := doc.Image
+	if url == "" {
+		url = firstImageURL(doc)
+	}
+	if url == "" {
+		return nil
+	}
+	return &notionapi.Image{
+		Type: notionapi.FileTypeExternal,
+		External: &notionapi.FileObject{
+			URL: url,
+		},
+	}
+}
+
+// firstEmoji returns the first emoji in text including any modifiers and joined sequences
+func firstEmoji(text string) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if !isEmoji(r) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) {
+			switch {
+			case runes[end] == 0xFE0F || (runes[end] >= 0x1F3FB && runes[end] <= 0x1F3FF):
+				// Variation selector and skin tone modifiers
+				end++
+			case runes[end] == 0x200D && end+1 < len(runes) && isEmoji(runes[end+1]):
+				// Zero width joiner sequences
+				end += 2
+			default:
+				return string(runes[i:end])
+			}
+		}
+		return string(runes[i:end])
+	}
+	return ""
+}
+
+// isEmoji reports whether r is in one of the common emoji ranges
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F300 && r <= 0x1FAFF: // Pictographs, emoticons, transport and supplemental symbols
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Miscellaneous symbols and dingbats
+		return unicode.Is(unicode.So, r)
+	case r >= 0x1F1E6 && r <= 0x1F1FF: // Regional indicators
+		return true
+	}
+	return false
+}
+
+// firstImageURL returns the URL of the first image in the document
+func firstImageURL(doc *models.Document) string {
+	for _, block := range doc.Blocks {
+		if url := findImageURL(block.Inline); url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// findImageURL returns the URL of the first image in inline spans
+func findImageURL(inlines []models.Inline) string {
+	for _, inline := range inlines {
+		if inline.Type == models.InlineImage {
+			return inline.URL
+		}
+		if url := findImageURL(inline.Children); url != "" {
+			return url
+		}
+	}
+	return ""
+}
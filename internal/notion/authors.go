@@ -0,0 +1,133 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// authorProperty is the people property of the Notion users who wrote a page
+const authorProperty = "Created by"
+
+// WithUserMapping fills the "Created by" property of pages with the Notion users
+// who wrote them. users maps Scrapbox user IDs to the email or ID of Notion users;
+// writers missing from it are left out.
+func WithUserMapping(users map[string]string) Option {
+	return func(c *Client) {
+		c.users = users
+	}
+}
+
+// LoadUserMapping reads a JSON file mapping Scrapbox user IDs to the email or ID
+// of Notion users, such as {"5b50c179c36b730014effd9c": "alice@example.com"}
+func LoadUserMapping(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user mapping: %w", err)
+	}
+	var users map[string]string
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse user mapping %s: %w", path, err)
+	}
+	return users, nil
+}
+
+// pageAuthors returns the Notion users who wrote the document, in the order of
+// their first line
+func (c *Client) pageAuthors(ctx context.Context, doc *models.Document) ([]notionapi.User, error) {
+	if err := c.resolveUsers(ctx); err != nil {
+		return nil, err
+	}
+
+	var authors []notionapi.User
+	seen := make(map[notionapi.UserID]bool)
+	for _, author := range doc.Authors {
+		id, ok := c.userIDs[author]
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		authors = append(authors, notionapi.User{Object: "user", ID: id})
+	}
+	return authors, nil
+}
+
+// resolveUsers looks up the Notion users of the user mapping once, listing the
+// users of the workspace to find those given by email
+func (c *Client) resolveUsers(ctx context.Context) error {
+	if c.userIDs != nil {
+		return nil
+	}
+
+	userIDs := make(map[string]notionapi.UserID)
+	byEmail := make(map[string]string)
+	for scrapboxID, user := range c.users {
+		if strings.Contains(user, "@") {
+			byEmail[strings.ToLower(user)] = scrapboxID
+			continue
+		}
+		userIDs[scrapboxID] = notionapi.UserID(user)
+	}
+
+	if len(byEmail) > 0 {
+		var cursor notionapi.Cursor
+		for {
+			resp, err := c.client.User().List(ctx, &notionapi.Pagination{
+				StartCursor: cursor,
+				PageSize:    maxPageSize,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list Notion users: %w", err)
+			}
+			for _, user := range resp.Results {
+				if user.Person == nil {
+					continue
+				}
+				if scrapboxID, ok := byEmail[strings.ToLower(user.Person.Email)]; ok {
+					userIDs[scrapboxID] = user.ID
+					delete(byEmail, strings.ToLower(user.Person.Email))
+				}
+			}
+			if !resp.HasMore {
+				break
+			}
+			cursor = resp.NextCursor
+		}
+
+		for email := range byEmail {
+			logger.Warn("No Notion user with the email of the user mapping", map[string]interface{}{
+				"email": email,
+			})
+		}
+	}
+
+	c.userIDs = userIDs
+	return nil
+}
+
+// ensureAuthorProperty adds the "Created by" property to a tag database created
+// without it, such as by a run without a user mapping
+func (c *Client) ensureAuthorProperty(ctx context.Context, db *notionapi.Database) error {
+	if _, ok := db.Properties[authorProperty]; ok || c.authorDBs[db.ID] {
+		return nil
+	}
+	_, err := c.client.Database().Update(ctx, notionapi.DatabaseID(db.ID), &notionapi.DatabaseUpdateRequest{
+		Properties: notionapi.PropertyConfigs{
+			authorProperty: notionapi.PeoplePropertyConfig{Type: notionapi.PropertyConfigTypePeople},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s property to tag database: %w", authorProperty, err)
+	}
+	if c.authorDBs == nil {
+		c.authorDBs = make(map[notionapi.ObjectID]bool)
+	}
+	c.authorDBs[db.ID] = true
+	return nil
+}
@@ -0,0 +1,192 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+)
+
+// Notion rejects requests beyond these limits
+const (
+	// maxBlocksPerRequest is the number of blocks Notion accepts in one list of children
+	maxBlocksPerRequest = 100
+	// maxBlockElements is the number of blocks, nested children included,
+	// Notion accepts in one request
+	maxBlockElements = 1000
+	// maxPayloadBytes is the size of request body Notion accepts, less a margin
+	// for what the estimate doesn't count, such as headers of the encoding
+	maxPayloadBytes = 480 * 1000
+	// maxNestingDepth is the levels of blocks Notion accepts in one request:
+	// the blocks and their children, but not the children of those
+	maxNestingDepth = 2
+)
+
+// payloadSize estimates the size of a value in a request body
+func payloadSize(v interface{}) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// deferChildren returns the block as sent in a request and the children to
+// append to it once it is created, when the block with its children doesn't
+// fit in one request: it holds more levels or more children than Notion
+// accepts, or its children exceed the nested block count or payload size.
+// Column lists are sent whole, as Notion takes a column list only with its
+// columns and their blocks, and columns hold no deeper children.
+func deferChildren(block notionapi.Block) (notionapi.Block, []notionapi.Block) {
+	if _, ok := block.(*notionapi.ColumnListBlock); ok {
+		return block, nil
+	}
+	children := blockChildren(block)
+	if len(children) == 0 {
+		return block, nil
+	}
+	if nestingDepth(children) < maxNestingDepth && len(children) <= maxBlocksPerRequest &&
+		countBlocks(children) < maxBlockElements && payloadSize(block) <= maxPayloadBytes {
+		return block, nil
+	}
+	return withoutChildren(block), children
+}
+
+// nestingDepth returns the number of levels of blocks, 0 for no blocks
+func nestingDepth(blocks []notionapi.Block) int {
+	depth := 0
+	for _, block := range blocks {
+		depth = max(depth, 1+nestingDepth(blockChildren(block)))
+	}
+	return depth
+}
+
+// withoutChildren returns a copy of a block without its children
+func withoutChildren(block notionapi.Block) notionapi.Block {
+	var copied notionapi.Block
+	switch b := block.(type) {
+	case *notionapi.ToggleBlock:
+		c := *b
+		c.HasChildren, copied = false, &c
+	case *notionapi.BulletedListItemBlock:
+		c := *b
+		c.HasChildren, copied = false, &c
+	case *notionapi.NumberedListItemBlock:
+		c := *b
+		c.HasChildren, copied = false, &c
+	case *notionapi.ToDoBlock:
+		c := *b
+		c.HasChildren, copied = false, &c
+	case *notionapi.SyncedBlock:
+		c := *b
+		c.HasChildren, copied = false, &c
+	default:
+		return block
+	}
+	setBlockChildren(copied, nil)
+	return copied
+}
+
+// hasDeferredChildren reports whether any of the blocks has children appended
+// after it is created
+func hasDeferredChildren(blocks []notionapi.Block) bool {
+	for _, block := range blocks {
+		if _, deferred := deferChildren(block); len(deferred) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// batchBlocks splits blocks into batches that each fit in one request within
+// the block count, nested block count and payload size limits, counting blocks
+// as sent, without the children deferred by deferChildren. The first batch
+// leaves room for reserved bytes of the rest of its request, such as the
+// properties of the page it creates. A block too large on its own gets a batch
+// of its own, for Notion to report.
+func batchBlocks(blocks []notionapi.Block, reserved int) [][]notionapi.Block {
+	var batches [][]notionapi.Block
+	var batch []notionapi.Block
+	elements, size := 0, reserved
+	for _, block := range blocks {
+		sent, _ := deferChildren(block)
+		blockElements := countBlocks([]notionapi.Block{sent})
+		// Each block after the first is also separated by a comma
+		blockSize := payloadSize(sent) + 1
+		if len(batch) > 0 && (len(batch) == maxBlocksPerRequest ||
+			elements+blockElements > maxBlockElements || size+blockSize > maxPayloadBytes) {
+			batches = append(batches, batch)
+			batch, elements, size = nil, 0, 0
+		}
+		batch = append(batch, block)
+		elements += blockElements
+		size += blockSize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// createPageWithBlocks creates the page of the request with the blocks, as
+// many as fit as its children and the rest appended after. Creating a page
+// doesn't return the IDs of its children, so when the first batch has children
+// to append to its blocks, every batch is appended.
+func (c *Client) createPageWithBlocks(ctx context.Context, req *notionapi.PageCreateRequest, blocks []notionapi.Block) (*notionapi.Page, error) {
+	req.Children = nil
+	batches := batchBlocks(blocks, payloadSize(req))
+	if len(batches) > 0 && !hasDeferredChildren(batches[0]) {
+		req.Children = batches[0]
+		batches = batches[1:]
+	}
+	page, err := c.client.Page().Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range batches {
+		if err := c.appendBatch(ctx, notionapi.BlockID(page.ID), batch); err != nil {
+			return page, fmt.Errorf("failed to append to page: %w", err)
+		}
+	}
+	return page, nil
+}
+
+// appendBlocks appends the blocks to the children of a block, in as many
+// requests as it takes to stay within the limits
+func (c *Client) appendBlocks(ctx context.Context, id notionapi.BlockID, blocks []notionapi.Block) error {
+	for _, batch := range batchBlocks(blocks, 0) {
+		if err := c.appendBatch(ctx, id, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendBatch appends one batch of blocks to the children of a block, then the
+// children deferred from the blocks to the blocks created
+func (c *Client) appendBatch(ctx context.Context, id notionapi.BlockID, batch []notionapi.Block) error {
+	sent := make([]notionapi.Block, len(batch))
+	deferred := make([][]notionapi.Block, len(batch))
+	for i, block := range batch {
+		sent[i], deferred[i] = deferChildren(block)
+	}
+	resp, err := c.client.Block().AppendChildren(ctx, id, &notionapi.AppendBlockChildrenRequest{
+		Children: sent,
+	})
+	if err != nil {
+		return err
+	}
+	for i, children := range deferred {
+		if len(children) == 0 {
+			continue
+		}
+		if resp == nil || i >= len(resp.Results) {
+			return fmt.Errorf("failed to append nested blocks: block %d was not returned", i)
+		}
+		if err := c.appendBlocks(ctx, resp.Results[i].GetID(), children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
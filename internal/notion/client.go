@@ -3,23 +3,137 @@ package notion
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/jomei/notionapi"
 	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
 )
 
 // Client wraps the Notion API client
 type Client struct {
-	client     NotionClient
-	parentID   notionapi.PageID
-	parentType notionapi.ParentType
+	client      NotionClient
+	parentID    notionapi.PageID
+	parentType  notionapi.ParentType
+	urlStyle    URLStyle
+	toggleDepth int
+	icon        bool
+	defaultIcon string
+	cover       bool
+	// foldTagCase matches tag databases regardless of case
+	foldTagCase bool
+	// untaggedDatabase is the title of the database of pages without tags,
+	// empty to create them directly under the parent
+	untaggedDatabase string
+	// columnMarker is the text of lines laying out the list items after them as columns
+	columnMarker string
+	// pages maps the titles of migrated pages to their Notion page
+	pages map[string]notionapi.PageID
+	// dbPages holds the pages of the tag databases by title
+	dbPages map[notionapi.DatabaseID]map[string]notionapi.PageID
+	// created lists the pages and databases created by the client
+	created []CreatedObject
+	// scope caches whether objects found by search are within the parent page
+	scope map[string]bool
+	// httpClient sends the API requests, nil for the notionapi default
+	httpClient *http.Client
+	// rateLimit is the most API requests sent per second on average, 0 for no limit
+	rateLimit float64
+	// rateBurst is the most API requests sent at once ahead of the rate limit
+	rateBurst int
+	// parentDB is the parent when it is a database, as found by DetectParent
+	parentDB *notionapi.Database
+	// users maps Scrapbox user IDs to the email or ID of Notion users
+	users map[string]string
+	// userIDs holds the Notion users of the user mapping once looked up
+	userIDs map[string]notionapi.UserID
+	// authorDBs are the tag databases the author property was added to
+	authorDBs map[notionapi.ObjectID]bool
+	// metrics fills the number properties of the views and backlinks of pages
+	metrics bool
+	// summary fills the text property of the Scrapbox description of pages
+	summary bool
+	// sourceURL fills the URL property of the Scrapbox page of pages
+	sourceURL bool
+	// metricDBs are the databases the metric properties were added to
+	metricDBs map[notionapi.ObjectID]bool
+	// onConflict is what happens to a page created under the parent when a
+	// page with its title is there
+	onConflict ConflictPolicy
+	// tagMode is how the tags of pages are modeled
+	tagMode TagMode
+	// tagHierarchy is how tags with levels are organized
+	tagHierarchy TagHierarchy
+	// parentTagDBs are the databases the parent tag property was added to
+	parentTagDBs map[notionapi.ObjectID]bool
+	// hubs maps the titles of pages created as child pages to the title of their hub page
+	hubs map[string]string
+	// hubChildren holds the child pages of hub and parent tag pages by title,
+	// once listed
+	hubChildren map[notionapi.PageID]map[string]notionapi.PageID
+	// syncedBlocks holds the original synced block of the page of each title in synced mode
+	syncedBlocks map[string]notionapi.BlockID
+	// stats counts the API requests sent by the client
+	stats *statsTransport
+	// tagsDB and pagesDB are the databases of the relation tag mode once found
+	tagsDB  notionapi.DatabaseID
+	pagesDB notionapi.DatabaseID
+	// missingLinks is what links to pages missing from the export become, and
+	// pageExists reports whether the export has the page of a title
+	missingLinks MissingLinkMode
+	pageExists   func(title string) bool
+	// linkTargets maps the titles of missing pages to the Notion pages links to
+	// them mention, empty for titles without a page
+	linkTargets map[string]notionapi.PageID
+	// anchorTargets maps the text of headings to the URL of their block while
+	// the anchors of a page are linked
+	anchorTargets map[string]string
 }
 
-// New creates a new Notion client
-func New() (*Client, error) {
+// Option configures optional behavior of the Client
+type Option func(*Client)
+
+// Options are the settings of a Notion client
+type Options struct {
+	// APIKey is the Notion integration token
+	APIKey string
+	// ParentID is the page under which pages and tag databases are created,
+	// or the database pages are added to
+	ParentID string
+	// ParentType is the type of the parent, page_id or database_id. It is
+	// page_id if empty, and DetectParent sets it from the parent itself.
+	ParentType notionapi.ParentType
+	// HTTPClient sends the API requests, nil for the notionapi default
+	HTTPClient *http.Client
+	// RateLimit is the most API requests sent per second on average, 0 for no limit
+	RateLimit float64
+	// RateBurst is the most API requests sent at once ahead of the rate limit
+	// after the client has been idle, 1 if 0
+	RateBurst int
+	// APIVersion is the Notion-Version header sent with requests, empty for
+	// the version of notionapi
+	APIVersion string
+	// BaseURL is where the API requests are sent instead of api.notion.com, such
+	// as a data residency endpoint or an API mock. Empty for api.notion.com.
+	BaseURL string
+	// Trace logs the method, path, status and payload sizes of every API request
+	// at debug level
+	Trace bool
+}
+
+// New creates a new Notion client configured from the NOTION_API_KEY and
+// NOTION_PARENT_PAGE_ID environment variables. NOTION_PROXY_URL and
+// NOTION_TIMEOUT optionally configure the HTTP client, unless opts set one, and
+// NOTION_API_VERSION and NOTION_BASE_URL the API version and endpoint.
+// NOTION_RATE_LIMIT and NOTION_RATE_BURST set the rate limit, by default the
+// average of three requests per second Notion allows. NOTION_TRACE enables
+// tracing of the API requests.
+func New(opts ...Option) (*Client, error) {
 	apiKey := os.Getenv("NOTION_API_KEY")
 	if apiKey == "" {
 		return nil, fmt.Errorf("NOTION_API_KEY is not set")
@@ -30,26 +144,168 @@ func New() (*Client, error) {
 		return nil, fmt.Errorf("NOTION_PARENT_PAGE_ID is not set")
 	}
 
-	notionClient := notionapi.NewClient(notionapi.Token(apiKey))
-	return &Client{
-		client:     newNotionClientAdapter(notionClient),
-		parentID:   notionapi.PageID(parentID),
-		parentType: "page_id",
-	}, nil
+	httpClient, err := httpClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	trace, err := traceFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimit, rateBurst, err := rateLimitFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithOptions(Options{
+		APIKey:     apiKey,
+		ParentID:   parentID,
+		HTTPClient: httpClient,
+		RateLimit:  rateLimit,
+		RateBurst:  rateBurst,
+		APIVersion: os.Getenv("NOTION_API_VERSION"),
+		BaseURL:    os.Getenv("NOTION_BASE_URL"),
+		Trace:      trace,
+	}, opts...)
+}
+
+// NewWithCredentials creates a new Notion client with an explicit API key and parent page ID
+func NewWithCredentials(apiKey, parentID string, opts ...Option) (*Client, error) {
+	return NewWithOptions(Options{APIKey: apiKey, ParentID: parentID}, opts...)
+}
+
+// NewWithOptions creates a new Notion client with explicit settings, without
+// reading the environment. opts configure the conversion and may override settings.
+func NewWithOptions(options Options, opts ...Option) (*Client, error) {
+	if options.APIKey == "" {
+		return nil, fmt.Errorf("API key is empty")
+	}
+	if options.ParentID == "" {
+		return nil, fmt.Errorf("parent page ID is empty")
+	}
+	parentType := options.ParentType
+	if parentType == "" {
+		parentType = notionapi.ParentTypePageID
+	}
+	if parentType != notionapi.ParentTypePageID && parentType != notionapi.ParentTypeDatabaseID {
+		return nil, fmt.Errorf("invalid parent type %q: must be page_id or database_id", parentType)
+	}
+	if options.RateLimit < 0 {
+		return nil, fmt.Errorf("invalid rate limit %v: must not be negative", options.RateLimit)
+	}
+	if options.RateBurst < 0 {
+		return nil, fmt.Errorf("invalid rate burst %d: must not be negative", options.RateBurst)
+	}
+	var baseURL *url.URL
+	if options.BaseURL != "" {
+		u, err := url.Parse(options.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid base URL %q: must be an http or https URL", options.BaseURL)
+		}
+		baseURL = u
+	}
+
+	// Keep the API key out of the logs, such as in errors quoting a request
+	logger.AddSecret(options.APIKey)
+
+	c := &Client{
+		parentID:     notionapi.PageID(options.ParentID),
+		parentType:   parentType,
+		urlStyle:     URLStylePlain,
+		tagMode:      TagModeDatabases,
+		onConflict:   ConflictSkip,
+		tagHierarchy: TagHierarchyFlat,
+		httpClient:   options.HTTPClient,
+		rateLimit:    options.RateLimit,
+		rateBurst:    options.RateBurst,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	httpClient := c.httpClient
+	if options.Trace {
+		httpClient = tracingClient(httpClient)
+	}
+	httpClient = c.countingClient(spanClient(httpClient))
+	if c.rateLimit > 0 {
+		httpClient = rateLimitedClient(httpClient, c.rateLimit, c.rateBurst)
+	}
+	if baseURL != nil {
+		httpClient = baseURLClient(httpClient, baseURL)
+	}
+	var clientOpts []notionapi.ClientOption
+	if httpClient != nil {
+		clientOpts = append(clientOpts, notionapi.WithHTTPClient(httpClient))
+	}
+	if options.APIVersion != "" {
+		clientOpts = append(clientOpts, notionapi.WithVersion(options.APIVersion))
+	}
+	c.client = newNotionClientAdapter(notionapi.NewClient(notionapi.Token(options.APIKey), clientOpts...))
+	return c, nil
+}
+
+// CreatePage creates a new page in Notion with the title and content of the
+// document. It returns an error wrapping ErrAlreadyExists when the page exists
+// everywhere it would be created, and wraps failures of the Notion API in the
+// other errors of the package they stand for.
+func (c *Client) CreatePage(ctx context.Context, doc *models.Document, tags []string) error {
+	return classifyError(c.createPage(ctx, doc, tags, c.onConflict))
 }
 
-// CreatePage creates a new page in Notion with the given title and markdown content
-func (c *Client) CreatePage(ctx context.Context, title string, content string, tags []string) error {
-	logger.Debug("Creating Notion page", map[string]interface{}{
+// createPage creates the page of the document in the database of each tag,
+// under its hub or under the parent, where the conflict policy decides what
+// happens to a page with the title already there
+func (c *Client) createPage(ctx context.Context, doc *models.Document, tags []string, onConflict ConflictPolicy) error {
+	title := doc.Title
+
+	log := logger.With(map[string]interface{}{
 		"title": title,
 		"tags":  tags,
 	})
+	log.Debug("Creating Notion page")
+
+	if err := c.resolveMissingLinks(ctx, doc, true); err != nil {
+		return err
+	}
+
+	var authors []notionapi.User
+	if c.users != nil {
+		var err error
+		authors, err = c.pageAuthors(ctx, doc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hub := c.hubPage(title); hub != "" {
+		return c.createChildPage(ctx, doc, hub)
+	}
+
+	// Tag databases can only be created under a page, so a parent database
+	// gets every page as a row instead
+	if c.parentType == notionapi.ParentTypeDatabaseID {
+		return c.createParentPage(ctx, doc, tags, authors, onConflict)
+	}
+
+	if c.tagMode == TagModeRelation {
+		return c.createRelationPage(ctx, doc, tags, authors)
+	}
 
 	// Create database for each tag and add page to it
-	for _, tag := range tags {
+	tags = c.fileTags(tags)
+	existing := 0
+	for i, tag := range tags {
+		dbParent, dbTitle, err := c.tagDatabaseParent(ctx, tag, true)
+		if err != nil {
+			return err
+		}
+
 		// Search for existing database with this tag name
 		query := &notionapi.SearchRequest{
-			Query: tag,
+			Query: dbTitle,
 			Filter: notionapi.SearchFilter{
 				Property: "object",
 				Value:    "database",
@@ -61,11 +317,11 @@ func (c *Client) CreatePage(ctx context.Context, title string, content string, t
 			return fmt.Errorf("failed to search for tag database: %w", err)
 		}
 
-		tagDB := validateTagsDatabase(tag, results)
+		tagDB := c.tagDatabase(ctx, results, dbParent, dbTitle)
 
 		// Create database if it doesn't exist
 		if tagDB == nil {
-			tagDB, err = c.createDatabase(ctx, tag, map[string]notionapi.PropertyConfig{
+			properties := map[string]notionapi.PropertyConfig{
 				"Name": notionapi.TitlePropertyConfig{
 					Type:  "title",
 					Title: struct{}{},
@@ -80,20 +336,31 @@ func (c *Client) CreatePage(ctx context.Context, title string, content string, t
 					Type: "date",
 					Date: struct{}{},
 				},
-			})
+			}
+			if c.users != nil {
+				properties[authorProperty] = notionapi.PeoplePropertyConfig{Type: notionapi.PropertyConfigTypePeople}
+			}
+			if c.pageProperties() {
+				for name, config := range c.metricPropertyConfigs() {
+					properties[name] = config
+				}
+			}
+			if c.tagHierarchy == TagHierarchyProperty {
+				properties[parentTagProperty] = parentTagPropertyConfig()
+			}
+			tagDB, err = c.createDatabaseIn(ctx, dbParent, dbTitle, properties)
 			if err != nil {
 				return fmt.Errorf("failed to create tag database: %w", err)
 			}
-			logger.Info("Successfully created tags database", map[string]interface{}{
-				"tags": tags,
-			})
+			c.recordCreated(ObjectDatabase, string(tagDB.ID), tag)
+			log.Info("Successfully created tags database")
 
 			// Confirm database creation
 			var exists bool
 			for i := 0; i < 15; i++ {
 				results, err := c.client.Search().Do(ctx, query)
 				if err == nil && len(results.Results) > 0 {
-					if validateTagsDatabase(tag, results) != nil {
+					if c.tagDatabase(ctx, results, dbParent, dbTitle) != nil {
 						exists = true
 						break
 					}
@@ -103,27 +370,55 @@ func (c *Client) CreatePage(ctx context.Context, title string, content string, t
 			if !exists {
 				return fmt.Errorf("failed to create tag database: %w", err)
 			}
+
+			// A new database has no pages to look up
+			c.trackDatabase(notionapi.DatabaseID(tagDB.ID), make(map[string]notionapi.PageID))
+		} else {
+			if c.users != nil {
+				if err := c.ensureAuthorProperty(ctx, tagDB); err != nil {
+					return err
+				}
+			}
+			if c.pageProperties() {
+				if err := c.ensureMetricProperties(ctx, tagDB); err != nil {
+					return err
+				}
+			}
+			if c.tagHierarchy == TagHierarchyProperty {
+				if err := c.ensureParentTagProperty(ctx, tagDB, parentTagPropertyConfig()); err != nil {
+					return err
+				}
+			}
 		}
 
 		createdAt := notionapi.Date(time.Now())
 
 		// Check if page with same title already exists in the database
-		pageQuery := &notionapi.DatabaseQueryRequest{
-			Filter: notionapi.PropertyFilter{
-				Property: "Name",
-				RichText: &notionapi.TextFilterCondition{
-					Equals: title,
-				},
-			},
-		}
-
-		existingPages, err := c.client.Database().Query(ctx, notionapi.DatabaseID(tagDB.ID), pageQuery)
+		existingPages, err := c.databasePages(ctx, notionapi.DatabaseID(tagDB.ID))
 		if err != nil {
-			return fmt.Errorf("failed to query database for existing pages: %w", err)
+			return err
 		}
 
 		// Only create page if it doesn't already exist
-		if len(existingPages.Results) == 0 {
+		if existingID, ok := existingPages[title]; !ok {
+			children := c.convertDocumentToBlocks(doc)
+			var syncedContent []notionapi.Block
+			if canonicalID, ok := c.pages[title]; ok && i > 0 && (c.tagMode == TagModeCanonical || c.tagMode == TagModeSynced) {
+				// Only the page in the database of the first tag holds the content
+				children = []notionapi.Block{c.createLinkToPageBlock(canonicalID)}
+				if c.tagMode == TagModeSynced {
+					original, err := c.syncedOriginal(ctx, title, canonicalID)
+					if err != nil {
+						return err
+					}
+					if original != "" {
+						children = []notionapi.Block{c.createSyncedBlock(original, nil)}
+					}
+				}
+			} else if c.tagMode == TagModeSynced && len(tags) > 1 {
+				// The content goes in a synced block shown in the pages of the other tags
+				syncedContent, children = children, nil
+			}
 			pageParams := &notionapi.PageCreateRequest{
 				Parent: notionapi.Parent{
 					Type:       "database_id",
@@ -151,11 +446,31 @@ func (c *Client) CreatePage(ctx context.Context, title string, content string, t
 						},
 					},
 				},
-				Children: c.convertMarkdownToBlocks(content),
+				Icon:  c.pageIcon(title),
+				Cover: c.pageCover(doc),
+			}
+			if len(authors) > 0 {
+				pageParams.Properties[authorProperty] = notionapi.PeopleProperty{
+					Type:   notionapi.PropertyTypePeople,
+					People: authors,
+				}
+			}
+			if c.pageProperties() {
+				for name, value := range c.metricProperties(doc) {
+					pageParams.Properties[name] = value
+				}
+			}
+			if c.tagHierarchy == TagHierarchyProperty {
+				if parents := parentTagPropertyValue(tag); parents != nil {
+					pageParams.Properties[parentTagProperty] = parents
+				}
 			}
 
 			var exists bool
-			page, err := c.client.Page().Create(ctx, pageParams)
+			page, err := c.createPageWithBlocks(ctx, pageParams, children)
+			if page != nil {
+				c.recordCreated(ObjectPage, string(page.ID), title)
+			}
 			if err != nil {
 				return fmt.Errorf("failed to create page in tag database: %w", err)
 			}
@@ -170,73 +485,135 @@ func (c *Client) CreatePage(ctx context.Context, title string, content string, t
 			if !exists {
 				return fmt.Errorf("failed to create page in tag database: %w", err)
 			}
-			logger.Info("Successfully created Notion page", map[string]interface{}{
-				"title": title,
-				"tags":  tags,
-			})
+			if len(syncedContent) > 0 {
+				original, err := c.appendSyncedOriginal(ctx, notionapi.PageID(page.ID), syncedContent)
+				if err != nil {
+					return fmt.Errorf("failed to create page in tag database: %w", err)
+				}
+				c.recordSyncedBlock(title, original)
+				if err := c.linkAnchors(ctx, notionapi.PageID(page.ID), original, doc); err != nil {
+					return err
+				}
+			} else if err := c.linkAnchors(ctx, notionapi.PageID(page.ID), notionapi.BlockID(page.ID), doc); err != nil {
+				return err
+			}
+			existingPages[title] = notionapi.PageID(page.ID)
+			c.recordPage(title, notionapi.PageID(page.ID))
+			log.Info("Successfully created Notion page")
 		} else {
-			logger.Info("Notion page has already existed, skip creating", map[string]interface{}{
-				"title": title,
-				"tags":  tags,
-			})
+			c.recordPage(title, existingID)
+			log.Info("Notion page has already existed, skip creating")
+			existing++
 		}
 	}
+	if len(tags) > 0 && existing == len(tags) {
+		return alreadyExists(title)
+	}
 
 	// If no tags, create page in default parent
 	if len(tags) == 0 {
-		req := &notionapi.SearchRequest{
-			Query: title,
-			Filter: notionapi.SearchFilter{
-				Property: "object",
-				Value:    "page",
-			},
-		}
-		resp, err := c.client.Search().Do(ctx, req)
-		if err != nil {
-			return fmt.Errorf("failed to search pages, %w", err)
-		}
-		if len(resp.Results) == 0 {
-			pageParams := &notionapi.PageCreateRequest{
-				Parent: notionapi.Parent{
-					Type:   c.parentType,
-					PageID: c.parentID,
-				},
-				Properties: notionapi.Properties{
-					"title": notionapi.TitleProperty{
-						Title: []notionapi.RichText{
-							{
-								Text: &notionapi.Text{
-									Content: title,
-								},
-							},
-						},
-					},
-				},
-				Children: c.convertMarkdownToBlocks(content),
-			}
+		return c.createParentPage(ctx, doc, nil, authors, onConflict)
+	}
 
-			_, err := c.client.Page().Create(ctx, pageParams)
-			if err != nil {
-				return fmt.Errorf("failed to create page: %w", err)
-			}
-			logger.Info("Successfully created Notion page", map[string]interface{}{
+	return nil
+}
+
+// createParentPage creates a page directly under the parent. A page with the
+// title already there is skipped, renamed or updated by the conflict policy.
+func (c *Client) createParentPage(ctx context.Context, doc *models.Document, tags []string, authors []notionapi.User, onConflict ConflictPolicy) error {
+	title := doc.Title
+
+	parentPages, err := c.parentPages(ctx)
+	if err != nil {
+		return err
+	}
+	if existingID, ok := parentPages[title]; ok {
+		switch onConflict {
+		case ConflictUpdate:
+			return c.updateConflictingPage(ctx, doc, existingID)
+		case ConflictRename:
+			title = uniqueTitle(title, parentPages)
+			logger.Info("Notion page has already existed, creating it with another title", map[string]interface{}{
+				"title":     doc.Title,
+				"new_title": title,
+			})
+		default:
+			c.recordPage(title, existingID)
+			logger.Info("Notion page has already existed, skip creating", map[string]interface{}{
 				"title": title,
 				"tags":  tags,
 			})
+			return alreadyExists(title)
+		}
+	}
+
+	properties := c.parentProperties(title, tags, authors)
+	if c.pageProperties() && c.parentDB != nil {
+		// Only the metric properties the parent database has can be filled
+		for name, value := range c.metricProperties(doc) {
+			if config, ok := c.parentDB.Properties[name]; ok && string(config.GetType()) == string(value.GetType()) {
+				properties[name] = value
+			}
 		}
 	}
+	pageParams := &notionapi.PageCreateRequest{
+		Parent:     c.parent(),
+		Properties: properties,
+		Icon:       c.pageIcon(title),
+		Cover:      c.pageCover(doc),
+	}
 
+	page, err := c.createPageWithBlocks(ctx, pageParams, c.convertDocumentToBlocks(doc))
+	if page != nil {
+		c.recordCreated(ObjectPage, string(page.ID), title)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create page: %w", err)
+	}
+	if err := c.linkAnchors(ctx, notionapi.PageID(page.ID), notionapi.BlockID(page.ID), doc); err != nil {
+		return err
+	}
+	c.recordPage(doc.Title, notionapi.PageID(page.ID))
+	parentPages[title] = notionapi.PageID(page.ID)
+	logger.Info("Successfully created Notion page", map[string]interface{}{
+		"title": title,
+		"tags":  tags,
+	})
 	return nil
 }
 
+// parentPages returns the pages directly under the parent by title: the rows
+// of a parent database or the child pages of a parent page. Unlike a search,
+// this matches whole titles and leaves out pages elsewhere in the workspace.
+func (c *Client) parentPages(ctx context.Context) (map[string]notionapi.PageID, error) {
+	if c.parentType == notionapi.ParentTypeDatabaseID {
+		return c.databasePages(ctx, notionapi.DatabaseID(c.parentID))
+	}
+	return c.childPages(ctx, c.parentID)
+}
+
+// recordPage remembers the Notion page of a title, keeping the first one for
+// pages added to several tag databases
+func (c *Client) recordPage(title string, id notionapi.PageID) {
+	if c.pages == nil {
+		c.pages = make(map[string]notionapi.PageID)
+	}
+	if _, ok := c.pages[title]; !ok {
+		c.pages[title] = id
+	}
+}
+
 // createDatabase creates a new database with the given name and properties
 func (c *Client) createDatabase(ctx context.Context, name string, properties notionapi.PropertyConfigs) (*notionapi.Database, error) {
+	return c.createDatabaseIn(ctx, c.parent(), name, properties)
+}
+
+// createDatabaseIn creates a new database with the given name and properties
+// in a page other than the parent, such as the page of a parent tag
+func (c *Client) createDatabaseIn(ctx context.Context, parent notionapi.Parent, name string, properties notionapi.PropertyConfigs) (*notionapi.Database, error) {
 	// Create new database
 	dbParams := &notionapi.DatabaseCreateRequest{
-		Parent: notionapi.Parent{
-			Type:   c.parentType,
-			PageID: c.parentID,
-		},
+		Parent: parent,
 		Title: []notionapi.RichText{
 			{
 				Text: &notionapi.Text{
@@ -256,61 +633,171 @@ func (c *Client) createDatabase(ctx context.Context, name string, properties not
 	return db, nil
 }
 
-// convertMarkdownToBlocks converts markdown content to Notion blocks
-func (c *Client) convertMarkdownToBlocks(content string) []notionapi.Block {
-	var blocks []notionapi.Block
-	lines := strings.Split(content, "\n")
+// convertDocumentToBlocks converts a document to Notion blocks. Notion blocks
+// have neither comments nor footnotes, so the history of lines is added to the
+// end of their text, or listed under a divider after the last block with a
+// numbered reference from each line. The footer of the document comes last.
+func (c *Client) convertDocumentToBlocks(doc *models.Document) []notionapi.Block {
+	var result []notionapi.Block
+	if doc.TableOfContents {
+		result = append(result, c.createTableOfContentsBlock())
+	}
+	if doc.History == "" {
+		result = append(result, c.convertBlocks(doc.Blocks)...)
+		return append(result, c.footerBlocks(doc)...)
+	}
 
-	for i := 0; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
-		if line == "" {
-			continue
+	blocks := make([]models.Block, len(doc.Blocks))
+	var notes []string
+	for i, block := range doc.Blocks {
+		if block.History != nil && len(block.Inline) > 0 && block.Type != models.BlockEmbed && block.Type != models.BlockAttachment {
+			note := " (" + block.History.String() + ")"
+			if doc.History == models.HistoryFootnote {
+				notes = append(notes, block.History.String())
+				note = fmt.Sprintf(" [%d]", len(notes))
+			}
+			block.Inline = append(slices.Clip(block.Inline), models.Inline{
+				Type:     models.InlineItalic,
+				Children: []models.Inline{{Type: models.InlineText, Text: note}},
+			})
 		}
+		blocks[i] = block
+	}
 
-		// Handle headings
-		if strings.HasPrefix(line, "# ") {
-			blocks = append(blocks, c.createHeadingBlock(line[2:], 1))
-			continue
+	result = append(result, c.convertBlocks(blocks)...)
+	if len(notes) > 0 {
+		result = append(result, c.createDividerBlock())
+		for i, note := range notes {
+			result = append(result, c.createParagraphBlock([]models.Inline{{
+				Type:     models.InlineItalic,
+				Children: []models.Inline{{Type: models.InlineText, Text: fmt.Sprintf("[%d] %s", i+1, note)}},
+			}}))
 		}
-		if strings.HasPrefix(line, "## ") {
-			blocks = append(blocks, c.createHeadingBlock(line[3:], 2))
+	}
+	return append(result, c.footerBlocks(doc)...)
+}
+
+// footerBlocks converts the footer of a document to a gray callout ending the
+// page, or returns nothing for a document without one
+func (c *Client) footerBlocks(doc *models.Document) []notionapi.Block {
+	if doc.Footer == nil {
+		return nil
+	}
+	return []notionapi.Block{c.createCalloutBlock(doc.Footer.Inline(), "ℹ️", "gray_background")}
+}
+
+// convertBlocks converts document blocks to Notion blocks
+func (c *Client) convertBlocks(blocks []models.Block) []notionapi.Block {
+	var result []notionapi.Block
+
+	for i := 0; i < len(blocks); i++ {
+		block := blocks[i]
+
+		if columns, n, ok := c.columnList(blocks, i); ok {
+			result = append(result, columns)
+			i += n
 			continue
 		}
 
-		// Handle code blocks
-		if strings.HasPrefix(line, "```") {
-			codeContent := []string{}
-			i++
-			for i < len(lines) && !strings.HasPrefix(lines[i], "```") {
-				codeContent = append(codeContent, lines[i])
-				i++
+		switch block.Type {
+		case models.BlockHeading:
+			result = append(result, c.createHeadingBlock(block.Inline, block.Level))
+
+		case models.BlockCode:
+			result = append(result, c.createCodeBlock(block.Text, block.Language))
+
+		case models.BlockEquation:
+			result = append(result, c.createEquationBlock(block.Text))
+
+		case models.BlockDivider:
+			result = append(result, c.createDividerBlock())
+
+		case models.BlockEmbed:
+			result = append(result, c.createEmbedBlock(block.Embed))
+
+		case models.BlockAttachment:
+			result = append(result, c.createAttachmentBlock(block.Attachment))
+
+		case models.BlockCallout:
+			result = append(result, c.createCalloutBlock(block.Inline, block.Icon, block.Color))
+
+		case models.BlockQuote:
+			result = append(result, c.createQuoteBlock(block.Inline))
+
+		case models.BlockToDo:
+			children, n := c.nestedItems(blocks, i)
+			result = append(result, c.createToDoBlock(block.Inline, block.Checked, children))
+			i += n
+
+		case models.BlockNumbered:
+			children, n := c.nestedItems(blocks, i)
+			result = append(result, c.createNumberedListBlock(block.Inline, children))
+			i += n
+
+		case models.BlockBullet:
+			// Collapse outlines nested deeper than the toggle depth into toggle blocks
+			if c.toggleDepth > 0 && block.Indent == c.toggleDepth-1 {
+				var children []models.Block
+				for i+1 < len(blocks) && isListBlock(blocks[i+1]) && blocks[i+1].Indent >= c.toggleDepth {
+					children = append(children, blocks[i+1])
+					i++
+				}
+				if len(children) > 0 {
+					result = append(result, c.createToggleBlock(block.Inline, c.convertBlocks(children)))
+					continue
+				}
 			}
-			blocks = append(blocks, c.createCodeBlock(strings.Join(codeContent, "\n")))
-			continue
-		}
+			children, n := c.nestedItems(blocks, i)
+			result = append(result, c.createBulletedListBlock(block.Inline, children))
+			i += n
 
-		// Handle bullet points
-		if strings.HasPrefix(line, "- ") {
-			blocks = append(blocks, c.createBulletedListBlock(line[2:]))
-			continue
-		}
+		default:
+			// Handle lines consisting of a single image, possibly linking somewhere
+			if image, link, ok := imageOnly(block.Inline); ok {
+				result = append(result, c.createImageBlock(image, link))
+				continue
+			}
 
-		// Handle regular text
-		blocks = append(blocks, c.createParagraphBlock(line))
+			// Handle lines consisting of a single URL
+			if url, ok := bareURL(block.Inline); ok {
+				switch c.urlStyle {
+				case URLStyleBookmark:
+					result = append(result, c.createBookmarkBlock(url))
+					continue
+				case URLStyleLink:
+					result = append(result, c.createLinkParagraphBlock(url))
+					continue
+				}
+			}
+
+			// Handle regular text
+			result = append(result, c.createParagraphBlock(block.Inline))
+		}
 	}
 
-	return blocks
+	return result
 }
 
-// createHeadingBlock creates a heading block with the specified level
-func (c *Client) createHeadingBlock(text string, level int) notionapi.Block {
-	richText := []notionapi.RichText{
-		{
-			Text: &notionapi.Text{
-				Content: text,
-			},
-		},
+// nestedItems converts the list items nested under the list item at
+// blocks[start] to its children, returning the number of items they hold
+func (c *Client) nestedItems(blocks []models.Block, start int) ([]notionapi.Block, int) {
+	var nested []models.Block
+	for _, block := range blocks[start+1:] {
+		if !isListBlock(block) || block.Indent <= blocks[start].Indent {
+			break
+		}
+		nested = append(nested, block)
+	}
+	if len(nested) == 0 {
+		return nil, 0
 	}
+	return c.convertBlocks(nested), len(nested)
+}
+
+// createHeadingBlock creates a heading block with the specified level.
+// Notion has three heading levels, so smaller headings become heading 3.
+func (c *Client) createHeadingBlock(inlines []models.Inline, level int) notionapi.Block {
+	richText := c.createRichText(inlines)
 
 	switch level {
 	case 1:
@@ -346,8 +833,8 @@ func (c *Client) createHeadingBlock(text string, level int) notionapi.Block {
 	}
 }
 
-// createCodeBlock creates a code block
-func (c *Client) createCodeBlock(content string) notionapi.Block {
+// createCodeBlock creates a code block with the given markdown fence language
+func (c *Client) createCodeBlock(content string, language string) notionapi.Block {
 	return &notionapi.CodeBlock{
 		BasicBlock: notionapi.BasicBlock{
 			Object: "block",
@@ -361,32 +848,111 @@ func (c *Client) createCodeBlock(content string) notionapi.Block {
 					},
 				},
 			},
-			Language: "plain text",
+			Language: notionCodeLanguage(language),
 		},
 	}
 }
 
-// createBulletedListBlock creates a bulleted list item block
-func (c *Client) createBulletedListBlock(text string) notionapi.Block {
+// createBulletedListBlock creates a bulleted list item block holding the
+// items nested under it
+func (c *Client) createBulletedListBlock(inlines []models.Inline, children []notionapi.Block) notionapi.Block {
 	return &notionapi.BulletedListItemBlock{
 		BasicBlock: notionapi.BasicBlock{
-			Object: "block",
-			Type:   notionapi.BlockTypeBulletedListItem,
+			Object:      "block",
+			Type:        notionapi.BlockTypeBulletedListItem,
+			HasChildren: len(children) > 0,
 		},
 		BulletedListItem: notionapi.ListItem{
-			RichText: []notionapi.RichText{
-				{
-					Text: &notionapi.Text{
-						Content: text,
-					},
-				},
+			RichText: c.createRichText(inlines),
+			Children: children,
+		},
+	}
+}
+
+// createNumberedListBlock creates a numbered list item block holding the items
+// nested under it
+func (c *Client) createNumberedListBlock(inlines []models.Inline, children []notionapi.Block) notionapi.Block {
+	return &notionapi.NumberedListItemBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object:      "block",
+			Type:        notionapi.BlockTypeNumberedListItem,
+			HasChildren: len(children) > 0,
+		},
+		NumberedListItem: notionapi.ListItem{
+			RichText: c.createRichText(inlines),
+			Children: children,
+		},
+	}
+}
+
+// createToggleBlock creates a toggle block holding the given children
+func (c *Client) createToggleBlock(inlines []models.Inline, children []notionapi.Block) notionapi.Block {
+	return &notionapi.ToggleBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object:      "block",
+			Type:        notionapi.BlockTypeToggle,
+			HasChildren: len(children) > 0,
+		},
+		Toggle: notionapi.Toggle{
+			RichText: c.createRichText(inlines),
+			Children: children,
+		},
+	}
+}
+
+// createToDoBlock creates a to-do block with the given checked state holding
+// the items nested under it
+func (c *Client) createToDoBlock(inlines []models.Inline, checked bool, children []notionapi.Block) notionapi.Block {
+	return &notionapi.ToDoBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object:      "block",
+			Type:        notionapi.BlockTypeToDo,
+			HasChildren: len(children) > 0,
+		},
+		ToDo: notionapi.ToDo{
+			RichText: c.createRichText(inlines),
+			Checked:  checked,
+			Children: children,
+		},
+	}
+}
+
+// createImageBlock creates an image block showing an external image. Images
+// linking somewhere get the link as their caption, as Notion images can't link.
+func (c *Client) createImageBlock(url, link string) notionapi.Block {
+	block := &notionapi.ImageBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeImage,
+		},
+		Image: notionapi.Image{
+			Type: notionapi.FileTypeExternal,
+			External: &notionapi.FileObject{
+				URL: url,
 			},
 		},
 	}
+	if link != "" {
+		block.Image.Caption = []notionapi.RichText{styledRichText(link, link, notionapi.Annotations{})}
+	}
+	return block
 }
 
-// createParagraphBlock creates a paragraph block
-func (c *Client) createParagraphBlock(text string) notionapi.Block {
+// createBookmarkBlock creates a bookmark block with a preview of the URL
+func (c *Client) createBookmarkBlock(url string) notionapi.Block {
+	return &notionapi.BookmarkBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeBookmark,
+		},
+		Bookmark: notionapi.Bookmark{
+			URL: url,
+		},
+	}
+}
+
+// createLinkParagraphBlock creates a paragraph block whose text links to the URL
+func (c *Client) createLinkParagraphBlock(url string) notionapi.Block {
 	return &notionapi.ParagraphBlock{
 		BasicBlock: notionapi.BasicBlock{
 			Object: "block",
@@ -396,7 +962,10 @@ func (c *Client) createParagraphBlock(text string) notionapi.Block {
 			RichText: []notionapi.RichText{
 				{
 					Text: &notionapi.Text{
-						Content: text,
+						Content: url,
+						Link: &notionapi.Link{
+							Url: url,
+						},
 					},
 				},
 			},
@@ -404,13 +973,318 @@ func (c *Client) createParagraphBlock(text string) notionapi.Block {
 	}
 }
 
-func validateTagsDatabase(tag string, results *notionapi.SearchResponse) *notionapi.Database {
+// createLinkToPageBlock creates a block linking to another page
+func (c *Client) createLinkToPageBlock(id notionapi.PageID) notionapi.Block {
+	return &notionapi.LinkToPageBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeLinkToPage,
+		},
+		LinkToPage: notionapi.LinkToPage{
+			Type:   notionapi.BlockType("page_id"),
+			PageID: id,
+		},
+	}
+}
+
+// createTableOfContentsBlock creates a table of contents of the headings of the page
+func (c *Client) createTableOfContentsBlock() notionapi.Block {
+	return &notionapi.TableOfContentsBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeTableOfContents,
+		},
+	}
+}
+
+// createDividerBlock creates a divider block
+func (c *Client) createDividerBlock() notionapi.Block {
+	return &notionapi.DividerBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeDivider,
+		},
+	}
+}
+
+// createQuoteBlock creates a quote block
+func (c *Client) createQuoteBlock(inlines []models.Inline) notionapi.Block {
+	return &notionapi.QuoteBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeQuote,
+		},
+		Quote: notionapi.Quote{
+			RichText: c.createRichText(inlines),
+		},
+	}
+}
+
+// createCalloutBlock creates a callout block with an emoji icon and color
+func (c *Client) createCalloutBlock(inlines []models.Inline, icon, color string) notionapi.Block {
+	block := &notionapi.CalloutBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeCallout,
+		},
+		Callout: notionapi.Callout{
+			RichText: c.createRichText(inlines),
+			Color:    color,
+		},
+	}
+	if icon != "" {
+		emoji := notionapi.Emoji(icon)
+		block.Callout.Icon = &notionapi.Icon{
+			Type:  "emoji",
+			Emoji: &emoji,
+		}
+	}
+	return block
+}
+
+// createEmbedBlock creates a video block for videos Notion can play and an
+// embed block for other media such as posts
+func (c *Client) createEmbedBlock(embed *models.Embed) notionapi.Block {
+	if embed.Provider == models.EmbedYouTube || embed.Provider == models.EmbedVimeo {
+		return &notionapi.VideoBlock{
+			BasicBlock: notionapi.BasicBlock{
+				Object: "block",
+				Type:   notionapi.BlockTypeVideo,
+			},
+			Video: notionapi.Video{
+				Type: notionapi.FileTypeExternal,
+				External: &notionapi.FileObject{
+					URL: embed.URL,
+				},
+			},
+		}
+	}
+	return &notionapi.EmbedBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeEmbed,
+		},
+		Embed: notionapi.Embed{
+			URL: embed.URL,
+		},
+	}
+}
+
+// createAttachmentBlock creates an audio, video, PDF or file block showing the
+// attachment from its URL, or a bookmark of the URL
+func (c *Client) createAttachmentBlock(attachment *models.Attachment) notionapi.Block {
+	external := &notionapi.FileObject{URL: attachment.URL}
+	switch attachment.Kind {
+	case models.AttachmentAudio:
+		// notionapi has no constant for audio blocks
+		return &notionapi.AudioBlock{
+			BasicBlock: notionapi.BasicBlock{Object: "block", Type: notionapi.BlockType("audio")},
+			Audio:      notionapi.Audio{Type: notionapi.FileTypeExternal, External: external},
+		}
+	case models.AttachmentVideo:
+		return &notionapi.VideoBlock{
+			BasicBlock: notionapi.BasicBlock{Object: "block", Type: notionapi.BlockTypeVideo},
+			Video:      notionapi.Video{Type: notionapi.FileTypeExternal, External: external},
+		}
+	case models.AttachmentPDF:
+		return &notionapi.PdfBlock{
+			BasicBlock: notionapi.BasicBlock{Object: "block", Type: notionapi.BlockTypePdf},
+			Pdf:        notionapi.Pdf{Type: notionapi.FileTypeExternal, External: external},
+		}
+	case models.AttachmentFile:
+		// The name of external files is shown as their caption
+		return &notionapi.FileBlock{
+			BasicBlock: notionapi.BasicBlock{Object: "block", Type: notionapi.BlockTypeFile},
+			File: notionapi.BlockFile{
+				Caption:  []notionapi.RichText{{Text: &notionapi.Text{Content: attachment.Name}}},
+				Type:     notionapi.FileTypeExternal,
+				External: external,
+			},
+		}
+	}
+	return c.createBookmarkBlock(attachment.URL)
+}
+
+// createEquationBlock creates an equation block from a LaTeX expression
+func (c *Client) createEquationBlock(expression string) notionapi.Block {
+	return &notionapi.EquationBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeEquation,
+		},
+		Equation: notionapi.Equation{
+			Expression: expression,
+		},
+	}
+}
+
+// createParagraphBlock creates a paragraph block
+func (c *Client) createParagraphBlock(inlines []models.Inline) notionapi.Block {
+	return &notionapi.ParagraphBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeParagraph,
+		},
+		Paragraph: notionapi.Paragraph{
+			RichText: c.createRichText(inlines),
+		},
+	}
+}
+
+// isListBlock reports whether block is a list item that can be nested
+func isListBlock(block models.Block) bool {
+	switch block.Type {
+	case models.BlockBullet, models.BlockNumbered, models.BlockToDo:
+		return true
+	}
+	return false
+}
+
+// imageOnly returns the image URL of inline spans consisting of a single image,
+// and the URL the image links to if it is wrapped in a link
+func imageOnly(inlines []models.Inline) (string, string, bool) {
+	if len(inlines) != 1 {
+		return "", "", false
+	}
+	switch inline := inlines[0]; {
+	case inline.Type == models.InlineImage:
+		return inline.URL, "", true
+	case inline.Type == models.InlineLink && len(inline.Children) == 1 && inline.Children[0].Type == models.InlineImage:
+		return inline.Children[0].URL, inline.URL, true
+	}
+	return "", "", false
+}
+
+// bareURL returns the URL of inline spans consisting of a single http(s) URL
+func bareURL(inlines []models.Inline) (string, bool) {
+	if len(inlines) != 1 {
+		return "", false
+	}
+
+	var url string
+	switch inlines[0].Type {
+	case models.InlineText:
+		url = strings.TrimSpace(inlines[0].Text)
+	case models.InlineLink:
+		if inlines[0].Text != "" || len(inlines[0].Children) > 0 {
+			return "", false
+		}
+		url = inlines[0].URL
+	default:
+		return "", false
+	}
+
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", false
+	}
+	return url, !strings.ContainsAny(url, " \t")
+}
+
+// validateTagsDatabase returns the database of the search results whose title is
+// the tag, comparing whole titles with normalized white space and optionally
+// regardless of case
+func validateTagsDatabase(tag string, results *notionapi.SearchResponse, foldCase bool) *notionapi.Database {
 	for _, result := range results.Results {
 		if db, ok := result.(*notionapi.Database); ok {
-			if len(db.Title) > 0 && db.Title[0].Text != nil && db.Title[0].Text.Content == tag {
+			if titlesMatch(plainText(db.Title), tag, foldCase) {
 				return db
 			}
 		}
 	}
 	return nil
 }
+
+// titlesMatch reports whether two titles are the same once white space is normalized
+func titlesMatch(a, b string, foldCase bool) bool {
+	a, b = normalizeTitle(a), normalizeTitle(b)
+	if foldCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// normalizeTitle trims a title and collapses runs of white space into a single space
+func normalizeTitle(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// plainText returns the text of every segment of a rich text
+func plainText(richText []notionapi.RichText) string {
+	var text strings.Builder
+	for _, rt := range richText {
+		switch {
+		case rt.PlainText != "":
+			text.WriteString(rt.PlainText)
+		case rt.Text != nil:
+			text.WriteString(rt.Text.Content)
+		}
+	}
+	return text.String()
+}
+
+// ConvertDocument converts a document to Notion blocks without a client
+func ConvertDocument(doc *models.Document, opts ...Option) []notionapi.Block {
+	c := &Client{urlStyle: URLStylePlain}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.convertDocumentToBlocks(doc)
+}
+
+// CountBlocks returns the number of Notion blocks the document converts to,
+// including nested children
+func CountBlocks(doc *models.Document, opts ...Option) int {
+	return countBlocks(ConvertDocument(doc, opts...))
+}
+
+// countBlocks counts blocks and their toggle and column children recursively
+func countBlocks(blocks []notionapi.Block) int {
+	count := len(blocks)
+	for _, block := range blocks {
+		count += countBlocks(blockChildren(block))
+	}
+	return count
+}
+
+// blockChildren returns the children of the kinds of blocks the client
+// creates with children
+func blockChildren(block notionapi.Block) []notionapi.Block {
+	switch b := block.(type) {
+	case *notionapi.ToggleBlock:
+		return b.Toggle.Children
+	case *notionapi.BulletedListItemBlock:
+		return b.BulletedListItem.Children
+	case *notionapi.NumberedListItemBlock:
+		return b.NumberedListItem.Children
+	case *notionapi.ToDoBlock:
+		return b.ToDo.Children
+	case *notionapi.ColumnListBlock:
+		return b.ColumnList.Children
+	case *notionapi.ColumnBlock:
+		return b.Column.Children
+	case *notionapi.SyncedBlock:
+		return b.SyncedBlock.Children
+	}
+	return nil
+}
+
+// setBlockChildren sets the children of a block blockChildren knows, such as
+// those fetched separately from the block
+func setBlockChildren(block notionapi.Block, children []notionapi.Block) {
+	switch b := block.(type) {
+	case *notionapi.ToggleBlock:
+		b.Toggle.Children = children
+	case *notionapi.BulletedListItemBlock:
+		b.BulletedListItem.Children = children
+	case *notionapi.NumberedListItemBlock:
+		b.NumberedListItem.Children = children
+	case *notionapi.ToDoBlock:
+		b.ToDo.Children = children
+	case *notionapi.ColumnListBlock:
+		b.ColumnList.Children = children
+	case *notionapi.ColumnBlock:
+		b.Column.Children = children
+	case *notionapi.SyncedBlock:
+		b.SyncedBlock.Children = children
+	}
+}
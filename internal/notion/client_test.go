@@ -3,14 +3,31 @@ package notion
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/models"
 	"github.com/takak2166/scrapbox2notion/internal/notion/mock_notion"
 )
 
+// text returns inline spans of plain text
+func text(s string) []models.Inline {
+	return []models.Inline{{Type: models.InlineText, Text: s}}
+}
+
+// document returns a document made of the given blocks
+func document(blocks ...models.Block) *models.Document {
+	return &models.Document{Title: "Page", Blocks: blocks}
+}
+
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -25,6 +42,25 @@ func TestNew(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "Proxy and timeout",
+			envVars: map[string]string{
+				"NOTION_API_KEY":        "test_key",
+				"NOTION_PARENT_PAGE_ID": "test_page_id",
+				"NOTION_PROXY_URL":      "http://proxy.example.com:8080",
+				"NOTION_TIMEOUT":        "30s",
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid timeout",
+			envVars: map[string]string{
+				"NOTION_API_KEY":        "test_key",
+				"NOTION_PARENT_PAGE_ID": "test_page_id",
+				"NOTION_TIMEOUT":        "30",
+			},
+			expectError: true,
+		},
 		{
 			name: "Missing API key",
 			envVars: map[string]string{
@@ -68,6 +104,176 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewWithOptions(t *testing.T) {
+	os.Clearenv()
+
+	client, err := NewWithOptions(Options{APIKey: "key", ParentID: "parent", RateLimit: 3}, WithToggleDepth(2))
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if client.parentType != notionapi.ParentTypePageID || client.rateLimit != 3 || client.toggleDepth != 2 {
+		t.Errorf("Expected the settings and options to be applied, got %+v", client)
+	}
+
+	invalid := []Options{
+		{ParentID: "parent"},
+		{APIKey: "key"},
+		{APIKey: "key", ParentID: "parent", ParentType: "workspace"},
+		{APIKey: "key", ParentID: "parent", RateLimit: -1},
+		{APIKey: "key", ParentID: "parent", RateBurst: -1},
+		{APIKey: "key", ParentID: "parent", BaseURL: "api.example.com"},
+	}
+	for _, options := range invalid {
+		if _, err := NewWithOptions(options); err == nil {
+			t.Errorf("Expected error for %+v, got nil", options)
+		}
+	}
+}
+
+func TestNewWithOptionsEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mock/v1/users/me" || r.Header.Get("Notion-Version") != "2025-01-01" {
+			t.Errorf("Expected the request at the base URL with the API version, got %s with %q", r.URL.Path, r.Header.Get("Notion-Version"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object": "user", "id": "bot", "name": "Importer"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewWithOptions(Options{APIKey: "key", ParentID: "parent", APIVersion: "2025-01-01", BaseURL: server.URL + "/mock"})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	me, err := client.client.User().Me(context.Background())
+	if err != nil {
+		t.Fatalf("Me() error = %v", err)
+	}
+	if me.Name != "Importer" {
+		t.Errorf("Expected the user of the mock, got %+v", me)
+	}
+	if stats := client.APIStats(); stats.Calls != 1 || stats.Retries != 0 || stats.Failures != 0 {
+		t.Errorf("Expected one successful call to be counted, got %+v", stats)
+	}
+}
+
+func TestTracingClient(t *testing.T) {
+	statuses := []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK}
+	base := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+	})}
+
+	client := tracingClient(base)
+	transport := client.Transport.(*traceTransport)
+	const url = "https://api.notion.com/v1/pages"
+	for retry := 0; retry < 3; retry++ {
+		if got := transport.retries["POST "+url]; got != retry {
+			t.Errorf("Expected attempt %d to be retry %d, got %d", retry, retry, got)
+		}
+		resp, err := client.Post(url, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if len(transport.retries) != 0 {
+		t.Errorf("Expected the retries to be forgotten after success, got %v", transport.retries)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRateLimitedClient(t *testing.T) {
+	var sent []time.Time
+	base := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, time.Now())
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})}
+
+	client := rateLimitedClient(base, 50, 1)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("https://api.notion.com/v1/users/me")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	for i := 1; i < len(sent); i++ {
+		if gap := sent[i].Sub(sent[i-1]); gap < 15*time.Millisecond {
+			t.Errorf("Expected requests to be 20ms apart, got %v", gap)
+		}
+	}
+	if base.Transport == client.Transport {
+		t.Error("Expected the given client to be left unchanged")
+	}
+}
+
+func TestRateLimitedClientBurst(t *testing.T) {
+	var sent []time.Time
+	base := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, time.Now())
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})}
+
+	// The first three requests go at once, the next waits for a token
+	client := rateLimitedClient(base, 20, 3)
+	started := time.Now()
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("https://api.notion.com/v1/users/me")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if burst := sent[2].Sub(started); burst > 30*time.Millisecond {
+		t.Errorf("Expected the burst sent at once, took %v", burst)
+	}
+	if gap := sent[3].Sub(sent[2]); gap < 35*time.Millisecond {
+		t.Errorf("Expected the request after the burst to wait 50ms, got %v", gap)
+	}
+}
+
+func TestRateLimitFromEnv(t *testing.T) {
+	os.Clearenv()
+	if rate, burst, err := rateLimitFromEnv(); err != nil || rate != defaultRateLimit || burst != defaultRateBurst {
+		t.Errorf("Expected the default rate limit, got %v, %d, %v", rate, burst, err)
+	}
+	t.Setenv("NOTION_RATE_LIMIT", "0.5")
+	t.Setenv("NOTION_RATE_BURST", "2")
+	if rate, burst, err := rateLimitFromEnv(); err != nil || rate != 0.5 || burst != 2 {
+		t.Errorf("Expected the rate limit of the environment, got %v, %d, %v", rate, burst, err)
+	}
+	t.Setenv("NOTION_RATE_BURST", "0")
+	if _, _, err := rateLimitFromEnv(); err == nil {
+		t.Error("Expected an error for a burst of 0")
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	client, err := NewHTTPClient("http://proxy.example.com:8080", 30*time.Second)
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	if client.Timeout != 30*time.Second {
+		t.Errorf("Expected timeout 30s, got %v", client.Timeout)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://api.notion.com/v1/users/me", nil)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxy == nil || proxy.Host != "proxy.example.com:8080" {
+		t.Errorf("Expected requests to go through the proxy, got %v, %v", proxy, err)
+	}
+
+	if _, err := NewHTTPClient("proxy.example.com", 0); err == nil {
+		t.Error("Expected error for a proxy URL without scheme, got nil")
+	}
+}
+
 func TestCreatePage(t *testing.T) {
 	// Set up test environment
 	os.Setenv("NOTION_API_KEY", "test_key")
@@ -81,18 +287,17 @@ func TestCreatePage(t *testing.T) {
 	ctx := context.Background()
 
 	tests := map[string]struct {
-		title      string
-		content    string
+		doc        *models.Document
 		tags       []string
-		setupMocks func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService)
+		setupMocks func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService, mockBlock *mock_notion.MockBlockService)
 	}{
 		"Success - With Tags": {
-			title: "Test Page",
-			content: `# Test Page
-
-This is a test page.`,
+			doc: &models.Document{
+				Title:  "Test Page",
+				Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("This is a test page.")}},
+			},
 			tags: []string{"Test"},
-			setupMocks: func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService) {
+			setupMocks: func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService, mockBlock *mock_notion.MockBlockService) {
 				// Set up service returns
 				mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
 				mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
@@ -120,6 +325,7 @@ This is a test page.`,
 						&notionapi.Database{
 							Object: "database",
 							ID:     "test_db_id",
+							Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "test_page_id"},
 							Title: []notionapi.RichText{
 								{
 									Text: &notionapi.Text{
@@ -131,10 +337,7 @@ This is a test page.`,
 					},
 				}, nil)
 
-				// Query database for existing pages
-				mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("test_db_id"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{
-					Results: []notionapi.Page{},
-				}, nil)
+				// The new database has no pages, so it isn't queried for existing ones
 
 				// Create page
 				mockPage.EXPECT().Create(ctx, gomock.Any()).Return(&notionapi.Page{
@@ -173,20 +376,18 @@ This is a test page.`,
 		},
 
 		"Success - Without Tags": {
-			title: "Test Page 2",
-			content: `# Test Page 2
-
-This is another test page.`,
+			doc: &models.Document{
+				Title:  "Test Page 2",
+				Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("This is another test page.")}},
+			},
 			tags: []string{},
-			setupMocks: func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService) {
+			setupMocks: func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService, mockBlock *mock_notion.MockBlockService) {
 				// Set up service returns
-				mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+				mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
 				mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
 
-				// Search for existing page
-				mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{
-					Results: []notionapi.Object{},
-				}, nil)
+				// List the pages under the parent for an existing page
+				mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("test_page_id"), gomock.Any()).Return(&notionapi.GetChildrenResponse{}, nil)
 
 				// Create page
 				mockPage.EXPECT().Create(ctx, gomock.Any()).Return(&notionapi.Page{
@@ -208,12 +409,12 @@ This is another test page.`,
 		},
 
 		"Failure - Empty Title": {
-			title: "",
-			content: `# Empty Page
-
-This page has no title.`,
+			doc: &models.Document{
+				Title:  "",
+				Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("This page has no title.")}},
+			},
 			tags: []string{"error"},
-			setupMocks: func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService) {
+			setupMocks: func(mockClient *mock_notion.MockNotionClient, mockPage *mock_notion.MockPageService, mockSearch *mock_notion.MockSearchService, mockDatabase *mock_notion.MockDatabaseService, mockBlock *mock_notion.MockBlockService) {
 				// Set up service returns
 				mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
 				mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
@@ -237,11 +438,12 @@ This page has no title.`,
 			mockPage := mock_notion.NewMockPageService(ctrl)
 			mockSearch := mock_notion.NewMockSearchService(ctrl)
 			mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+			mockBlock := mock_notion.NewMockBlockService(ctrl)
 
 			client.client = mockClient
-			tt.setupMocks(mockClient, mockPage, mockSearch, mockDatabase)
+			tt.setupMocks(mockClient, mockPage, mockSearch, mockDatabase, mockBlock)
 
-			err := client.CreatePage(context.Background(), tt.title, tt.content, tt.tags)
+			err := client.CreatePage(context.Background(), tt.doc, tt.tags)
 			if name == "Failure - Empty Title" {
 				if err == nil {
 					t.Error("Expected error but got nil")
@@ -254,3 +456,2078 @@ This page has no title.`,
 		})
 	}
 }
+
+func TestConvertDocumentToBlocks(t *testing.T) {
+	client := &Client{}
+
+	tests := map[string]struct {
+		blocks        []models.Block
+		expectedTypes []notionapi.BlockType
+	}{
+		"Paragraph": {
+			blocks:        []models.Block{{Type: models.BlockParagraph, Inline: text("Hello world")}},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeParagraph},
+		},
+		"Headings": {
+			blocks: []models.Block{
+				{Type: models.BlockHeading, Level: 1, Inline: text("h1")},
+				{Type: models.BlockHeading, Level: 2, Inline: text("h2")},
+				{Type: models.BlockHeading, Level: 4, Inline: text("h4")},
+			},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeHeading1, notionapi.BlockTypeHeading2, notionapi.BlockTypeHeading3},
+		},
+		"Bulleted list": {
+			blocks: []models.Block{
+				{Type: models.BlockBullet, Inline: text("item1")},
+				{Type: models.BlockBullet, Indent: 1, Inline: text("item2")},
+				{Type: models.BlockBullet, Inline: text("item3")},
+			},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeBulletedListItem, notionapi.BlockTypeBulletedListItem},
+		},
+		"Numbered list": {
+			blocks: []models.Block{
+				{Type: models.BlockNumbered, Number: 1, Inline: text("first")},
+				{Type: models.BlockNumbered, Number: 1, Indent: 1, Inline: text("nested")},
+				{Type: models.BlockNumbered, Number: 2, Inline: text("second")},
+			},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeNumberedListItem, notionapi.BlockTypeNumberedListItem},
+		},
+		"To-do items": {
+			blocks: []models.Block{
+				{Type: models.BlockToDo, Inline: text("open")},
+				{Type: models.BlockToDo, Checked: true, Inline: text("done")},
+			},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeToDo, notionapi.BlockTypeToDo},
+		},
+		"Display equation": {
+			blocks:        []models.Block{{Type: models.BlockEquation, Text: "E = mc^2"}},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeEquation},
+		},
+		"Divider": {
+			blocks:        []models.Block{{Type: models.BlockDivider}},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeDivider},
+		},
+		"Embeds": {
+			blocks: []models.Block{
+				{Type: models.BlockEmbed, Embed: &models.Embed{Provider: models.EmbedYouTube, ID: "dQw4w9WgXcQ", URL: "https://youtu.be/dQw4w9WgXcQ"}},
+				{Type: models.BlockEmbed, Embed: &models.Embed{Provider: models.EmbedTwitter, ID: "1", URL: "https://x.com/golang/status/1"}},
+			},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeVideo, notionapi.BlockTypeEmbed},
+		},
+		"Quote": {
+			blocks:        []models.Block{{Type: models.BlockQuote, Inline: text("quoted")}},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeQuote},
+		},
+		"Helpfeel callout": {
+			blocks:        []models.Block{{Type: models.BlockCallout, Icon: "❓", Inline: text("question")}},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeCallout},
+		},
+		"Image": {
+			blocks:        []models.Block{{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineImage, URL: "https://gyazo.com/a.png"}}}},
+			expectedTypes: []notionapi.BlockType{notionapi.BlockTypeImage},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			blocks := client.convertDocumentToBlocks(document(tt.blocks...))
+			if len(blocks) != len(tt.expectedTypes) {
+				t.Fatalf("Expected %d blocks, got %d", len(tt.expectedTypes), len(blocks))
+			}
+			for i, block := range blocks {
+				if block.GetType() != tt.expectedTypes[i] {
+					t.Errorf("Expected block %d to be %s, got %s", i, tt.expectedTypes[i], block.GetType())
+				}
+			}
+		})
+	}
+}
+
+func TestCreatePageScansDatabaseOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+
+	tagDB := &notionapi.Database{
+		ID:     "db",
+		Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+		Title:  []notionapi.RichText{{PlainText: "go"}},
+	}
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{Results: []notionapi.Object{tagDB}}, nil).Times(3)
+
+	row := func(id, title string) notionapi.Page {
+		return notionapi.Page{ID: notionapi.ObjectID(id), Properties: notionapi.Properties{
+			"Name": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: title}}},
+		}}
+	}
+	// The database is scanned once, following the cursor to the second page of results
+	first := mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db"), &notionapi.DatabaseQueryRequest{PageSize: maxPageSize}).
+		Return(&notionapi.DatabaseQueryResponse{Results: []notionapi.Page{row("1", "Other")}, HasMore: true, NextCursor: "next"}, nil)
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db"), &notionapi.DatabaseQueryRequest{StartCursor: "next", PageSize: maxPageSize}).
+		Return(&notionapi.DatabaseQueryResponse{Results: []notionapi.Page{row("2", "Existing")}}, nil).After(first)
+
+	// Only the new page is created, once
+	mockPage.EXPECT().Create(ctx, gomock.Any()).Return(&notionapi.Page{ID: "3"}, nil).Times(1)
+	mockPage.EXPECT().Get(ctx, notionapi.PageID("3")).Return(&notionapi.Page{ID: "3"}, nil).Times(1)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id"}
+	for i, title := range []string{"Existing", "New", "New"} {
+		if err := client.CreatePage(ctx, &models.Document{Title: title}, []string{"go"}); (i == 1 && err != nil) || (i != 1 && !errors.Is(err, ErrAlreadyExists)) {
+			t.Fatalf("CreatePage(%q) error = %v", title, err)
+		}
+	}
+	if client.pages["Existing"] != "2" || client.pages["New"] != "3" {
+		t.Errorf("Expected pages to be recorded, got %v", client.pages)
+	}
+	if created := client.Created(); len(created) != 1 || created[0] != (CreatedObject{Type: ObjectPage, ID: "3", Title: "New"}) {
+		t.Errorf("Expected only the new page to be recorded as created, got %+v", created)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	mockPage.EXPECT().Update(ctx, notionapi.PageID("page"), &notionapi.PageUpdateRequest{Archived: true}).Return(&notionapi.Page{}, nil)
+	mockBlock.EXPECT().Delete(ctx, notionapi.BlockID("db")).Return(nil, errors.New("not found"))
+
+	client := &Client{client: mockClient}
+	if err := client.Archive(ctx, CreatedObject{Type: ObjectPage, ID: "page"}); err != nil {
+		t.Errorf("Archive() page error = %v", err)
+	}
+	if err := client.Archive(ctx, CreatedObject{Type: ObjectDatabase, ID: "db"}); err == nil {
+		t.Error("Expected error archiving database, got nil")
+	}
+	if err := client.Archive(ctx, CreatedObject{Type: "block", ID: "x"}); err == nil {
+		t.Error("Expected error for unknown object type, got nil")
+	}
+}
+
+func TestCreateLinkedImageBlock(t *testing.T) {
+	client := &Client{}
+	image := models.Inline{Type: models.InlineImage, URL: "https://gyazo.com/a.png"}
+	blocks := client.convertDocumentToBlocks(document(models.Block{
+		Type:   models.BlockParagraph,
+		Inline: []models.Inline{{Type: models.InlineLink, URL: "https://example.com", Children: []models.Inline{image}}},
+	}))
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+	block, ok := blocks[0].(*notionapi.ImageBlock)
+	if !ok {
+		t.Fatalf("Expected an image block, got %s", blocks[0].GetType())
+	}
+	if block.Image.External.URL != image.URL {
+		t.Errorf("Expected image URL %q, got %q", image.URL, block.Image.External.URL)
+	}
+	if len(block.Image.Caption) != 1 || block.Image.Caption[0].Text.Link.Url != "https://example.com" {
+		t.Errorf("Expected caption linking to https://example.com, got %+v", block.Image.Caption)
+	}
+}
+
+func TestCreateToDoBlockCheckedState(t *testing.T) {
+	client := &Client{}
+	blocks := client.convertDocumentToBlocks(document(
+		models.Block{Type: models.BlockToDo, Inline: text("open")},
+		models.Block{Type: models.BlockToDo, Checked: true, Inline: text("done")},
+	))
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+	for i, expected := range []bool{false, true} {
+		todo, ok := blocks[i].(*notionapi.ToDoBlock)
+		if !ok {
+			t.Fatalf("Expected block %d to be a to-do block", i)
+		}
+		if todo.ToDo.Checked != expected {
+			t.Errorf("Expected block %d checked=%v, got %v", i, expected, todo.ToDo.Checked)
+		}
+	}
+}
+
+func TestCreateCodeBlockLanguage(t *testing.T) {
+	client := &Client{}
+
+	tests := map[string]struct {
+		language string
+		expected string
+	}{
+		"Known language":   {language: "go", expected: "go"},
+		"Upper case":       {language: "Python", expected: "python"},
+		"Alias":            {language: "cpp", expected: "c++"},
+		"Shell alias":      {language: "sh", expected: "shell"},
+		"Unknown language": {language: "test4", expected: "plain text"},
+		"No language":      {language: "", expected: "plain text"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			blocks := client.convertDocumentToBlocks(document(models.Block{Type: models.BlockCode, Language: tt.language, Text: "test"}))
+			if len(blocks) != 1 {
+				t.Fatalf("Expected 1 block, got %d", len(blocks))
+			}
+			code, ok := blocks[0].(*notionapi.CodeBlock)
+			if !ok {
+				t.Fatal("Expected a code block")
+			}
+			if code.Code.Language != tt.expected {
+				t.Errorf("Expected language %q, got %q", tt.expected, code.Code.Language)
+			}
+		})
+	}
+}
+
+func TestCreateRichText(t *testing.T) {
+	client := &Client{}
+
+	inlines := []models.Inline{
+		{Type: models.InlineText, Text: "Area: "},
+		{Type: models.InlineMath, Text: "\\pi r^2"},
+		{Type: models.InlineBold, Children: []models.Inline{
+			{Type: models.InlineItalic, Children: text("strong")},
+		}},
+		{Type: models.InlineCode, Text: "code"},
+		{Type: models.InlineLink, Text: "label", URL: "https://example.com"},
+		{Type: models.InlinePageLink, Text: "Other page"},
+	}
+
+	richText := client.createRichText(inlines)
+	if len(richText) != 6 {
+		t.Fatalf("Expected 6 rich text segments, got %d", len(richText))
+	}
+
+	if richText[0].Text == nil || richText[0].Text.Content != "Area: " || richText[0].Annotations != nil {
+		t.Errorf("Expected plain text segment, got %+v", richText[0])
+	}
+	if richText[1].Equation == nil || richText[1].Equation.Expression != "\\pi r^2" {
+		t.Errorf("Expected equation segment, got %+v", richText[1])
+	}
+	if a := richText[2].Annotations; a == nil || !a.Bold || !a.Italic || richText[2].Text.Content != "strong" {
+		t.Errorf("Expected bold italic segment, got %+v", richText[2])
+	}
+	if a := richText[3].Annotations; a == nil || !a.Code {
+		t.Errorf("Expected code segment, got %+v", richText[3])
+	}
+	if link := richText[4].Text.Link; link == nil || link.Url != "https://example.com" || richText[4].Text.Content != "label" {
+		t.Errorf("Expected link segment, got %+v", richText[4])
+	}
+	if richText[5].Text == nil || richText[5].Text.Content != "Other page" {
+		t.Errorf("Expected page link text, got %+v", richText[5])
+	}
+
+	if empty := client.createRichText(nil); len(empty) != 1 || empty[0].Text.Content != "" {
+		t.Errorf("Expected a single empty segment, got %+v", empty)
+	}
+}
+
+func TestConvertBareURL(t *testing.T) {
+	url := []models.Inline{{Type: models.InlineLink, URL: "https://example.com"}}
+
+	tests := map[string]struct {
+		style        URLStyle
+		inlines      []models.Inline
+		expectedType notionapi.BlockType
+	}{
+		"Bookmark":          {style: URLStyleBookmark, inlines: url, expectedType: notionapi.BlockTypeBookmark},
+		"Link":              {style: URLStyleLink, inlines: url, expectedType: notionapi.BlockTypeParagraph},
+		"Plain":             {style: URLStylePlain, inlines: url, expectedType: notionapi.BlockTypeParagraph},
+		"Plain text URL":    {style: URLStyleBookmark, inlines: text("https://example.com"), expectedType: notionapi.BlockTypeBookmark},
+		"URL inside a line": {style: URLStyleBookmark, inlines: text("see https://example.com"), expectedType: notionapi.BlockTypeParagraph},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &Client{}
+			WithURLStyle(tt.style)(client)
+
+			blocks := client.convertDocumentToBlocks(document(models.Block{Type: models.BlockParagraph, Inline: tt.inlines}))
+			if len(blocks) != 1 {
+				t.Fatalf("Expected 1 block, got %d", len(blocks))
+			}
+			if blocks[0].GetType() != tt.expectedType {
+				t.Errorf("Expected block type %s, got %s", tt.expectedType, blocks[0].GetType())
+			}
+			if tt.style == URLStyleLink {
+				paragraph := blocks[0].(*notionapi.ParagraphBlock)
+				if link := paragraph.Paragraph.RichText[0].Text.Link; link == nil || link.Url != "https://example.com" {
+					t.Errorf("Expected link to https://example.com, got %+v", link)
+				}
+			}
+		})
+	}
+}
+
+func TestParseURLStyle(t *testing.T) {
+	for _, style := range []string{"bookmark", "link", "plain"} {
+		if _, err := ParseURLStyle(style); err != nil {
+			t.Errorf("Unexpected error for %s: %v", style, err)
+		}
+	}
+	if _, err := ParseURLStyle("preview"); err == nil {
+		t.Error("Expected error for invalid style, got nil")
+	}
+}
+
+func TestConvertToggleDepth(t *testing.T) {
+	doc := document(
+		models.Block{Type: models.BlockBullet, Indent: 0, Inline: text("a")},
+		models.Block{Type: models.BlockBullet, Indent: 1, Inline: text("b")},
+		models.Block{Type: models.BlockBullet, Indent: 2, Inline: text("c")},
+		models.Block{Type: models.BlockBullet, Indent: 2, Inline: text("d")},
+		models.Block{Type: models.BlockBullet, Indent: 1, Inline: text("e")},
+		models.Block{Type: models.BlockBullet, Indent: 0, Inline: text("f")},
+	)
+
+	tests := map[string]struct {
+		depth            int
+		expectedTypes    []notionapi.BlockType
+		expectedChildren []int
+		// expectedNested is the type of the first child of the first block
+		expectedNested notionapi.BlockType
+	}{
+		"Disabled": {
+			depth:            0,
+			expectedTypes:    []notionapi.BlockType{"bulleted_list_item", "bulleted_list_item"},
+			expectedChildren: []int{2, 0},
+			expectedNested:   "bulleted_list_item",
+		},
+		"Depth 1": {
+			depth:            1,
+			expectedTypes:    []notionapi.BlockType{"toggle", "bulleted_list_item"},
+			expectedChildren: []int{2, 0},
+			expectedNested:   "bulleted_list_item",
+		},
+		"Depth 2": {
+			depth:            2,
+			expectedTypes:    []notionapi.BlockType{"bulleted_list_item", "bulleted_list_item"},
+			expectedChildren: []int{2, 0},
+			expectedNested:   "toggle",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &Client{}
+			WithToggleDepth(tt.depth)(client)
+
+			blocks := client.convertDocumentToBlocks(doc)
+			if len(blocks) != len(tt.expectedTypes) {
+				t.Fatalf("Expected %d blocks, got %d", len(tt.expectedTypes), len(blocks))
+			}
+			for i, block := range blocks {
+				if block.GetType() != tt.expectedTypes[i] {
+					t.Errorf("Expected block %d to be %s, got %s", i, tt.expectedTypes[i], block.GetType())
+				}
+				if children := blockChildren(block); len(children) != tt.expectedChildren[i] {
+					t.Errorf("Expected block %d to have %d children, got %d", i, tt.expectedChildren[i], len(children))
+				}
+			}
+			if nested := blockChildren(blocks[0]); len(nested) == 0 || nested[0].GetType() != tt.expectedNested {
+				t.Errorf("Expected the first nested block to be %s, got %v", tt.expectedNested, nested)
+			}
+			// Every item is kept, nested under its parent
+			if expected := "- a\n  - b\n    - c\n    - d\n  - e\n- f\n"; blocksMarkdown(blocks) != expected {
+				t.Errorf("Expected outline %q, got %q", expected, blocksMarkdown(blocks))
+			}
+		})
+	}
+}
+
+func TestConvertNestedLists(t *testing.T) {
+	doc := document(
+		models.Block{Type: models.BlockNumbered, Number: 1, Inline: text("step")},
+		models.Block{Type: models.BlockBullet, Indent: 1, Inline: text("detail")},
+		models.Block{Type: models.BlockToDo, Indent: 2, Inline: text("check")},
+		models.Block{Type: models.BlockToDo, Inline: text("task")},
+		models.Block{Type: models.BlockBullet, Indent: 1, Inline: text("note")},
+		models.Block{Type: models.BlockParagraph, Inline: text("after")},
+		models.Block{Type: models.BlockBullet, Indent: 1, Inline: text("loose")},
+	)
+
+	blocks := (&Client{}).convertDocumentToBlocks(doc)
+	expected := "1. step\n  - detail\n    - [ ] check\n- [ ] task\n  - note\nafter\n- loose\n"
+	if md := blocksMarkdown(blocks); md != expected {
+		t.Errorf("Expected nested lists %q, got %q", expected, md)
+	}
+	if count := countBlocks(blocks); count != 7 {
+		t.Errorf("Expected every item counted, got %d", count)
+	}
+	if !blocks[0].GetHasChildren() || blocks[2].GetHasChildren() {
+		t.Errorf("Expected only items with nested items to have children")
+	}
+}
+
+func TestPageIcon(t *testing.T) {
+	tests := map[string]struct {
+		opts     []Option
+		title    string
+		expected string
+	}{
+		"Disabled":                {title: "🚀 Launch", expected: ""},
+		"Emoji in title":          {opts: []Option{WithPageIcon("")}, title: "Launch 🚀 plan", expected: "🚀"},
+		"Emoji with modifier":     {opts: []Option{WithPageIcon("")}, title: "👍🏽 Good", expected: "👍🏽"},
+		"Symbol with selector":    {opts: []Option{WithPageIcon("")}, title: "☀️ Weather", expected: "☀️"},
+		"Default icon":            {opts: []Option{WithPageIcon("📄")}, title: "Plain title", expected: "📄"},
+		"No emoji, no default":    {opts: []Option{WithPageIcon("")}, title: "Plain title", expected: ""},
+		"Japanese title":          {opts: []Option{WithPageIcon("")}, title: "日本語のページ", expected: ""},
+		"Emoji overrides default": {opts: []Option{WithPageIcon("📄")}, title: "📝 Memo", expected: "📝"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &Client{}
+			for _, opt := range tt.opts {
+				opt(client)
+			}
+
+			icon := client.pageIcon(tt.title)
+			if tt.expected == "" {
+				if icon != nil {
+					t.Errorf("Expected no icon, got %v", *icon.Emoji)
+				}
+				return
+			}
+			if icon == nil || icon.Emoji == nil || string(*icon.Emoji) != tt.expected {
+				t.Errorf("Expected icon %s, got %+v", tt.expected, icon)
+			}
+		})
+	}
+}
+
+func TestPageCover(t *testing.T) {
+	image := func(url string) models.Block {
+		return models.Block{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineImage, URL: url}}}
+	}
+	doc := document(
+		models.Block{Type: models.BlockParagraph, Inline: text("text")},
+		image("https://gyazo.com/a.png"),
+		image("https://gyazo.com/b.png"),
+	)
+
+	client := &Client{}
+	if cover := client.pageCover(doc); cover != nil {
+		t.Errorf("Expected no cover when disabled, got %+v", cover)
+	}
+
+	WithPageCover()(client)
+	cover := client.pageCover(doc)
+	if cover == nil || cover.External == nil || cover.External.URL != "https://gyazo.com/a.png" {
+		t.Errorf("Expected cover https://gyazo.com/a.png, got %+v", cover)
+	}
+
+	if cover := client.pageCover(document(models.Block{Type: models.BlockParagraph, Inline: text("no images")})); cover != nil {
+		t.Errorf("Expected no cover for a page without images, got %+v", cover)
+	}
+
+	// The thumbnail of exports with metadata comes before the images
+	doc.Image = "https://gyazo.com/thumbnail/raw"
+	if cover := client.pageCover(doc); cover == nil || cover.External.URL != doc.Image {
+		t.Errorf("Expected the thumbnail as the cover, got %+v", cover)
+	}
+}
+
+func TestCreateIndexPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id"}
+	client.recordPage("Linked", "linked_id")
+
+	titles := []string{"Linked"}
+	for i := 0; i < 119; i++ {
+		titles = append(titles, fmt.Sprintf("Page %d", i))
+	}
+	groups := []models.TagGroup{{Tag: "go", Titles: titles}}
+
+	ctx := context.Background()
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		if len(req.Children) != 100 {
+			t.Errorf("Expected 100 blocks in the create request, got %d", len(req.Children))
+		}
+		if req.Children[0].GetType() != notionapi.BlockTypeHeading2 {
+			t.Errorf("Expected the tag heading first, got %s", req.Children[0].GetType())
+		}
+		link, ok := req.Children[1].(*notionapi.LinkToPageBlock)
+		if !ok || link.LinkToPage.PageID != "linked_id" {
+			t.Errorf("Expected a link to the migrated page, got %+v", req.Children[1])
+		}
+		if req.Children[2].GetType() != notionapi.BlockTypeBulletedListItem {
+			t.Errorf("Expected unknown pages as list items, got %s", req.Children[2].GetType())
+		}
+		return &notionapi.Page{ID: "index_id"}, nil
+	})
+	mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("index_id"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+		if len(req.Children) != 21 {
+			t.Errorf("Expected the remaining 21 blocks to be appended, got %d", len(req.Children))
+		}
+		return &notionapi.AppendBlockChildrenResponse{}, nil
+	})
+
+	if err := client.CreateIndexPage(ctx, "Index", groups); err != nil {
+		t.Fatalf("CreateIndexPage() error = %v", err)
+	}
+}
+
+func TestBatchBlocks(t *testing.T) {
+	paragraph := func(text string) notionapi.Block {
+		return &notionapi.ParagraphBlock{
+			BasicBlock: notionapi.BasicBlock{Object: notionapi.ObjectTypeBlock, Type: notionapi.BlockTypeParagraph},
+			Paragraph:  notionapi.Paragraph{RichText: []notionapi.RichText{{Text: &notionapi.Text{Content: text}}}},
+		}
+	}
+	toggle := func(children int) notionapi.Block {
+		block := &notionapi.ToggleBlock{
+			BasicBlock: notionapi.BasicBlock{Object: notionapi.ObjectTypeBlock, Type: notionapi.BlockTypeToggle},
+		}
+		for i := 0; i < children; i++ {
+			block.Toggle.Children = append(block.Toggle.Children, paragraph("child"))
+		}
+		return block
+	}
+
+	var small, large, toggles []notionapi.Block
+	for i := 0; i < 250; i++ {
+		small = append(small, paragraph("line"))
+	}
+	for i := 0; i < 5; i++ {
+		large = append(large, paragraph(strings.Repeat("x", 200*1000)))
+	}
+	for i := 0; i < 11; i++ {
+		toggles = append(toggles, toggle(99))
+	}
+
+	tests := map[string]struct {
+		blocks   []notionapi.Block
+		reserved int
+		expected []int
+	}{
+		"Block count":        {blocks: small, expected: []int{100, 100, 50}},
+		"Payload size":       {blocks: large, expected: []int{2, 2, 1}},
+		"Reserved size":      {blocks: large, reserved: 200 * 1000, expected: []int{1, 2, 2}},
+		"Nested block count": {blocks: toggles, expected: []int{10, 1}},
+		"Oversized block":    {blocks: large[:1], reserved: maxPayloadBytes, expected: []int{1}},
+		"No blocks":          {blocks: nil, expected: nil},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sizes []int
+			for _, batch := range batchBlocks(tt.blocks, tt.reserved) {
+				sizes = append(sizes, len(batch))
+			}
+			if !slices.Equal(sizes, tt.expected) {
+				t.Errorf("Expected batches of %v blocks, got %v", tt.expected, sizes)
+			}
+		})
+	}
+}
+
+func TestAppendNestedBlocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id"}
+	blocks := client.convertDocumentToBlocks(document(
+		models.Block{Type: models.BlockBullet, Inline: text("a")},
+		models.Block{Type: models.BlockBullet, Indent: 1, Inline: text("b")},
+		models.Block{Type: models.BlockBullet, Indent: 2, Inline: text("c")},
+		models.Block{Type: models.BlockBullet, Inline: text("d")},
+	))
+
+	// Notion takes two levels of blocks in a request, so the page is created
+	// empty, and the items nested under b are appended to a once created
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		if len(req.Children) != 0 {
+			t.Errorf("Expected the page created without children, got %d", len(req.Children))
+		}
+		return &notionapi.Page{ID: "page"}, nil
+	})
+	gomock.InOrder(
+		mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("page"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+			if md := blocksMarkdown(req.Children); md != "- a\n- d\n" {
+				t.Errorf("Expected the top level items without their children, got %q", md)
+			}
+			if !blocks[0].GetHasChildren() || len(blockChildren(blocks[0])) != 1 {
+				t.Errorf("Expected the converted blocks left whole")
+			}
+			return &notionapi.AppendBlockChildrenResponse{Results: []notionapi.Block{
+				&notionapi.BulletedListItemBlock{BasicBlock: notionapi.BasicBlock{ID: "a"}},
+				&notionapi.BulletedListItemBlock{BasicBlock: notionapi.BasicBlock{ID: "d"}},
+			}}, nil
+		}),
+		mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("a"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+			if md := blocksMarkdown(req.Children); md != "- b\n  - c\n" {
+				t.Errorf("Expected the nested items within two levels, got %q", md)
+			}
+			return &notionapi.AppendBlockChildrenResponse{Results: []notionapi.Block{
+				&notionapi.BulletedListItemBlock{BasicBlock: notionapi.BasicBlock{ID: "b"}},
+			}}, nil
+		}),
+	)
+
+	if _, err := client.createPageWithBlocks(ctx, &notionapi.PageCreateRequest{}, blocks); err != nil {
+		t.Fatalf("createPageWithBlocks() error = %v", err)
+	}
+}
+
+func TestAppendManyChildren(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id"}
+	items := []models.Block{{Type: models.BlockBullet, Inline: text("list")}}
+	for i := range 150 {
+		items = append(items, models.Block{Type: models.BlockBullet, Indent: 1, Inline: text(fmt.Sprintf("item %d", i))})
+	}
+	blocks := client.convertDocumentToBlocks(document(items...))
+
+	// Notion takes 100 children of a block in a request, so the page is created
+	// empty and the items under the list are appended in two requests
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		if len(req.Children) != 0 {
+			t.Errorf("Expected the page created without children, got %d", len(req.Children))
+		}
+		return &notionapi.Page{ID: "page"}, nil
+	})
+	var appended []int
+	gomock.InOrder(
+		mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("page"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+			if len(req.Children) != 1 || req.Children[0].GetHasChildren() {
+				t.Errorf("Expected the list sent without its children, got %+v", req.Children)
+			}
+			return &notionapi.AppendBlockChildrenResponse{Results: []notionapi.Block{
+				&notionapi.BulletedListItemBlock{BasicBlock: notionapi.BasicBlock{ID: "list"}},
+			}}, nil
+		}),
+		mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("list"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+			appended = append(appended, len(req.Children))
+			return &notionapi.AppendBlockChildrenResponse{}, nil
+		}).Times(2),
+	)
+
+	if _, err := client.createPageWithBlocks(ctx, &notionapi.PageCreateRequest{}, blocks); err != nil {
+		t.Fatalf("createPageWithBlocks() error = %v", err)
+	}
+	if !slices.Equal(appended, []int{100, 50}) {
+		t.Errorf("Expected the items appended in batches of 100 and 50, got %v", appended)
+	}
+}
+
+func TestWithinParent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+
+	underPage := func(id string) notionapi.Parent {
+		return notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: notionapi.PageID(id)}
+	}
+	// Child pages are looked up once, then cached
+	mockPage.EXPECT().Get(ctx, notionapi.PageID("child")).Return(&notionapi.Page{Parent: underPage("0123-abcd")}, nil).Times(1)
+	mockPage.EXPECT().Get(ctx, notionapi.PageID("elsewhere")).Return(&notionapi.Page{Parent: notionapi.Parent{Type: "workspace", Workspace: true}}, nil).Times(1)
+
+	client := &Client{client: mockClient, parentID: "0123ABCD"}
+	results := &notionapi.SearchResponse{Results: []notionapi.Object{
+		&notionapi.Database{ID: "direct", Parent: underPage("0123-abcd")},
+		&notionapi.Database{ID: "nested", Parent: underPage("child")},
+		&notionapi.Page{ID: "nested page", Parent: underPage("child")},
+		&notionapi.Database{ID: "other", Parent: underPage("elsewhere")},
+		&notionapi.Database{ID: "workspace", Parent: notionapi.Parent{Type: "workspace", Workspace: true}},
+	}}
+
+	scoped := client.withinParent(ctx, results)
+	var ids []string
+	for _, result := range scoped.Results {
+		switch object := result.(type) {
+		case *notionapi.Database:
+			ids = append(ids, string(object.ID))
+		case *notionapi.Page:
+			ids = append(ids, string(object.ID))
+		}
+	}
+	expected := []string{"direct", "nested", "nested page"}
+	if fmt.Sprint(ids) != fmt.Sprint(expected) {
+		t.Errorf("Expected results %v, got %v", expected, ids)
+	}
+
+	// Cached lookups do not query the API again
+	client.withinParent(ctx, results)
+}
+
+func TestValidateTagsDatabase(t *testing.T) {
+	database := func(id string, segments ...string) *notionapi.Database {
+		db := &notionapi.Database{ID: notionapi.ObjectID(id)}
+		for _, segment := range segments {
+			db.Title = append(db.Title, notionapi.RichText{PlainText: segment})
+		}
+		return db
+	}
+	results := &notionapi.SearchResponse{Results: []notionapi.Object{
+		database("prefix", "Go Tips"),
+		database("segments", "Go ", " Language"),
+		database("case", "Rust"),
+	}}
+
+	tests := map[string]struct {
+		tag      string
+		foldCase bool
+		expected string
+	}{
+		"Multiple segments":         {tag: "Go Language", expected: "segments"},
+		"Prefix only":               {tag: "Go", expected: ""},
+		"Different case":            {tag: "rust", expected: ""},
+		"Different case, folded":    {tag: "rust", foldCase: true, expected: "case"},
+		"Surrounding white space":   {tag: " Rust ", expected: "case"},
+		"Folded segments and space": {tag: "go  language", foldCase: true, expected: "segments"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db := validateTagsDatabase(tt.tag, results, tt.foldCase)
+			switch {
+			case tt.expected == "" && db != nil:
+				t.Errorf("Expected no database, got %s", db.ID)
+			case tt.expected != "" && (db == nil || string(db.ID) != tt.expected):
+				t.Errorf("Expected database %s, got %+v", tt.expected, db)
+			}
+		})
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	inParent := notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"}
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{Results: []notionapi.Object{
+		&notionapi.Database{ID: "db", Parent: inParent, Title: []notionapi.RichText{{PlainText: "go"}}},
+		&notionapi.Database{ID: "other", Parent: notionapi.Parent{Type: "workspace", Workspace: true}},
+	}}, nil)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	row := func(id, title string, created time.Time) notionapi.Page {
+		return notionapi.Page{ID: notionapi.ObjectID(id), CreatedTime: created, Properties: notionapi.Properties{
+			"Name": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: title}}},
+		}}
+	}
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{Results: []notionapi.Page{
+		row("1", "Tips", day(1)),
+		row("2", "Unique", day(1)),
+		row("3", "Tips ", day(3)),
+		row("4", "Tips", day(2)),
+	}}, nil)
+
+	client := &Client{client: mockClient, parentID: "parent"}
+	groups, err := client.FindDuplicates(ctx)
+	if err != nil {
+		t.Fatalf("FindDuplicates() error = %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %+v", groups)
+	}
+	group := groups[0]
+	if group.Database != "go" || group.Title != "Tips" || group.Keep.ID != "3" {
+		t.Errorf("Expected the newest Tips page to be kept, got %+v", group)
+	}
+	if len(group.Duplicates) != 2 || group.Duplicates[0].ID != "4" || group.Duplicates[1].ID != "1" {
+		t.Errorf("Expected duplicates 4 and 1, got %+v", group.Duplicates)
+	}
+}
+
+func TestVerifyPage(t *testing.T) {
+	doc := &models.Document{Title: "Tips", Blocks: []models.Block{
+		{Type: models.BlockBullet, Inline: []models.Inline{
+			{Type: models.InlineBold, Children: text("bold")},
+			{Type: models.InlineText, Text: " text"},
+		}},
+		{Type: models.BlockParagraph, Inline: text("end")},
+	}}
+
+	// Blocks as Notion returns them, with text split into several segments
+	bullet := &notionapi.BulletedListItemBlock{BulletedListItem: notionapi.ListItem{RichText: []notionapi.RichText{
+		{PlainText: "bo", Annotations: &notionapi.Annotations{Bold: true, Color: "default"}},
+		{PlainText: "ld", Annotations: &notionapi.Annotations{Bold: true, Color: "default"}},
+		{PlainText: " text", Annotations: &notionapi.Annotations{Color: "default"}},
+	}}}
+	paragraph := func(s string) notionapi.Block {
+		return &notionapi.ParagraphBlock{Paragraph: notionapi.Paragraph{RichText: []notionapi.RichText{{PlainText: s}}}}
+	}
+
+	tests := []struct {
+		name     string
+		title    string
+		blocks   []notionapi.Block
+		expected VerifyStatus
+		diff     []string
+	}{
+		{
+			name:     "same content",
+			title:    "Tips",
+			blocks:   []notionapi.Block{bullet, paragraph("end")},
+			expected: VerifyOK,
+		},
+		{
+			name:     "upload stopped partway",
+			title:    "Tips",
+			blocks:   []notionapi.Block{bullet},
+			expected: VerifyPartial,
+			diff:     []string{"-end"},
+		},
+		{
+			name:     "edited in Notion",
+			title:    "Tips",
+			blocks:   []notionapi.Block{bullet, paragraph("edited")},
+			expected: VerifyDrifted,
+			diff:     []string{"-end", "+edited"},
+		},
+		{
+			name:     "not migrated",
+			title:    "Other",
+			expected: VerifyMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			mockClient := mock_notion.NewMockNotionClient(ctrl)
+			mockSearch := mock_notion.NewMockSearchService(ctrl)
+			mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+			mockBlock := mock_notion.NewMockBlockService(ctrl)
+			mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+			mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+			mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+			mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{Results: []notionapi.Object{
+				&notionapi.Database{
+					ID:     "db",
+					Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+					Title:  []notionapi.RichText{{PlainText: "go"}},
+				},
+			}}, nil)
+			mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{Results: []notionapi.Page{
+				{ID: "page", Properties: notionapi.Properties{
+					"Name": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: tt.title}}},
+				}},
+			}}, nil)
+			if tt.expected != VerifyMissing {
+				mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("page"), gomock.Any()).Return(&notionapi.GetChildrenResponse{Results: tt.blocks}, nil)
+			}
+
+			client := &Client{client: mockClient, parentID: "parent"}
+			result, err := client.VerifyPage(ctx, doc, []string{"go"})
+			if err != nil {
+				t.Fatalf("VerifyPage() error = %v", err)
+			}
+			if result.Status != tt.expected {
+				t.Errorf("Expected status %s, got %s (diff %q)", tt.expected, result.Status, result.Diff)
+			}
+			if !equalLines(result.Diff, tt.diff) {
+				t.Errorf("Expected diff %q, got %q", tt.diff, result.Diff)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	diff := diffLines([]string{"a", "b", "c", "d"}, []string{"a", "c", "x", "d", "e"})
+	expected := []string{"-b", "+x", "+e"}
+	if !equalLines(diff, expected) {
+		t.Errorf("diffLines() = %q, want %q", diff, expected)
+	}
+	if diff := diffLines([]string{"a"}, []string{"a"}); diff != nil {
+		t.Errorf("Expected no diff for the same lines, got %q", diff)
+	}
+}
+
+func TestDetectParent(t *testing.T) {
+	notFound := &notionapi.Error{Status: http.StatusNotFound, Code: "object_not_found"}
+	tests := []struct {
+		name         string
+		pageErr      error
+		db           *notionapi.Database
+		dbErr        error
+		expectedType notionapi.ParentType
+		expectError  string
+	}{
+		{
+			name:         "page",
+			expectedType: notionapi.ParentTypePageID,
+		},
+		{
+			name:         "database",
+			pageErr:      &notionapi.Error{Status: http.StatusBadRequest, Code: "validation_error"},
+			db:           &notionapi.Database{ID: "parent"},
+			expectedType: notionapi.ParentTypeDatabaseID,
+		},
+		{
+			name:        "not shared with the integration",
+			pageErr:     notFound,
+			dbErr:       notFound,
+			expectError: "Connections menu",
+		},
+		{
+			name:        "invalid token",
+			pageErr:     &notionapi.Error{Status: http.StatusUnauthorized, Code: "unauthorized"},
+			expectError: "NOTION_API_KEY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			mockClient := mock_notion.NewMockNotionClient(ctrl)
+			mockPage := mock_notion.NewMockPageService(ctrl)
+			mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+			mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+			mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+			mockPage.EXPECT().Get(ctx, notionapi.PageID("parent")).Return(&notionapi.Page{}, tt.pageErr)
+			if tt.pageErr != nil && tt.expectError != "NOTION_API_KEY" {
+				mockDatabase.EXPECT().Get(ctx, notionapi.DatabaseID("parent")).Return(tt.db, tt.dbErr)
+			}
+
+			client := &Client{client: mockClient, parentID: "parent", parentType: notionapi.ParentTypePageID}
+			err := client.DetectParent(ctx)
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Errorf("Expected error mentioning %q, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectParent() error = %v", err)
+			}
+			if client.parentType != tt.expectedType {
+				t.Errorf("Expected parent type %s, got %s", tt.expectedType, client.parentType)
+			}
+		})
+	}
+}
+
+func TestCreatePageInParentDatabase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("parent"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil)
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		if req.Parent.Type != notionapi.ParentTypeDatabaseID || req.Parent.DatabaseID != "parent" {
+			t.Errorf("Expected the page to be a row of the parent database, got %+v", req.Parent)
+		}
+		if _, ok := req.Properties["Title"].(notionapi.TitleProperty); !ok {
+			t.Errorf("Expected the title in the Title property, got %+v", req.Properties)
+		}
+		tags, ok := req.Properties["Tags"].(notionapi.MultiSelectProperty)
+		if !ok || len(tags.MultiSelect) != 2 || tags.MultiSelect[0].Name != "go" {
+			t.Errorf("Expected the tags in the Tags property, got %+v", req.Properties)
+		}
+		return &notionapi.Page{ID: "page"}, nil
+	})
+
+	client := &Client{
+		client:     mockClient,
+		parentID:   "parent",
+		parentType: notionapi.ParentTypeDatabaseID,
+		parentDB: &notionapi.Database{ID: "parent", Properties: notionapi.PropertyConfigs{
+			"Title": &notionapi.TitlePropertyConfig{Type: notionapi.PropertyConfigTypeTitle},
+			"Tags":  &notionapi.MultiSelectPropertyConfig{Type: notionapi.PropertyConfigTypeMultiSelect},
+		}},
+	}
+	doc := &models.Document{Title: "Tips"}
+	for i := 0; i < 2; i++ {
+		// The second call finds the page created by the first
+		if err := client.CreatePage(ctx, doc, []string{"go", "tips"}); (i == 0 && err != nil) || (i == 1 && !errors.Is(err, ErrAlreadyExists)) {
+			t.Fatalf("CreatePage() error = %v", err)
+		}
+	}
+}
+
+func TestUpdatePage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("parent"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{
+		Results: []notionapi.Page{{
+			ID: "page",
+			Properties: notionapi.Properties{
+				"Title": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Tips"}}},
+			},
+		}},
+	}, nil)
+	mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("page"), gomock.Any()).Return(&notionapi.GetChildrenResponse{
+		Results: []notionapi.Block{
+			&notionapi.ParagraphBlock{BasicBlock: notionapi.BasicBlock{ID: "old1"}},
+			&notionapi.ParagraphBlock{BasicBlock: notionapi.BasicBlock{ID: "old2"}},
+		},
+	}, nil)
+	mockBlock.EXPECT().Delete(ctx, notionapi.BlockID("old1")).Return(nil, nil)
+	mockBlock.EXPECT().Delete(ctx, notionapi.BlockID("old2")).Return(nil, nil)
+	mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("page"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+		if len(req.Children) != 1 {
+			t.Errorf("Expected the converted block to be appended, got %d blocks", len(req.Children))
+		}
+		return &notionapi.AppendBlockChildrenResponse{}, nil
+	})
+
+	client := &Client{
+		client:     mockClient,
+		parentID:   "parent",
+		parentType: notionapi.ParentTypeDatabaseID,
+		parentDB: &notionapi.Database{ID: "parent", Properties: notionapi.PropertyConfigs{
+			"Title": &notionapi.TitlePropertyConfig{Type: notionapi.PropertyConfigTypeTitle},
+		}},
+	}
+	doc := &models.Document{
+		Title:  "Tips",
+		Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("New body")}},
+	}
+	// The existing page is updated in place, not created again
+	if err := client.UpdatePage(ctx, doc, nil); err != nil {
+		t.Fatalf("UpdatePage() error = %v", err)
+	}
+}
+
+func TestHardFailure(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"Unauthorized":   {err: &notionapi.Error{Status: http.StatusUnauthorized}, expected: true},
+		"Wrapped outage": {err: fmt.Errorf("failed to create page: %w", &notionapi.Error{Status: http.StatusBadGateway}), expected: true},
+		"Bad request":    {err: &notionapi.Error{Status: http.StatusBadRequest}},
+		"Other error":    {err: errors.New("timeout")},
+	}
+	for name, tt := range tests {
+		if result := HardFailure(tt.err); result != tt.expected {
+			t.Errorf("%s: HardFailure() = %v, want %v", name, result, tt.expected)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected error
+	}{
+		"Rate limited":       {err: &notionapi.RateLimitedError{Message: "retries failed"}, expected: ErrRateLimited},
+		"Too large":          {err: fmt.Errorf("failed to create page: %w", &notionapi.Error{Status: http.StatusRequestEntityTooLarge}), expected: ErrPayloadTooLarge},
+		"Unauthorized":       {err: &notionapi.Error{Status: http.StatusUnauthorized}, expected: ErrUnauthorized},
+		"Restricted":         {err: &notionapi.Error{Status: http.StatusForbidden}, expected: ErrUnauthorized},
+		"Already classified": {err: classifyError(&notionapi.Error{Status: http.StatusForbidden}), expected: ErrUnauthorized},
+		"Bad request":        {err: &notionapi.Error{Status: http.StatusBadRequest}},
+	}
+	kinds := []error{ErrAlreadyExists, ErrRateLimited, ErrPayloadTooLarge, ErrUnauthorized}
+	for name, tt := range tests {
+		err := classifyError(tt.err)
+		if err.Error() != tt.err.Error() {
+			t.Errorf("%s: classifyError() = %q, want the message kept", name, err)
+		}
+		var apiErr *notionapi.Error
+		if _, ok := tt.err.(*notionapi.RateLimitedError); !ok && !errors.As(err, &apiErr) {
+			t.Errorf("%s: classifyError() lost the Notion error", name)
+		}
+		for _, kind := range kinds {
+			if errors.Is(err, kind) != (kind == tt.expected) {
+				t.Errorf("%s: errors.Is(classifyError(), %v) = %v", name, kind, kind != tt.expected)
+			}
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockUser := mock_notion.NewMockUserService(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockClient.EXPECT().User().Return(mockUser).AnyTimes()
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+
+	client := &Client{client: mockClient, parentID: "parent"}
+
+	// A rejected token stops before the parent is looked up
+	mockUser.EXPECT().Me(ctx).Return(nil, &notionapi.Error{Status: http.StatusUnauthorized, Code: "unauthorized"})
+	if err := client.Preflight(ctx); err == nil || !strings.Contains(err.Error(), "NOTION_API_KEY") {
+		t.Errorf("Expected error mentioning NOTION_API_KEY, got %v", err)
+	}
+
+	mockUser.EXPECT().Me(ctx).Return(&notionapi.User{Name: "scrapbox2notion", Bot: &notionapi.Bot{WorkspaceName: "Team"}}, nil)
+	mockPage.EXPECT().Get(ctx, notionapi.PageID("parent")).Return(&notionapi.Page{}, nil)
+	if err := client.Preflight(ctx); err != nil {
+		t.Errorf("Preflight() error = %v", err)
+	}
+	if client.parentType != notionapi.ParentTypePageID {
+		t.Errorf("Expected the parent type to be detected, got %q", client.parentType)
+	}
+}
+
+func TestCreatePageAuthors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockUser := mock_notion.NewMockUserService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+	mockClient.EXPECT().User().Return(mockUser).AnyTimes()
+
+	// Users given by email are looked up once
+	mockUser.EXPECT().List(ctx, gomock.Any()).Return(&notionapi.UsersListResponse{Results: []notionapi.User{
+		{ID: "user-alice", Person: &notionapi.Person{Email: "Alice@example.com"}},
+		{ID: "bot", Bot: &notionapi.Bot{}},
+	}}, nil).Times(1)
+
+	// The tag database from a run without a user mapping gets the property once
+	tagDB := &notionapi.Database{
+		ID:     "db",
+		Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+		Title:  []notionapi.RichText{{PlainText: "go"}},
+	}
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{Results: []notionapi.Object{tagDB}}, nil).Times(2)
+	mockDatabase.EXPECT().Update(ctx, notionapi.DatabaseID("db"), gomock.Any()).Return(tagDB, nil).Times(1)
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil)
+
+	var created []*notionapi.PageCreateRequest
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		created = append(created, req)
+		return &notionapi.Page{ID: notionapi.ObjectID(fmt.Sprintf("page%d", len(created)))}, nil
+	}).Times(2)
+	mockPage.EXPECT().Get(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, id notionapi.PageID) (*notionapi.Page, error) {
+		return &notionapi.Page{ID: notionapi.ObjectID(id)}, nil
+	}).Times(2)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id"}
+	WithUserMapping(map[string]string{"alice": "alice@example.com", "bob": "user-bob", "carol": "carol@example.com"})(client)
+
+	docs := []*models.Document{
+		{Title: "Shared", Authors: []string{"bob", "alice", "unknown"}},
+		{Title: "Unmapped", Authors: []string{"carol"}},
+	}
+	for _, doc := range docs {
+		if err := client.CreatePage(ctx, doc, []string{"go"}); err != nil {
+			t.Fatalf("CreatePage(%q) error = %v", doc.Title, err)
+		}
+	}
+
+	people, ok := created[0].Properties[authorProperty].(notionapi.PeopleProperty)
+	if !ok || len(people.People) != 2 || people.People[0].ID != "user-bob" || people.People[1].ID != "user-alice" {
+		t.Errorf("Expected the mapped writers as authors, got %+v", created[0].Properties[authorProperty])
+	}
+	if _, ok := created[1].Properties[authorProperty]; ok {
+		t.Errorf("Expected no authors for a page without mapped writers, got %+v", created[1].Properties[authorProperty])
+	}
+}
+
+func TestCreatePageRelationMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	// The Tags and Pages databases are found once: Tags exists with a row, Pages is created
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.SearchRequest) (*notionapi.SearchResponse, error) {
+		if req.Query == "Tags" {
+			return &notionapi.SearchResponse{Results: []notionapi.Object{&notionapi.Database{
+				ID:     "tags",
+				Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+				Title:  []notionapi.RichText{{PlainText: "Tags"}},
+			}}}, nil
+		}
+		return &notionapi.SearchResponse{}, nil
+	}).Times(2)
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("tags"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{Results: []notionapi.Page{
+		{ID: "row-go", Properties: notionapi.Properties{
+			"Name": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "go"}}},
+		}},
+	}}, nil)
+	mockDatabase.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.DatabaseCreateRequest) (*notionapi.Database, error) {
+		relation, ok := req.Properties["Tags"].(notionapi.RelationPropertyConfig)
+		if !ok || relation.Relation.DatabaseID != "tags" {
+			t.Errorf("Expected a Tags relation to the Tags database, got %+v", req.Properties["Tags"])
+		}
+		return &notionapi.Database{ID: "pages"}, nil
+	})
+
+	var rows, pages []*notionapi.PageCreateRequest
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		if req.Parent.DatabaseID == "tags" {
+			rows = append(rows, req)
+			return &notionapi.Page{ID: "row-tips"}, nil
+		}
+		pages = append(pages, req)
+		return &notionapi.Page{ID: notionapi.ObjectID(fmt.Sprintf("page%d", len(pages)))}, nil
+	}).Times(3)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", tagMode: TagModeRelation}
+	for i, doc := range []*models.Document{{Title: "First"}, {Title: "Second"}, {Title: "First"}} {
+		if err := client.CreatePage(ctx, doc, []string{"go", "tips"}); (i < 2 && err != nil) || (i == 2 && !errors.Is(err, ErrAlreadyExists)) {
+			t.Fatalf("CreatePage(%q) error = %v", doc.Title, err)
+		}
+	}
+
+	if len(rows) != 1 {
+		t.Errorf("Expected only the missing tag row to be created once, got %d rows", len(rows))
+	}
+	if len(pages) != 2 {
+		t.Fatalf("Expected each page to be created once, got %d", len(pages))
+	}
+	relation, ok := pages[1].Properties["Tags"].(notionapi.RelationProperty)
+	if !ok || len(relation.Relation) != 2 || relation.Relation[0].ID != "row-go" || relation.Relation[1].ID != "row-tips" {
+		t.Errorf("Expected the page to relate to both tag rows, got %+v", pages[1].Properties["Tags"])
+	}
+}
+
+func TestCreatePageCanonicalMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.SearchRequest) (*notionapi.SearchResponse, error) {
+		return &notionapi.SearchResponse{Results: []notionapi.Object{&notionapi.Database{
+			ID:     notionapi.ObjectID("db-" + req.Query),
+			Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+			Title:  []notionapi.RichText{{PlainText: req.Query}},
+		}}}, nil
+	}).Times(2)
+	mockDatabase.EXPECT().Query(ctx, gomock.Any(), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil).Times(2)
+
+	var created []*notionapi.PageCreateRequest
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		created = append(created, req)
+		return &notionapi.Page{ID: notionapi.ObjectID(fmt.Sprintf("page%d", len(created)))}, nil
+	}).Times(2)
+	mockPage.EXPECT().Get(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, id notionapi.PageID) (*notionapi.Page, error) {
+		return &notionapi.Page{ID: notionapi.ObjectID(id)}, nil
+	}).Times(2)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", tagMode: TagModeCanonical}
+	doc := &models.Document{Title: "Shared", Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("Body")}}}
+	if err := client.CreatePage(ctx, doc, []string{"go", "tips"}); err != nil {
+		t.Fatalf("CreatePage() error = %v", err)
+	}
+
+	if len(created) != 2 {
+		t.Fatalf("Expected a page in each tag database, got %d", len(created))
+	}
+	if created[0].Parent.DatabaseID != "db-go" || len(created[0].Children) == 0 {
+		t.Errorf("Expected the content in the database of the first tag, got %+v", created[0])
+	}
+	if created[1].Parent.DatabaseID != "db-tips" || len(created[1].Children) != 1 {
+		t.Fatalf("Expected a single link in the database of the other tag, got %+v", created[1])
+	}
+	link, ok := created[1].Children[0].(*notionapi.LinkToPageBlock)
+	if !ok || link.LinkToPage.PageID != "page1" {
+		t.Errorf("Expected a link to the canonical page, got %+v", created[1].Children[0])
+	}
+}
+
+func TestCreatePageSyncedMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.SearchRequest) (*notionapi.SearchResponse, error) {
+		return &notionapi.SearchResponse{Results: []notionapi.Object{&notionapi.Database{
+			ID:     notionapi.ObjectID("db-" + req.Query),
+			Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+			Title:  []notionapi.RichText{{PlainText: req.Query}},
+		}}}, nil
+	}).Times(2)
+	mockDatabase.EXPECT().Query(ctx, gomock.Any(), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil).Times(2)
+
+	var created []*notionapi.PageCreateRequest
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		created = append(created, req)
+		return &notionapi.Page{ID: notionapi.ObjectID(fmt.Sprintf("page%d", len(created)))}, nil
+	}).Times(2)
+	mockPage.EXPECT().Get(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, id notionapi.PageID) (*notionapi.Page, error) {
+		return &notionapi.Page{ID: notionapi.ObjectID(id)}, nil
+	}).Times(2)
+
+	// The synced block is created with the first block, the others appended to it
+	mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("page1"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+		synced, ok := req.Children[0].(*notionapi.SyncedBlock)
+		if len(req.Children) != 1 || !ok || synced.SyncedBlock.SyncedFrom != nil || len(synced.SyncedBlock.Children) != 1 {
+			t.Errorf("Expected an original synced block holding the first block, got %+v", req.Children)
+		}
+		return &notionapi.AppendBlockChildrenResponse{Results: []notionapi.Block{&notionapi.SyncedBlock{BasicBlock: notionapi.BasicBlock{ID: "synced"}}}}, nil
+	})
+	mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("synced"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.AppendBlockChildrenRequest) (*notionapi.AppendBlockChildrenResponse, error) {
+		if len(req.Children) != 1 {
+			t.Errorf("Expected the second block appended to the synced block, got %d", len(req.Children))
+		}
+		return &notionapi.AppendBlockChildrenResponse{}, nil
+	})
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", tagMode: TagModeSynced}
+	doc := &models.Document{Title: "Shared", Blocks: []models.Block{
+		{Type: models.BlockParagraph, Inline: text("Body")},
+		{Type: models.BlockParagraph, Inline: text("More")},
+	}}
+	if err := client.CreatePage(ctx, doc, []string{"go", "tips"}); err != nil {
+		t.Fatalf("CreatePage() error = %v", err)
+	}
+
+	if len(created) != 2 {
+		t.Fatalf("Expected a page in each tag database, got %d", len(created))
+	}
+	if created[0].Parent.DatabaseID != "db-go" || len(created[0].Children) != 0 {
+		t.Errorf("Expected the page of the first tag created empty for the synced block, got %+v", created[0])
+	}
+	if created[1].Parent.DatabaseID != "db-tips" || len(created[1].Children) != 1 {
+		t.Fatalf("Expected a single block in the database of the other tag, got %+v", created[1])
+	}
+	synced, ok := created[1].Children[0].(*notionapi.SyncedBlock)
+	if !ok || synced.SyncedBlock.SyncedFrom == nil || synced.SyncedBlock.SyncedFrom.BlockID != "synced" {
+		t.Errorf("Expected a copy of the synced block, got %+v", created[1].Children[0])
+	}
+}
+
+func TestCreateChildPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("hub_id"), gomock.Any()).Return(&notionapi.GetChildrenResponse{
+		Results: []notionapi.Block{&notionapi.ChildPageBlock{
+			BasicBlock: notionapi.BasicBlock{ID: "existing_id", Type: notionapi.BlockTypeChildPage},
+			ChildPage: struct {
+				Title string `json:"title"`
+			}{Title: "Existing"},
+		}},
+	}, nil).Times(1)
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		if req.Parent.Type != notionapi.ParentTypePageID || req.Parent.PageID != "hub_id" {
+			t.Errorf("Expected the page under the hub page, got %+v", req.Parent)
+		}
+		if len(req.Children) == 0 {
+			t.Error("Expected the content of the page")
+		}
+		return &notionapi.Page{ID: "child_id"}, nil
+	}).Times(1)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id"}
+	WithHierarchy(map[string]string{"Child": "Hub", "Existing": "Hub", "Orphan": "Missing Hub"})(client)
+	client.recordPage("Hub", "hub_id")
+
+	doc := &models.Document{Title: "Child", Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("Body")}}}
+	if err := client.CreatePage(ctx, doc, []string{"go"}); err != nil {
+		t.Fatalf("CreatePage() error = %v", err)
+	}
+	if err := client.CreatePage(ctx, &models.Document{Title: "Existing"}, nil); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("CreatePage() error = %v, want ErrAlreadyExists", err)
+	}
+	if client.pages["Child"] != "child_id" || client.pages["Existing"] != "existing_id" {
+		t.Errorf("Expected the child pages to be recorded, got %v", client.pages)
+	}
+	if hub := client.hubPage("Orphan"); hub != "" {
+		t.Errorf("Expected no hub page for a hub not created, got %q", hub)
+	}
+}
+
+func TestLinkAnchors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	doc := &models.Document{Title: "Page", Blocks: []models.Block{
+		{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineText, Text: "See "}, {Type: models.InlineAnchor, Text: "usage", URL: "Usage"}}},
+		{Type: models.BlockHeading, Level: 2, Inline: text("Usage")},
+		{Type: models.BlockParagraph, Inline: text("Body")},
+	}}
+	mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("page-id"), gomock.Any()).Return(&notionapi.GetChildrenResponse{
+		Results: []notionapi.Block{
+			&notionapi.ParagraphBlock{BasicBlock: notionapi.BasicBlock{ID: "intro-id", Type: notionapi.BlockTypeParagraph}},
+			&notionapi.Heading2Block{BasicBlock: notionapi.BasicBlock{ID: "usage-id", Type: notionapi.BlockTypeHeading2}},
+			&notionapi.ParagraphBlock{BasicBlock: notionapi.BasicBlock{ID: "body-id", Type: notionapi.BlockTypeParagraph}},
+		},
+	}, nil).Times(1)
+	mockBlock.EXPECT().Update(ctx, notionapi.BlockID("intro-id"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.BlockID, req *notionapi.BlockUpdateRequest) (notionapi.Block, error) {
+		if req.Paragraph == nil || len(req.Paragraph.RichText) != 2 {
+			t.Fatalf("Expected the text of the paragraph, got %+v", req)
+		}
+		if link := req.Paragraph.RichText[1].Text.Link; link == nil || link.Url != "https://www.notion.so/pageid#usageid" {
+			t.Errorf("Expected a link to the heading block, got %+v", link)
+		}
+		return nil, nil
+	}).Times(1)
+
+	client := &Client{client: mockClient}
+	if err := client.linkAnchors(ctx, "page-id", "page-id", doc); err != nil {
+		t.Fatalf("linkAnchors() error = %v", err)
+	}
+	if client.anchorTargets != nil {
+		t.Error("Expected the anchor targets cleared")
+	}
+
+	// Documents without anchors need no requests
+	if err := client.linkAnchors(ctx, "page-id", "page-id", &models.Document{Blocks: doc.Blocks[1:]}); err != nil {
+		t.Fatalf("linkAnchors() error = %v", err)
+	}
+	if markdown := blocksMarkdown(ConvertDocument(doc)); !strings.HasPrefix(markdown, "See usage") {
+		t.Errorf("Expected anchors created as text, got %q", markdown)
+	}
+}
+
+func TestCreatePageMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	// The tag database from a run without metrics gets the missing property once
+	tagDB := &notionapi.Database{
+		ID:         "db",
+		Parent:     notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+		Title:      []notionapi.RichText{{PlainText: "go"}},
+		Properties: notionapi.PropertyConfigs{viewsProperty: &notionapi.NumberPropertyConfig{}},
+	}
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{Results: []notionapi.Object{tagDB}}, nil).Times(2)
+	mockDatabase.EXPECT().Update(ctx, notionapi.DatabaseID("db"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.DatabaseID, req *notionapi.DatabaseUpdateRequest) (*notionapi.Database, error) {
+		if _, ok := req.Properties[viewsProperty]; ok || len(req.Properties) != 1 {
+			t.Errorf("Expected only the missing Backlinks property to be added, got %+v", req.Properties)
+		}
+		return tagDB, nil
+	}).Times(1)
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil)
+
+	var created []*notionapi.PageCreateRequest
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		created = append(created, req)
+		return &notionapi.Page{ID: notionapi.ObjectID(fmt.Sprintf("page%d", len(created)))}, nil
+	}).Times(2)
+	mockPage.EXPECT().Get(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, id notionapi.PageID) (*notionapi.Page, error) {
+		return &notionapi.Page{ID: notionapi.ObjectID(id)}, nil
+	}).Times(2)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id"}
+	WithPageMetrics()(client)
+	for _, doc := range []*models.Document{{Title: "Popular", Views: 42, Backlinks: 3}, {Title: "Quiet"}} {
+		if err := client.CreatePage(ctx, doc, []string{"go"}); err != nil {
+			t.Fatalf("CreatePage(%q) error = %v", doc.Title, err)
+		}
+	}
+
+	views, ok := created[0].Properties[viewsProperty].(notionapi.NumberProperty)
+	if !ok || views.Number != 42 {
+		t.Errorf("Expected 42 views, got %+v", created[0].Properties[viewsProperty])
+	}
+	backlinks, ok := created[0].Properties[backlinksProperty].(notionapi.NumberProperty)
+	if !ok || backlinks.Number != 3 {
+		t.Errorf("Expected 3 backlinks, got %+v", created[0].Properties[backlinksProperty])
+	}
+}
+
+func TestMissingLinks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("parent"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil)
+	stub := mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		if title := plainText(req.Properties["Title"].(notionapi.TitleProperty).Title); title != "Missing" || len(req.Children) != 0 {
+			t.Errorf("Expected an empty stub page for Missing, got %q with %d blocks", title, len(req.Children))
+		}
+		return &notionapi.Page{ID: "stub"}, nil
+	})
+	mockPage.EXPECT().Create(ctx, gomock.Any()).After(stub).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		richText := req.Children[0].(*notionapi.ParagraphBlock).Paragraph.RichText
+		if len(richText) != 3 || richText[0].Mention != nil || richText[2].Mention == nil || richText[2].Mention.Page.ID != "stub" {
+			t.Errorf("Expected only the missing page mentioned, got %+v", richText)
+		}
+		return &notionapi.Page{ID: "page"}, nil
+	})
+
+	exists := func(title string) bool { return title == "Known" }
+	client := &Client{
+		client:     mockClient,
+		parentID:   "parent",
+		parentType: notionapi.ParentTypeDatabaseID,
+		parentDB: &notionapi.Database{ID: "parent", Properties: notionapi.PropertyConfigs{
+			"Title": &notionapi.TitlePropertyConfig{Type: notionapi.PropertyConfigTypeTitle},
+		}},
+	}
+	WithMissingLinks(MissingLinksStub, exists)(client)
+	doc := &models.Document{
+		Title: "Page",
+		Blocks: []models.Block{{Type: models.BlockParagraph, Inline: []models.Inline{
+			{Type: models.InlinePageLink, Text: "Known", URL: "known"},
+			{Type: models.InlineText, Text: " and "},
+			{Type: models.InlinePageLink, Text: "Missing"},
+		}}},
+	}
+	if err := client.CreatePage(ctx, doc, nil); err != nil {
+		t.Fatalf("CreatePage() error = %v", err)
+	}
+
+	if _, err := ParseMissingLinkMode("mention"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestConvertDocumentTableOfContents(t *testing.T) {
+	doc := &models.Document{
+		TableOfContents: true,
+		Blocks:          []models.Block{{Type: models.BlockHeading, Level: 2, Inline: text("Heading")}},
+	}
+	blocks := ConvertDocument(doc)
+	if len(blocks) != 2 || blocks[0].GetType() != notionapi.BlockTypeTableOfContents {
+		t.Errorf("Expected a table of contents before the blocks, got %+v", blocks)
+	}
+
+	doc.TableOfContents = false
+	if blocks := ConvertDocument(doc); len(blocks) != 1 {
+		t.Errorf("Expected no table of contents, got %+v", blocks)
+	}
+}
+
+func TestConvertColumns(t *testing.T) {
+	doc := &models.Document{Blocks: []models.Block{
+		{Type: models.BlockParagraph, Inline: text("columns:")},
+		{Type: models.BlockBullet, Inline: text("Pros")},
+		{Type: models.BlockBullet, Indent: 1, Inline: text("fast")},
+		{Type: models.BlockBullet, Indent: 2, Inline: text("really")},
+		{Type: models.BlockBullet, Inline: text("Cons")},
+		{Type: models.BlockBullet, Indent: 1, Inline: text("slow")},
+		{Type: models.BlockParagraph, Inline: text("after")},
+	}}
+
+	blocks := ConvertDocument(doc, WithColumns("columns:"))
+	if len(blocks) != 2 {
+		t.Fatalf("Expected a column list and the paragraph after it, got %d blocks", len(blocks))
+	}
+	list, ok := blocks[0].(*notionapi.ColumnListBlock)
+	if !ok || len(list.ColumnList.Children) != 2 {
+		t.Fatalf("Expected a column list of two columns, got %+v", blocks[0])
+	}
+	var columns []string
+	for _, column := range list.ColumnList.Children {
+		columns = append(columns, strings.TrimSpace(blocksMarkdown(column.(*notionapi.ColumnBlock).Column.Children)))
+	}
+	expected := []string{"Pros\n- fast\n- really", "Cons\n- slow"}
+	if !slices.Equal(columns, expected) {
+		t.Errorf("Expected columns %q, got %q", expected, columns)
+	}
+	if count := countBlocks(blocks); count != 9 {
+		t.Errorf("Expected the columns and their blocks counted, got %d", count)
+	}
+
+	// A single column and a disabled marker leave the lines as they are
+	if blocks := ConvertDocument(&models.Document{Blocks: doc.Blocks[:4]}, WithColumns("columns:")); countBlocks(blocks) != 4 || blocks[0].GetType() == notionapi.BlockTypeColumnList {
+		t.Errorf("Expected no columns for a single item, got %d blocks", countBlocks(blocks))
+	}
+	if blocks := ConvertDocument(doc); countBlocks(blocks) != len(doc.Blocks) || blocks[0].GetType() == notionapi.BlockTypeColumnList {
+		t.Errorf("Expected no columns without the option, got %d blocks", countBlocks(blocks))
+	}
+}
+
+func TestConvertAttachments(t *testing.T) {
+	attachment := func(kind models.AttachmentKind, url string) models.Block {
+		return models.Block{Type: models.BlockAttachment, Attachment: &models.Attachment{Kind: kind, URL: url, Name: "name"}}
+	}
+	doc := &models.Document{Blocks: []models.Block{
+		attachment(models.AttachmentAudio, "https://example.com/a.mp3"),
+		attachment(models.AttachmentVideo, "https://example.com/v.mp4"),
+		attachment(models.AttachmentPDF, "https://example.com/d.pdf"),
+		attachment(models.AttachmentFile, "https://example.com/f.zip"),
+		attachment(models.AttachmentBookmark, "https://example.com/b.wav"),
+	}}
+
+	blocks := ConvertDocument(doc)
+	expected := []string{"[audio](https://example.com/a.mp3)", "[video](https://example.com/v.mp4)",
+		"[pdf](https://example.com/d.pdf)", "[file](https://example.com/f.zip)", "[bookmark](https://example.com/b.wav)"}
+	if markdown := strings.Split(strings.TrimSpace(blocksMarkdown(blocks)), "\n"); !slices.Equal(markdown, expected) {
+		t.Errorf("Expected blocks %q, got %q", expected, markdown)
+	}
+	if file := blocks[3].(*notionapi.FileBlock); len(file.File.Caption) == 0 || file.File.Caption[0].Text.Content != "name" {
+		t.Errorf("Expected the file name as the caption, got %+v", file.File.Caption)
+	}
+}
+
+func TestConvertFooter(t *testing.T) {
+	doc := &models.Document{
+		Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("text")}},
+		Footer: &models.Footer{Project: "project", URL: "https://scrapbox.io/project/Page", Version: "v1.0.0"},
+	}
+	blocks := ConvertDocument(doc)
+	if len(blocks) != 2 {
+		t.Fatalf("Expected the footer after the blocks, got %d blocks", len(blocks))
+	}
+	callout, ok := blocks[1].(*notionapi.CalloutBlock)
+	if !ok || callout.Callout.Color != "gray_background" {
+		t.Fatalf("Expected a gray callout, got %+v", blocks[1])
+	}
+	if rich := callout.Callout.RichText; len(rich) < 2 || rich[1].Text.Link == nil || rich[1].Text.Link.Url != "https://scrapbox.io/project/Page" {
+		t.Errorf("Expected the footer to link to the page on Scrapbox, got %+v", rich)
+	}
+}
+
+func TestConvertDocumentHistory(t *testing.T) {
+	doc := &models.Document{
+		History: models.HistoryFootnote,
+		Blocks: []models.Block{
+			{Type: models.BlockParagraph, Inline: text("text"), History: &models.LineHistory{Author: "alice"}},
+			{Type: models.BlockCode, Text: "code", History: &models.LineHistory{Author: "bob"}},
+		},
+	}
+
+	blocks := ConvertDocument(doc)
+	if len(blocks) != 4 {
+		t.Fatalf("Expected the blocks, a divider and a footnote, got %d blocks", len(blocks))
+	}
+	if got := plainText(blocks[0].(*notionapi.ParagraphBlock).Paragraph.RichText); got != "text [1]" {
+		t.Errorf("Expected a reference to the footnote, got %q", got)
+	}
+	if got := plainText(blocks[3].(*notionapi.ParagraphBlock).Paragraph.RichText); got != "[1] alice, 1970-01-01 00:00 UTC" {
+		t.Errorf("Expected the footnote, got %q", got)
+	}
+	if len(doc.Blocks[0].Inline) != 1 {
+		t.Errorf("Expected the document left as it was")
+	}
+}
+
+func TestPageSummary(t *testing.T) {
+	doc := &models.Document{Title: "Page", Views: 2, Summary: "first line"}
+
+	client := &Client{}
+	WithPageSummary()(client)
+	configs := client.metricPropertyConfigs()
+	if _, ok := configs[summaryProperty]; !ok || len(configs) != 1 {
+		t.Errorf("Expected only the Summary property, got %+v", configs)
+	}
+	summary, ok := client.metricProperties(doc)[summaryProperty].(notionapi.RichTextProperty)
+	if !ok || plainText(summary.RichText) != "first line" {
+		t.Errorf("Expected the summary of the page, got %+v", summary)
+	}
+
+	if properties := client.metricProperties(&models.Document{Title: "Empty"}); len(properties) != 0 {
+		t.Errorf("Expected no properties without a summary, got %+v", properties)
+	}
+}
+
+func TestSourceURLProperty(t *testing.T) {
+	client := &Client{}
+	WithSourceURL()(client)
+	if configs := client.metricPropertyConfigs(); len(configs) != 1 || configs[sourceURLProperty] == nil {
+		t.Errorf("Expected only the Scrapbox URL property, got %+v", configs)
+	}
+	doc := &models.Document{Title: "Page", SourceURL: "https://scrapbox.io/project/Page"}
+	source, ok := client.metricProperties(doc)[sourceURLProperty].(notionapi.URLProperty)
+	if !ok || source.URL != doc.SourceURL {
+		t.Errorf("Expected the Scrapbox URL of the page, got %+v", source)
+	}
+}
+
+func TestParentTags(t *testing.T) {
+	tests := []struct {
+		tag     string
+		levels  []string
+		parents []string
+	}{
+		{tag: "go", levels: []string{"go"}, parents: []string{}},
+		{tag: "work/projectX", levels: []string{"work", "projectX"}, parents: []string{"work"}},
+		{tag: "a/b/c", levels: []string{"a", "b", "c"}, parents: []string{"a", "a/b"}},
+		{tag: "/work//notes/", levels: []string{"work", "notes"}, parents: []string{"work"}},
+	}
+	for _, tt := range tests {
+		if got := tagLevels(tt.tag); !slices.Equal(got, tt.levels) {
+			t.Errorf("tagLevels(%q) = %q, want %q", tt.tag, got, tt.levels)
+		}
+		if got := parentTags(tt.tag); !slices.Equal(got, tt.parents) {
+			t.Errorf("parentTags(%q) = %q, want %q", tt.tag, got, tt.parents)
+		}
+	}
+}
+
+func TestCreatePageNestedTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockBlock := mock_notion.NewMockBlockService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+	mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+	// The parent has the page of the work tag, whose page has the projectX database
+	mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("parent"), gomock.Any()).Return(&notionapi.GetChildrenResponse{Results: []notionapi.Block{
+		&notionapi.ChildPageBlock{BasicBlock: notionapi.BasicBlock{ID: "page-work", Type: notionapi.BlockTypeChildPage}, ChildPage: struct {
+			Title string `json:"title"`
+		}{Title: "work"}},
+	}}, nil)
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.SearchRequest) (*notionapi.SearchResponse, error) {
+		if req.Query != "projectX" {
+			t.Errorf("Expected a search for the last level of the tag, got %q", req.Query)
+		}
+		return &notionapi.SearchResponse{Results: []notionapi.Object{
+			&notionapi.Database{
+				ID:     "db-other",
+				Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "page-other"},
+				Title:  []notionapi.RichText{{PlainText: "projectX"}},
+			},
+			&notionapi.Database{
+				ID:     "db-projectX",
+				Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "page-work"},
+				Title:  []notionapi.RichText{{PlainText: "projectX"}},
+			},
+		}}, nil
+	})
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db-projectX"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil)
+
+	var created *notionapi.PageCreateRequest
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		created = req
+		return &notionapi.Page{ID: "page1"}, nil
+	})
+	mockPage.EXPECT().Get(ctx, gomock.Any()).Return(&notionapi.Page{ID: "page1"}, nil)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", tagMode: TagModeDatabases, tagHierarchy: TagHierarchyNested}
+	if err := client.CreatePage(ctx, &models.Document{Title: "Plan"}, []string{"work/projectX"}); err != nil {
+		t.Fatalf("CreatePage() error = %v", err)
+	}
+
+	if created == nil || created.Parent.DatabaseID != "db-projectX" {
+		t.Fatalf("Expected the page in the projectX database of the work page, got %+v", created)
+	}
+	if tag, ok := created.Properties["Tag"].(notionapi.SelectProperty); !ok || tag.Select.Name != "work/projectX" {
+		t.Errorf("Expected the whole tag in the Tag property, got %+v", created.Properties["Tag"])
+	}
+}
+
+func TestParentTagPropertyValue(t *testing.T) {
+	if value := parentTagPropertyValue("go"); value != nil {
+		t.Errorf("Expected no parent tags for a tag without levels, got %+v", value)
+	}
+	value, ok := parentTagPropertyValue("a/b/c").(notionapi.MultiSelectProperty)
+	if !ok || len(value.MultiSelect) != 2 || value.MultiSelect[0].Name != "a" || value.MultiSelect[1].Name != "a/b" {
+		t.Errorf("Expected the ancestors of the tag, got %+v", value)
+	}
+}
+
+func TestCreatePageUntaggedDatabase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	// Only the database of untagged pages is looked for, never a page under the parent
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.SearchRequest) (*notionapi.SearchResponse, error) {
+		if req.Query != "Untagged" || req.Filter.Value != "database" {
+			t.Errorf("Expected a search for the untagged database, got %+v", req)
+		}
+		return &notionapi.SearchResponse{Results: []notionapi.Object{&notionapi.Database{
+			ID:     "db-untagged",
+			Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+			Title:  []notionapi.RichText{{PlainText: "Untagged"}},
+		}}}, nil
+	})
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db-untagged"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{}, nil)
+
+	var created *notionapi.PageCreateRequest
+	mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+		created = req
+		return &notionapi.Page{ID: "page1"}, nil
+	})
+	mockPage.EXPECT().Get(ctx, gomock.Any()).Return(&notionapi.Page{ID: "page1"}, nil)
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", tagMode: TagModeDatabases}
+	WithUntaggedDatabase("Untagged")(client)
+	if err := client.CreatePage(ctx, &models.Document{Title: "Loose"}, nil); err != nil {
+		t.Fatalf("CreatePage() error = %v", err)
+	}
+	if created == nil || created.Parent.DatabaseID != "db-untagged" {
+		t.Errorf("Expected the page in the untagged database, got %+v", created)
+	}
+}
+
+func TestCreatePageConflictPolicy(t *testing.T) {
+	childPage := func(id, title string) notionapi.Block {
+		return &notionapi.ChildPageBlock{BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(id), Type: notionapi.BlockTypeChildPage}, ChildPage: struct {
+			Title string `json:"title"`
+		}{Title: title}}
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		mockClient := mock_notion.NewMockNotionClient(ctrl)
+		mockPage := mock_notion.NewMockPageService(ctrl)
+		mockBlock := mock_notion.NewMockBlockService(ctrl)
+		mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+		mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+		// A page elsewhere whose title only contains the title is no conflict
+		mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("parent"), gomock.Any()).Return(&notionapi.GetChildrenResponse{Results: []notionapi.Block{
+			childPage("page-note", "Note"),
+			childPage("page-note-2", "Note (2)"),
+			childPage("page-notes", "Notes"),
+		}}, nil)
+		var created *notionapi.PageCreateRequest
+		mockPage.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *notionapi.PageCreateRequest) (*notionapi.Page, error) {
+			created = req
+			return &notionapi.Page{ID: "page-note-3"}, nil
+		})
+
+		client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", onConflict: ConflictRename}
+		if err := client.CreatePage(ctx, &models.Document{Title: "Note"}, nil); err != nil {
+			t.Fatalf("CreatePage() error = %v", err)
+		}
+		title, ok := created.Properties["title"].(notionapi.TitleProperty)
+		if !ok || plainText(title.Title) != "Note (3)" {
+			t.Errorf("Expected the page created with the first free title, got %+v", created.Properties["title"])
+		}
+		if client.pages["Note"] != "page-note-3" {
+			t.Errorf("Expected links to the title to go to the new page, got %q", client.pages["Note"])
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		mockClient := mock_notion.NewMockNotionClient(ctrl)
+		mockBlock := mock_notion.NewMockBlockService(ctrl)
+		mockClient.EXPECT().Block().Return(mockBlock).AnyTimes()
+
+		mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("parent"), gomock.Any()).Return(&notionapi.GetChildrenResponse{Results: []notionapi.Block{
+			childPage("page-note", "Note"),
+		}}, nil)
+		mockBlock.EXPECT().GetChildren(ctx, notionapi.BlockID("page-note"), gomock.Any()).Return(&notionapi.GetChildrenResponse{Results: []notionapi.Block{
+			&notionapi.ParagraphBlock{BasicBlock: notionapi.BasicBlock{ID: "old", Type: notionapi.BlockTypeParagraph}},
+		}}, nil)
+		mockBlock.EXPECT().Delete(ctx, notionapi.BlockID("old")).Return(nil, nil)
+		mockBlock.EXPECT().AppendChildren(ctx, notionapi.BlockID("page-note"), gomock.Any()).Return(&notionapi.AppendBlockChildrenResponse{}, nil)
+
+		client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", onConflict: ConflictUpdate}
+		doc := &models.Document{Title: "Note", Blocks: []models.Block{{Type: models.BlockParagraph, Inline: text("New")}}}
+		if err := client.CreatePage(ctx, doc, nil); err != nil {
+			t.Fatalf("CreatePage() error = %v", err)
+		}
+	})
+}
+
+func TestRenamePage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{Results: []notionapi.Object{&notionapi.Database{
+		ID:     "db-go",
+		Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+		Title:  []notionapi.RichText{{PlainText: "go"}},
+	}}}, nil)
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db-go"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{Results: []notionapi.Page{
+		{ID: "page-old", Properties: notionapi.Properties{
+			"Name": &notionapi.TitleProperty{Type: notionapi.PropertyTypeTitle, Title: []notionapi.RichText{{PlainText: "Old"}}},
+		}},
+	}}, nil)
+	mockPage.EXPECT().Get(ctx, notionapi.PageID("page-old")).Return(&notionapi.Page{ID: "page-old", Properties: notionapi.Properties{
+		"Tag":  &notionapi.SelectProperty{Type: notionapi.PropertyTypeSelect},
+		"Name": &notionapi.TitleProperty{Type: notionapi.PropertyTypeTitle, Title: []notionapi.RichText{{PlainText: "Old"}}},
+	}}, nil)
+	mockPage.EXPECT().Update(ctx, notionapi.PageID("page-old"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.PageID, req *notionapi.PageUpdateRequest) (*notionapi.Page, error) {
+		title, ok := req.Properties["Name"].(notionapi.TitleProperty)
+		if !ok || plainText(title.Title) != "New" {
+			t.Errorf("Expected the title property set to the new title, got %+v", req.Properties)
+		}
+		return &notionapi.Page{ID: "page-old"}, nil
+	})
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", tagMode: TagModeDatabases}
+	if err := client.RenamePage(ctx, "Old", &models.Document{Title: "New"}, []string{"go"}); err != nil {
+		t.Fatalf("RenamePage() error = %v", err)
+	}
+	if pages := client.dbPages["db-go"]; pages["New"] != "page-old" || pages["Old"] != "" {
+		t.Errorf("Expected the page known under its new title, got %v", pages)
+	}
+	if client.pages["New"] != "page-old" {
+		t.Errorf("Expected links to the new title to go to the page, got %v", client.pages)
+	}
+}
+
+func TestArchivePage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockClient := mock_notion.NewMockNotionClient(ctrl)
+	mockPage := mock_notion.NewMockPageService(ctrl)
+	mockSearch := mock_notion.NewMockSearchService(ctrl)
+	mockDatabase := mock_notion.NewMockDatabaseService(ctrl)
+	mockClient.EXPECT().Page().Return(mockPage).AnyTimes()
+	mockClient.EXPECT().Search().Return(mockSearch).AnyTimes()
+	mockClient.EXPECT().Database().Return(mockDatabase).AnyTimes()
+
+	mockSearch.EXPECT().Do(ctx, gomock.Any()).Return(&notionapi.SearchResponse{Results: []notionapi.Object{&notionapi.Database{
+		ID:     "db-go",
+		Parent: notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: "parent"},
+		Title:  []notionapi.RichText{{PlainText: "go"}},
+	}}}, nil)
+	mockDatabase.EXPECT().Query(ctx, notionapi.DatabaseID("db-go"), gomock.Any()).Return(&notionapi.DatabaseQueryResponse{Results: []notionapi.Page{
+		{ID: "page-gone", Properties: notionapi.Properties{
+			"Name": &notionapi.TitleProperty{Type: notionapi.PropertyTypeTitle, Title: []notionapi.RichText{{PlainText: "Gone"}}},
+		}},
+	}}, nil)
+	mockPage.EXPECT().Update(ctx, notionapi.PageID("page-gone"), gomock.Any()).DoAndReturn(func(_ context.Context, _ notionapi.PageID, req *notionapi.PageUpdateRequest) (*notionapi.Page, error) {
+		if !req.Archived {
+			t.Errorf("Expected the page archived, got %+v", req)
+		}
+		return &notionapi.Page{ID: "page-gone"}, nil
+	})
+
+	client := &Client{client: mockClient, parentID: "parent", parentType: "page_id", tagMode: TagModeDatabases}
+	archived, err := client.ArchivePage(ctx, "Gone", []string{"go"})
+	if err != nil {
+		t.Fatalf("ArchivePage() error = %v", err)
+	}
+	if archived != 1 {
+		t.Errorf("Expected 1 page archived, got %d", archived)
+	}
+	if pages := client.dbPages["db-go"]; pages["Gone"] != "" {
+		t.Errorf("Expected the archived page forgotten, got %v", pages)
+	}
+}
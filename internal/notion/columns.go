@@ -0,0 +1,68 @@
+package notion
+
+import (
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// WithColumns lays out the list items following a line of the marker text,
+// such as columns:, side by side: every top level item starts a column holding
+// it and the items nested under it. The marker line is left out.
+func WithColumns(marker string) Option {
+	return func(c *Client) {
+		c.columnMarker = marker
+	}
+}
+
+// columnList converts the list items following the marker line at blocks[start]
+// to a column list, returning the number of items it holds. It returns false
+// unless the marker is followed by at least two top level items, as Notion
+// needs two columns in a column list.
+func (c *Client) columnList(blocks []models.Block, start int) (notionapi.Block, int, bool) {
+	marker := blocks[start]
+	if c.columnMarker == "" || marker.Type != models.BlockParagraph ||
+		strings.TrimSpace(models.PlainText(marker.Inline)) != c.columnMarker {
+		return nil, 0, false
+	}
+
+	var columns [][]models.Block
+	n := 0
+	for _, block := range blocks[start+1:] {
+		if !isListBlock(block) {
+			break
+		}
+		if block.Indent == 0 {
+			// The top level item heads its column as a paragraph
+			block.Type = models.BlockParagraph
+			columns = append(columns, nil)
+		} else {
+			// Notion takes only so much nesting in one request, so the items of
+			// a column are not nested further
+			block.Indent = 0
+		}
+		columns[len(columns)-1] = append(columns[len(columns)-1], block)
+		n++
+	}
+	if len(columns) < 2 {
+		return nil, 0, false
+	}
+
+	list := &notionapi.ColumnListBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeColumnList,
+		},
+	}
+	for _, column := range columns {
+		list.ColumnList.Children = append(list.ColumnList.Children, &notionapi.ColumnBlock{
+			BasicBlock: notionapi.BasicBlock{
+				Object: "block",
+				Type:   notionapi.BlockTypeColumn,
+			},
+			Column: notionapi.Column{Children: c.convertBlocks(column)},
+		})
+	}
+	return list, n, true
+}
@@ -0,0 +1,68 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// ConflictPolicy decides what happens to a page created directly under the
+// parent when the parent already has a page with its title
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves the existing page alone and skips the page
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictRename creates the page with a number added to its title, such
+	// as "Title (2)"
+	ConflictRename ConflictPolicy = "rename"
+	// ConflictUpdate replaces the content of the existing page with the page
+	ConflictUpdate ConflictPolicy = "update"
+)
+
+// ParseConflictPolicy parses a conflict policy name
+func ParseConflictPolicy(policy string) (ConflictPolicy, error) {
+	switch ConflictPolicy(policy) {
+	case ConflictSkip, ConflictRename, ConflictUpdate:
+		return ConflictPolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid conflict policy %q: must be one of skip, rename, update", policy)
+}
+
+// WithConflictPolicy sets what happens to a page created directly under the
+// parent page or in the parent database when a page with its title is there
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(c *Client) {
+		c.onConflict = policy
+	}
+}
+
+// updateConflictingPage replaces the content of the existing page with the
+// title of the document, for the update conflict policy
+func (c *Client) updateConflictingPage(ctx context.Context, doc *models.Document, id notionapi.PageID) error {
+	if err := c.replaceBlocks(ctx, notionapi.BlockID(id), c.convertDocumentToBlocks(doc)); err != nil {
+		return fmt.Errorf("failed to update existing page: %w", err)
+	}
+	if err := c.linkAnchors(ctx, id, notionapi.BlockID(id), doc); err != nil {
+		return err
+	}
+	c.recordPage(doc.Title, id)
+	logger.Info("Notion page has already existed, updated its content", map[string]interface{}{
+		"title": doc.Title,
+	})
+	return nil
+}
+
+// uniqueTitle returns the title with the lowest number from 2 added that no
+// page of pages has, for the rename conflict policy
+func uniqueTitle(title string, pages map[string]notionapi.PageID) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", title, n)
+		if _, ok := pages[candidate]; !ok {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+)
+
+// ObjectType is the kind of a Notion object created by a run
+type ObjectType string
+
+const (
+	// ObjectPage is a page, in a tag database or under the parent page
+	ObjectPage ObjectType = "page"
+	// ObjectDatabase is a tag database
+	ObjectDatabase ObjectType = "database"
+)
+
+// CreatedObject is a page or database created by the client
+type CreatedObject struct {
+	Type  ObjectType `json:"type"`
+	ID    string     `json:"id"`
+	Title string     `json:"title"`
+}
+
+// recordCreated remembers an object created by the client
+func (c *Client) recordCreated(objectType ObjectType, id, title string) {
+	c.created = append(c.created, CreatedObject{Type: objectType, ID: id, Title: title})
+}
+
+// Created returns the pages and databases created by the client, in order of creation
+func (c *Client) Created() []CreatedObject {
+	return c.created
+}
+
+// Archive moves a created page or database to the trash
+func (c *Client) Archive(ctx context.Context, object CreatedObject) error {
+	switch object.Type {
+	case ObjectPage:
+		if _, err := c.client.Page().Update(ctx, notionapi.PageID(object.ID), &notionapi.PageUpdateRequest{Archived: true}); err != nil {
+			return fmt.Errorf("failed to archive page: %w", err)
+		}
+	case ObjectDatabase:
+		// Databases are archived by deleting the block holding them
+		if _, err := c.client.Block().Delete(ctx, notionapi.BlockID(object.ID)); err != nil {
+			return fmt.Errorf("failed to archive database: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown object type %q", object.Type)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// PageRef identifies a page of a database
+type PageRef struct {
+	ID      string
+	Created time.Time
+}
+
+// DuplicateGroup is a set of pages with the same title in one database. The
+// newest page is kept and the others are duplicates to archive.
+type DuplicateGroup struct {
+	Database   string
+	Title      string
+	Keep       PageRef
+	Duplicates []PageRef
+}
+
+// FindDuplicates scans the databases within the parent page, or the parent
+// database itself, for pages with the same title, as created by re-running
+// older versions of the migration
+func (c *Client) FindDuplicates(ctx context.Context) ([]DuplicateGroup, error) {
+	databases, err := c.searchDatabases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.parentDB != nil {
+		databases = append([]*notionapi.Database{c.parentDB}, databases...)
+	}
+
+	var groups []DuplicateGroup
+	for _, db := range databases {
+		rows, err := c.queryDatabase(ctx, notionapi.DatabaseID(db.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query database %q: %w", plainText(db.Title), err)
+		}
+		logger.Debug("Scanned database for duplicates", map[string]interface{}{
+			"database": plainText(db.Title),
+			"pages":    len(rows),
+		})
+		groups = append(groups, duplicateGroups(plainText(db.Title), rows)...)
+	}
+	return groups, nil
+}
+
+// duplicateGroups groups the pages of a database by normalized title and
+// returns the groups of more than one page, keeping the newest page of each
+func duplicateGroups(database string, rows []notionapi.Page) []DuplicateGroup {
+	byTitle := make(map[string][]PageRef)
+	var titles []string
+	for _, row := range rows {
+		title := normalizeTitle(pageTitle(row))
+		if title == "" {
+			continue
+		}
+		if _, ok := byTitle[title]; !ok {
+			titles = append(titles, title)
+		}
+		byTitle[title] = append(byTitle[title], PageRef{ID: string(row.ID), Created: row.CreatedTime})
+	}
+
+	var groups []DuplicateGroup
+	for _, title := range titles {
+		pages := byTitle[title]
+		if len(pages) < 2 {
+			continue
+		}
+		sort.SliceStable(pages, func(i, j int) bool {
+			return pages[i].Created.After(pages[j].Created)
+		})
+		groups = append(groups, DuplicateGroup{
+			Database:   database,
+			Title:      title,
+			Keep:       pages[0],
+			Duplicates: pages[1:],
+		})
+	}
+	return groups
+}
+
+// searchDatabases returns every database within the parent page
+func (c *Client) searchDatabases(ctx context.Context) ([]*notionapi.Database, error) {
+	var databases []*notionapi.Database
+	var cursor notionapi.Cursor
+	for {
+		results, err := c.client.Search().Do(ctx, &notionapi.SearchRequest{
+			Filter: notionapi.SearchFilter{
+				Property: "object",
+				Value:    "database",
+			},
+			StartCursor: cursor,
+			PageSize:    maxPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for databases: %w", err)
+		}
+		for _, result := range c.withinParent(ctx, results).Results {
+			if db, ok := result.(*notionapi.Database); ok {
+				databases = append(databases, db)
+			}
+		}
+		if !results.HasMore {
+			return databases, nil
+		}
+		cursor = results.NextCursor
+	}
+}
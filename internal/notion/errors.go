@@ -0,0 +1,68 @@
+package notion
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jomei/notionapi"
+)
+
+// Errors the client returns wrapped in the errors they stand for, so callers
+// can tell failures apart with errors.Is
+var (
+	// ErrAlreadyExists is returned when a page is not created because a page
+	// with its title is already everywhere it would be created
+	ErrAlreadyExists = errors.New("page already exists in Notion")
+	// ErrRateLimited is returned when Notion kept rate limiting a request
+	// after every retry
+	ErrRateLimited = errors.New("rate limited by Notion")
+	// ErrPayloadTooLarge is returned when Notion rejected a request as too
+	// large, which retrying doesn't help
+	ErrPayloadTooLarge = errors.New("request too large for Notion")
+	// ErrUnauthorized is returned when Notion rejected the API key, or the
+	// integration lacks access to a page or database
+	ErrUnauthorized = errors.New("not authorized by Notion")
+)
+
+// classifiedError is an error wrapped with the error of the package it stands
+// for, keeping its message
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// classifyError wraps an error from the Notion API with the error of the
+// package it stands for, if any
+func classifyError(err error) error {
+	var classified *classifiedError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+	var limited *notionapi.RateLimitedError
+	var kind error
+	switch s := status(err); {
+	case errors.As(err, &limited) || s == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case s == http.StatusRequestEntityTooLarge:
+		kind = ErrPayloadTooLarge
+	case s == http.StatusUnauthorized || s == http.StatusForbidden:
+		kind = ErrUnauthorized
+	default:
+		return err
+	}
+	return &classifiedError{kind: kind, err: err}
+}
+
+// alreadyExists returns the error for a page not created because it exists
+func alreadyExists(title string) error {
+	return fmt.Errorf("%w: %q", ErrAlreadyExists, title)
+}
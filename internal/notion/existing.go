@@ -0,0 +1,82 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// maxPageSize is the largest number of results Notion returns per query
+const maxPageSize = 100
+
+// databasePages returns the pages of a tag database by title. Each database is
+// scanned once per run and the result is kept up to date as pages are created,
+// instead of querying the database for every page added to it.
+func (c *Client) databasePages(ctx context.Context, id notionapi.DatabaseID) (map[string]notionapi.PageID, error) {
+	if pages, ok := c.dbPages[id]; ok {
+		return pages, nil
+	}
+
+	rows, err := c.queryDatabase(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database for existing pages: %w", err)
+	}
+	pages := make(map[string]notionapi.PageID)
+	for _, page := range rows {
+		if title := pageTitle(page); title != "" {
+			if _, ok := pages[title]; !ok {
+				pages[title] = notionapi.PageID(page.ID)
+			}
+		}
+	}
+
+	logger.Debug("Scanned tag database for existing pages", map[string]interface{}{
+		"database": id,
+		"pages":    len(pages),
+	})
+	c.trackDatabase(id, pages)
+	return pages, nil
+}
+
+// queryDatabase returns every page of a database, following the result cursor
+func (c *Client) queryDatabase(ctx context.Context, id notionapi.DatabaseID) ([]notionapi.Page, error) {
+	var pages []notionapi.Page
+	var cursor notionapi.Cursor
+	for {
+		resp, err := c.client.Database().Query(ctx, id, &notionapi.DatabaseQueryRequest{
+			StartCursor: cursor,
+			PageSize:    maxPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, resp.Results...)
+		if !resp.HasMore {
+			return pages, nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+// trackDatabase starts keeping the pages of a database, such as one just created
+func (c *Client) trackDatabase(id notionapi.DatabaseID, pages map[string]notionapi.PageID) {
+	if c.dbPages == nil {
+		c.dbPages = make(map[notionapi.DatabaseID]map[string]notionapi.PageID)
+	}
+	c.dbPages[id] = pages
+}
+
+// pageTitle returns the title of a database page
+func pageTitle(page notionapi.Page) string {
+	for _, property := range page.Properties {
+		switch title := property.(type) {
+		case *notionapi.TitleProperty:
+			return plainText(title.Title)
+		case notionapi.TitleProperty:
+			return plainText(title.Title)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// WithHierarchy creates the pages of the titles mapped to a hub title as child
+// pages of the Notion page of the hub, instead of under the parent or in tag
+// databases. Hubs must be created before their children; pages whose hub has
+// no Notion page yet are created as usual.
+func WithHierarchy(hubs map[string]string) Option {
+	return func(c *Client) {
+		c.hubs = hubs
+	}
+}
+
+// hubPage returns the Notion page of the hub the page of the title is a child
+// of, or an empty ID if it has none
+func (c *Client) hubPage(title string) notionapi.PageID {
+	hub, ok := c.hubs[title]
+	if !ok {
+		return ""
+	}
+	return c.pages[hub]
+}
+
+// createChildPage creates the page as a child page of the hub page, unless the
+// hub already has a child page with the title
+func (c *Client) createChildPage(ctx context.Context, doc *models.Document, hub notionapi.PageID) error {
+	title := doc.Title
+
+	children, err := c.childPages(ctx, hub)
+	if err != nil {
+		return err
+	}
+	if existingID, ok := children[title]; ok {
+		c.recordPage(title, existingID)
+		logger.Info("Notion page has already existed, skip creating", map[string]interface{}{
+			"title": title,
+			"hub":   c.hubs[title],
+		})
+		return alreadyExists(title)
+	}
+
+	page, err := c.createPageWithBlocks(ctx, &notionapi.PageCreateRequest{
+		Parent: notionapi.Parent{
+			Type:   notionapi.ParentTypePageID,
+			PageID: hub,
+		},
+		Properties: notionapi.Properties{
+			"title": notionapi.TitleProperty{Title: []notionapi.RichText{textRichText(title)}},
+		},
+		Icon:  c.pageIcon(title),
+		Cover: c.pageCover(doc),
+	}, c.convertDocumentToBlocks(doc))
+	if page != nil {
+		c.recordCreated(ObjectPage, string(page.ID), title)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create child page: %w", err)
+	}
+	if err := c.linkAnchors(ctx, notionapi.PageID(page.ID), notionapi.BlockID(page.ID), doc); err != nil {
+		return err
+	}
+	children[title] = notionapi.PageID(page.ID)
+	c.recordPage(title, notionapi.PageID(page.ID))
+	logger.Info("Successfully created Notion page", map[string]interface{}{
+		"title": title,
+		"hub":   c.hubs[title],
+	})
+	return nil
+}
+
+// childPages returns the child pages of a page by title, listing them once
+func (c *Client) childPages(ctx context.Context, id notionapi.PageID) (map[string]notionapi.PageID, error) {
+	if pages, ok := c.hubChildren[id]; ok {
+		return pages, nil
+	}
+
+	pages := make(map[string]notionapi.PageID)
+	var cursor notionapi.Cursor
+	for {
+		resp, err := c.client.Block().GetChildren(ctx, notionapi.BlockID(id), &notionapi.Pagination{
+			StartCursor: cursor,
+			PageSize:    maxPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list child pages: %w", err)
+		}
+		for _, block := range resp.Results {
+			if child, ok := block.(*notionapi.ChildPageBlock); ok {
+				pages[child.ChildPage.Title] = notionapi.PageID(child.GetID())
+			}
+		}
+		if !resp.HasMore {
+			break
+		}
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+
+	if c.hubChildren == nil {
+		c.hubChildren = make(map[notionapi.PageID]map[string]notionapi.PageID)
+	}
+	c.hubChildren[id] = pages
+	return pages, nil
+}
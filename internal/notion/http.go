@@ -0,0 +1,384 @@
+package notion
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/tracing"
+)
+
+// NewHTTPClient creates an HTTP client for the Notion API that sends requests
+// through proxyURL and gives up on requests taking longer than timeout. An empty
+// proxyURL uses the proxy of the HTTPS_PROXY environment variable, if any, and a
+// zero timeout waits indefinitely.
+func NewHTTPClient(proxyURL string, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// httpClientFromEnv creates the HTTP client configured by the NOTION_PROXY_URL
+// and NOTION_TIMEOUT environment variables, or returns nil if neither is set
+func httpClientFromEnv() (*http.Client, error) {
+	proxyURL := os.Getenv("NOTION_PROXY_URL")
+	timeoutEnv := os.Getenv("NOTION_TIMEOUT")
+	if proxyURL == "" && timeoutEnv == "" {
+		return nil, nil
+	}
+
+	var timeout time.Duration
+	if timeoutEnv != "" {
+		t, err := time.ParseDuration(timeoutEnv)
+		if err != nil || t < 0 {
+			return nil, fmt.Errorf("invalid NOTION_TIMEOUT %q: must be a duration such as 30s", timeoutEnv)
+		}
+		timeout = t
+	}
+	return NewHTTPClient(proxyURL, timeout)
+}
+
+// WithHTTPClient sends the requests to the Notion API with the given HTTP client,
+// such as one created by NewHTTPClient or one with custom TLS settings
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// Notion allows an average of three requests per second, with some bursts
+// beyond it
+const (
+	defaultRateLimit = 3
+	defaultRateBurst = 5
+)
+
+// rateLimitFromEnv returns the rate limit and burst of NOTION_RATE_LIMIT and
+// NOTION_RATE_BURST, defaulting to the rate Notion allows
+func rateLimitFromEnv() (float64, int, error) {
+	rate, burst := float64(defaultRateLimit), defaultRateBurst
+	if env := os.Getenv("NOTION_RATE_LIMIT"); env != "" {
+		r, err := strconv.ParseFloat(env, 64)
+		if err != nil || r < 0 {
+			return 0, 0, fmt.Errorf("invalid NOTION_RATE_LIMIT %q: must be a number of requests per second, 0 for no limit", env)
+		}
+		rate = r
+	}
+	if env := os.Getenv("NOTION_RATE_BURST"); env != "" {
+		b, err := strconv.Atoi(env)
+		if err != nil || b < 1 {
+			return 0, 0, fmt.Errorf("invalid NOTION_RATE_BURST %q: must be a positive number of requests", env)
+		}
+		burst = b
+	}
+	return rate, burst, nil
+}
+
+// WithRateLimit sends at most perSecond requests per second to the Notion API
+// on average, shared by every request of the client. 0 disables the limit.
+func WithRateLimit(perSecond float64) Option {
+	return func(c *Client) {
+		c.rateLimit = perSecond
+	}
+}
+
+// WithRateBurst lets up to burst requests through at once ahead of the rate
+// limit, after the client has been idle long enough to save them up
+func WithRateBurst(burst int) Option {
+	return func(c *Client) {
+		c.rateBurst = burst
+	}
+}
+
+// rateLimitedClient returns a copy of client, or of the default client if it is
+// nil, that holds requests back to send at most perSecond of them per second on
+// average, in bursts of at most burst
+func rateLimitedClient(client *http.Client, perSecond float64, burst int) *http.Client {
+	limited := http.Client{}
+	if client != nil {
+		limited = *client
+	}
+	next := limited.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	limited.Transport = &rateLimitTransport{
+		next:      next,
+		perSecond: perSecond,
+		burst:     float64(max(burst, 1)),
+	}
+	return &limited
+}
+
+// baseURLClient returns a copy of client, or of the default client if it is nil,
+// that sends the requests to baseURL instead of the host notionapi is built for
+func baseURLClient(client *http.Client, baseURL *url.URL) *http.Client {
+	redirected := http.Client{}
+	if client != nil {
+		redirected = *client
+	}
+	next := redirected.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	redirected.Transport = &baseURLTransport{next: next, baseURL: baseURL}
+	return &redirected
+}
+
+// baseURLTransport sends requests to the scheme and host of baseURL, with the
+// path of baseURL prepended to theirs
+type baseURLTransport struct {
+	next    http.RoundTripper
+	baseURL *url.URL
+}
+
+func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.baseURL.Scheme
+	req.URL.Host = t.baseURL.Host
+	req.URL.Path = path.Join("/", t.baseURL.Path, req.URL.Path)
+	req.URL.RawPath = ""
+	req.Host = t.baseURL.Host
+	return t.next.RoundTrip(req)
+}
+
+// traceFromEnv reports whether the NOTION_TRACE environment variable enables
+// tracing of the API requests
+func traceFromEnv() (bool, error) {
+	env := os.Getenv("NOTION_TRACE")
+	if env == "" {
+		return false, nil
+	}
+	trace, err := strconv.ParseBool(env)
+	if err != nil {
+		return false, fmt.Errorf("invalid NOTION_TRACE %q: must be true or false", env)
+	}
+	return trace, nil
+}
+
+// tracingClient returns a copy of client, or of the default client if it is nil,
+// that logs every request and its response at debug level
+func tracingClient(client *http.Client) *http.Client {
+	traced := http.Client{}
+	if client != nil {
+		traced = *client
+	}
+	next := traced.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	traced.Transport = &traceTransport{next: next, retries: make(map[string]int)}
+	return &traced
+}
+
+// traceTransport logs the method, path and status of requests, with the sizes
+// of the payloads but not their content, which holds the pages, nor the headers,
+// which hold the API key. The query is left out of the path as well.
+type traceTransport struct {
+	next http.RoundTripper
+
+	mu sync.Mutex
+	// retries counts the rate limited attempts of requests by method and URL, as
+	// notionapi sends a rate limited request again
+	retries map[string]int
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+	t.mu.Lock()
+	retry := t.retries[key]
+	t.mu.Unlock()
+
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	fields := map[string]interface{}{
+		"method":        req.Method,
+		"path":          req.URL.Path,
+		"retry":         retry,
+		"request_bytes": req.ContentLength,
+		"duration_ms":   time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		logger.Debug("Notion API request failed", fields)
+		return nil, err
+	}
+	fields["status"] = resp.StatusCode
+	fields["response_bytes"] = resp.ContentLength
+	logger.Debug("Notion API request", fields)
+
+	t.mu.Lock()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		t.retries[key] = retry + 1
+	} else {
+		delete(t.retries, key)
+	}
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// APIStats are the counts and time of the requests sent to the Notion API
+type APIStats struct {
+	// Calls is the number of requests sent, retries included
+	Calls int
+	// Retries is the number of requests rate limited by Notion and sent again
+	Retries int
+	// Failures is the number of requests that failed or got an error status
+	// other than a rate limit
+	Failures int
+	// Duration is the time spent waiting for responses
+	Duration time.Duration
+}
+
+// APIStats returns the counts and time of the requests the client sent so far
+func (c *Client) APIStats() APIStats {
+	if c.stats == nil {
+		return APIStats{}
+	}
+	return c.stats.snapshot()
+}
+
+// countingClient returns a copy of client, or of the default client if it is
+// nil, that counts the requests it sends into the stats of the client
+func (c *Client) countingClient(client *http.Client) *http.Client {
+	counting := http.Client{}
+	if client != nil {
+		counting = *client
+	}
+	next := counting.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.stats = &statsTransport{next: next}
+	counting.Transport = c.stats
+	return &counting
+}
+
+// statsTransport counts the requests it sends into stats
+type statsTransport struct {
+	next http.RoundTripper
+
+	mu    sync.Mutex
+	stats APIStats
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Calls++
+	t.stats.Duration += time.Since(started)
+	switch {
+	case err != nil:
+		t.stats.Failures++
+	case resp.StatusCode == http.StatusTooManyRequests:
+		t.stats.Retries++
+	case resp.StatusCode >= http.StatusBadRequest:
+		t.stats.Failures++
+	}
+	return resp, err
+}
+
+// snapshot returns the stats so far
+func (t *statsTransport) snapshot() APIStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// spanClient returns a copy of client, or of the default client if it is nil,
+// that records a span of every request when tracing is on
+func spanClient(client *http.Client) *http.Client {
+	spanning := http.Client{}
+	if client != nil {
+		spanning = *client
+	}
+	next := spanning.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	spanning.Transport = &spanTransport{next: next}
+	return &spanning
+}
+
+// spanTransport records a span of every request, as a child of the span of the
+// request context, such as the upload of a page
+type spanTransport struct {
+	next http.RoundTripper
+}
+
+func (t *spanTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, span := tracing.Start(req.Context(), "notion.api", tracing.KindClient, map[string]string{
+		"http.request.method": req.Method,
+		"url.path":            req.URL.Path,
+	})
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	span.SetAttribute("http.response.status_code", strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.End(fmt.Errorf("notion API returned %s", resp.Status))
+	} else {
+		span.End(nil)
+	}
+	return resp, nil
+}
+
+// rateLimitTransport holds requests back with a token bucket: the bucket holds
+// up to burst tokens, refilled at perSecond, and each request takes a token or
+// waits for the next
+type rateLimitTransport struct {
+	next      http.RoundTripper
+	perSecond float64
+	burst     float64
+
+	mu sync.Mutex
+	// tokens are the requests that may be sent without waiting as of last,
+	// negative for requests already waiting for tokens
+	tokens float64
+	last   time.Time
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	now := time.Now()
+	if t.last.IsZero() {
+		t.tokens = t.burst
+	} else {
+		t.tokens = min(t.burst, t.tokens+now.Sub(t.last).Seconds()*t.perSecond)
+	}
+	t.last = now
+	t.tokens--
+	var wait time.Duration
+	if t.tokens < 0 {
+		wait = time.Duration(-t.tokens / t.perSecond * float64(time.Second))
+	}
+	t.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,55 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// CreateIndexPage creates a page under the parent listing the migrated pages
+// grouped by tag. Pages created or found by CreatePage are linked, others are
+// listed by title.
+func (c *Client) CreateIndexPage(ctx context.Context, title string, groups []models.TagGroup) error {
+	blocks := c.indexBlocks(groups)
+
+	page, err := c.createPageWithBlocks(ctx, &notionapi.PageCreateRequest{
+		Parent:     c.parent(),
+		Properties: c.parentProperties(title, nil, nil),
+	}, blocks)
+	if page != nil {
+		c.recordCreated(ObjectPage, string(page.ID), title)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create index page: %w", err)
+	}
+
+	logger.Info("Successfully created Notion index page", map[string]interface{}{
+		"title":  title,
+		"blocks": len(blocks),
+	})
+	return nil
+}
+
+// indexBlocks returns a heading for each tag followed by its pages
+func (c *Client) indexBlocks(groups []models.TagGroup) []notionapi.Block {
+	var blocks []notionapi.Block
+	for _, group := range groups {
+		heading := "#" + group.Tag
+		if group.Tag == "" {
+			heading = "Untagged"
+		}
+		blocks = append(blocks, c.createHeadingBlock([]models.Inline{{Type: models.InlineText, Text: heading}}, 2))
+
+		for _, title := range group.Titles {
+			if id, ok := c.pages[title]; ok {
+				blocks = append(blocks, c.createLinkToPageBlock(id))
+				continue
+			}
+			blocks = append(blocks, c.createBulletedListBlock([]models.Inline{{Type: models.InlineText, Text: title}}, nil))
+		}
+	}
+	return blocks
+}
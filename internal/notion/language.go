@@ -0,0 +1,81 @@
+package notion
+
+import (
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// notionLanguages is the set of code block languages accepted by the Notion API
+var notionLanguages = map[string]bool{
+	"abap": true, "arduino": true, "bash": true, "basic": true, "c": true, "clojure": true,
+	"coffeescript": true, "c++": true, "c#": true, "css": true, "dart": true, "diff": true,
+	"docker": true, "elixir": true, "elm": true, "erlang": true, "flow": true, "fortran": true,
+	"f#": true, "gherkin": true, "glsl": true, "go": true, "graphql": true, "groovy": true,
+	"haskell": true, "html": true, "java": true, "javascript": true, "json": true, "julia": true,
+	"kotlin": true, "latex": true, "less": true, "lisp": true, "livescript": true, "lua": true,
+	"makefile": true, "markdown": true, "markup": true, "matlab": true, "mermaid": true, "nix": true,
+	"objective-c": true, "ocaml": true, "pascal": true, "perl": true, "php": true, "plain text": true,
+	"powershell": true, "prolog": true, "protobuf": true, "python": true, "r": true, "reason": true,
+	"ruby": true, "rust": true, "sass": true, "scala": true, "scheme": true, "scss": true,
+	"shell": true, "sql": true, "swift": true, "typescript": true, "vb.net": true, "verilog": true,
+	"vhdl": true, "visual basic": true, "webassembly": true, "xml": true, "yaml": true,
+	"java/c/c++/c#": true,
+}
+
+// languageAliases maps common markdown fence languages to their Notion names
+var languageAliases = map[string]string{
+	"cjs":        "javascript",
+	"cpp":        "c++",
+	"cs":         "c#",
+	"csharp":     "c#",
+	"dockerfile": "docker",
+	"ex":         "elixir",
+	"exs":        "elixir",
+	"fsharp":     "f#",
+	"golang":     "go",
+	"hs":         "haskell",
+	"js":         "javascript",
+	"jsx":        "javascript",
+	"kt":         "kotlin",
+	"make":       "makefile",
+	"md":         "markdown",
+	"mjs":        "javascript",
+	"objc":       "objective-c",
+	"plaintext":  "plain text",
+	"proto":      "protobuf",
+	"ps1":        "powershell",
+	"pwsh":       "powershell",
+	"py":         "python",
+	"rb":         "ruby",
+	"rs":         "rust",
+	"sh":         "shell",
+	"tex":        "latex",
+	"text":       "plain text",
+	"ts":         "typescript",
+	"tsx":        "typescript",
+	"txt":        "plain text",
+	"vb":         "visual basic",
+	"yml":        "yaml",
+	"zsh":        "shell",
+}
+
+// notionCodeLanguage returns the Notion code block language for a markdown fence language,
+// falling back to "plain text" for languages Notion does not support
+func notionCodeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return "plain text"
+	}
+	if notionLanguages[language] {
+		return language
+	}
+	if alias, ok := languageAliases[language]; ok {
+		return alias
+	}
+
+	logger.Debug("Unsupported code language, falling back to plain text", map[string]interface{}{
+		"language": language,
+	})
+	return "plain text"
+}
@@ -0,0 +1,195 @@
+package notion
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jomei/notionapi"
+)
+
+// blocksMarkdown renders Notion blocks as markdown, one line per block with
+// toggle children indented below their toggle. Blocks built for upload and
+// blocks fetched back from Notion render the same when their content is the same.
+func blocksMarkdown(blocks []notionapi.Block) string {
+	var md strings.Builder
+	writeBlocksMarkdown(&md, blocks, "")
+	return md.String()
+}
+
+// writeBlocksMarkdown writes blocks as markdown with the given indent
+func writeBlocksMarkdown(md *strings.Builder, blocks []notionapi.Block, indent string) {
+	for _, block := range blocks {
+		switch b := block.(type) {
+		case *notionapi.ColumnListBlock:
+			// Columns render one after another, as markdown has no columns
+			writeBlocksMarkdown(md, b.ColumnList.Children, indent)
+			continue
+		case *notionapi.ColumnBlock:
+			writeBlocksMarkdown(md, b.Column.Children, indent)
+			continue
+		}
+		md.WriteString(indent + blockMarkdown(block) + "\n")
+		writeBlocksMarkdown(md, blockChildren(block), indent+"  ")
+	}
+}
+
+// blockMarkdown renders a single Notion block as markdown
+func blockMarkdown(block notionapi.Block) string {
+	switch b := block.(type) {
+	case *notionapi.ParagraphBlock:
+		return richTextMarkdown(b.Paragraph.RichText)
+	case *notionapi.Heading1Block:
+		return "# " + richTextMarkdown(b.Heading1.RichText)
+	case *notionapi.Heading2Block:
+		return "## " + richTextMarkdown(b.Heading2.RichText)
+	case *notionapi.Heading3Block:
+		return "### " + richTextMarkdown(b.Heading3.RichText)
+	case *notionapi.BulletedListItemBlock:
+		return "- " + richTextMarkdown(b.BulletedListItem.RichText)
+	case *notionapi.NumberedListItemBlock:
+		// Notion numbers items itself, so the number is not part of the content
+		return "1. " + richTextMarkdown(b.NumberedListItem.RichText)
+	case *notionapi.ToDoBlock:
+		marker := "[ ]"
+		if b.ToDo.Checked {
+			marker = "[x]"
+		}
+		return "- " + marker + " " + richTextMarkdown(b.ToDo.RichText)
+	case *notionapi.ToggleBlock:
+		return "- " + richTextMarkdown(b.Toggle.RichText)
+	case *notionapi.CodeBlock:
+		return fmt.Sprintf("```%s\n%s\n```", b.Code.Language, plainText(b.Code.RichText))
+	case *notionapi.QuoteBlock:
+		return "> " + richTextMarkdown(b.Quote.RichText)
+	case *notionapi.CalloutBlock:
+		icon := ""
+		if b.Callout.Icon != nil && b.Callout.Icon.Emoji != nil {
+			icon = string(*b.Callout.Icon.Emoji) + " "
+		}
+		return "> " + icon + richTextMarkdown(b.Callout.RichText)
+	case *notionapi.DividerBlock:
+		return "---"
+	case *notionapi.EquationBlock:
+		return "$$" + b.Equation.Expression + "$$"
+	case *notionapi.ImageBlock:
+		return fmt.Sprintf("![%s](%s)", richTextMarkdown(b.Image.Caption), fileURL(b.Image.External, b.Image.File))
+	case *notionapi.VideoBlock:
+		return fmt.Sprintf("[video](%s)", fileURL(b.Video.External, b.Video.File))
+	case *notionapi.AudioBlock:
+		return fmt.Sprintf("[audio](%s)", fileURL(b.Audio.External, b.Audio.File))
+	case *notionapi.PdfBlock:
+		return fmt.Sprintf("[pdf](%s)", fileURL(b.Pdf.External, b.Pdf.File))
+	case *notionapi.FileBlock:
+		return fmt.Sprintf("[file](%s)", fileURL(b.File.External, b.File.File))
+	case *notionapi.EmbedBlock:
+		return fmt.Sprintf("[embed](%s)", b.Embed.URL)
+	case *notionapi.BookmarkBlock:
+		return fmt.Sprintf("[bookmark](%s)", b.Bookmark.URL)
+	case *notionapi.LinkToPageBlock:
+		return fmt.Sprintf("[page](%s)", normalizeID(string(b.LinkToPage.PageID)))
+	default:
+		return fmt.Sprintf("<%s>", block.GetType())
+	}
+}
+
+// fileURL returns the URL of an external or uploaded file
+func fileURL(external, file *notionapi.FileObject) string {
+	switch {
+	case external != nil:
+		return external.URL
+	case file != nil:
+		return file.URL
+	}
+	return ""
+}
+
+// textSpan is a run of rich text with the formatting that shows in markdown
+type textSpan struct {
+	text     string
+	url      string
+	equation bool
+	bold     bool
+	italic   bool
+	strike   bool
+	code     bool
+}
+
+// richTextMarkdown renders rich text as markdown. Adjacent segments with the
+// same formatting are merged first, as Notion may split text differently than
+// it was uploaded.
+func richTextMarkdown(richText []notionapi.RichText) string {
+	var spans []textSpan
+	for _, rt := range richText {
+		span := richTextSpan(rt)
+		if n := len(spans); n > 0 && !span.equation && !spans[n-1].equation {
+			last := spans[n-1]
+			last.text = span.text
+			if last == span {
+				spans[n-1].text += span.text
+				continue
+			}
+		}
+		spans = append(spans, span)
+	}
+
+	var md strings.Builder
+	for _, span := range spans {
+		if span.text == "" {
+			continue
+		}
+		text := span.text
+		if span.equation {
+			md.WriteString("$" + text + "$")
+			continue
+		}
+		if span.code {
+			text = "`" + text + "`"
+		}
+		if span.strike {
+			text = "~~" + text + "~~"
+		}
+		if span.italic {
+			text = "_" + text + "_"
+		}
+		if span.bold {
+			text = "**" + text + "**"
+		}
+		if span.url != "" {
+			text = fmt.Sprintf("[%s](%s)", text, span.url)
+		}
+		md.WriteString(text)
+	}
+	return md.String()
+}
+
+// blockLink reports whether the URL links to a block of a Notion page, as
+// anchors do once linked, so they compare equal to the text they are created as
+func blockLink(url string) bool {
+	return strings.Contains(url, "#") && (strings.HasPrefix(url, "/") || strings.HasPrefix(url, "https://www.notion.so/"))
+}
+
+// richTextSpan returns the text and formatting of a rich text segment
+func richTextSpan(rt notionapi.RichText) textSpan {
+	if rt.Equation != nil {
+		return textSpan{text: rt.Equation.Expression, equation: true}
+	}
+
+	var span textSpan
+	switch {
+	case rt.Text != nil:
+		span.text = rt.Text.Content
+		if rt.Text.Link != nil && !blockLink(rt.Text.Link.Url) {
+			span.url = rt.Text.Link.Url
+		}
+	default:
+		span.text = rt.PlainText
+		span.url = rt.Href
+	}
+	if a := rt.Annotations; a != nil {
+		span.bold = a.Bold
+		span.italic = a.Italic
+		span.strike = a.Strikethrough
+		span.code = a.Code
+	}
+	return span
+}
@@ -0,0 +1,129 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+const (
+	// viewsProperty is the number property of the Scrapbox views of a page
+	viewsProperty = "Views"
+	// backlinksProperty is the number property of the pages linking to a page
+	backlinksProperty = "Backlinks"
+	// summaryProperty is the text property of the Scrapbox description of a page
+	summaryProperty = "Summary"
+	// sourceURLProperty is the URL property of the Scrapbox page of a page
+	sourceURLProperty = "Scrapbox URL"
+)
+
+// WithPageMetrics fills the "Views" and "Backlinks" number properties of pages,
+// so the migrated databases can be sorted by popularity
+func WithPageMetrics() Option {
+	return func(c *Client) {
+		c.metrics = true
+	}
+}
+
+// WithPageSummary fills the "Summary" text property of pages with the
+// description Scrapbox shows for them, from exports with metadata
+func WithPageSummary() Option {
+	return func(c *Client) {
+		c.summary = true
+	}
+}
+
+// WithSourceURL fills the "Scrapbox URL" property of pages with the URL of
+// their page on Scrapbox, to jump back to the source
+func WithSourceURL() Option {
+	return func(c *Client) {
+		c.sourceURL = true
+	}
+}
+
+// pageProperties reports whether pages get the metric, summary or source URL
+// properties
+func (c *Client) pageProperties() bool {
+	return c.metrics || c.summary || c.sourceURL
+}
+
+// metricPropertyConfigs returns the number properties of the page metrics, the
+// text property of the summary and the URL property of the source, as enabled
+func (c *Client) metricPropertyConfigs() notionapi.PropertyConfigs {
+	configs := notionapi.PropertyConfigs{}
+	if c.metrics {
+		configs[viewsProperty] = notionapi.NumberPropertyConfig{
+			Type:   notionapi.PropertyConfigTypeNumber,
+			Number: notionapi.NumberFormat{Format: notionapi.FormatNumber},
+		}
+		configs[backlinksProperty] = notionapi.NumberPropertyConfig{
+			Type:   notionapi.PropertyConfigTypeNumber,
+			Number: notionapi.NumberFormat{Format: notionapi.FormatNumber},
+		}
+	}
+	if c.summary {
+		configs[summaryProperty] = notionapi.RichTextPropertyConfig{Type: notionapi.PropertyConfigTypeRichText}
+	}
+	if c.sourceURL {
+		configs[sourceURLProperty] = notionapi.URLPropertyConfig{Type: notionapi.PropertyConfigTypeURL}
+	}
+	return configs
+}
+
+// metricProperties returns the page metrics, the summary and the source URL of
+// the document as properties, as enabled
+func (c *Client) metricProperties(doc *models.Document) notionapi.Properties {
+	properties := notionapi.Properties{}
+	if c.metrics {
+		properties[viewsProperty] = notionapi.NumberProperty{
+			Type:   notionapi.PropertyTypeNumber,
+			Number: float64(doc.Views),
+		}
+		properties[backlinksProperty] = notionapi.NumberProperty{
+			Type:   notionapi.PropertyTypeNumber,
+			Number: float64(doc.Backlinks),
+		}
+	}
+	if c.summary && doc.Summary != "" {
+		properties[summaryProperty] = notionapi.RichTextProperty{
+			Type:     notionapi.PropertyTypeRichText,
+			RichText: []notionapi.RichText{textRichText(doc.Summary)},
+		}
+	}
+	if c.sourceURL && doc.SourceURL != "" {
+		properties[sourceURLProperty] = notionapi.URLProperty{
+			Type: notionapi.PropertyTypeURL,
+			URL:  doc.SourceURL,
+		}
+	}
+	return properties
+}
+
+// ensureMetricProperties adds the number properties of the page metrics to a
+// database created without them, such as by a run without page metrics
+func (c *Client) ensureMetricProperties(ctx context.Context, db *notionapi.Database) error {
+	if c.metricDBs[db.ID] {
+		return nil
+	}
+	missing := notionapi.PropertyConfigs{}
+	for name, config := range c.metricPropertyConfigs() {
+		if _, ok := db.Properties[name]; !ok {
+			missing[name] = config
+		}
+	}
+	if len(missing) > 0 {
+		_, err := c.client.Database().Update(ctx, notionapi.DatabaseID(db.ID), &notionapi.DatabaseUpdateRequest{
+			Properties: missing,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add page metric properties to database: %w", err)
+		}
+	}
+	if c.metricDBs == nil {
+		c.metricDBs = make(map[notionapi.ObjectID]bool)
+	}
+	c.metricDBs[db.ID] = true
+	return nil
+}
@@ -0,0 +1,160 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// MissingLinkMode decides what links to pages missing from the export become
+type MissingLinkMode string
+
+const (
+	// MissingLinksPlain keeps links to missing pages as plain text
+	MissingLinksPlain MissingLinkMode = "plain"
+	// MissingLinksLink mentions the Notion page of the title when there is one
+	// under the parent, keeping the others as plain text
+	MissingLinksLink MissingLinkMode = "link"
+	// MissingLinksStub mentions the Notion page of the title, creating an empty
+	// placeholder page under the parent when there is none
+	MissingLinksStub MissingLinkMode = "stub"
+)
+
+// ParseMissingLinkMode parses a missing link mode name
+func ParseMissingLinkMode(mode string) (MissingLinkMode, error) {
+	switch MissingLinkMode(mode) {
+	case MissingLinksPlain, MissingLinksLink, MissingLinksStub:
+		return MissingLinkMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid missing link mode %q: must be one of plain, link, stub", mode)
+}
+
+// WithMissingLinks sets what page links become when exists reports the page
+// they link to is missing from the export
+func WithMissingLinks(mode MissingLinkMode, exists func(title string) bool) Option {
+	return func(c *Client) {
+		c.missingLinks = mode
+		c.pageExists = exists
+	}
+}
+
+// resolveMissingLinks finds the Notion pages of the links of the document to
+// pages missing from the export, so the links convert to mentions of them. With
+// stubs, pages not found are created in stub mode.
+func (c *Client) resolveMissingLinks(ctx context.Context, doc *models.Document, stubs bool) error {
+	if c.missingLinks == "" || c.missingLinks == MissingLinksPlain || c.pageExists == nil {
+		return nil
+	}
+	if c.linkTargets == nil {
+		c.linkTargets = make(map[string]notionapi.PageID)
+	}
+
+	for _, title := range missingLinkTitles(doc, c.pageExists) {
+		if _, ok := c.linkTargets[title]; ok {
+			continue
+		}
+		id, err := c.findLinkTarget(ctx, title)
+		if err != nil {
+			return err
+		}
+		if id == "" && stubs && c.missingLinks == MissingLinksStub {
+			if id, err = c.createStubPage(ctx, title); err != nil {
+				return err
+			}
+		}
+		// Titles without a page are remembered too, so they are searched once,
+		// unless a stub may still be created for them
+		if id != "" || stubs || c.missingLinks != MissingLinksStub {
+			c.linkTargets[title] = id
+		}
+	}
+	return nil
+}
+
+// findLinkTarget returns the ID of the Notion page with the title under the
+// parent, or an empty ID if there is none
+func (c *Client) findLinkTarget(ctx context.Context, title string) (notionapi.PageID, error) {
+	if id, ok := c.pages[title]; ok {
+		return id, nil
+	}
+	if c.parentType == notionapi.ParentTypeDatabaseID {
+		pages, err := c.databasePages(ctx, notionapi.DatabaseID(c.parentID))
+		if err != nil {
+			return "", err
+		}
+		return pages[title], nil
+	}
+
+	resp, err := c.client.Search().Do(ctx, &notionapi.SearchRequest{
+		Query: title,
+		Filter: notionapi.SearchFilter{
+			Property: "object",
+			Value:    "page",
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search for linked page: %w", err)
+	}
+	for _, result := range c.withinParent(ctx, resp).Results {
+		if page, ok := result.(*notionapi.Page); ok && pageTitle(*page) == title {
+			return notionapi.PageID(page.ID), nil
+		}
+	}
+	return "", nil
+}
+
+// createStubPage creates an empty page with the title under the parent
+func (c *Client) createStubPage(ctx context.Context, title string) (notionapi.PageID, error) {
+	page, err := c.client.Page().Create(ctx, &notionapi.PageCreateRequest{
+		Parent:     c.parent(),
+		Properties: c.parentProperties(title, nil, nil),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create stub page %q: %w", title, err)
+	}
+	id := notionapi.PageID(page.ID)
+	c.recordCreated(ObjectPage, string(id), title)
+	if pages, ok := c.dbPages[notionapi.DatabaseID(c.parentID)]; ok {
+		pages[title] = id
+	}
+	logger.Info("Created stub page for missing link", map[string]interface{}{
+		"title": title,
+	})
+	return id, nil
+}
+
+// missingLinkTitles returns the titles of the pages the document links to that
+// are missing from the export, once each
+func missingLinkTitles(doc *models.Document, exists func(title string) bool) []string {
+	var titles []string
+	seen := make(map[string]bool)
+	var walk func(inlines []models.Inline)
+	walk = func(inlines []models.Inline) {
+		for _, inline := range inlines {
+			if inline.Type == models.InlinePageLink && !seen[inline.Text] && !exists(inline.Text) {
+				seen[inline.Text] = true
+				titles = append(titles, inline.Text)
+			}
+			walk(inline.Children)
+		}
+	}
+	for _, block := range doc.Blocks {
+		walk(block.Inline)
+	}
+	return titles
+}
+
+// pageMention creates a rich text mentioning the Notion page, with the plain
+// text and link Notion gives mentions it returns
+func pageMention(title string, id notionapi.PageID) notionapi.RichText {
+	return notionapi.RichText{
+		Type:      "mention",
+		Mention:   &notionapi.Mention{Type: notionapi.MentionTypePage, Page: &notionapi.PageMention{ID: notionapi.ObjectID(id)}},
+		PlainText: title,
+		Href:      "https://www.notion.so/" + strings.ReplaceAll(string(id), "-", ""),
+	}
+}
@@ -0,0 +1,81 @@
+package notion
+
+import "fmt"
+
+// URLStyle controls how lines consisting of a single URL are uploaded
+type URLStyle string
+
+const (
+	// URLStyleBookmark uploads bare URLs as bookmark blocks with a preview
+	URLStyleBookmark URLStyle = "bookmark"
+	// URLStyleLink uploads bare URLs as paragraphs linking to the URL
+	URLStyleLink URLStyle = "link"
+	// URLStylePlain uploads bare URLs as plain text paragraphs
+	URLStylePlain URLStyle = "plain"
+)
+
+// ParseURLStyle parses a URL style name
+func ParseURLStyle(style string) (URLStyle, error) {
+	switch URLStyle(style) {
+	case URLStyleBookmark, URLStyleLink, URLStylePlain:
+		return URLStyle(style), nil
+	}
+	return "", fmt.Errorf("invalid URL style %q: must be one of bookmark, link, plain", style)
+}
+
+// WithURLStyle sets how lines consisting of a single URL are uploaded
+func WithURLStyle(style URLStyle) Option {
+	return func(c *Client) {
+		c.urlStyle = style
+	}
+}
+
+// WithToggleDepth collapses list items nested at or beyond depth into toggle blocks.
+// A depth of 1 turns every top-level item with children into a toggle; 0 disables toggles.
+func WithToggleDepth(depth int) Option {
+	return func(c *Client) {
+		c.toggleDepth = depth
+	}
+}
+
+// WithPageIcon sets the page icon to the first emoji in the title,
+// falling back to defaultEmoji when the title has none (empty for no icon)
+func WithPageIcon(defaultEmoji string) Option {
+	return func(c *Client) {
+		c.icon = true
+		c.defaultIcon = defaultEmoji
+	}
+}
+
+// WithCaseInsensitiveTags reuses tag databases whose title differs from the tag only in case
+func WithCaseInsensitiveTags() Option {
+	return func(c *Client) {
+		c.foldTagCase = true
+	}
+}
+
+// WithUntaggedDatabase creates pages without tags in the tag database of the
+// title, as if they had it as their tag, instead of directly under the parent
+// page. It has no effect with a parent database or in relation tag mode, which
+// hold every page in one database already.
+func WithUntaggedDatabase(title string) Option {
+	return func(c *Client) {
+		c.untaggedDatabase = title
+	}
+}
+
+// fileTags returns the tags whose databases a page goes in: its own tags, or
+// the untagged database for a page without tags
+func (c *Client) fileTags(tags []string) []string {
+	if len(tags) == 0 && c.untaggedDatabase != "" {
+		return []string{c.untaggedDatabase}
+	}
+	return tags
+}
+
+// WithPageCover sets the page cover to the first image found in the page
+func WithPageCover() Option {
+	return func(c *Client) {
+		c.cover = true
+	}
+}
@@ -0,0 +1,141 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// Preflight checks the API key and the access to the parent before any page is
+// processed, so that a misconfiguration is reported up front with a hint on how
+// to fix it instead of failing on the first page. It also detects the parent type.
+func (c *Client) Preflight(ctx context.Context) error {
+	me, err := c.client.User().Me(ctx)
+	if err != nil {
+		if status(err) == http.StatusUnauthorized {
+			return fmt.Errorf("the Notion API key was rejected, check NOTION_API_KEY: %w", err)
+		}
+		return fmt.Errorf("failed to check the Notion API key: %w", err)
+	}
+
+	fields := map[string]interface{}{
+		"integration": me.Name,
+	}
+	if me.Bot != nil {
+		fields["workspace"] = me.Bot.WorkspaceName
+	}
+	logger.Info("Connected to Notion", fields)
+
+	return c.DetectParent(ctx)
+}
+
+// DetectParent looks up the configured parent and sets the parent type to page
+// or database accordingly, so a database ID pasted as the parent works instead
+// of failing on the first page. It fails with an explanation if the integration
+// can't access the parent.
+func (c *Client) DetectParent(ctx context.Context) error {
+	_, pageErr := c.client.Page().Get(ctx, c.parentID)
+	if pageErr == nil {
+		c.parentType = notionapi.ParentTypePageID
+		return nil
+	}
+	switch status(pageErr) {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("the Notion API key was rejected, check NOTION_API_KEY: %w", pageErr)
+	case http.StatusForbidden:
+		return fmt.Errorf("the integration can't read content, enable the Read content capability in its settings: %w", pageErr)
+	}
+
+	db, dbErr := c.client.Database().Get(ctx, notionapi.DatabaseID(c.parentID))
+	if dbErr != nil {
+		if status(pageErr) == http.StatusNotFound && status(dbErr) == http.StatusNotFound {
+			return fmt.Errorf("parent %s is not a page or database the integration can access: "+
+				"check NOTION_PARENT_PAGE_ID and add the integration to the page from its Connections menu", c.parentID)
+		}
+		return fmt.Errorf("failed to look up parent %s: %w", c.parentID, errors.Join(pageErr, dbErr))
+	}
+
+	c.parentType = notionapi.ParentTypeDatabaseID
+	c.parentDB = db
+	logger.Info("Parent is a database, pages are added to it as rows", map[string]interface{}{
+		"database": plainText(db.Title),
+	})
+	return nil
+}
+
+// parent returns the parent of pages created directly under the parent
+func (c *Client) parent() notionapi.Parent {
+	if c.parentType == notionapi.ParentTypeDatabaseID {
+		return notionapi.Parent{
+			Type:       notionapi.ParentTypeDatabaseID,
+			DatabaseID: notionapi.DatabaseID(c.parentID),
+		}
+	}
+	return notionapi.Parent{
+		Type:   notionapi.ParentTypePageID,
+		PageID: c.parentID,
+	}
+}
+
+// parentProperties returns the properties of a page created directly under the
+// parent: the title, and if the parent database has the properties, the tags in
+// a Tags multi-select and the authors in the "Created by" people property
+func (c *Client) parentProperties(title string, tags []string, authors []notionapi.User) notionapi.Properties {
+	titleProperty := notionapi.TitleProperty{
+		Title: []notionapi.RichText{textRichText(title)},
+	}
+	if c.parentDB == nil {
+		return notionapi.Properties{"title": titleProperty}
+	}
+
+	properties := notionapi.Properties{}
+	for name, config := range c.parentDB.Properties {
+		switch config.GetType() {
+		case notionapi.PropertyConfigTypeTitle:
+			properties[name] = titleProperty
+		case notionapi.PropertyConfigTypeMultiSelect:
+			if !strings.EqualFold(name, "Tags") || len(tags) == 0 {
+				continue
+			}
+			options := make([]notionapi.Option, 0, len(tags))
+			for _, tag := range tags {
+				options = append(options, notionapi.Option{Name: tag})
+			}
+			properties[name] = notionapi.MultiSelectProperty{
+				Type:        notionapi.PropertyTypeMultiSelect,
+				MultiSelect: options,
+			}
+		case notionapi.PropertyConfigTypePeople:
+			if name != authorProperty || len(authors) == 0 {
+				continue
+			}
+			properties[name] = notionapi.PeopleProperty{
+				Type:   notionapi.PropertyTypePeople,
+				People: authors,
+			}
+		}
+	}
+	return properties
+}
+
+// HardFailure reports whether the error is one Notion keeps returning until
+// something outside the run changes, such as a revoked token or an outage: an
+// authentication or permission error, or a server error
+func HardFailure(err error) bool {
+	s := status(err)
+	return s == http.StatusUnauthorized || s == http.StatusForbidden || s >= http.StatusInternalServerError
+}
+
+// status returns the HTTP status of a Notion API error, or 0 for other errors
+func status(err error) int {
+	var apiErr *notionapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// ArchivePage moves the Notion pages migrated from the page of the title to the
+// trash, as when the page was deleted on Scrapbox, and returns the number of
+// pages archived. Failures of the Notion API are wrapped like those of
+// CreatePage.
+func (c *Client) ArchivePage(ctx context.Context, title string, tags []string) (int, error) {
+	archived, err := c.archivePage(ctx, title, tags)
+	return archived, classifyError(err)
+}
+
+// archivePage archives every copy of the page of the title
+func (c *Client) archivePage(ctx context.Context, title string, tags []string) (int, error) {
+	ids, err := c.pageCopies(ctx, title, tags)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		if _, err := c.client.Page().Update(ctx, id, &notionapi.PageUpdateRequest{Archived: true}); err != nil {
+			return i, fmt.Errorf("failed to archive page %q: %w", title, err)
+		}
+		c.forgetKnownPage(title, id)
+	}
+	if len(ids) > 0 {
+		logger.Info("Successfully archived Notion page", map[string]interface{}{
+			"title":  title,
+			"copies": len(ids),
+		})
+	}
+	return len(ids), nil
+}
+
+// forgetKnownPage drops an archived page from the pages the client has found or
+// created, so a page created later with its title is not taken for it
+func (c *Client) forgetKnownPage(title string, id notionapi.PageID) {
+	for _, pages := range c.dbPages {
+		if pages[title] == id {
+			delete(pages, title)
+		}
+	}
+	for _, pages := range c.hubChildren {
+		if pages[title] == id {
+			delete(pages, title)
+		}
+	}
+	if c.pages[title] == id {
+		delete(c.pages, title)
+	}
+}
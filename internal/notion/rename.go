@@ -0,0 +1,80 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// RenamePage renames the Notion pages migrated from a page under its old title
+// to the title of the document, as when the page was renamed on Scrapbox, so
+// updating the page doesn't create it again under the new title. Only the
+// titles change; UpdatePage brings the content up to date. Failures of the
+// Notion API are wrapped like those of CreatePage.
+func (c *Client) RenamePage(ctx context.Context, oldTitle string, doc *models.Document, tags []string) error {
+	return classifyError(c.renamePage(ctx, oldTitle, doc, tags))
+}
+
+// renamePage sets the title of every copy of the page of the old title
+func (c *Client) renamePage(ctx context.Context, oldTitle string, doc *models.Document, tags []string) error {
+	ids, err := c.pageCopies(ctx, oldTitle, tags)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		page, err := c.client.Page().Get(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to rename page %q: %w", oldTitle, err)
+		}
+		// The title property is named after the database of the page, or
+		// "title" for pages outside of databases
+		name := "title"
+		for key, property := range page.Properties {
+			if property.GetType() == notionapi.PropertyTypeTitle {
+				name = key
+			}
+		}
+		_, err = c.client.Page().Update(ctx, id, &notionapi.PageUpdateRequest{
+			Properties: notionapi.Properties{
+				name: notionapi.TitleProperty{Title: []notionapi.RichText{textRichText(doc.Title)}},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to rename page %q: %w", oldTitle, err)
+		}
+		c.renameKnownPage(oldTitle, doc.Title, id)
+	}
+	if len(ids) > 0 {
+		logger.Info("Successfully renamed Notion page", map[string]interface{}{
+			"title":     doc.Title,
+			"old_title": oldTitle,
+			"copies":    len(ids),
+		})
+	}
+	return nil
+}
+
+// renameKnownPage moves a renamed page to its new title in the pages the client
+// has found or created, so it is not looked for under its old title
+func (c *Client) renameKnownPage(oldTitle, title string, id notionapi.PageID) {
+	rename := func(pages map[string]notionapi.PageID) {
+		if pages[oldTitle] == id {
+			delete(pages, oldTitle)
+			pages[title] = id
+		}
+	}
+	for _, pages := range c.dbPages {
+		rename(pages)
+	}
+	for _, pages := range c.hubChildren {
+		rename(pages)
+	}
+	if c.pages[oldTitle] == id {
+		delete(c.pages, oldTitle)
+	}
+	c.recordPage(title, id)
+}
@@ -0,0 +1,88 @@
+package notion
+
+import (
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// createRichText converts inline spans to Notion rich text, carrying decorations
+// over as annotations and math as inline equations
+func (c *Client) createRichText(inlines []models.Inline) []notionapi.RichText {
+	richText := c.appendRichText(nil, inlines, notionapi.Annotations{})
+	if len(richText) == 0 {
+		richText = append(richText, textRichText(""))
+	}
+	return richText
+}
+
+// appendRichText appends the rich text of inline spans with the given annotations
+func (c *Client) appendRichText(richText []notionapi.RichText, inlines []models.Inline, annotations notionapi.Annotations) []notionapi.RichText {
+	for _, inline := range inlines {
+		switch inline.Type {
+		case models.InlineBold:
+			a := annotations
+			a.Bold = true
+			richText = c.appendRichText(richText, inline.Children, a)
+		case models.InlineItalic:
+			a := annotations
+			a.Italic = true
+			richText = c.appendRichText(richText, inline.Children, a)
+		case models.InlineStrike:
+			a := annotations
+			a.Strikethrough = true
+			richText = c.appendRichText(richText, inline.Children, a)
+		case models.InlineCode:
+			a := annotations
+			a.Code = true
+			richText = append(richText, styledRichText(inline.Text, "", a))
+		case models.InlineMath:
+			richText = append(richText, notionapi.RichText{
+				Type: "equation",
+				Equation: &notionapi.Equation{
+					Expression: inline.Text,
+				},
+			})
+		case models.InlineLink:
+			label := inline.Text
+			if label == "" {
+				label = inline.URL
+			}
+			richText = append(richText, styledRichText(label, inline.URL, annotations))
+		case models.InlineImage:
+			richText = append(richText, styledRichText(inline.URL, inline.URL, annotations))
+		case models.InlineAnchor:
+			// Linked to the block of the heading once it is created
+			richText = append(richText, styledRichText(inline.Text, c.anchorTargets[inline.URL], annotations))
+		case models.InlinePageLink:
+			if id := c.linkTargets[inline.Text]; id != "" {
+				richText = append(richText, pageMention(inline.Text, id))
+			} else {
+				richText = append(richText, styledRichText(inline.Text, "", annotations))
+			}
+		default:
+			richText = append(richText, styledRichText(inline.Text, "", annotations))
+		}
+	}
+	return richText
+}
+
+// styledRichText creates a text rich text object with optional link and annotations
+func styledRichText(content, url string, annotations notionapi.Annotations) notionapi.RichText {
+	rt := textRichText(content)
+	if url != "" {
+		rt.Text.Link = &notionapi.Link{Url: url}
+	}
+	if annotations != (notionapi.Annotations{}) {
+		rt.Annotations = &annotations
+	}
+	return rt
+}
+
+// textRichText creates a plain text rich text object
+func textRichText(content string) notionapi.RichText {
+	return notionapi.RichText{
+		Text: &notionapi.Text{
+			Content: content,
+		},
+	}
+}
@@ -0,0 +1,128 @@
+package notion
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// maxParentDepth limits how far up the page tree the parent page is looked for
+const maxParentDepth = 16
+
+// withinParent returns the search results that are the parent page or one of
+// its descendants, so that pages and databases with the same title elsewhere in
+// the workspace are not reused
+func (c *Client) withinParent(ctx context.Context, results *notionapi.SearchResponse) *notionapi.SearchResponse {
+	scoped := &notionapi.SearchResponse{
+		Object:     results.Object,
+		HasMore:    results.HasMore,
+		NextCursor: results.NextCursor,
+	}
+	for _, result := range results.Results {
+		var parent notionapi.Parent
+		switch object := result.(type) {
+		case *notionapi.Database:
+			parent = object.Parent
+		case *notionapi.Page:
+			parent = object.Parent
+		default:
+			continue
+		}
+		if c.inParent(ctx, parent) {
+			scoped.Results = append(scoped.Results, result)
+		}
+	}
+	return scoped
+}
+
+// inParent reports whether an object with the given parent is within the
+// parent page, walking up the tree of pages, databases and blocks. Results are
+// cached for every object passed on the way.
+func (c *Client) inParent(ctx context.Context, parent notionapi.Parent) bool {
+	if c.scope == nil {
+		c.scope = make(map[string]bool)
+	}
+
+	var visited []string
+	result := false
+	for depth := 0; depth < maxParentDepth; depth++ {
+		id := parentID(parent)
+		if id == "" {
+			// The workspace or an unknown parent
+			break
+		}
+		if normalizeID(id) == normalizeID(string(c.parentID)) {
+			result = true
+			break
+		}
+		if cached, ok := c.scope[normalizeID(id)]; ok {
+			result = cached
+			break
+		}
+		visited = append(visited, normalizeID(id))
+
+		next, err := c.getParent(ctx, parent)
+		if err != nil {
+			logger.Debug("Failed to look up parent", map[string]interface{}{
+				"id":    id,
+				"error": err.Error(),
+			})
+			break
+		}
+		parent = next
+	}
+
+	for _, id := range visited {
+		c.scope[id] = result
+	}
+	return result
+}
+
+// getParent fetches the object a parent points to and returns its own parent
+func (c *Client) getParent(ctx context.Context, parent notionapi.Parent) (notionapi.Parent, error) {
+	switch parent.Type {
+	case notionapi.ParentTypePageID:
+		page, err := c.client.Page().Get(ctx, parent.PageID)
+		if err != nil {
+			return notionapi.Parent{}, err
+		}
+		return page.Parent, nil
+	case notionapi.ParentTypeDatabaseID:
+		db, err := c.client.Database().Get(ctx, parent.DatabaseID)
+		if err != nil {
+			return notionapi.Parent{}, err
+		}
+		return db.Parent, nil
+	default:
+		block, err := c.client.Block().Get(ctx, parent.BlockID)
+		if err != nil {
+			return notionapi.Parent{}, err
+		}
+		if p := block.GetParent(); p != nil {
+			return *p, nil
+		}
+		return notionapi.Parent{}, nil
+	}
+}
+
+// parentID returns the ID of the object a parent points to, or an empty
+// string for the workspace
+func parentID(parent notionapi.Parent) string {
+	switch parent.Type {
+	case notionapi.ParentTypePageID:
+		return string(parent.PageID)
+	case notionapi.ParentTypeDatabaseID:
+		return string(parent.DatabaseID)
+	case notionapi.ParentTypeBlockID:
+		return string(parent.BlockID)
+	}
+	return ""
+}
+
+// normalizeID returns a Notion ID without dashes in lower case, so IDs copied
+// from URLs match those returned by the API
+func normalizeID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
@@ -0,0 +1,109 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+)
+
+// createSyncedBlock creates a synced block, the original holding the children
+// when from is empty, or else a copy of the original with the ID
+func (c *Client) createSyncedBlock(from notionapi.BlockID, children []notionapi.Block) notionapi.Block {
+	synced := notionapi.Synced{Children: children}
+	if from != "" {
+		synced.SyncedFrom = &notionapi.SyncedFrom{BlockID: from}
+	}
+	return &notionapi.SyncedBlock{
+		BasicBlock: notionapi.BasicBlock{
+			Object: "block",
+			Type:   notionapi.BlockTypeSyncedBlock,
+		},
+		SyncedBlock: synced,
+	}
+}
+
+// appendSyncedOriginal appends to the page an original synced block holding
+// the blocks and returns its ID. The synced block is created with the first
+// block, or an empty paragraph removed after when it has children of its own,
+// so the request stays within the nesting Notion accepts, and the other
+// blocks are appended to it.
+func (c *Client) appendSyncedOriginal(ctx context.Context, page notionapi.PageID, blocks []notionapi.Block) (notionapi.BlockID, error) {
+	seed := c.createParagraphBlock(nil)
+	placeholder := len(blocks) == 0 || countBlocks(blocks[:1]) > 1
+	if !placeholder {
+		seed, blocks = blocks[0], blocks[1:]
+	}
+
+	resp, err := c.client.Block().AppendChildren(ctx, notionapi.BlockID(page), &notionapi.AppendBlockChildrenRequest{
+		Children: []notionapi.Block{c.createSyncedBlock("", []notionapi.Block{seed})},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create synced block: %w", err)
+	}
+	if len(resp.Results) == 0 {
+		return "", fmt.Errorf("failed to create synced block: no block returned")
+	}
+	id := resp.Results[0].GetID()
+
+	if err := c.appendBlocks(ctx, id, blocks); err != nil {
+		return "", fmt.Errorf("failed to append to synced block: %w", err)
+	}
+	if placeholder && len(blocks) > 0 {
+		children, err := c.client.Block().GetChildren(ctx, id, &notionapi.Pagination{PageSize: 1})
+		if err != nil {
+			return "", err
+		}
+		if len(children.Results) > 0 {
+			if _, err := c.client.Block().Delete(ctx, children.Results[0].GetID()); err != nil {
+				return "", err
+			}
+		}
+	}
+	return id, nil
+}
+
+// syncedOriginal returns the ID of the original synced block of the page, or an
+// empty ID if the page has none. Originals created by this client are
+// remembered; others are looked up among the blocks of the page.
+func (c *Client) syncedOriginal(ctx context.Context, title string, page notionapi.PageID) (notionapi.BlockID, error) {
+	if id, ok := c.syncedBlocks[title]; ok {
+		return id, nil
+	}
+	resp, err := c.client.Block().GetChildren(ctx, notionapi.BlockID(page), &notionapi.Pagination{PageSize: maxPageSize})
+	if err != nil {
+		return "", fmt.Errorf("failed to find synced block: %w", err)
+	}
+	for _, block := range resp.Results {
+		if synced, ok := block.(*notionapi.SyncedBlock); ok && synced.SyncedBlock.SyncedFrom == nil {
+			c.recordSyncedBlock(title, synced.GetID())
+			return synced.GetID(), nil
+		}
+	}
+	return "", nil
+}
+
+// ensureSyncedOriginal returns the ID of the original synced block of the page,
+// replacing the content of the page with an empty one when it has none
+func (c *Client) ensureSyncedOriginal(ctx context.Context, title string, page notionapi.PageID) (notionapi.BlockID, error) {
+	id, err := c.syncedOriginal(ctx, title, page)
+	if err != nil || id != "" {
+		return id, err
+	}
+	if err := c.replaceBlocks(ctx, notionapi.BlockID(page), nil); err != nil {
+		return "", err
+	}
+	if id, err = c.appendSyncedOriginal(ctx, page, nil); err != nil {
+		return "", err
+	}
+	c.recordSyncedBlock(title, id)
+	return id, nil
+}
+
+// recordSyncedBlock remembers the original synced block of the page of a title
+func (c *Client) recordSyncedBlock(title string, id notionapi.BlockID) {
+	if c.syncedBlocks == nil {
+		c.syncedBlocks = make(map[string]notionapi.BlockID)
+	}
+	c.syncedBlocks[title] = id
+}
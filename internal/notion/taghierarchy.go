@@ -0,0 +1,201 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// TagHierarchy controls how tags with levels separated by slashes, such as
+// work/projectX, are organized in Notion
+type TagHierarchy string
+
+const (
+	// TagHierarchyFlat treats every tag on its own, named after the whole tag
+	TagHierarchyFlat TagHierarchy = "flat"
+	// TagHierarchyNested creates the database of a tag in a page of its parent
+	// tag, named after its last level, such as a projectX database in a work
+	// page under the parent
+	TagHierarchyNested TagHierarchy = "nested"
+	// TagHierarchyProperty keeps the tag databases under the parent and fills
+	// the "Parent tag" multi-select of their pages with the parent tags
+	TagHierarchyProperty TagHierarchy = "property"
+)
+
+// parentTagProperty is the property of the parent tags of a tag: a
+// multi-select of tag database pages, or a relation of Tags database rows
+const parentTagProperty = "Parent tag"
+
+// ParseTagHierarchy parses a tag hierarchy name
+func ParseTagHierarchy(hierarchy string) (TagHierarchy, error) {
+	switch TagHierarchy(hierarchy) {
+	case TagHierarchyFlat, TagHierarchyNested, TagHierarchyProperty:
+		return TagHierarchy(hierarchy), nil
+	}
+	return "", fmt.Errorf("invalid tag hierarchy %q: must be one of flat, nested, property", hierarchy)
+}
+
+// WithTagHierarchy sets how hierarchical tags are organized. The Tags database
+// of the relation tag mode has no place for nested databases, so both nested
+// and property relate its rows to the row of their parent tag.
+func WithTagHierarchy(hierarchy TagHierarchy) Option {
+	return func(c *Client) {
+		c.tagHierarchy = hierarchy
+	}
+}
+
+// hierarchical reports whether tags with levels are organized by level
+func (c *Client) hierarchical() bool {
+	return c.tagHierarchy == TagHierarchyNested || c.tagHierarchy == TagHierarchyProperty
+}
+
+// tagLevels splits a tag into its levels, such as work and projectX for
+// work/projectX, leaving out empty levels. A tag without a slash has one level.
+func tagLevels(tag string) []string {
+	var levels []string
+	for _, level := range strings.Split(tag, "/") {
+		if level = strings.TrimSpace(level); level != "" {
+			levels = append(levels, level)
+		}
+	}
+	if len(levels) == 0 {
+		return []string{tag}
+	}
+	return levels
+}
+
+// parentTags returns the ancestors of a tag from the top level down, such as
+// work and work/projectX for work/projectX/notes
+func parentTags(tag string) []string {
+	levels := tagLevels(tag)
+	parents := make([]string, 0, len(levels)-1)
+	for i := 1; i < len(levels); i++ {
+		parents = append(parents, strings.Join(levels[:i], "/"))
+	}
+	return parents
+}
+
+// tagDatabaseParent returns where the database of a tag is and its title: in
+// nested mode the page of its parent tag and the last level of the tag, and
+// otherwise the parent and the tag. The pages of parent tags are created when
+// missing if create is set, and otherwise an empty parent is returned.
+func (c *Client) tagDatabaseParent(ctx context.Context, tag string, create bool) (notionapi.Parent, string, error) {
+	levels := tagLevels(tag)
+	if c.tagHierarchy != TagHierarchyNested || len(levels) < 2 {
+		return c.parent(), tag, nil
+	}
+
+	page := c.parentID
+	for _, level := range levels[:len(levels)-1] {
+		children, err := c.childPages(ctx, page)
+		if err != nil {
+			return notionapi.Parent{}, "", err
+		}
+		id, ok := children[level]
+		if !ok {
+			if !create {
+				return notionapi.Parent{}, "", nil
+			}
+			created, err := c.client.Page().Create(ctx, &notionapi.PageCreateRequest{
+				Parent: notionapi.Parent{
+					Type:   notionapi.ParentTypePageID,
+					PageID: page,
+				},
+				Properties: notionapi.Properties{
+					"title": notionapi.TitleProperty{Title: []notionapi.RichText{textRichText(level)}},
+				},
+			})
+			if err != nil {
+				return notionapi.Parent{}, "", fmt.Errorf("failed to create parent tag page: %w", err)
+			}
+			c.recordCreated(ObjectPage, string(created.ID), level)
+			id = notionapi.PageID(created.ID)
+			children[level] = id
+			logger.Info("Successfully created parent tag page", map[string]interface{}{
+				"tag":   tag,
+				"title": level,
+			})
+		}
+		page = id
+	}
+	return notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: page}, levels[len(levels)-1], nil
+}
+
+// tagDatabase returns the database with the title among the search results:
+// one within the parent for a database directly under the parent, and one
+// directly in the page of its parent tag in nested mode, as levels of different
+// tags may share a title
+func (c *Client) tagDatabase(ctx context.Context, results *notionapi.SearchResponse, parent notionapi.Parent, title string) *notionapi.Database {
+	if parentID(parent) == string(c.parentID) {
+		return validateTagsDatabase(title, c.withinParent(ctx, results), c.foldTagCase)
+	}
+	scoped := &notionapi.SearchResponse{}
+	for _, result := range results.Results {
+		if db, ok := result.(*notionapi.Database); ok && normalizeID(parentID(db.Parent)) == normalizeID(parentID(parent)) {
+			scoped.Results = append(scoped.Results, db)
+		}
+	}
+	return validateTagsDatabase(title, scoped, c.foldTagCase)
+}
+
+// parentTagPropertyConfig returns the multi-select of the parent tags of the
+// pages of a tag database
+func parentTagPropertyConfig() notionapi.PropertyConfig {
+	return notionapi.MultiSelectPropertyConfig{
+		Type:        notionapi.PropertyConfigTypeMultiSelect,
+		MultiSelect: notionapi.Select{Options: []notionapi.Option{}},
+	}
+}
+
+// parentTagPropertyValue returns the parent tags of a tag as a multi-select
+// value, or nil for a tag without parents
+func parentTagPropertyValue(tag string) notionapi.Property {
+	parents := parentTags(tag)
+	if len(parents) == 0 {
+		return nil
+	}
+	options := make([]notionapi.Option, 0, len(parents))
+	for _, parent := range parents {
+		options = append(options, notionapi.Option{Name: parent})
+	}
+	return notionapi.MultiSelectProperty{
+		Type:        notionapi.PropertyTypeMultiSelect,
+		MultiSelect: options,
+	}
+}
+
+// ensureParentTagProperty adds the "Parent tag" property to a database created
+// without it, such as by a run with flat tags: the multi-select to a tag
+// database, or the relation to its own rows to the Tags database
+func (c *Client) ensureParentTagProperty(ctx context.Context, db *notionapi.Database, config notionapi.PropertyConfig) error {
+	if _, ok := db.Properties[parentTagProperty]; ok || c.parentTagDBs[db.ID] {
+		return nil
+	}
+	_, err := c.client.Database().Update(ctx, notionapi.DatabaseID(db.ID), &notionapi.DatabaseUpdateRequest{
+		Properties: notionapi.PropertyConfigs{parentTagProperty: config},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s property to database: %w", parentTagProperty, err)
+	}
+	if c.parentTagDBs == nil {
+		c.parentTagDBs = make(map[notionapi.ObjectID]bool)
+	}
+	c.parentTagDBs[db.ID] = true
+	return nil
+}
+
+// parentTagRelationConfig returns the relation of the rows of the Tags database
+// to the row of their parent tag
+func parentTagRelationConfig(tagsDB notionapi.DatabaseID) notionapi.PropertyConfig {
+	return notionapi.RelationPropertyConfig{
+		Type: notionapi.PropertyConfigTypeRelation,
+		Relation: notionapi.RelationConfig{
+			DatabaseID:     tagsDB,
+			Type:           notionapi.RelationSingleProperty,
+			SingleProperty: &notionapi.SingleProperty{},
+		},
+	}
+}
@@ -0,0 +1,299 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// TagMode controls how the tags of pages are modeled in Notion
+type TagMode string
+
+const (
+	// TagModeDatabases creates a database per tag holding the pages with the tag
+	TagModeDatabases TagMode = "databases"
+	// TagModeCanonical creates the page in the database of its first tag and
+	// rows linking to it in the databases of its other tags
+	TagModeCanonical TagMode = "canonical"
+	// TagModeSynced creates the content of pages with several tags once, as a
+	// synced block in the page in the database of its first tag, and copies of
+	// the synced block in the pages in the databases of its other tags
+	TagModeSynced TagMode = "synced"
+	// TagModeRelation creates every page once in a Pages database, relating it
+	// to the rows of its tags in a Tags database
+	TagModeRelation TagMode = "relation"
+)
+
+const (
+	// tagsDatabaseTitle is the title of the database of tags in relation mode
+	tagsDatabaseTitle = "Tags"
+	// pagesDatabaseTitle is the title of the database of pages in relation mode
+	pagesDatabaseTitle = "Pages"
+)
+
+// ParseTagMode parses a tag mode name
+func ParseTagMode(mode string) (TagMode, error) {
+	switch TagMode(mode) {
+	case TagModeDatabases, TagModeCanonical, TagModeSynced, TagModeRelation:
+		return TagMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid tag mode %q: must be one of databases, canonical, synced, relation", mode)
+}
+
+// WithTagMode sets how the tags of pages are modeled in Notion
+func WithTagMode(mode TagMode) Option {
+	return func(c *Client) {
+		c.tagMode = mode
+	}
+}
+
+// createRelationPage creates the page in the Pages database, relating it to the
+// rows of its tags in the Tags database, unless a page with the title is there
+func (c *Client) createRelationPage(ctx context.Context, doc *models.Document, tags []string, authors []notionapi.User) error {
+	title := doc.Title
+
+	tagsDB, pagesDB, err := c.relationDatabases(ctx)
+	if err != nil {
+		return err
+	}
+
+	pages, err := c.databasePages(ctx, pagesDB)
+	if err != nil {
+		return err
+	}
+	if existingID, ok := pages[title]; ok {
+		c.recordPage(title, existingID)
+		logger.Info("Notion page has already existed, skip creating", map[string]interface{}{
+			"title": title,
+			"tags":  tags,
+		})
+		return alreadyExists(title)
+	}
+
+	relations := make([]notionapi.Relation, 0, len(tags))
+	for _, tag := range tags {
+		id, err := c.tagRow(ctx, tagsDB, tag)
+		if err != nil {
+			return err
+		}
+		relations = append(relations, notionapi.Relation{ID: id})
+	}
+
+	createdAt := notionapi.Date(time.Now())
+	properties := notionapi.Properties{
+		"Name": notionapi.TitleProperty{
+			Title: []notionapi.RichText{textRichText(title)},
+		},
+		"Tags": notionapi.RelationProperty{
+			Type:     notionapi.PropertyTypeRelation,
+			Relation: relations,
+		},
+		"Created": notionapi.DateProperty{
+			Date: &notionapi.DateObject{
+				Start: &createdAt,
+			},
+		},
+	}
+	if len(authors) > 0 {
+		properties[authorProperty] = notionapi.PeopleProperty{
+			Type:   notionapi.PropertyTypePeople,
+			People: authors,
+		}
+	}
+	if c.pageProperties() {
+		for name, value := range c.metricProperties(doc) {
+			properties[name] = value
+		}
+	}
+
+	page, err := c.createPageWithBlocks(ctx, &notionapi.PageCreateRequest{
+		Parent: notionapi.Parent{
+			Type:       notionapi.ParentTypeDatabaseID,
+			DatabaseID: pagesDB,
+		},
+		Properties: properties,
+		Icon:       c.pageIcon(title),
+		Cover:      c.pageCover(doc),
+	}, c.convertDocumentToBlocks(doc))
+	if page != nil {
+		c.recordCreated(ObjectPage, string(page.ID), title)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create page in pages database: %w", err)
+	}
+	if err := c.linkAnchors(ctx, notionapi.PageID(page.ID), notionapi.BlockID(page.ID), doc); err != nil {
+		return err
+	}
+	pages[title] = notionapi.PageID(page.ID)
+	c.recordPage(title, notionapi.PageID(page.ID))
+	logger.Info("Successfully created Notion page", map[string]interface{}{
+		"title": title,
+		"tags":  tags,
+	})
+	return nil
+}
+
+// relationDatabases returns the Tags and Pages databases within the parent page,
+// creating them on first use
+func (c *Client) relationDatabases(ctx context.Context) (notionapi.DatabaseID, notionapi.DatabaseID, error) {
+	if c.tagsDB != "" && c.pagesDB != "" {
+		return c.tagsDB, c.pagesDB, nil
+	}
+
+	tagsDB, err := c.findOrCreateDatabase(ctx, tagsDatabaseTitle, func() notionapi.PropertyConfigs {
+		return notionapi.PropertyConfigs{
+			"Name": notionapi.TitlePropertyConfig{
+				Type:  notionapi.PropertyConfigTypeTitle,
+				Title: struct{}{},
+			},
+		}
+	})
+	if err != nil {
+		return "", "", err
+	}
+	// A relation to the rows of the database itself can only be added once
+	// the database exists
+	if c.hierarchical() {
+		if err := c.ensureParentTagProperty(ctx, tagsDB, parentTagRelationConfig(notionapi.DatabaseID(tagsDB.ID))); err != nil {
+			return "", "", err
+		}
+	}
+
+	pagesDB, err := c.findOrCreateDatabase(ctx, pagesDatabaseTitle, func() notionapi.PropertyConfigs {
+		properties := notionapi.PropertyConfigs{
+			"Name": notionapi.TitlePropertyConfig{
+				Type:  notionapi.PropertyConfigTypeTitle,
+				Title: struct{}{},
+			},
+			// A dual relation also lists the pages of each tag on its row
+			"Tags": notionapi.RelationPropertyConfig{
+				Type: notionapi.PropertyConfigTypeRelation,
+				Relation: notionapi.RelationConfig{
+					DatabaseID:   notionapi.DatabaseID(tagsDB.ID),
+					Type:         "dual_property",
+					DualProperty: &notionapi.DualProperty{},
+				},
+			},
+			"Created": notionapi.DatePropertyConfig{
+				Type: notionapi.PropertyConfigTypeDate,
+				Date: struct{}{},
+			},
+		}
+		if c.users != nil {
+			properties[authorProperty] = notionapi.PeoplePropertyConfig{Type: notionapi.PropertyConfigTypePeople}
+		}
+		if c.pageProperties() {
+			for name, config := range c.metricPropertyConfigs() {
+				properties[name] = config
+			}
+		}
+		return properties
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if c.users != nil {
+		if err := c.ensureAuthorProperty(ctx, pagesDB); err != nil {
+			return "", "", err
+		}
+	}
+	if c.pageProperties() {
+		if err := c.ensureMetricProperties(ctx, pagesDB); err != nil {
+			return "", "", err
+		}
+	}
+
+	c.tagsDB = notionapi.DatabaseID(tagsDB.ID)
+	c.pagesDB = notionapi.DatabaseID(pagesDB.ID)
+	return c.tagsDB, c.pagesDB, nil
+}
+
+// findOrCreateDatabase returns the database with the title within the parent
+// page, creating it with the given properties if there is none
+func (c *Client) findOrCreateDatabase(ctx context.Context, title string, properties func() notionapi.PropertyConfigs) (*notionapi.Database, error) {
+	db, err := c.findDatabase(ctx, title)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		return db, nil
+	}
+
+	db, err = c.createDatabase(ctx, title, properties())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s database: %w", title, err)
+	}
+	c.recordCreated(ObjectDatabase, string(db.ID), title)
+	c.trackDatabase(notionapi.DatabaseID(db.ID), make(map[string]notionapi.PageID))
+	logger.Info("Successfully created database", map[string]interface{}{
+		"title": title,
+	})
+	return db, nil
+}
+
+// findDatabase returns the database with the title within the parent page, or
+// nil if there is none
+func (c *Client) findDatabase(ctx context.Context, title string) (*notionapi.Database, error) {
+	results, err := c.client.Search().Do(ctx, &notionapi.SearchRequest{
+		Query: title,
+		Filter: notionapi.SearchFilter{
+			Property: "object",
+			Value:    "database",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for %s database: %w", title, err)
+	}
+	return validateTagsDatabase(title, c.withinParent(ctx, results), false), nil
+}
+
+// tagRow returns the row of a tag in the Tags database, creating it if needed.
+// With a tag hierarchy, new rows relate to the row of their parent tag.
+func (c *Client) tagRow(ctx context.Context, tagsDB notionapi.DatabaseID, tag string) (notionapi.PageID, error) {
+	rows, err := c.databasePages(ctx, tagsDB)
+	if err != nil {
+		return "", err
+	}
+	if id, ok := rows[tag]; ok {
+		return id, nil
+	}
+	for title, id := range rows {
+		if titlesMatch(title, tag, c.foldTagCase) {
+			return id, nil
+		}
+	}
+
+	properties := notionapi.Properties{
+		"Name": notionapi.TitleProperty{
+			Title: []notionapi.RichText{textRichText(tag)},
+		},
+	}
+	if parents := parentTags(tag); c.hierarchical() && len(parents) > 0 {
+		parentRow, err := c.tagRow(ctx, tagsDB, parents[len(parents)-1])
+		if err != nil {
+			return "", err
+		}
+		properties[parentTagProperty] = notionapi.RelationProperty{
+			Type:     notionapi.PropertyTypeRelation,
+			Relation: []notionapi.Relation{{ID: parentRow}},
+		}
+	}
+
+	row, err := c.client.Page().Create(ctx, &notionapi.PageCreateRequest{
+		Parent: notionapi.Parent{
+			Type:       notionapi.ParentTypeDatabaseID,
+			DatabaseID: tagsDB,
+		},
+		Properties: properties,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create tag row: %w", err)
+	}
+	c.recordCreated(ObjectPage, string(row.ID), tag)
+	rows[tag] = notionapi.PageID(row.ID)
+	return notionapi.PageID(row.ID), nil
+}
@@ -0,0 +1,135 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// UpdatePage replaces the content of the Notion pages migrated from the document
+// with the blocks it converts to, and creates the pages that are missing, such
+// as the page of a new tag. Copies of the page in the databases of tags the
+// page no longer has are left alone. Failures of the Notion API are wrapped
+// like those of CreatePage.
+func (c *Client) UpdatePage(ctx context.Context, doc *models.Document, tags []string) error {
+	return classifyError(c.updatePage(ctx, doc, tags))
+}
+
+// updatePage replaces the content of the pages of the document and creates the
+// missing ones
+func (c *Client) updatePage(ctx context.Context, doc *models.Document, tags []string) error {
+	if err := c.resolveMissingLinks(ctx, doc, true); err != nil {
+		return err
+	}
+	ids, err := c.pageCopies(ctx, doc.Title, tags)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		blocks := c.convertDocumentToBlocks(doc)
+		target := notionapi.BlockID(id)
+		switch {
+		case c.tagMode == TagModeCanonical && i > 0:
+			// The copies of other tags link to the page of the first tag
+			blocks = []notionapi.Block{c.createLinkToPageBlock(ids[0])}
+		case c.tagMode == TagModeSynced && len(ids) > 1:
+			// The content is in the synced block of the page of the first tag,
+			// shown in the copies of other tags
+			original, err := c.ensureSyncedOriginal(ctx, doc.Title, ids[0])
+			if err != nil {
+				return fmt.Errorf("failed to update page %q: %w", doc.Title, err)
+			}
+			if i == 0 {
+				target = original
+			} else {
+				blocks = []notionapi.Block{c.createSyncedBlock(original, nil)}
+			}
+		}
+		if err := c.replaceBlocks(ctx, target, blocks); err != nil {
+			return fmt.Errorf("failed to update page %q: %w", doc.Title, err)
+		}
+		if err := c.linkAnchors(ctx, id, target, doc); err != nil {
+			return fmt.Errorf("failed to update page %q: %w", doc.Title, err)
+		}
+		c.recordPage(doc.Title, id)
+	}
+	if len(ids) > 0 {
+		logger.Info("Successfully updated Notion page", map[string]interface{}{
+			"title":  doc.Title,
+			"copies": len(ids),
+		})
+	}
+
+	// Pages found above are skipped as existing
+	if err := c.createPage(ctx, doc, tags, ConflictSkip); !errors.Is(err, ErrAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+// pageCopies returns the IDs of the Notion pages with the title: the child page
+// of its hub in a hierarchy, the page in the database of each tag, or the
+// single page of the title with a parent database, in relation mode or without
+// tags
+func (c *Client) pageCopies(ctx context.Context, title string, tags []string) ([]notionapi.PageID, error) {
+	if hub := c.hubPage(title); hub != "" {
+		children, err := c.childPages(ctx, hub)
+		if err != nil || children[title] == "" {
+			return nil, err
+		}
+		return []notionapi.PageID{children[title]}, nil
+	}
+	tags = c.fileTags(tags)
+	if c.parentType == notionapi.ParentTypeDatabaseID || c.tagMode == TagModeRelation || len(tags) == 0 {
+		id, err := c.findPage(ctx, title, tags)
+		if err != nil || id == "" {
+			return nil, err
+		}
+		return []notionapi.PageID{id}, nil
+	}
+
+	var ids []notionapi.PageID
+	for _, tag := range tags {
+		id, err := c.findPage(ctx, title, []string{tag})
+		if err != nil {
+			return nil, err
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// replaceBlocks deletes the child blocks of a block and appends the given ones
+func (c *Client) replaceBlocks(ctx context.Context, id notionapi.BlockID, blocks []notionapi.Block) error {
+	var existing []notionapi.Block
+	var cursor notionapi.Cursor
+	for {
+		resp, err := c.client.Block().GetChildren(ctx, id, &notionapi.Pagination{
+			StartCursor: cursor,
+			PageSize:    maxPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		existing = append(existing, resp.Results...)
+		if !resp.HasMore {
+			break
+		}
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+
+	for _, block := range existing {
+		if _, err := c.client.Block().Delete(ctx, block.GetID()); err != nil {
+			return err
+		}
+	}
+
+	return c.appendBlocks(ctx, id, blocks)
+}
@@ -0,0 +1,252 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// VerifyStatus is the result of comparing a page in Notion with the export
+type VerifyStatus string
+
+const (
+	// VerifyOK means the Notion page has the content of the export
+	VerifyOK VerifyStatus = "ok"
+	// VerifyPartial means the Notion page stops short of the content of the
+	// export, as left by an upload that failed partway
+	VerifyPartial VerifyStatus = "partial"
+	// VerifyDrifted means the Notion page differs from the export
+	VerifyDrifted VerifyStatus = "drifted"
+	// VerifyMissing means no Notion page was found for the title
+	VerifyMissing VerifyStatus = "missing"
+)
+
+// Verification is the result of verifying one page. Diff lists the lines of
+// the export missing from Notion prefixed with - and the lines only in Notion
+// prefixed with +.
+type Verification struct {
+	Title  string
+	PageID string
+	Status VerifyStatus
+	Diff   []string
+}
+
+// VerifyPage fetches the Notion page migrated from the document and compares
+// its blocks with the blocks the document converts to. Pages with tags are
+// looked up in the database of their first tag, other pages under the parent
+// page. With a parent database or in relation tag mode every page is looked up
+// in the database holding all pages.
+func (c *Client) VerifyPage(ctx context.Context, doc *models.Document, tags []string) (*Verification, error) {
+	result := &Verification{Title: doc.Title, Status: VerifyMissing}
+
+	id, err := c.findPage(ctx, doc.Title, tags)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return result, nil
+	}
+	result.PageID = string(id)
+
+	// Links to missing pages mention the pages found, but verifying creates no stubs
+	if err := c.resolveMissingLinks(ctx, doc, false); err != nil {
+		return nil, err
+	}
+
+	blocks, err := c.fetchBlocks(ctx, notionapi.BlockID(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page blocks: %w", err)
+	}
+
+	expected := markdownLines(blocksMarkdown(c.convertDocumentToBlocks(doc)))
+	actual := markdownLines(blocksMarkdown(blocks))
+	result.Diff = diffLines(expected, actual)
+
+	switch {
+	case len(result.Diff) == 0:
+		result.Status = VerifyOK
+	case len(actual) < len(expected) && equalLines(actual, expected[:len(actual)]):
+		result.Status = VerifyPartial
+	default:
+		result.Status = VerifyDrifted
+	}
+
+	logger.Debug("Verified Notion page", map[string]interface{}{
+		"title":  doc.Title,
+		"id":     id,
+		"status": result.Status,
+	})
+	return result, nil
+}
+
+// findPage returns the ID of the Notion page with the title, or an empty ID if
+// there is none
+func (c *Client) findPage(ctx context.Context, title string, tags []string) (notionapi.PageID, error) {
+	if c.parentType == notionapi.ParentTypeDatabaseID {
+		pages, err := c.databasePages(ctx, notionapi.DatabaseID(c.parentID))
+		if err != nil {
+			return "", err
+		}
+		return pages[title], nil
+	}
+
+	if c.tagMode == TagModeRelation {
+		db, err := c.findDatabase(ctx, pagesDatabaseTitle)
+		if err != nil || db == nil {
+			return "", err
+		}
+		pages, err := c.databasePages(ctx, notionapi.DatabaseID(db.ID))
+		if err != nil {
+			return "", err
+		}
+		return pages[title], nil
+	}
+
+	tags = c.fileTags(tags)
+	if len(tags) > 0 {
+		dbParent, dbTitle, err := c.tagDatabaseParent(ctx, tags[0], false)
+		if err != nil || dbParent.Type == "" {
+			return "", err
+		}
+		results, err := c.client.Search().Do(ctx, &notionapi.SearchRequest{
+			Query: dbTitle,
+			Filter: notionapi.SearchFilter{
+				Property: "object",
+				Value:    "database",
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to search for tag database: %w", err)
+		}
+		db := c.tagDatabase(ctx, results, dbParent, dbTitle)
+		if db == nil {
+			return "", nil
+		}
+		pages, err := c.databasePages(ctx, notionapi.DatabaseID(db.ID))
+		if err != nil {
+			return "", err
+		}
+		return pages[title], nil
+	}
+
+	pages, err := c.parentPages(ctx)
+	if err != nil {
+		return "", err
+	}
+	return pages[title], nil
+}
+
+// fetchBlocks returns the child blocks of a block, following the result cursor
+// and fetching the children of toggles and columns
+func (c *Client) fetchBlocks(ctx context.Context, id notionapi.BlockID) ([]notionapi.Block, error) {
+	var blocks []notionapi.Block
+	var cursor notionapi.Cursor
+	for {
+		resp, err := c.client.Block().GetChildren(ctx, id, &notionapi.Pagination{
+			StartCursor: cursor,
+			PageSize:    maxPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, resp.Results...)
+		if !resp.HasMore {
+			break
+		}
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+
+	var expanded []notionapi.Block
+	for _, block := range blocks {
+		switch block.(type) {
+		case *notionapi.ToggleBlock, *notionapi.BulletedListItemBlock, *notionapi.NumberedListItemBlock, *notionapi.ToDoBlock:
+			if block.GetHasChildren() {
+				children, err := c.fetchBlocks(ctx, block.GetID())
+				if err != nil {
+					return nil, err
+				}
+				setBlockChildren(block, children)
+			}
+		case *notionapi.ColumnListBlock, *notionapi.ColumnBlock:
+			children, err := c.fetchBlocks(ctx, block.GetID())
+			if err != nil {
+				return nil, err
+			}
+			setBlockChildren(block, children)
+		}
+		// The content of pages in synced mode is in an original synced block
+		if synced, ok := block.(*notionapi.SyncedBlock); ok && synced.SyncedBlock.SyncedFrom == nil && synced.HasChildren {
+			children, err := c.fetchBlocks(ctx, synced.GetID())
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, children...)
+			continue
+		}
+		expanded = append(expanded, block)
+	}
+	return expanded, nil
+}
+
+// markdownLines splits rendered markdown into lines
+func markdownLines(md string) []string {
+	md = strings.TrimSuffix(md, "\n")
+	if md == "" {
+		return nil
+	}
+	return strings.Split(md, "\n")
+}
+
+// equalLines reports whether two slices hold the same lines
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffLines returns the lines only in expected prefixed with - and the lines
+// only in actual prefixed with +, in the order of a longest common subsequence
+// of the two. It returns nil if they are the same.
+func diffLines(expected, actual []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of expected[i:] and actual[j:]
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			i++
+			j++
+		case j == len(actual) || (i < len(expected) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "-"+expected[i])
+			i++
+		default:
+			diff = append(diff, "+"+actual[j])
+			j++
+		}
+	}
+	return diff
+}
@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// linkAnchors turns links written as [#section] into anchors when the page has
+// a heading of the section, matched the way page links are. Other links keep
+// pointing at the page they name.
+func linkAnchors(doc *models.Document) {
+	headings := make(map[string]string)
+	for _, block := range doc.Blocks {
+		if block.Type != models.BlockHeading {
+			continue
+		}
+		text := models.PlainText(block.Inline)
+		if _, ok := headings[linkID(text)]; !ok {
+			headings[linkID(text)] = text
+		}
+	}
+	if len(headings) == 0 {
+		return
+	}
+	for i := range doc.Blocks {
+		anchorLinks(doc.Blocks[i].Inline, headings)
+	}
+}
+
+// anchorLinks turns the [#section] links to the headings into anchors
+func anchorLinks(inlines []models.Inline, headings map[string]string) {
+	for i := range inlines {
+		inline := &inlines[i]
+		if section, ok := strings.CutPrefix(inline.Text, "#"); ok && inline.Type == models.InlinePageLink {
+			if heading, ok := headings[linkID(section)]; ok {
+				*inline = models.Inline{Type: models.InlineAnchor, Text: section, URL: heading}
+			}
+		}
+		anchorLinks(inline.Children, headings)
+	}
+}
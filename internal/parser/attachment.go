@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// AttachmentRule shows files linked on a line of their own by their extension
+type AttachmentRule struct {
+	// Extension is the file extension without the dot, such as mp3
+	Extension string
+	// Kind is how files with the extension are shown
+	Kind models.AttachmentKind
+}
+
+// DefaultAttachmentRules are the attachment rules for common audio, video,
+// PDF and document files
+var DefaultAttachmentRules = []AttachmentRule{
+	{Extension: "mp3", Kind: models.AttachmentAudio},
+	{Extension: "wav", Kind: models.AttachmentAudio},
+	{Extension: "m4a", Kind: models.AttachmentAudio},
+	{Extension: "ogg", Kind: models.AttachmentAudio},
+	{Extension: "flac", Kind: models.AttachmentAudio},
+	{Extension: "mp4", Kind: models.AttachmentVideo},
+	{Extension: "mov", Kind: models.AttachmentVideo},
+	{Extension: "webm", Kind: models.AttachmentVideo},
+	{Extension: "pdf", Kind: models.AttachmentPDF},
+	{Extension: "zip", Kind: models.AttachmentFile},
+	{Extension: "csv", Kind: models.AttachmentFile},
+	{Extension: "docx", Kind: models.AttachmentFile},
+	{Extension: "xlsx", Kind: models.AttachmentFile},
+	{Extension: "pptx", Kind: models.AttachmentFile},
+}
+
+// ParseAttachmentRule parses an attachment rule written as EXT=KIND
+func ParseAttachmentRule(spec string) (AttachmentRule, error) {
+	ext, kind, ok := strings.Cut(spec, "=")
+	ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+	if !ok || ext == "" {
+		return AttachmentRule{}, fmt.Errorf("invalid attachment rule %q: must be EXT=KIND", spec)
+	}
+	switch k := models.AttachmentKind(strings.TrimSpace(kind)); k {
+	case models.AttachmentAudio, models.AttachmentVideo, models.AttachmentPDF, models.AttachmentFile, models.AttachmentBookmark:
+		return AttachmentRule{Extension: ext, Kind: k}, nil
+	}
+	return AttachmentRule{}, fmt.Errorf("invalid attachment kind %q: must be one of audio, video, pdf, file, bookmark", kind)
+}
+
+// WithAttachmentRules turns lines consisting only of a link to a file with
+// the extension of a rule into attachments. Later rules override earlier ones
+// for the same extension.
+func WithAttachmentRules(rules ...AttachmentRule) Option {
+	return func(p *Parser) {
+		if p.attachments == nil {
+			p.attachments = make(map[string]models.AttachmentKind)
+		}
+		for _, rule := range rules {
+			p.attachments[rule.Extension] = rule.Kind
+		}
+	}
+}
+
+// attachmentLine returns the attachment of a line consisting only of a link
+// to a file with the extension of an attachment rule, written bare or in
+// brackets with or without a label, and the link itself
+func (p *Parser) attachmentLine(line string, links []string) (*models.Attachment, []models.Inline, bool) {
+	inlines := p.parseInline(line, links)
+	if len(inlines) != 1 || inlines[0].Type != models.InlineLink || len(inlines[0].Children) > 0 {
+		return nil, nil, false
+	}
+	link := inlines[0]
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		return nil, nil, false
+	}
+	kind, ok := p.attachments[strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))]
+	if !ok {
+		return nil, nil, false
+	}
+
+	name := link.Text
+	if name == "" {
+		name = path.Base(u.Path)
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+	}
+	return &models.Attachment{Kind: kind, URL: link.URL, Name: name}, inlines, true
+}
@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CalloutRule turns lines starting with a marker into callouts
+type CalloutRule struct {
+	// Marker is the text a line starts with, such as NOTE:
+	Marker string
+	// Icon is the emoji of the callout
+	Icon string
+	// Color is the Notion color of the callout, such as blue_background.
+	// Empty keeps the default color.
+	Color string
+}
+
+// DefaultCalloutRules are the callout rules for common note markers
+var DefaultCalloutRules = []CalloutRule{
+	{Marker: "NOTE:", Icon: "💡", Color: "blue_background"},
+	{Marker: "TIP:", Icon: "✅", Color: "green_background"},
+	{Marker: "WARN:", Icon: "⚠️", Color: "yellow_background"},
+	{Marker: "WARNING:", Icon: "⚠️", Color: "yellow_background"},
+	{Marker: "⚠️", Icon: "⚠️", Color: "yellow_background"},
+	{Marker: "IMPORTANT:", Icon: "❗", Color: "red_background"},
+}
+
+// calloutColors are the colors Notion accepts for blocks
+var calloutColors = []string{
+	"default", "gray", "brown", "orange", "yellow", "green", "blue", "purple", "pink", "red",
+	"gray_background", "brown_background", "orange_background", "yellow_background", "green_background",
+	"blue_background", "purple_background", "pink_background", "red_background",
+}
+
+// ParseCalloutRule parses a callout rule written as MARKER=ICON or MARKER=ICON,COLOR
+func ParseCalloutRule(spec string) (CalloutRule, error) {
+	marker, value, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(marker) == "" {
+		return CalloutRule{}, fmt.Errorf("invalid callout rule %q: must be MARKER=ICON or MARKER=ICON,COLOR", spec)
+	}
+	icon, color, _ := strings.Cut(value, ",")
+
+	rule := CalloutRule{Marker: strings.TrimSpace(marker), Icon: strings.TrimSpace(icon), Color: strings.TrimSpace(color)}
+	if rule.Icon == "" {
+		return CalloutRule{}, fmt.Errorf("invalid callout rule %q: icon is required", spec)
+	}
+	if rule.Color != "" && !containsString(calloutColors, rule.Color) {
+		return CalloutRule{}, fmt.Errorf("invalid callout color %q: must be one of %s", rule.Color, strings.Join(calloutColors, ", "))
+	}
+	return rule, nil
+}
+
+// matchCallout returns the rule of the first marker the line starts with and
+// the text after the marker
+func (p *Parser) matchCallout(line string) (CalloutRule, string, bool) {
+	for _, rule := range p.callouts {
+		if text, ok := strings.CutPrefix(line, rule.Marker); ok && strings.TrimSpace(text) != "" {
+			return rule, strings.TrimSpace(text), true
+		}
+	}
+	return CalloutRule{}, "", false
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
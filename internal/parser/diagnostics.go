@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// emptyBracket matches brackets without content, such as [] and [* ]
+var emptyBracket = regexp.MustCompile(`\[[*/\-]*\s*\]`)
+
+// lineWarnings returns the reasons a line may not convert as written
+func lineWarnings(text string) []string {
+	var reasons []string
+
+	if strings.Count(text, "`")%2 != 0 {
+		reasons = append(reasons, "unclosed code span, kept as text")
+	}
+
+	plain := stripCodeSpans(text)
+	depth := 0
+	balanced := true
+	for _, c := range plain {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth < 0 {
+				balanced = false
+				depth = 0
+			}
+		}
+	}
+	if !balanced || depth != 0 {
+		reasons = append(reasons, "unbalanced brackets, kept as text")
+	}
+
+	if emptyBracket.MatchString(plain) {
+		reasons = append(reasons, "empty bracket, kept as text")
+	}
+
+	return reasons
+}
+
+// stripCodeSpans removes `code` spans, whose brackets are literal text
+func stripCodeSpans(text string) string {
+	parts := strings.Split(text, "`")
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 0 {
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+// warn records a warning about a line of the page on the document
+func warn(doc *models.Document, line int, text, reason string) {
+	doc.Warnings = append(doc.Warnings, models.Warning{Line: line, Text: text, Reason: reason})
+}
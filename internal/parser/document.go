@@ -0,0 +1,598 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// helpfeelIcon is the callout icon of Helpfeel questions
+const helpfeelIcon = "❓"
+
+// ParseDocument converts a Scrapbox page to a format independent document.
+// Pages can be converted at the same time once every page is added.
+func (p *Parser) ParseDocument(page *models.Page) *models.Document {
+	doc := &models.Document{
+		Title:     page.Title,
+		Tags:      page.Tags,
+		Created:   page.Created,
+		Updated:   page.Updated,
+		Views:     page.Views,
+		Backlinks: p.countBacklinks(page.Title),
+		History:   p.history,
+		Image:     page.Image,
+		Summary:   strings.Join(page.Descriptions, " "),
+		Footer:    p.footer(page),
+	}
+	if p.sourceURLs {
+		doc.SourceURL = p.sourceURL(page)
+	}
+	// Scrapbox counts the links of every page, including those left out of the export
+	if page.Linked > doc.Backlinks {
+		doc.Backlinks = page.Linked
+	}
+
+	var codeBlock *models.Block
+	var codeContent []string
+	var codeIndent int
+	var codeBlanks int
+	var codeLine int
+	flushCode := func() {
+		if len(codeContent) == 0 {
+			warn(doc, codeLine, page.Lines[codeLine-1].Text, "empty code block")
+		}
+		codeBlock.Text = strings.Join(codeContent, "\n")
+		doc.Blocks = append(doc.Blocks, *codeBlock)
+		codeBlock = nil
+		codeContent = nil
+		codeBlanks = 0
+	}
+
+	for i, line := range page.Lines {
+		if line.UserID != "" && !slices.Contains(doc.Authors, line.UserID) {
+			doc.Authors = append(doc.Authors, line.UserID)
+		}
+
+		// Skip the title line as it is held by the document
+		if i == 0 && line.Text == page.Title {
+			continue
+		}
+
+		if codeBlock != nil {
+			// Blank lines belong to the block only if it continues after them
+			if strings.TrimSpace(line.Text) == "" {
+				codeBlanks++
+				continue
+			}
+			// Lines indented deeper than code: are the content, keeping their
+			// indentation relative to the block
+			if indent := indentWidth(line.Text); indent > codeIndent {
+				for ; codeBlanks > 0; codeBlanks-- {
+					codeContent = append(codeContent, "")
+				}
+				codeContent = append(codeContent, line.Text[codeIndent+1:])
+				if codeBlock.History != nil && line.Updated > codeBlock.History.Updated {
+					codeBlock.History = lineHistory(line)
+				}
+				continue
+			}
+			// End of code block
+			flushCode()
+		}
+
+		// Skip lines of hashtags as they'll be handled by Notion relations
+		if p.tagLines == TagLineStrip && isTagLine(line.Text) {
+			continue
+		}
+
+		// Handle code blocks
+		if strings.HasPrefix(strings.TrimSpace(line.Text), "code:") {
+			codeBlock = &models.Block{
+				Type:     models.BlockCode,
+				Language: inferCodeLanguage(strings.TrimPrefix(strings.TrimSpace(line.Text), "code:")),
+			}
+			if p.history != "" {
+				codeBlock.History = lineHistory(line)
+			}
+			codeIndent = indentWidth(line.Text)
+			codeLine = i + 1
+			continue
+		}
+
+		for _, reason := range lineWarnings(line.Text) {
+			warn(doc, i+1, line.Text, reason)
+		}
+
+		if block, ok := p.parseLine(line.Text, page.LinksLc); ok {
+			if p.history != "" {
+				block.History = lineHistory(line)
+			}
+			doc.Blocks = append(doc.Blocks, block)
+		}
+	}
+
+	// Handle any remaining code block
+	if codeBlock != nil {
+		if len(codeContent) > 0 {
+			flushCode()
+		} else {
+			warn(doc, codeLine, page.Lines[codeLine-1].Text, "empty code block")
+		}
+	}
+
+	linkAnchors(doc)
+	p.applyTitleMapping(doc)
+	if p.tocHeadings > 0 {
+		headings := 0
+		for _, block := range doc.Blocks {
+			if block.Type == models.BlockHeading {
+				headings++
+			}
+		}
+		doc.TableOfContents = headings >= p.tocHeadings
+	}
+	return doc
+}
+
+// lineHistory returns the author and edit time of the line
+func lineHistory(line models.Line) *models.LineHistory {
+	return &models.LineHistory{Author: line.UserID, Updated: line.Updated}
+}
+
+// parseLine converts a single Scrapbox line to a block. It returns false for empty lines.
+func (p *Parser) parseLine(line string, links []string) (models.Block, bool) {
+	if line == "" {
+		return models.Block{}, false
+	}
+
+	// Count leading spaces and tabs for indentation level
+	indentLevel := 0
+	for _, char := range line {
+		if char == ' ' || char == '\t' {
+			indentLevel++
+		} else {
+			break
+		}
+	}
+
+	// Trim leading whitespace
+	line = strings.TrimLeft(line, " \t")
+
+	// Nested items are indented one level less than the line, as the first
+	// level of Scrapbox indentation becomes a top level list item
+	listIndent := 0
+	if indentLevel > 0 {
+		listIndent = indentLevel - 1
+	}
+
+	// Convert [----] and lines of dashes to a horizontal rule
+	if isDivider(line) {
+		return models.Block{Type: models.BlockDivider}, true
+	}
+
+	// Convert a line consisting only of math to a display equation
+	if indentLevel == 0 && strings.HasPrefix(line, "[$ ") && strings.Index(line, "]") == len(line)-1 {
+		return models.Block{
+			Type: models.BlockEquation,
+			Text: unescapeMath(line[3 : len(line)-1]),
+		}, true
+	}
+
+	// Embed videos and posts linked on a line of their own
+	if p.embeds && indentLevel == 0 {
+		if embed, ok := embedLine(line); ok {
+			return models.Block{
+				Type:   models.BlockEmbed,
+				Embed:  embed,
+				Inline: []models.Inline{{Type: models.InlineLink, URL: embed.URL}},
+			}, true
+		}
+	}
+
+	// Show files linked on a line of their own as attachments
+	if len(p.attachments) > 0 && indentLevel == 0 {
+		if attachment, inlines, ok := p.attachmentLine(line, links); ok {
+			return models.Block{
+				Type:       models.BlockAttachment,
+				Attachment: attachment,
+				Inline:     inlines,
+			}, true
+		}
+	}
+
+	// Quotes > text and ["quoted text"]
+	if quote, ok := splitQuote(line); ok {
+		return models.Block{
+			Type:   models.BlockQuote,
+			Inline: p.parseInline(quote, links),
+		}, true
+	}
+
+	// Helpfeel notation ? question becomes a callout of the question
+	if question, ok := strings.CutPrefix(line, "? "); ok && strings.TrimSpace(question) != "" {
+		return models.Block{
+			Type:   models.BlockCallout,
+			Icon:   helpfeelIcon,
+			Inline: []models.Inline{{Type: models.InlineBold, Children: p.parseInline(question, links)}},
+		}, true
+	}
+
+	// Convert checkboxes to task list items before the brackets are taken as links
+	if checked, task, ok := splitCheckbox(line); ok {
+		return models.Block{
+			Type:    models.BlockToDo,
+			Indent:  listIndent,
+			Checked: checked,
+			Inline:  p.parseInline(task, links),
+		}, true
+	}
+
+	// Convert headings [** text]
+	if level, heading, ok := parseHeading(line); ok {
+		return models.Block{
+			Type:   models.BlockHeading,
+			Level:  headingLevel(level),
+			Inline: p.parseInline(heading, links),
+		}, true
+	}
+
+	// Keep ordered list items as they are, only indenting nested levels
+	if number, item, ok := splitOrderedListItem(line); ok {
+		return models.Block{
+			Type:   models.BlockNumbered,
+			Indent: listIndent,
+			Number: number,
+			Inline: p.parseInline(item, links),
+		}, true
+	}
+
+	// Lines starting with a callout marker such as NOTE:
+	if rule, text, ok := p.matchCallout(line); ok {
+		return models.Block{
+			Type:   models.BlockCallout,
+			Icon:   rule.Icon,
+			Color:  rule.Color,
+			Inline: p.parseInline(text, links),
+		}, true
+	}
+
+	// Command line notation $ command or % command is shown as code
+	var inlines []models.Inline
+	if strings.HasPrefix(line, "$ ") || strings.HasPrefix(line, "% ") {
+		inlines = []models.Inline{{Type: models.InlineCode, Text: line}}
+	} else {
+		inlines = p.parseInline(line, links)
+	}
+
+	// Add bullet point if there was indentation
+	if indentLevel > 0 {
+		return models.Block{
+			Type:   models.BlockBullet,
+			Indent: listIndent,
+			Inline: inlines,
+		}, true
+	}
+
+	return models.Block{
+		Type:   models.BlockParagraph,
+		Inline: inlines,
+	}, true
+}
+
+// parseInline converts Scrapbox inline notation to inline spans
+func (p *Parser) parseInline(text string, links []string) []models.Inline {
+	// A line consisting of an image URL is shown as the image
+	if p.isImage(text) {
+		return []models.Inline{imageInline(text)}
+	}
+
+	var inlines []models.Inline
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			inlines = append(inlines, models.Inline{Type: models.InlineText, Text: p.replaceShortcodes(plain.String())})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '`':
+			// Code spans are kept verbatim
+			if end := strings.IndexByte(text[i+1:], '`'); end != -1 {
+				flush()
+				inlines = append(inlines, models.Inline{Type: models.InlineCode, Text: text[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+		case '#':
+			// Link hashtags to the page of the tag
+			if p.tagLines == TagLineLink {
+				if tag, end, ok := scanHashtag(text, i); ok {
+					flush()
+					inlines = append(inlines, p.pageLink(tag, links))
+					i = end
+					continue
+				}
+			}
+		case 'h':
+			// Link URLs written without brackets
+			if url, ok := scanURL(text, i); ok {
+				flush()
+				inlines = append(inlines, models.Inline{Type: models.InlineLink, URL: url})
+				i += len(url)
+				continue
+			}
+		case '[':
+			if end := matchBracket(text, i); end != -1 {
+				if inline, ok := p.parseBracket(text[i+1:end], links); ok {
+					flush()
+					inlines = append(inlines, inline)
+					i = end + 1
+					continue
+				}
+			}
+		}
+		plain.WriteByte(text[i])
+		i++
+	}
+	flush()
+
+	return inlines
+}
+
+// decorations are the Scrapbox decoration marks, innermost first
+var decorations = []struct {
+	mark       byte
+	inlineType models.InlineType
+}{
+	{'-', models.InlineStrike},
+	{'/', models.InlineItalic},
+	{'*', models.InlineBold},
+}
+
+// parseBracket converts the content of a [...] notation to an inline span
+func (p *Parser) parseBracket(content string, links []string) (models.Inline, bool) {
+	if content == "" {
+		return models.Inline{}, false
+	}
+
+	// Strong notation [[text]], showing images large and text in bold
+	if len(content) > 2 && content[0] == '[' && matchBracket(content, 0) == len(content)-1 {
+		inner := strings.TrimSpace(content[1 : len(content)-1])
+		if p.isImage(inner) {
+			return imageInline(inner), true
+		}
+		if inner == "" {
+			return models.Inline{}, false
+		}
+		return models.Inline{Type: models.InlineBold, Children: p.parseInline(inner, links)}, true
+	}
+
+	// Math [$ expression]
+	if strings.HasPrefix(content, "$ ") {
+		return models.Inline{Type: models.InlineMath, Text: unescapeMath(content[2:])}, true
+	}
+
+	// Decorations such as [* bold], [/ italic], [- strike] and combinations like [*/ text]
+	marks := 0
+	for marks < len(content) && strings.IndexByte("*/-", content[marks]) != -1 {
+		marks++
+	}
+	if marks > 0 && marks < len(content) && content[marks] == ' ' {
+		inner := content[marks+1:]
+		if strings.TrimSpace(inner) == "" {
+			return models.Inline{}, false
+		}
+		children := p.parseInline(inner, links)
+		for _, decoration := range decorations {
+			if strings.IndexByte(content[:marks], decoration.mark) != -1 {
+				children = []models.Inline{{Type: decoration.inlineType, Children: children}}
+			}
+		}
+		return children[0], true
+	}
+
+	// External links [https://example.com], [label https://example.com] and [https://example.com label]
+	fields := strings.Fields(content)
+	if len(fields) > 0 {
+		first, last := fields[0], fields[len(fields)-1]
+		switch {
+		case len(fields) == 1 && isURL(first):
+			if p.isImage(first) {
+				return imageInline(first), true
+			}
+			return models.Inline{Type: models.InlineLink, URL: first}, true
+		case len(fields) == 2 && isURL(first) && isURL(last) && p.isImage(first) != p.isImage(last):
+			// An image linking to the other URL, [https://example.com https://gyazo.com/id.png]
+			image, link := first, last
+			if p.isImage(link) {
+				image, link = link, image
+			}
+			return models.Inline{Type: models.InlineLink, URL: link, Children: []models.Inline{imageInline(image)}}, true
+		case isURL(first):
+			return models.Inline{Type: models.InlineLink, Text: strings.TrimSpace(content[len(first):]), URL: first}, true
+		case isURL(last):
+			return models.Inline{Type: models.InlineLink, Text: strings.TrimSpace(content[:len(content)-len(last)]), URL: last}, true
+		}
+	}
+
+	// Links to other projects and their pages [/project/page title]
+	if link, ok := p.projectLink(content); ok {
+		return link, true
+	}
+
+	// Page links [page title]
+	return p.pageLink(content, links), true
+}
+
+// countBacklinks returns the number of pages of the export linking to the page
+// with the title, by the linksLc entries of the pages
+func (p *Parser) countBacklinks(title string) int {
+	if p.export == nil {
+		return 0
+	}
+	p.lookups.Lock()
+	defer p.lookups.Unlock()
+	if p.backlinks == nil {
+		p.backlinks = make(map[string]int)
+		for _, page := range p.export.Pages {
+			// Links of a page to itself are not counted
+			seen := map[string]bool{linkID(page.Title): true}
+			for _, link := range page.LinksLc {
+				link = linkID(link)
+				if !seen[link] {
+					seen[link] = true
+					p.backlinks[link]++
+				}
+			}
+		}
+	}
+	return p.backlinks[linkID(title)]
+}
+
+// linkID returns the ID Scrapbox links the page with the title by, its titleLc.
+// Scrapbox replaces spaces with underscores and lowercases the title the way
+// JavaScript does, which differs from strings.ToLower for İ and a final Σ.
+func linkID(title string) string {
+	title = strings.ReplaceAll(title, " ", "_")
+	if !strings.ContainsAny(title, "\u0130\u03a3") {
+		return strings.ToLower(title)
+	}
+	var b strings.Builder
+	runes := []rune(title)
+	for i, r := range runes {
+		switch {
+		case r == '\u0130':
+			b.WriteString("i\u0307")
+		case r == '\u03a3' && i > 0 && unicode.IsLetter(runes[i-1]) && (i == len(runes)-1 || !unicode.IsLetter(runes[i+1])):
+			b.WriteRune('\u03c2')
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// pageLink returns a link to the page with the title, resolved against the
+// linksLc entries of the page, or with unlisted links against the titles of
+// the export
+func (p *Parser) pageLink(title string, links []string) models.Inline {
+	link := models.Inline{Type: models.InlinePageLink, Text: title}
+	id := linkID(title)
+	for _, l := range links {
+		if linkID(l) == id {
+			link.URL = l
+			return link
+		}
+	}
+	if p.unlistedLinks && p.hasPage(id) {
+		link.URL = id
+	}
+	return link
+}
+
+// HasPage reports whether the export has a page with the title, matching titles
+// the way Scrapbox links do. Pages renamed by the title mapping have their new
+// title too.
+func (p *Parser) HasPage(title string) bool {
+	return p.hasPage(linkID(title))
+}
+
+// TitleLc returns the titleLc Scrapbox links the page with the title by
+func TitleLc(title string) string {
+	return linkID(title)
+}
+
+// hasPage reports whether the export has a page with the link ID
+func (p *Parser) hasPage(id string) bool {
+	if p.export == nil {
+		return false
+	}
+	p.lookups.Lock()
+	defer p.lookups.Unlock()
+	if p.titleIDs == nil {
+		p.titleIDs = make(map[string]bool, len(p.export.Pages))
+		for _, page := range p.export.Pages {
+			p.titleIDs[linkID(page.Title)] = true
+			if mapping, ok := p.titleMap[linkID(page.Title)]; ok && mapping.Title != "" {
+				p.titleIDs[linkID(mapping.Title)] = true
+			}
+		}
+	}
+	return p.titleIDs[id]
+}
+
+// splitQuote returns the quoted text of a > line or of a line consisting of a
+// ["quote"] bracket, with or without the closing quotation mark
+func splitQuote(line string) (string, bool) {
+	if quote, ok := strings.CutPrefix(line, ">"); ok {
+		return strings.TrimSpace(quote), true
+	}
+	if strings.HasPrefix(line, `["`) && matchBracket(line, 0) == len(line)-1 {
+		quote := strings.TrimSuffix(line[2:len(line)-1], `"`)
+		if strings.TrimSpace(quote) != "" {
+			return strings.TrimSpace(quote), true
+		}
+	}
+	return "", false
+}
+
+// indentWidth returns the number of spaces and tabs a line is indented with
+func indentWidth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// matchBracket returns the index of the ] closing the [ at start, or -1.
+// Brackets inside code spans are skipped.
+func matchBracket(text string, start int) int {
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end != -1 {
+				i += end + 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// unescapeMath handles escaped backslashes in LaTeX
+func unescapeMath(expression string) string {
+	return strings.ReplaceAll(expression, "\\\\", "\\")
+}
+
+// isURL reports whether text is an http(s) URL
+func isURL(text string) bool {
+	return strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://")
+}
+
+// gyazoID returns the image ID of a Gyazo page URL such as https://gyazo.com/<id>,
+// or an empty string for other URLs
+func gyazoID(text string) string {
+	for _, prefix := range []string{"https://gyazo.com/", "http://gyazo.com/"} {
+		if id := strings.TrimPrefix(text, prefix); id != text && id != "" && !strings.ContainsAny(id, "/?#.") {
+			return id
+		}
+	}
+	return ""
+}
+
+// imageInline returns an image span for an image URL. Gyazo page URLs are
+// turned into the URL of the image itself so every output format can show it.
+func imageInline(url string) models.Inline {
+	if id := gyazoID(url); id != "" {
+		url = "https://gyazo.com/" + id + "/raw"
+	}
+	return models.Inline{Type: models.InlineImage, URL: url}
+}
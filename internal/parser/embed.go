@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+var (
+	youTubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	numericID = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// embedLine returns the embed of a line consisting only of a URL of a
+// supported media site, written bare or in brackets
+func embedLine(line string) (*models.Embed, bool) {
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		line = strings.TrimSpace(line[1 : len(line)-1])
+	}
+	if !isURL(line) || strings.ContainsAny(line, " \t") {
+		return nil, false
+	}
+	return parseEmbed(line)
+}
+
+// parseEmbed returns the embed of a YouTube, Vimeo or Twitter URL
+func parseEmbed(rawURL string) (*models.Embed, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(u.Hostname(), "www."), "m.")
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	embed := &models.Embed{URL: rawURL}
+	switch host {
+	case "youtube.com":
+		embed.Provider = models.EmbedYouTube
+		switch {
+		case len(parts) == 1 && parts[0] == "watch":
+			embed.ID = u.Query().Get("v")
+		case len(parts) == 2 && (parts[0] == "shorts" || parts[0] == "embed" || parts[0] == "live"):
+			embed.ID = parts[1]
+		}
+	case "youtu.be":
+		embed.Provider = models.EmbedYouTube
+		if len(parts) == 1 {
+			embed.ID = parts[0]
+		}
+	case "vimeo.com":
+		embed.Provider = models.EmbedVimeo
+		if len(parts) == 1 && numericID.MatchString(parts[0]) {
+			embed.ID = parts[0]
+		}
+	case "twitter.com", "x.com":
+		embed.Provider = models.EmbedTwitter
+		// https://twitter.com/<user>/status/<id>
+		if len(parts) >= 3 && parts[1] == "status" && numericID.MatchString(parts[2]) {
+			embed.ID = parts[2]
+		}
+	}
+
+	if embed.Provider == models.EmbedYouTube && !youTubeID.MatchString(embed.ID) {
+		return nil, false
+	}
+	if embed.ID == "" {
+		return nil, false
+	}
+	return embed, true
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultEmojiShortcodes maps common emoji shortcodes, without their colons,
+// to their emoji
+var DefaultEmojiShortcodes = map[string]string{
+	"smile":            "😄",
+	"smiley":           "😃",
+	"grin":             "😁",
+	"laughing":         "😆",
+	"joy":              "😂",
+	"wink":             "😉",
+	"blush":            "😊",
+	"heart_eyes":       "😍",
+	"thinking":         "🤔",
+	"sweat_smile":      "😅",
+	"cry":              "😢",
+	"sob":              "😭",
+	"angry":            "😠",
+	"scream":           "😱",
+	"sunglasses":       "😎",
+	"pray":             "🙏",
+	"clap":             "👏",
+	"wave":             "👋",
+	"muscle":           "💪",
+	"eyes":             "👀",
+	"+1":               "👍",
+	"thumbsup":         "👍",
+	"-1":               "👎",
+	"thumbsdown":       "👎",
+	"ok_hand":          "👌",
+	"raised_hands":     "🙌",
+	"point_right":      "👉",
+	"heart":            "❤️",
+	"broken_heart":     "💔",
+	"sparkles":         "✨",
+	"star":             "⭐",
+	"fire":             "🔥",
+	"tada":             "🎉",
+	"rocket":           "🚀",
+	"bulb":             "💡",
+	"memo":             "📝",
+	"book":             "📖",
+	"bookmark":         "🔖",
+	"link":             "🔗",
+	"pushpin":          "📌",
+	"calendar":         "📅",
+	"warning":          "⚠️",
+	"x":                "❌",
+	"white_check_mark": "✅",
+	"heavy_check_mark": "✔️",
+	"question":         "❓",
+	"exclamation":      "❗",
+	"bug":              "🐛",
+	"wrench":           "🔧",
+	"hammer":           "🔨",
+	"lock":             "🔒",
+	"key":              "🔑",
+	"coffee":           "☕",
+	"sushi":            "🍣",
+	"beer":             "🍺",
+	"sunny":            "☀️",
+	"zap":              "⚡",
+	"100":              "💯",
+}
+
+// LoadEmojiShortcodes reads a CSV file of SHORTCODE,EMOJI rows, the shortcode
+// written with or without its colons
+func LoadEmojiShortcodes(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read emoji map: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse emoji map %s: %w", path, err)
+	}
+
+	shortcodes := make(map[string]string)
+	for i, record := range records {
+		if i == 0 && strings.TrimSpace(record[0]) == "shortcode" {
+			continue
+		}
+		shortcode := strings.Trim(strings.TrimSpace(record[0]), ":")
+		if len(record) != 2 || shortcode == "" || strings.TrimSpace(record[1]) == "" {
+			return nil, fmt.Errorf("invalid emoji map %s on line %d: must be SHORTCODE,EMOJI", path, i+1)
+		}
+		shortcodes[shortcode] = strings.TrimSpace(record[1])
+	}
+	return shortcodes, nil
+}
+
+// WithEmojiShortcodes replaces the emoji shortcodes of the map in text with
+// their emoji. Shortcodes missing from the map are kept as they are. Later
+// maps override earlier ones for the same shortcode.
+func WithEmojiShortcodes(shortcodes map[string]string) Option {
+	return func(p *Parser) {
+		if p.emoji == nil {
+			p.emoji = make(map[string]string, len(shortcodes))
+		}
+		for shortcode, emoji := range shortcodes {
+			p.emoji[shortcode] = emoji
+		}
+	}
+}
+
+// replaceShortcodes replaces the emoji shortcodes in text with their emoji.
+// The closing colon of text that isn't a shortcode may open the next, as in
+// 10:30:smile:.
+func (p *Parser) replaceShortcodes(text string) string {
+	if len(p.emoji) == 0 || strings.Count(text, ":") < 2 {
+		return text
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(text, ':')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(text[start+1:], ':')
+		if end == -1 {
+			break
+		}
+		end += start + 1
+		emoji, ok := p.emoji[text[start+1:end]]
+		if !ok {
+			b.WriteString(text[:end])
+			text = text[end:]
+			continue
+		}
+		b.WriteString(text[:start] + emoji)
+		text = text[end+1:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
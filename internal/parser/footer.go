@@ -0,0 +1,23 @@
+package parser
+
+import "github.com/takak2166/scrapbox2notion/internal/models"
+
+// WithMetadataFooter ends documents with a footer of the Scrapbox project and
+// page they come from, the time of the export and the version of the tool
+func WithMetadataFooter(version string) Option {
+	return func(p *Parser) {
+		p.footerVersion = version
+	}
+}
+
+// footer returns the metadata footer of the page, or nil without one
+func (p *Parser) footer(page *models.Page) *models.Footer {
+	if p.footerVersion == "" {
+		return nil
+	}
+	footer := &models.Footer{Project: p.Project(), URL: p.sourceURL(page), Version: p.footerVersion}
+	if p.export != nil {
+		footer.Exported = p.export.Exported
+	}
+	return footer
+}
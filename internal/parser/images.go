@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// imageExtensions are the file extensions Scrapbox shows as images
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".bmp", ".avif"}
+
+// imageHosts are hosts serving only images, whatever their paths end with
+var imageHosts = []string{"i.gyazo.com", "pbs.twimg.com", "i.imgur.com", "images.unsplash.com", "lh3.googleusercontent.com"}
+
+// ImageProbe reports whether the URL, which doesn't look like an image by its
+// extension or host, is an image
+type ImageProbe func(url string) bool
+
+// WithImageProbe asks the probe about bracketed URLs that don't look like
+// images, such as image URLs without an extension
+func WithImageProbe(probe ImageProbe) Option {
+	return func(p *Parser) {
+		p.imageProbe = probe
+	}
+}
+
+// ContentTypeProbe returns a probe sending a HEAD request for the URL and
+// taking it as an image if the server answers with an image content type.
+// Answers are remembered, so each URL is requested once.
+func ContentTypeProbe(client *http.Client) ImageProbe {
+	var mu sync.Mutex
+	answers := make(map[string]bool)
+	return func(url string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if image, ok := answers[url]; ok {
+			return image
+		}
+		image := false
+		if resp, err := client.Head(url); err == nil {
+			resp.Body.Close()
+			image = resp.StatusCode < 300 && strings.HasPrefix(resp.Header.Get("Content-Type"), "image/")
+		}
+		answers[url] = image
+		return image
+	}
+}
+
+// isImage reports whether text is a URL of an image, asking the image probe
+// when neither the extension nor the host tell
+func (p *Parser) isImage(text string) bool {
+	if isImageURL(text) {
+		return true
+	}
+	return p.imageProbe != nil && isURL(text) && !strings.ContainsAny(text, " \t") && p.imageProbe(text)
+}
+
+// isImageURL reports whether text is a URL of an image file, ignoring any
+// query string, of an image host or of a Gyazo image
+func isImageURL(text string) bool {
+	if !isURL(text) || strings.ContainsAny(text, " \t") {
+		return false
+	}
+	if gyazoID(text) != "" {
+		return true
+	}
+	u, err := url.Parse(text)
+	if err != nil {
+		return false
+	}
+	path := strings.ToLower(u.Path)
+	for _, ext := range imageExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	for _, host := range imageHosts {
+		if u.Host == host && u.Path != "" && u.Path != "/" {
+			return true
+		}
+	}
+	return false
+}
+
+// scanURL returns the URL starting at start in text, if a URL starts there
+// after a space or another character that can't be part of a word. The URL
+// ends at white space, and trailing punctuation is left out of it.
+func scanURL(text string, start int) (string, bool) {
+	if !isURL(text[start:]) {
+		return "", false
+	}
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return "", false
+		}
+	}
+	end := start
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsSpace(r) || r == '[' || r == ']' || r == '`' {
+			break
+		}
+		end += size
+	}
+	url := strings.TrimRight(text[start:end], ".,;:!?'\")。、」』）")
+	// Keep the closing parenthesis of URLs such as Wikipedia's that open one
+	if strings.Count(url, "(") > strings.Count(url, ")") && end > start+len(url) && text[start+len(url)] == ')' {
+		url += ")"
+	}
+	if url == "http://" || url == "https://" {
+		return "", false
+	}
+	return url, true
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// UnicodeForm decides how the text of pages is normalized
+type UnicodeForm string
+
+const (
+	// FormNone keeps text as it is in the export
+	FormNone UnicodeForm = "none"
+	// FormNFC composes characters, such as か and a voiced sound mark into が
+	FormNFC UnicodeForm = "nfc"
+	// FormNFD decomposes characters, such as が into か and a voiced sound mark
+	FormNFD UnicodeForm = "nfd"
+)
+
+// ParseUnicodeForm parses a Unicode normalization form name
+func ParseUnicodeForm(form string) (UnicodeForm, error) {
+	switch UnicodeForm(strings.ToLower(form)) {
+	case FormNone, FormNFC, FormNFD:
+		return UnicodeForm(strings.ToLower(form)), nil
+	}
+	return "", fmt.Errorf("invalid unicode form %q: must be one of nfc, nfd, none", form)
+}
+
+// WithUnicodeForm normalizes the titles, lines and links of pages to the form,
+// so titles typed on different systems match
+func WithUnicodeForm(form UnicodeForm) Option {
+	return func(p *Parser) {
+		p.unicodeForm = form
+	}
+}
+
+// WithFullWidthIndent treats full-width spaces (U+3000) at the start of lines
+// as indentation, the same as half-width spaces
+func WithFullWidthIndent() Option {
+	return func(p *Parser) {
+		p.fullWidthIndent = true
+	}
+}
+
+// Normalize returns the text in the Unicode normalization form
+func Normalize(text string, form UnicodeForm) string {
+	switch form {
+	case FormNFC:
+		return norm.NFC.String(text)
+	case FormNFD:
+		return norm.NFD.String(text)
+	}
+	return text
+}
+
+// normalizePage normalizes the text of the page and its full-width indentation
+// before it is parsed
+func (p *Parser) normalizePage(page *models.Page) {
+	normalize := p.unicodeForm != FormNone
+	if !normalize && !p.fullWidthIndent {
+		return
+	}
+	// Copy what changes so the export the page came from is left as it was
+	if normalize {
+		page.Title = Normalize(page.Title, p.unicodeForm)
+		links := make([]string, len(page.LinksLc))
+		for i, link := range page.LinksLc {
+			links[i] = Normalize(link, p.unicodeForm)
+		}
+		page.LinksLc = links
+	}
+	lines := make([]models.Line, len(page.Lines))
+	for i, line := range page.Lines {
+		if normalize {
+			line.Text = Normalize(line.Text, p.unicodeForm)
+		}
+		if p.fullWidthIndent {
+			line.Text = fullWidthIndent(line.Text)
+		}
+		lines[i] = line
+	}
+	page.Lines = lines
+}
+
+// fullWidthIndent replaces the full-width spaces in the indentation of the line
+// with half-width spaces
+func fullWidthIndent(line string) string {
+	rest := strings.TrimLeft(line, " \t\u3000")
+	indent := line[:len(line)-len(rest)]
+	if !strings.ContainsRune(indent, '\u3000') {
+		return line
+	}
+	return strings.ReplaceAll(indent, "\u3000", " ") + rest
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// PageOrder decides the order pages are processed in
+type PageOrder string
+
+const (
+	// OrderNone keeps the order of the export
+	OrderNone PageOrder = "none"
+	// OrderCreated processes the oldest pages first
+	OrderCreated PageOrder = "created"
+	// OrderUpdated processes the least recently updated pages first
+	OrderUpdated PageOrder = "updated"
+	// OrderTitle processes pages in the order of their titles
+	OrderTitle PageOrder = "title"
+	// OrderViews processes the least viewed pages first
+	OrderViews PageOrder = "views"
+	// OrderPinned processes the pages pinned in Scrapbox last, in the order
+	// they were pinned, so they show first as they do in Scrapbox
+	OrderPinned PageOrder = "pinned"
+)
+
+// ParsePageOrder parses a page order name
+func ParsePageOrder(order string) (PageOrder, error) {
+	switch PageOrder(order) {
+	case OrderNone, OrderCreated, OrderUpdated, OrderTitle, OrderViews, OrderPinned:
+		return PageOrder(order), nil
+	}
+	return "", fmt.Errorf("invalid page order %q: must be one of created, updated, title, views, pinned, none", order)
+}
+
+// SortPages returns a copy of the pages in the order. Every order is ascending,
+// so the pages that come last, such as the newest, are created in Notion last
+// and show first in views sorted by creation. Pages that tie keep their order.
+func SortPages(pages []models.Page, order PageOrder) []models.Page {
+	sorted := slices.Clone(pages)
+	var compare func(a, b models.Page) int
+	switch order {
+	case OrderCreated:
+		compare = func(a, b models.Page) int { return cmp.Compare(a.Created, b.Created) }
+	case OrderUpdated:
+		compare = func(a, b models.Page) int { return cmp.Compare(a.Updated, b.Updated) }
+	case OrderTitle:
+		compare = func(a, b models.Page) int { return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)) }
+	case OrderViews:
+		compare = func(a, b models.Page) int { return cmp.Compare(a.Views, b.Views) }
+	case OrderPinned:
+		compare = func(a, b models.Page) int { return cmp.Compare(a.Pin, b.Pin) }
+	default:
+		return sorted
+	}
+	slices.SortStableFunc(sorted, compare)
+	return sorted
+}
@@ -2,22 +2,207 @@ package parser
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/takak2166/scrapbox2notion/internal/logger"
 	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/render/markdown"
 )
 
+// DuplicatePolicy decides what happens when several inputs contain pages with the same title
+type DuplicatePolicy string
+
+const (
+	// DuplicateKeepNewest keeps the most recently updated page
+	DuplicateKeepNewest DuplicatePolicy = "newest"
+	// DuplicateKeepFirst keeps the page that was parsed first
+	DuplicateKeepFirst DuplicatePolicy = "first"
+	// DuplicateRename keeps both pages, adding a numbered suffix to the later title
+	DuplicateRename DuplicatePolicy = "rename"
+)
+
+// ParseDuplicatePolicy parses a duplicate policy name
+func ParseDuplicatePolicy(policy string) (DuplicatePolicy, error) {
+	switch DuplicatePolicy(policy) {
+	case DuplicateKeepNewest, DuplicateKeepFirst, DuplicateRename:
+		return DuplicatePolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid duplicate policy %q: must be one of newest, first, rename", policy)
+}
+
+// TagLineMode decides what happens to lines consisting only of hashtags
+type TagLineMode string
+
+const (
+	// TagLineStrip removes tag lines from the content, as tags become Notion relations
+	TagLineStrip TagLineMode = "strip"
+	// TagLineKeep keeps tag lines as text
+	TagLineKeep TagLineMode = "keep"
+	// TagLineLink keeps tag lines and turns every hashtag into a link to the tag page
+	TagLineLink TagLineMode = "keep-and-link"
+)
+
+// ParseTagLineMode parses a tag line mode name
+func ParseTagLineMode(mode string) (TagLineMode, error) {
+	switch TagLineMode(mode) {
+	case TagLineStrip, TagLineKeep, TagLineLink:
+		return TagLineMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid tag line mode %q: must be one of strip, keep, keep-and-link", mode)
+}
+
 // Parser handles the conversion from Scrapbox JSON to markdown
 type Parser struct {
-	export *models.ScrapboxExport
+	export      *models.ScrapboxExport
+	titles      map[string]int
+	duplicates  DuplicatePolicy
+	bracketTags bool
+	// defaultTag is the tag of pages without tags, empty for none
+	defaultTag string
+	tagLines   TagLineMode
+	embeds     bool
+	callouts   []CalloutRule
+	// unicodeForm is the form the text of pages is normalized to
+	unicodeForm UnicodeForm
+	// fullWidthIndent treats leading full-width spaces as indentation
+	fullWidthIndent bool
+	// titleMap renames pages by the link ID of their Scrapbox title
+	titleMap map[string]TitleMapping
+	// backlinks counts the pages linking to each page by link ID, once needed
+	backlinks map[string]int
+	// unlistedLinks resolves page links missing from linksLc against the titles
+	unlistedLinks bool
+	// history is how the author and edit time of lines are shown, empty for not at all
+	history models.HistoryStyle
+	// imageProbe tells whether URLs that don't look like images are
+	imageProbe ImageProbe
+	// titleIDs holds the link IDs of the titles of the export, once needed
+	titleIDs map[string]bool
+	// lookups guards backlinks and titleIDs, built by the first document that
+	// needs them, so documents can be parsed at the same time
+	lookups sync.Mutex
+	// tocHeadings is the number of headings documents lead with a table of
+	// contents from, 0 for never
+	tocHeadings int
+	// force converts input that doesn't look like a Scrapbox export
+	force bool
+	// attachments is how files linked on a line of their own are shown by
+	// their extension
+	attachments map[string]models.AttachmentKind
+	// emoji maps emoji shortcodes to the emoji they are replaced with
+	emoji map[string]string
+	// footerVersion is the version of the tool shown in the metadata footer
+	// of documents, empty for no footer
+	footerVersion string
+	// sourceURLs gives documents the URL of their page on Scrapbox
+	sourceURLs bool
+	// project is the name of the Scrapbox project of the pages, overriding
+	// the name of the export
+	project string
+}
+
+// Option configures optional behavior of the Parser
+type Option func(*Parser)
+
+// WithDuplicatePolicy sets how pages with the same title across inputs are merged
+func WithDuplicatePolicy(policy DuplicatePolicy) Option {
+	return func(p *Parser) {
+		p.duplicates = policy
+	}
+}
+
+// WithBracketTags also takes the [page links] on the last lines of a page as its tags
+func WithBracketTags() Option {
+	return func(p *Parser) {
+		p.bracketTags = true
+	}
+}
+
+// WithDefaultTag gives pages without tags the tag, so they are filed with the
+// pages of the tag instead of on their own
+func WithDefaultTag(tag string) Option {
+	return func(p *Parser) {
+		p.defaultTag = tag
+	}
+}
+
+// WithTagLineMode sets what happens to lines consisting only of hashtags
+func WithTagLineMode(mode TagLineMode) Option {
+	return func(p *Parser) {
+		p.tagLines = mode
+	}
+}
+
+// WithEmbeds turns lines consisting of a YouTube, Vimeo or Twitter URL into embed blocks
+func WithEmbeds() Option {
+	return func(p *Parser) {
+		p.embeds = true
+	}
+}
+
+// WithCalloutRules turns lines starting with the markers of the rules into callouts.
+// Rules are tried in order, so longer markers sharing a prefix should come first.
+func WithCalloutRules(rules ...CalloutRule) Option {
+	return func(p *Parser) {
+		p.callouts = append(p.callouts, rules...)
+	}
+}
+
+// ParseHistoryMode parses a history mode name, off returning no style
+func ParseHistoryMode(mode string) (models.HistoryStyle, error) {
+	switch mode {
+	case "off":
+		return "", nil
+	case string(models.HistoryComment), string(models.HistoryFootnote):
+		return models.HistoryStyle(mode), nil
+	}
+	return "", fmt.Errorf("invalid history mode %q: must be one of off, comment, footnote", mode)
+}
+
+// WithLineHistory records the author and edit time of every line on its block,
+// shown in the style by the output formats
+func WithLineHistory(style models.HistoryStyle) Option {
+	return func(p *Parser) {
+		p.history = style
+	}
+}
+
+// WithTableOfContents leads documents with at least minHeadings headings with
+// a table of contents in the output formats
+func WithTableOfContents(minHeadings int) Option {
+	return func(p *Parser) {
+		p.tocHeadings = minHeadings
+	}
+}
+
+// WithUnlistedLinks links page links missing from the linksLc of their page,
+// such as those of pages fetched without it, when the export has the page
+func WithUnlistedLinks() Option {
+	return func(p *Parser) {
+		p.unlistedLinks = true
+	}
 }
 
 // New creates a new Parser instance
-func New() *Parser {
-	return &Parser{}
+func New(opts ...Option) *Parser {
+	p := &Parser{
+		titles:      make(map[string]int),
+		duplicates:  DuplicateKeepNewest,
+		tagLines:    TagLineStrip,
+		unicodeForm: FormNone,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // ParseFile reads and parses a Scrapbox JSON export file
@@ -26,45 +211,120 @@ func (p *Parser) ParseFile(filepath string) error {
 		"filepath": filepath,
 	})
 
-	data, err := os.ReadFile(filepath)
+	f, err := os.Open(filepath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
+	defer f.Close()
 
-	p.export = &models.ScrapboxExport{}
-	if err := json.Unmarshal(data, p.export); err != nil {
+	return p.Parse(f)
+}
+
+// Parse reads and parses a Scrapbox JSON export from r.
+// Calling Parse more than once merges the pages of every export.
+func (p *Parser) Parse(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	version, err := DetectSchema(data)
+	if err != nil {
+		if !p.force || !errors.Is(err, ErrNotScrapboxExport) {
+			return err
+		}
+		logger.Warn("Converting input anyway", map[string]interface{}{
+			"reason": err.Error(),
+		})
+	}
+
+	export := &models.ScrapboxExport{}
+	if err := json.Unmarshal(data, export); err != nil {
 		return fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
 	// Extract tags from each page
-	for i := range p.export.Pages {
-		p.extractTags(&p.export.Pages[i])
+	for i := range export.Pages {
+		p.normalizePage(&export.Pages[i])
+		p.extractTags(&export.Pages[i])
 	}
 
 	logger.Info("Successfully parsed Scrapbox export file", map[string]interface{}{
-		"pages_count": len(p.export.Pages),
+		"pages_count":    len(export.Pages),
+		"schema_version": version,
 	})
 
+	if p.export == nil {
+		p.export = &models.ScrapboxExport{
+			Name:        export.Name,
+			DisplayName: export.DisplayName,
+			Exported:    export.Exported,
+		}
+	}
+	for _, page := range export.Pages {
+		p.addPage(page)
+	}
+
 	return nil
 }
 
-// extractTags extracts tags from page lines and stores them in the Page struct
-func (p *Parser) extractTags(page *models.Page) {
-	var tags []string
-	for _, line := range page.Lines {
-		// Split the line into words
-		words := strings.Fields(line.Text)
-		for _, word := range words {
-			// Check if the word starts with #
-			if strings.HasPrefix(word, "#") {
-				tag := strings.TrimPrefix(word, "#")
-				if tag != "" {
-					tags = append(tags, tag)
-				}
-			}
+// AddPages adds pages read other than from an export, such as through the
+// Scrapbox API, extracting their tags and merging them as Parse does
+func (p *Parser) AddPages(pages ...models.Page) {
+	if p.export == nil {
+		p.export = &models.ScrapboxExport{}
+	}
+	for _, page := range pages {
+		p.normalizePage(&page)
+		p.extractTags(&page)
+		p.addPage(page)
+	}
+}
+
+// addPage adds a page to the merged export, resolving duplicate titles by the duplicate policy
+func (p *Parser) addPage(page models.Page) {
+	p.backlinks = nil
+	p.titleIDs = nil
+	idx, exists := p.titles[page.Title]
+	if !exists {
+		p.titles[page.Title] = len(p.export.Pages)
+		p.export.Pages = append(p.export.Pages, page)
+		return
+	}
+
+	existing := p.export.Pages[idx]
+	switch p.duplicates {
+	case DuplicateKeepFirst:
+		logger.Info("Skipping page with duplicate title", map[string]interface{}{
+			"title": page.Title,
+		})
+	case DuplicateRename:
+		title := page.Title
+		for n := 2; exists; n++ {
+			title = fmt.Sprintf("%s (%d)", page.Title, n)
+			_, exists = p.titles[title]
+		}
+		logger.Info("Renaming page with duplicate title", map[string]interface{}{
+			"title":     page.Title,
+			"new_title": title,
+		})
+		// The first line holds the title, so it is renamed along with the page
+		// rather than left in the body
+		if len(page.Lines) > 0 && page.Lines[0].Text == page.Title {
+			page.Lines = slices.Clone(page.Lines)
+			page.Lines[0].Text = title
 		}
+		page.Title = title
+		p.titles[title] = len(p.export.Pages)
+		p.export.Pages = append(p.export.Pages, page)
+	default:
+		if page.Updated > existing.Updated {
+			p.export.Pages[idx] = page
+		}
+		logger.Info("Keeping newest page with duplicate title", map[string]interface{}{
+			"title": page.Title,
+		})
 	}
-	page.Tags = tags
 }
 
 // ConvertToMarkdown converts a Scrapbox page to markdown format
@@ -73,200 +333,150 @@ func (p *Parser) ConvertToMarkdown(page *models.Page) string {
 		"page_title": page.Title,
 	})
 
-	var md strings.Builder
-
-	// Add title
-	md.WriteString(fmt.Sprintf("# %s\n\n", page.Title))
-
-	// Process lines
-	var codeBlock bool
-	var codeLanguage string
-	var codeContent []string
-
-	for i, line := range page.Lines {
-		// Skip the title line as we've already added it
-		if i == 0 && line.Text == page.Title {
-			continue
-		}
-
-		// Skip tag lines as they'll be handled by Notion relations
-		if strings.HasPrefix(strings.TrimSpace(line.Text), "#") {
-			continue
-		}
-
-		// Handle code blocks
-		if strings.HasPrefix(strings.TrimSpace(line.Text), "code:") {
-			codeBlock = true
-			codeLanguage = strings.TrimSpace(strings.TrimPrefix(line.Text, "code:"))
-			continue
-		}
+	return markdown.Render(p.ParseDocument(page))
+}
 
-		if codeBlock {
-			if strings.HasPrefix(line.Text, " ") || strings.HasPrefix(line.Text, "\t") {
-				codeContent = append(codeContent, strings.TrimLeft(line.Text, " \t"))
-				continue
-			} else {
-				// End of code block
-				md.WriteString(fmt.Sprintf("```%s\n%s\n```\n", codeLanguage, strings.Join(codeContent, "\n")))
-				codeBlock = false
-				codeContent = nil
-				codeLanguage = ""
-			}
-		}
+// codeLanguages maps file extensions to the language names used for markdown code fences
+var codeLanguages = map[string]string{
+	"bash":  "bash",
+	"c":     "c",
+	"cc":    "cpp",
+	"cpp":   "cpp",
+	"cs":    "csharp",
+	"css":   "css",
+	"dart":  "dart",
+	"diff":  "diff",
+	"ex":    "elixir",
+	"go":    "go",
+	"h":     "c",
+	"hpp":   "cpp",
+	"hs":    "haskell",
+	"html":  "html",
+	"java":  "java",
+	"js":    "javascript",
+	"json":  "json",
+	"jsx":   "jsx",
+	"kt":    "kotlin",
+	"lua":   "lua",
+	"md":    "markdown",
+	"php":   "php",
+	"pl":    "perl",
+	"ps1":   "powershell",
+	"py":    "python",
+	"r":     "r",
+	"rb":    "ruby",
+	"rs":    "rust",
+	"scala": "scala",
+	"scss":  "scss",
+	"sh":    "shell",
+	"sql":   "sql",
+	"swift": "swift",
+	"tex":   "latex",
+	"toml":  "toml",
+	"ts":    "typescript",
+	"tsx":   "tsx",
+	"xml":   "xml",
+	"yaml":  "yaml",
+	"yml":   "yaml",
+	"zsh":   "shell",
+}
 
-		// Convert line to markdown
-		mdLine := p.convertLineToMarkdown(line.Text, page.LinksLc)
-		if mdLine != "" {
-			md.WriteString(mdLine + "\n")
+// inferCodeLanguage returns the code fence language for a Scrapbox code block name.
+// Names with a known extension such as "main.go" are mapped to their language, other
+// names such as "python" are used as they are.
+func inferCodeLanguage(name string) string {
+	name = strings.TrimSpace(name)
+	if ext := strings.TrimPrefix(path.Ext(name), "."); ext != "" {
+		if lang, ok := codeLanguages[strings.ToLower(ext)]; ok {
+			return lang
 		}
 	}
-
-	// Handle any remaining code block
-	if codeBlock && len(codeContent) > 0 {
-		md.WriteString(fmt.Sprintf("```%s\n%s\n```\n", codeLanguage, strings.Join(codeContent, "\n")))
-	}
-
-	return md.String()
+	return name
 }
 
 // convertLineToMarkdown converts a single line from Scrapbox format to markdown
 func (p *Parser) convertLineToMarkdown(line string, links []string) string {
-	if line == "" {
+	block, ok := p.parseLine(line, links)
+	if !ok {
 		return ""
 	}
+	return markdown.RenderBlock(block)
+}
 
-	// Count leading spaces and tabs for indentation level
-	indentLevel := 0
-	for _, char := range line {
-		if char == ' ' || char == '\t' {
-			indentLevel++
-		} else {
-			break
-		}
+// isDivider reports whether text is a Scrapbox divider such as [----] or a line of three or more dashes
+func isDivider(text string) bool {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		text = text[1 : len(text)-1]
+		return len(text) >= 2 && strings.Trim(text, "-") == ""
 	}
+	return len(text) >= 3 && strings.Trim(text, "-") == ""
+}
 
-	// Trim leading whitespace
-	line = strings.TrimLeft(line, " \t")
-
-	// Convert Scrapbox syntax to markdown
-	line = p.convertSyntax(line, links)
-
-	// Add bullet point if there was indentation
-	if indentLevel > 0 {
-		indent := strings.Repeat("  ", indentLevel-1)
-		return indent + "- " + line
+// splitCheckbox splits a "[ ] task" or "[x] task" line into its checked state and text
+func splitCheckbox(text string) (bool, string, bool) {
+	switch {
+	case strings.HasPrefix(text, "[ ] "):
+		return false, text[4:], true
+	case strings.HasPrefix(text, "[x] "), strings.HasPrefix(text, "[X] "):
+		return true, text[4:], true
 	}
-
-	return line
+	return false, text, false
 }
 
-// convertSyntax converts Scrapbox syntax to markdown
-func (p *Parser) convertSyntax(text string, links []string) string {
-	// Convert headings [** text] to #### text
-	if strings.HasPrefix(text, "[**") {
-		level := strings.Count(text[:strings.Index(text, " ")], "*")
-		heading := strings.TrimPrefix(text, "["+strings.Repeat("*", level)+" ")
-		heading = strings.TrimSuffix(heading, "]")
-
-		// Map Scrapbox heading levels to Markdown heading levels
-		var mdLevel int
-		switch level {
-		case 2: // [** text] -> #### text
-			mdLevel = 4
-		case 3: // [*** text] -> ### text
-			mdLevel = 3
-		case 4: // [**** text] -> ## text
-			mdLevel = 2
-		default:
-			mdLevel = 4
-		}
-
-		return strings.Repeat("#", mdLevel) + " " + heading
+// splitOrderedListItem splits a "1. item" line into its number and text
+func splitOrderedListItem(text string) (int, string, bool) {
+	digits := 0
+	for digits < len(text) && text[digits] >= '0' && text[digits] <= '9' {
+		digits++
 	}
-
-	// Convert strikethrough [- text]
-	text = p.replaceEnclosed(text, "[- ", "]", "~~", "~~")
-
-	// Convert bold [* text]
-	text = p.replaceEnclosed(text, "[* ", "]", "**", "**")
-
-	// Convert italic [/ text]
-	text = p.replaceEnclosed(text, "[/ ", "]", "_", "_")
-
-	// Convert math equations [$ text]
-	text = p.replaceEnclosed(text, "[$ ", "]", "$", "$")
-
-	// Convert backtick-quoted text
-	if strings.HasPrefix(text, "`") && strings.HasSuffix(text, "`") {
-		return text
+	if digits == 0 || !strings.HasPrefix(text[digits:], ". ") {
+		return 0, text, false
 	}
-
-	// Convert page links
-	text = p.convertPageLinks(text, links)
-
-	// Convert external links
-	text = p.convertExternalLinks(text)
-
-	return text
+	number, err := strconv.Atoi(text[:digits])
+	if err != nil {
+		return 0, text, false
+	}
+	return number, text[digits+2:], true
 }
 
-// replaceEnclosed replaces text enclosed in Scrapbox syntax with markdown syntax
-func (p *Parser) replaceEnclosed(text, prefix, suffix, mdPrefix, mdSuffix string) string {
-	startIdx := strings.Index(text, prefix)
-	if startIdx == -1 {
-		return text
+// parseHeading parses a whole-line Scrapbox heading such as [** text] and returns
+// the number of asterisks and the heading text
+func parseHeading(text string) (int, string, bool) {
+	if !strings.HasPrefix(text, "[**") || !strings.HasSuffix(text, "]") {
+		return 0, "", false
 	}
 
-	endIdx := strings.Index(text[startIdx:], suffix)
-	if endIdx == -1 {
-		return text
+	level := 0
+	for level+1 < len(text) && text[level+1] == '*' {
+		level++
 	}
-	endIdx += startIdx
 
-	content := text[startIdx+len(prefix) : endIdx]
-	// Handle escaped backslashes in LaTeX
-	if prefix == "[$ " {
-		content = strings.ReplaceAll(content, "\\\\", "\\")
+	rest := text[level+1:]
+	if !strings.HasPrefix(rest, " ") {
+		return 0, "", false
 	}
-	return text[:startIdx] + mdPrefix + content + mdSuffix + text[endIdx+1:]
-}
 
-// convertPageLinks converts Scrapbox page links to markdown links
-func (p *Parser) convertPageLinks(text string, links []string) string {
-	// First, handle explicit page links in the format [page title]
-	startIdx := strings.Index(text, "[")
-	if startIdx != -1 && !strings.HasPrefix(text[startIdx:], "[- ") &&
-		!strings.HasPrefix(text[startIdx:], "[* ") &&
-		!strings.HasPrefix(text[startIdx:], "[$ ") &&
-		!strings.HasPrefix(text[startIdx:], "[**") &&
-		!strings.HasPrefix(text[startIdx:], "[/ ") {
-		endIdx := strings.Index(text[startIdx:], "]")
-		if endIdx != -1 {
-			endIdx += startIdx
-			linkText := text[startIdx+1 : endIdx]
-			linkId := strings.ToLower(strings.ReplaceAll(linkText, " ", "_"))
-
-			// Check if this is a valid page link
-			for _, link := range links {
-				if strings.EqualFold(link, linkId) {
-					return text[:startIdx] + fmt.Sprintf("[%s](./%s.md)", linkText, link) + text[endIdx+1:]
-				}
-			}
-		}
+	heading := strings.TrimSpace(strings.TrimSuffix(rest, "]"))
+	if heading == "" {
+		return 0, "", false
 	}
-	return text
+	return level, heading, true
 }
 
-// convertExternalLinks converts external URLs to markdown links
-func (p *Parser) convertExternalLinks(text string) string {
-	// Handle image links
-	if strings.HasPrefix(text, "http") &&
-		(strings.HasSuffix(text, ".jpg") || strings.HasSuffix(text, ".png") ||
-			strings.HasSuffix(text, ".gif") || strings.HasSuffix(text, ".jpeg")) {
-		return fmt.Sprintf("![image](%s)", text)
+// headingLevel maps the number of Scrapbox asterisks to a markdown heading level.
+// More asterisks mean a larger heading, so anything beyond four becomes h1.
+func headingLevel(asterisks int) int {
+	switch asterisks {
+	case 2: // [** text] -> #### text
+		return 4
+	case 3: // [*** text] -> ### text
+		return 3
+	case 4: // [**** text] -> ## text
+		return 2
+	default: // [***** text] and more -> # text
+		return 1
 	}
-
-	return text
 }
 
 // GetPages returns all pages from the parsed export
@@ -1,10 +1,20 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
 )
 
 func TestParseFile(t *testing.T) {
@@ -180,6 +190,705 @@ func TestParseFile(t *testing.T) {
 	}
 }
 
+func TestExtractTags(t *testing.T) {
+	tests := map[string]struct {
+		lines       []string
+		bracketTags bool
+		defaultTag  string
+		expected    []string
+	}{
+		"Hashtags":               {lines: []string{"#tag1 text #tag2"}, expected: []string{"tag1", "tag2"}},
+		"Japanese hashtag":       {lines: []string{"#日本語のタグ　本文"}, expected: []string{"日本語のタグ"}},
+		"Punctuation in tag":     {lines: []string{"#C++ and #Node.js"}, expected: []string{"C++", "Node.js"}},
+		"Trailing punctuation":   {lines: []string{"tagged #go, #rust."}, expected: []string{"go", "rust"}},
+		"Japanese punctuation":   {lines: []string{"これは #メモ。"}, expected: []string{"メモ"}},
+		"Not after a word":       {lines: []string{"issue#12 https://example.com/#anchor"}, expected: nil},
+		"Inside code span":       {lines: []string{"`#include` #c"}, expected: []string{"c"}},
+		"Inside code block":      {lines: []string{"code:sh", " # comment", "#shell"}, expected: []string{"shell"}},
+		"Ends at bracket":        {lines: []string{"[#tag]"}, expected: nil},
+		"Duplicates":             {lines: []string{"#tag", "#tag"}, expected: []string{"tag"}},
+		"Bracket tags disabled":  {lines: []string{"text", "[tag1] [tag2]"}, expected: nil},
+		"Bracket tags":           {lines: []string{"text", "[tag1] [tag with spaces]", "[tag3] #tag4", ""}, bracketTags: true, expected: []string{"tag4", "tag1", "tag with spaces", "tag3"}},
+		"Only trailing brackets": {lines: []string{"[link]", "text [inline]"}, bracketTags: true, expected: nil},
+		"Not links":              {lines: []string{"text", "[* bold] [https://example.com]"}, bracketTags: true, expected: nil},
+		"Default tag":            {lines: []string{"text"}, defaultTag: "Untagged", expected: []string{"Untagged"}},
+		"Default tag unused":     {lines: []string{"#tag"}, defaultTag: "Untagged", expected: []string{"tag"}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var opts []Option
+			if tt.bracketTags {
+				opts = append(opts, WithBracketTags())
+			}
+			if tt.defaultTag != "" {
+				opts = append(opts, WithDefaultTag(tt.defaultTag))
+			}
+			page := &models.Page{Title: "Page", Lines: []models.Line{{Text: "Page"}}}
+			for _, line := range tt.lines {
+				page.Lines = append(page.Lines, models.Line{Text: line})
+			}
+
+			New(opts...).extractTags(page)
+			if len(page.Tags) != len(tt.expected) {
+				t.Fatalf("Expected tags %v, got %v", tt.expected, page.Tags)
+			}
+			for i, tag := range tt.expected {
+				if page.Tags[i] != tag {
+					t.Errorf("Expected tag %q, got %q", tag, page.Tags[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTagLineMode(t *testing.T) {
+	page := &models.Page{
+		Title: "Page",
+		Lines: []models.Line{
+			{Text: "Page"},
+			{Text: "#tag1 #tag2"},
+			{Text: "#include <stdio.h> is C"},
+			{Text: "text #tag1"},
+		},
+		LinksLc: []string{"tag1", "tag2"},
+	}
+
+	tests := map[TagLineMode]string{
+		TagLineStrip: "# Page\n\n#include <stdio.h> is C\ntext #tag1\n",
+		TagLineKeep:  "# Page\n\n#tag1 #tag2\n#include <stdio.h> is C\ntext #tag1\n",
+		TagLineLink:  "# Page\n\n[tag1](./tag1.md) [tag2](./tag2.md)\n[include] <stdio.h> is C\ntext [tag1](./tag1.md)\n",
+	}
+
+	for mode, expected := range tests {
+		t.Run(string(mode), func(t *testing.T) {
+			result := New(WithTagLineMode(mode)).ConvertToMarkdown(page)
+			if result != expected {
+				t.Errorf("ConvertToMarkdown() = %q, want %q", result, expected)
+			}
+		})
+	}
+
+	if _, err := ParseTagLineMode("drop"); err == nil {
+		t.Error("Expected error for invalid tag line mode, got nil")
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	tests := map[string]struct {
+		lines    []string
+		expected string
+	}{
+		"Blank lines inside the block": {
+			lines:    []string{"code:main.go", " package main", "", " func main() {", "  \tprintln()", " }", "after"},
+			expected: "```go\npackage main\n\nfunc main() {\n \tprintln()\n}\n```\nafter\n",
+		},
+		"Trailing blank lines end the block": {
+			lines:    []string{"code:sh", " ls", "", "  ", "after"},
+			expected: "```sh\nls\n```\nafter\n",
+		},
+		"Indented block": {
+			lines:    []string{" code:py", "  def f():", "", "      return 1", " item"},
+			expected: "```py\ndef f():\n\n    return 1\n```\n- item\n",
+		},
+		"Hashtags in code": {
+			lines:    []string{"code:c", " #include <stdio.h>"},
+			expected: "```c\n#include <stdio.h>\n```\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			page := &models.Page{Title: "Page", Lines: []models.Line{{Text: "Page"}}}
+			for _, line := range tt.lines {
+				page.Lines = append(page.Lines, models.Line{Text: line})
+			}
+			if result := New().ConvertToMarkdown(page); result != "# Page\n\n"+tt.expected {
+				t.Errorf("ConvertToMarkdown() = %q, want %q", result, "# Page\n\n"+tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmbeds(t *testing.T) {
+	tests := map[string]struct {
+		line     string
+		expected *models.Embed
+	}{
+		"YouTube":         {line: "https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=10", expected: &models.Embed{Provider: models.EmbedYouTube, ID: "dQw4w9WgXcQ"}},
+		"YouTube short":   {line: "[https://youtu.be/dQw4w9WgXcQ]", expected: &models.Embed{Provider: models.EmbedYouTube, ID: "dQw4w9WgXcQ"}},
+		"Vimeo":           {line: "https://vimeo.com/76979871", expected: &models.Embed{Provider: models.EmbedVimeo, ID: "76979871"}},
+		"Tweet":           {line: "https://x.com/golang/status/1234567890", expected: &models.Embed{Provider: models.EmbedTwitter, ID: "1234567890"}},
+		"YouTube channel": {line: "https://www.youtube.com/@golang"},
+		"Twitter profile": {line: "https://twitter.com/golang"},
+		"Link with text":  {line: "watch https://youtu.be/dQw4w9WgXcQ"},
+		"Indented":        {line: " https://youtu.be/dQw4w9WgXcQ"},
+	}
+
+	p := New(WithEmbeds())
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			block, _ := p.parseLine(tt.line, nil)
+			if tt.expected == nil {
+				if block.Type == models.BlockEmbed {
+					t.Errorf("Expected no embed, got %+v", block.Embed)
+				}
+				return
+			}
+			if block.Type != models.BlockEmbed {
+				t.Fatalf("Expected an embed block, got %s", block.Type)
+			}
+			if block.Embed.Provider != tt.expected.Provider || block.Embed.ID != tt.expected.ID {
+				t.Errorf("Expected embed %+v, got %+v", tt.expected, block.Embed)
+			}
+		})
+	}
+
+	if block, _ := New().parseLine("https://youtu.be/dQw4w9WgXcQ", nil); block.Type == models.BlockEmbed {
+		t.Error("Expected no embeds without WithEmbeds")
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	tests := map[string]struct {
+		line     string
+		expected *models.Attachment
+	}{
+		"Bare audio":    {line: "https://example.com/talk.MP3", expected: &models.Attachment{Kind: models.AttachmentAudio, URL: "https://example.com/talk.MP3", Name: "talk.MP3"}},
+		"Bracketed PDF": {line: "[https://example.com/docs/slides%20v2.pdf?dl=1]", expected: &models.Attachment{Kind: models.AttachmentPDF, URL: "https://example.com/docs/slides%20v2.pdf?dl=1", Name: "slides v2.pdf"}},
+		"Labeled file":  {line: "[Budget https://example.com/budget.xlsx]", expected: &models.Attachment{Kind: models.AttachmentFile, URL: "https://example.com/budget.xlsx", Name: "Budget"}},
+		"Custom rule":   {line: "https://example.com/song.wav", expected: &models.Attachment{Kind: models.AttachmentBookmark, URL: "https://example.com/song.wav", Name: "song.wav"}},
+		"Unknown type":  {line: "https://example.com/notes.txt"},
+		"Link in text":  {line: "listen to https://example.com/talk.mp3"},
+		"Indented":      {line: " https://example.com/talk.mp3"},
+	}
+
+	p := New(WithAttachmentRules(DefaultAttachmentRules...), WithAttachmentRules(AttachmentRule{Extension: "wav", Kind: models.AttachmentBookmark}))
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			block, _ := p.parseLine(tt.line, nil)
+			if tt.expected == nil {
+				if block.Type == models.BlockAttachment {
+					t.Errorf("Expected no attachment, got %+v", block.Attachment)
+				}
+				return
+			}
+			if block.Type != models.BlockAttachment {
+				t.Fatalf("Expected an attachment block, got %s", block.Type)
+			}
+			if *block.Attachment != *tt.expected {
+				t.Errorf("Expected attachment %+v, got %+v", tt.expected, block.Attachment)
+			}
+		})
+	}
+
+	if block, _ := New().parseLine("https://example.com/talk.mp3", nil); block.Type == models.BlockAttachment {
+		t.Error("Expected no attachments without WithAttachmentRules")
+	}
+
+	for spec, valid := range map[string]bool{"mp3=audio": true, ".KEY=file": true, "mp3": false, "=audio": false, "mp3=sound": false} {
+		if _, err := ParseAttachmentRule(spec); (err == nil) != valid {
+			t.Errorf("ParseAttachmentRule(%q) error = %v, want valid %v", spec, err, valid)
+		}
+	}
+}
+
+func TestMetadataFooter(t *testing.T) {
+	export := `{"name": "my-project", "exported": 1700000000, "pages": [{"title": "Go tips", "lines": ["Go tips", "text"]}]}`
+	p := New(WithMetadataFooter("v1.2.3"))
+	if err := p.Parse(strings.NewReader(export)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	doc := p.ParseDocument(&p.GetPages()[0])
+	expected := &models.Footer{Project: "my-project", URL: "https://scrapbox.io/my-project/Go_tips", Exported: 1700000000, Version: "v1.2.3"}
+	if !reflect.DeepEqual(doc.Footer, expected) {
+		t.Errorf("ParseDocument() footer = %+v, want %+v", doc.Footer, expected)
+	}
+	if text := models.PlainText(doc.Footer.Inline()); text != "Source: my-project · Exported: 2023-11-14 22:13 UTC · Migrated with scrapbox2notion v1.2.3" {
+		t.Errorf("Footer text = %q", text)
+	}
+
+	// The footer changes with every export without the page changing
+	plain := New()
+	if err := plain.Parse(strings.NewReader(export)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if other := plain.ParseDocument(&plain.GetPages()[0]); other.Footer != nil || other.Hash() != doc.Hash() {
+		t.Errorf("Expected no footer and the same hash without WithMetadataFooter, got %+v", other.Footer)
+	}
+
+	sourced := New(WithSourceURLs())
+	if err := sourced.Parse(strings.NewReader(export)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if other := sourced.ParseDocument(&sourced.GetPages()[0]); other.SourceURL != "https://scrapbox.io/my-project/Go_tips" {
+		t.Errorf("Expected the Scrapbox URL of the page with WithSourceURLs, got %q", other.SourceURL)
+	}
+}
+
+func TestProjectLinks(t *testing.T) {
+	export := `{"name": "export-name", "pages": [{"title": "Go tips", "lines": ["Go tips"]}]}`
+	p := New(WithProject("my-project"), WithSourceURLs())
+	if err := p.Parse(strings.NewReader(export)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if project := p.Project(); project != "my-project" {
+		t.Errorf("Project() = %q, want the project of WithProject", project)
+	}
+	if doc := p.ParseDocument(&p.GetPages()[0]); doc.SourceURL != "https://scrapbox.io/my-project/Go_tips" {
+		t.Errorf("Expected the source URL in the project of WithProject, got %q", doc.SourceURL)
+	}
+
+	tests := map[string]models.Inline{
+		"[/my-project/Go tips]":  {Type: models.InlinePageLink, Text: "Go tips", URL: "go_tips"},
+		"[/My-Project/Missing]":  {Type: models.InlinePageLink, Text: "Missing"},
+		"[/other/Some page]":     {Type: models.InlineLink, Text: "/other/Some page", URL: "https://scrapbox.io/other/Some_page"},
+		"[/other]":               {Type: models.InlineLink, Text: "/other", URL: "https://scrapbox.io/other"},
+		"[/not a project/title]": {Type: models.InlinePageLink, Text: "/not a project/title"},
+	}
+	for line, expected := range tests {
+		if result := p.parseInline(line, nil); len(result) != 1 || !reflect.DeepEqual(result[0], expected) {
+			t.Errorf("parseInline(%q) = %+v, want %+v", line, result, expected)
+		}
+	}
+}
+
+func TestEmojiShortcodes(t *testing.T) {
+	p := New(WithEmojiShortcodes(DefaultEmojiShortcodes), WithEmojiShortcodes(map[string]string{"smile": "🙂", "shipit": "🐿️"}))
+	tests := map[string]string{
+		"Nice :+1: :shipit:":        "Nice 👍 🐿️",
+		"at 10:30:smile:":           "at 10:30🙂",
+		":unknown: and 😀 stay":      ":unknown: and 😀 stay",
+		"[* :fire:] `:fire:` :fire": "🔥 :fire: :fire",
+	}
+	for line, expected := range tests {
+		if result := models.PlainText(p.parseInline(line, nil)); result != expected {
+			t.Errorf("parseInline(%q) = %q, want %q", line, result, expected)
+		}
+	}
+
+	if result := models.PlainText(New().parseInline(":smile:", nil)); result != ":smile:" {
+		t.Errorf("Expected shortcodes kept without WithEmojiShortcodes, got %q", result)
+	}
+
+	path := filepath.Join(t.TempDir(), "emoji.csv")
+	if err := os.WriteFile(path, []byte("shortcode,emoji\n:party:,🥳\nok,🆗\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	shortcodes, err := LoadEmojiShortcodes(path)
+	if err != nil {
+		t.Fatalf("LoadEmojiShortcodes() error = %v", err)
+	}
+	if !maps.Equal(shortcodes, map[string]string{"party": "🥳", "ok": "🆗"}) {
+		t.Errorf("LoadEmojiShortcodes() = %v", shortcodes)
+	}
+}
+
+func TestAnchorLinks(t *testing.T) {
+	page := &models.Page{
+		Title: "Page",
+		Lines: []models.Line{
+			{Text: "Page"},
+			{Text: "See [#getting started] and [* [#Usage]], not [#Missing]"},
+			{Text: "[** Getting Started]"},
+			{Text: "[*** Usage]"},
+		},
+	}
+
+	doc := New().ParseDocument(page)
+	expected := []models.Inline{
+		{Type: models.InlineText, Text: "See "},
+		{Type: models.InlineAnchor, Text: "getting started", URL: "Getting Started"},
+		{Type: models.InlineText, Text: " and "},
+		{Type: models.InlineBold, Children: []models.Inline{{Type: models.InlineAnchor, Text: "Usage", URL: "Usage"}}},
+		{Type: models.InlineText, Text: ", not "},
+		{Type: models.InlinePageLink, Text: "#Missing"},
+	}
+	if !reflect.DeepEqual(doc.Blocks[0].Inline, expected) {
+		t.Errorf("Expected inlines %+v, got %+v", expected, doc.Blocks[0].Inline)
+	}
+}
+
+func TestDocumentWarnings(t *testing.T) {
+	page := &models.Page{
+		Title: "Page",
+		Lines: []models.Line{
+			{Text: "Page"},
+			{Text: "[* broken"},
+			{Text: "`[literal` and [* ]"},
+			{Text: "a ` b"},
+			{Text: "code:empty"},
+			{Text: "code:sh"},
+			{Text: " echo [unbalanced"},
+		},
+	}
+
+	expected := []models.Warning{
+		{Line: 2, Text: "[* broken", Reason: "unbalanced brackets, kept as text"},
+		{Line: 3, Text: "`[literal` and [* ]", Reason: "empty bracket, kept as text"},
+		{Line: 4, Text: "a ` b", Reason: "unclosed code span, kept as text"},
+		{Line: 5, Text: "code:empty", Reason: "empty code block"},
+	}
+
+	doc := New().ParseDocument(page)
+	if len(doc.Warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %+v", len(expected), doc.Warnings)
+	}
+	for i, warning := range doc.Warnings {
+		if warning != expected[i] {
+			t.Errorf("Expected warning %+v, got %+v", expected[i], warning)
+		}
+	}
+}
+
+func TestDocumentAuthors(t *testing.T) {
+	page := &models.Page{
+		Title: "Page",
+		Lines: []models.Line{
+			{Text: "Page", UserID: "alice"},
+			{Text: "first", UserID: "bob"},
+			{Text: "second", UserID: "alice"},
+			{Text: "imported"},
+		},
+	}
+
+	doc := New().ParseDocument(page)
+	if len(doc.Authors) != 2 || doc.Authors[0] != "alice" || doc.Authors[1] != "bob" {
+		t.Errorf("Expected authors [alice bob], got %v", doc.Authors)
+	}
+}
+
+func TestLineHistory(t *testing.T) {
+	page := &models.Page{
+		Title: "Page",
+		Lines: []models.Line{
+			{Text: "Page", UserID: "alice", Updated: 1},
+			{Text: "first", UserID: "bob", Updated: 2},
+			{Text: "code:a.go", UserID: "alice", Updated: 3},
+			{Text: " fmt.Println()", UserID: "carol", Updated: 5},
+			{Text: " return", UserID: "bob", Updated: 4},
+		},
+	}
+
+	if doc := New().ParseDocument(page); doc.History != "" || doc.Blocks[0].History != nil {
+		t.Errorf("Expected no history by default")
+	}
+
+	doc := New(WithLineHistory(models.HistoryFootnote)).ParseDocument(page)
+	if doc.History != models.HistoryFootnote {
+		t.Errorf("Expected the history style on the document, got %q", doc.History)
+	}
+	if h := doc.Blocks[0].History; h == nil || h.Author != "bob" || h.Updated != 2 {
+		t.Errorf("Expected the history of the line, got %+v", h)
+	}
+	if h := doc.Blocks[1].History; h == nil || h.Author != "carol" || h.Updated != 5 {
+		t.Errorf("Expected the code block to have its latest edit, got %+v", h)
+	}
+
+	if _, err := ParseHistoryMode("inline"); err == nil {
+		t.Errorf("Expected an error for an unknown history mode")
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	page := &models.Page{
+		Title: "Page",
+		Lines: []models.Line{{Text: "Page"}, {Text: "[** One]"}, {Text: "text"}, {Text: "[*** Two]"}},
+	}
+
+	tests := map[string]struct {
+		opts     []Option
+		expected bool
+	}{
+		"Disabled":         {expected: false},
+		"Enough headings":  {opts: []Option{WithTableOfContents(2)}, expected: true},
+		"Too few headings": {opts: []Option{WithTableOfContents(3)}, expected: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if doc := New(tt.opts...).ParseDocument(page); doc.TableOfContents != tt.expected {
+				t.Errorf("Expected TableOfContents %v, got %v", tt.expected, doc.TableOfContents)
+			}
+		})
+	}
+}
+
+func TestPageMetadata(t *testing.T) {
+	export := `{"pages": [{
+		"title": "Page",
+		"image": "https://gyazo.com/abc/raw",
+		"descriptions": ["first line", "second line"],
+		"pin": 1681398816,
+		"persistent": true,
+		"linked": 3,
+		"lines": [{"text": "Page"}, {"text": "first line"}]
+	}]}`
+
+	p := New()
+	if err := p.Parse(strings.NewReader(export)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	page := p.GetPages()[0]
+	if page.Pin != 1681398816 || !page.Persistent {
+		t.Errorf("Expected the metadata of the page, got %+v", page)
+	}
+
+	doc := p.ParseDocument(&page)
+	if doc.Image != "https://gyazo.com/abc/raw" || doc.Summary != "first line second line" || doc.Backlinks != 3 {
+		t.Errorf("Expected the image, summary and linked count on the document, got %q %q %d", doc.Image, doc.Summary, doc.Backlinks)
+	}
+}
+
+func TestLinksLcShapes(t *testing.T) {
+	export := `{"pages": [
+		{"title": "Strings", "lines": [{"text": "Strings"}, {"text": "[Go Lang]"}], "linksLc": ["go_lang"]},
+		{"title": "Objects", "lines": [{"text": "Objects"}, {"text": "[Go Lang] [Other Page]"}],
+			"linksLc": [{"titleLc": "go_lang", "title": "Go Lang"}, {"title": "Other Page"}]}
+	]}`
+
+	p := New()
+	if err := p.Parse(strings.NewReader(export)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	pages := p.GetPages()
+	if !slices.Equal(pages[0].LinksLc, []string{"go_lang"}) || !slices.Equal(pages[1].LinksLc, []string{"go_lang", "other_page"}) {
+		t.Errorf("Expected the links of both shapes as link IDs, got %q and %q", pages[0].LinksLc, pages[1].LinksLc)
+	}
+
+	if err := p.Parse(strings.NewReader(`{"pages": [{"title": "Bad", "linksLc": [1]}]}`)); err == nil {
+		t.Error("Expected an error for a linksLc entry that is neither a string nor an object")
+	}
+}
+
+func TestDetectSchema(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected SchemaVersion
+		err      string
+	}{
+		"With metadata":    {input: `{"name": "p", "pages": [{"title": "A", "lines": [{"text": "A"}]}]}`, expected: SchemaMetadata},
+		"Without metadata": {input: `{"name": "p", "pages": [{"title": "A", "lines": ["A", "text"]}]}`, expected: SchemaPlain},
+		"List":             {input: `[{"title": "A"}]`, err: "found a list of 1 items"},
+		"No pages":         {input: `{"data": [], "meta": {}}`, err: "missing name and pages, found an object with keys data, meta"},
+		"Untitled page":    {input: `{"name": "p", "pages": [{"text": "A"}]}`, err: "page #1 is not a page with a title"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			version, err := DetectSchema([]byte(tt.input))
+			if tt.err != "" {
+				if !errors.Is(err, ErrNotScrapboxExport) || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil || version != tt.expected {
+				t.Errorf("DetectSchema() = %q, %v, want %q", version, err, tt.expected)
+			}
+		})
+	}
+
+	input := `{"name": "p", "pages": [{"title": "A", "lines": ["A", "text"]}], "extra": true}`
+	p := New()
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if lines := p.GetPages()[0].Lines; len(lines) != 2 || lines[1].Text != "text" {
+		t.Errorf("Expected the lines of an export without metadata, got %+v", lines)
+	}
+
+	if err := New().Parse(strings.NewReader(`{"data": [{"title": "A"}]}`)); !errors.Is(err, ErrNotScrapboxExport) {
+		t.Errorf("Expected input without pages to be rejected, got %v", err)
+	}
+	if err := New(WithForce()).Parse(strings.NewReader(`{"data": [{"title": "A"}]}`)); err != nil {
+		t.Errorf("Expected input without pages to be accepted with force, got %v", err)
+	}
+}
+
+func TestDocumentMetrics(t *testing.T) {
+	p := New()
+	err := p.Parse(strings.NewReader(`{"pages": [
+		{"title": "Go Tips", "views": 42, "linksLc": ["go_tips"]},
+		{"title": "A", "linksLc": ["go_tips", "go_tips"]},
+		{"title": "B", "linksLc": ["Go_Tips", "a"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	pages := p.GetPages()
+	doc := p.ParseDocument(&pages[0])
+	if doc.Views != 42 {
+		t.Errorf("Expected 42 views, got %d", doc.Views)
+	}
+	if doc.Backlinks != 2 {
+		t.Errorf("Expected 2 backlinks, got %d", doc.Backlinks)
+	}
+	if doc := p.ParseDocument(&pages[1]); doc.Backlinks != 1 {
+		t.Errorf("Expected 1 backlink, got %d", doc.Backlinks)
+	}
+}
+
+func TestDocumentHash(t *testing.T) {
+	p := New()
+	page := models.Page{Title: "A", Updated: 1, Views: 1, Lines: []models.Line{{Text: "A"}, {Text: "body"}}}
+	first := p.ParseDocument(&page).Hash()
+
+	page.Updated, page.Views = 2, 5
+	if touched := p.ParseDocument(&page).Hash(); touched != first {
+		t.Errorf("Expected the same hash when only the update time and views change")
+	}
+	page.Lines[1].Text = "edited body"
+	if edited := p.ParseDocument(&page).Hash(); edited == first {
+		t.Errorf("Expected a different hash when the content changes")
+	}
+}
+
+func TestCalloutRules(t *testing.T) {
+	rule, err := ParseCalloutRule("Q:=🙋,purple_background")
+	if err != nil {
+		t.Fatalf("ParseCalloutRule() error = %v", err)
+	}
+	p := New(WithCalloutRules(rule), WithCalloutRules(DefaultCalloutRules...))
+
+	tests := map[string]struct {
+		line     string
+		expected models.Block
+	}{
+		"Custom rule":    {line: "Q: why?", expected: models.Block{Type: models.BlockCallout, Icon: "🙋", Color: "purple_background"}},
+		"Default rule":   {line: "WARNING: careful", expected: models.Block{Type: models.BlockCallout, Icon: "⚠️", Color: "yellow_background"}},
+		"Icon marker":    {line: "⚠️careful", expected: models.Block{Type: models.BlockCallout, Icon: "⚠️", Color: "yellow_background"}},
+		"Marker only":    {line: "NOTE:", expected: models.Block{Type: models.BlockParagraph}},
+		"Marker in text": {line: "see NOTE: below", expected: models.Block{Type: models.BlockParagraph}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			block, _ := p.parseLine(tt.line, nil)
+			if block.Type != tt.expected.Type || block.Icon != tt.expected.Icon || block.Color != tt.expected.Color {
+				t.Errorf("Expected %+v, got %+v", tt.expected, block)
+			}
+		})
+	}
+
+	for _, spec := range []string{"NOTE:", "=💡", "NOTE:=", "NOTE:=💡,neon"} {
+		if _, err := ParseCalloutRule(spec); err == nil {
+			t.Errorf("Expected error for callout rule %q, got nil", spec)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	content := `{"name": "test", "pages": [{"title": "From Reader", "lines": [{"text": "From Reader"}, {"text": "#tag1"}]}]}`
+
+	p := New()
+	if err := p.Parse(strings.NewReader(content)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	pages := p.GetPages()
+	if len(pages) != 1 || pages[0].Title != "From Reader" {
+		t.Fatalf("Expected page 'From Reader', got %v", pages)
+	}
+	if len(pages[0].Tags) != 1 || pages[0].Tags[0] != "tag1" {
+		t.Errorf("Expected tags [tag1], got %v", pages[0].Tags)
+	}
+
+	if err := p.Parse(strings.NewReader("not json")); err == nil {
+		t.Error("Expected error for invalid JSON, got nil")
+	}
+}
+
+func TestParseMultipleInputs(t *testing.T) {
+	first := `{"name": "first", "pages": [
+		{"title": "Shared", "updated": 100, "lines": [{"text": "Shared"}, {"text": "old"}]},
+		{"title": "Only First", "updated": 100, "lines": [{"text": "Only First"}]}
+	]}`
+	second := `{"name": "second", "pages": [
+		{"title": "Shared", "updated": 200, "lines": [{"text": "Shared"}, {"text": "new"}]},
+		{"title": "Only Second", "updated": 100, "lines": [{"text": "Only Second"}]}
+	]}`
+
+	tests := map[DuplicatePolicy]struct {
+		expectedTitles []string
+		expectedShared string
+	}{
+		DuplicateKeepNewest: {
+			expectedTitles: []string{"Shared", "Only First", "Only Second"},
+			expectedShared: "new",
+		},
+		DuplicateKeepFirst: {
+			expectedTitles: []string{"Shared", "Only First", "Only Second"},
+			expectedShared: "old",
+		},
+		DuplicateRename: {
+			expectedTitles: []string{"Shared", "Only First", "Shared (2)", "Only Second"},
+			expectedShared: "old",
+		},
+	}
+
+	for policy, tt := range tests {
+		t.Run(string(policy), func(t *testing.T) {
+			p := New(WithDuplicatePolicy(policy))
+			for _, content := range []string{first, second} {
+				if err := p.Parse(strings.NewReader(content)); err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+			}
+
+			pages := p.GetPages()
+			if len(pages) != len(tt.expectedTitles) {
+				t.Fatalf("Expected %d pages, got %d", len(tt.expectedTitles), len(pages))
+			}
+			for i, title := range tt.expectedTitles {
+				if pages[i].Title != title {
+					t.Errorf("Expected page %d to be '%s', got '%s'", i, title, pages[i].Title)
+				}
+			}
+			if pages[0].Lines[1].Text != tt.expectedShared {
+				t.Errorf("Expected shared page content '%s', got '%s'", tt.expectedShared, pages[0].Lines[1].Text)
+			}
+		})
+	}
+}
+
+func TestDuplicateRenameTitleLine(t *testing.T) {
+	p := New(WithDuplicatePolicy(DuplicateRename))
+	for _, content := range []string{
+		`{"pages": [{"title": "Shared", "lines": [{"text": "Shared"}, {"text": "old"}]}]}`,
+		`{"pages": [{"title": "Shared", "lines": [{"text": "Shared"}, {"text": "new"}]}]}`,
+	} {
+		if err := p.Parse(strings.NewReader(content)); err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+	}
+
+	pages := p.GetPages()
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(pages))
+	}
+	if pages[1].Lines[0].Text != "Shared (2)" {
+		t.Errorf("Expected the title line renamed, got '%s'", pages[1].Lines[0].Text)
+	}
+	doc := p.ParseDocument(&pages[1])
+	if doc.Title != "Shared (2)" {
+		t.Errorf("Expected title 'Shared (2)', got '%s'", doc.Title)
+	}
+	if len(doc.Blocks) != 1 || len(doc.Blocks[0].Inline) != 1 || doc.Blocks[0].Inline[0].Text != "new" {
+		t.Errorf("Expected only the body without the old title, got %+v", doc.Blocks)
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, policy := range []string{"newest", "first", "rename"} {
+		if _, err := ParseDuplicatePolicy(policy); err != nil {
+			t.Errorf("Unexpected error for %s: %v", policy, err)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("merge"); err == nil {
+		t.Error("Expected error for invalid policy, got nil")
+	}
+}
+
 func TestConvertToMarkdown(t *testing.T) {
 	// Load test cases from testfiles/output directory
 	testCases := []struct {
@@ -267,6 +976,107 @@ func TestConvertLineToMarkdown(t *testing.T) {
 			line:     "[* Bold text]",
 			expected: "**Bold text**",
 		},
+		{
+			name:     "Strong image",
+			line:     "[[https://gyazo.com/0123abcd]]",
+			expected: "![image](https://gyazo.com/0123abcd/raw)",
+		},
+		{
+			name:     "Strong text",
+			line:     "[[Strong]] text",
+			expected: "**Strong** text",
+		},
+		{
+			name:     "Image linking to a URL",
+			line:     "[https://example.com https://gyazo.com/a.png]",
+			expected: "[![image](https://gyazo.com/a.png)](https://example.com)",
+		},
+		{
+			name:     "Image before the URL it links to",
+			line:     "[https://i.gyazo.com/a.jpg https://example.com]",
+			expected: "[![image](https://i.gyazo.com/a.jpg)](https://example.com)",
+		},
+		{
+			name:     "URL in a line",
+			line:     "see https://example.com/a_(b), and more",
+			expected: "see [https://example.com/a_(b)](https://example.com/a_(b)), and more",
+		},
+		{
+			name:     "URL after Japanese text",
+			line:     "参照https://example.com。",
+			expected: "参照[https://example.com](https://example.com)。",
+		},
+		{
+			name:     "URL inside a word",
+			line:     "xhttps://example.com",
+			expected: "xhttps://example.com",
+		},
+		{
+			name:     "Image with a query string",
+			line:     "[https://example.com/a.PNG?raw=true]",
+			expected: "![image](https://example.com/a.PNG?raw=true)",
+		},
+		{
+			name:     "Image host without an extension",
+			line:     "[https://pbs.twimg.com/media/abc?format=jpg]",
+			expected: "![image](https://pbs.twimg.com/media/abc?format=jpg)",
+		},
+		{
+			name:     "Quote",
+			line:     "> [* quoted] text",
+			expected: "> **quoted** text",
+		},
+		{
+			name:     "Indented quote",
+			line:     "  >quoted",
+			expected: "> quoted",
+		},
+		{
+			name:     "Quote bracket",
+			line:     `["To be, or not to be"]`,
+			expected: "> To be, or not to be",
+		},
+		{
+			name:     "Quote bracket without closing mark",
+			line:     `[" quoted [link]]`,
+			expected: "> quoted [link]",
+		},
+		{
+			name:     "Helpfeel question",
+			line:     "? How do I [deploy]",
+			links:    []string{"deploy"},
+			expected: "> ❓ **How do I [deploy](./deploy.md)**",
+		},
+		{
+			name:     "Command line",
+			line:     "$ go test ./... [not a link]",
+			expected: "`$ go test ./... [not a link]`",
+		},
+		{
+			name:     "Indented command line",
+			line:     " % make",
+			expected: "- `% make`",
+		},
+		{
+			name:     "Dollar without a space",
+			line:     "$100",
+			expected: "$100",
+		},
+		{
+			name:     "Inline code mid-line",
+			line:     "Run `go test ./...` before [* `git push`]",
+			expected: "Run `go test ./...` before **`git push`**",
+		},
+		{
+			name:     "Inline code with brackets",
+			line:     "[* `a[0]` and `]`] `[not a link]`",
+			expected: "**`a[0]` and `]`** `[not a link]`",
+		},
+		{
+			name:     "Unclosed backtick",
+			line:     "a ` b",
+			expected: "a ` b",
+		},
 		{
 			name:     "Italic text",
 			line:     "[/ Italic text]",
@@ -287,6 +1097,106 @@ func TestConvertLineToMarkdown(t *testing.T) {
 			line:     "[**** h2 text]",
 			expected: "## h2 text",
 		},
+		{
+			name:     "h1 text",
+			line:     "[***** h1 text]",
+			expected: "# h1 text",
+		},
+		{
+			name:     "Unknown asterisk count",
+			line:     "[******* huge text]",
+			expected: "# huge text",
+		},
+		{
+			name:     "Heading without space",
+			line:     "[**no space]",
+			expected: "[**no space]",
+		},
+		{
+			name:     "Heading without text",
+			line:     "[** ]",
+			expected: "[** ]",
+		},
+		{
+			name:     "Unclosed heading",
+			line:     "[** unclosed",
+			expected: "[** unclosed",
+		},
+		{
+			name:     "Asterisks only",
+			line:     "[**",
+			expected: "[**",
+		},
+		{
+			name:     "Emphasis followed by text",
+			line:     "[*** strong] and more",
+			expected: "**strong** and more",
+		},
+		{
+			name:     "Standalone math",
+			line:     "[$ \\\\frac{a}{b}]",
+			expected: "$$\\frac{a}{b}$$",
+		},
+		{
+			name:     "Inline math",
+			line:     "Area: [$ \\pi r^2] cm",
+			expected: "Area: $\\pi r^2$ cm",
+		},
+		{
+			name:     "Indented math",
+			line:     " [$ x^2]",
+			expected: "- $x^2$",
+		},
+		{
+			name:     "Bracket divider",
+			line:     "[----]",
+			expected: "---",
+		},
+		{
+			name:     "Dash divider",
+			line:     "-----",
+			expected: "---",
+		},
+		{
+			name:     "Two dashes",
+			line:     "--",
+			expected: "--",
+		},
+		{
+			name:     "Numbered item",
+			line:     "1. First item",
+			expected: "1. First item",
+		},
+		{
+			name:     "Indented numbered item",
+			line:     " 2. Second item",
+			expected: "2. Second item",
+		},
+		{
+			name:     "Nested numbered item",
+			line:     "  10. Nested item",
+			expected: "  10. Nested item",
+		},
+		{
+			name:     "Number without list marker",
+			line:     " 2024.01.01",
+			expected: "- 2024.01.01",
+		},
+		{
+			name:     "Unchecked task",
+			line:     "[ ] Write tests",
+			expected: "- [ ] Write tests",
+		},
+		{
+			name:     "Checked task",
+			line:     " [x] [* Done] task",
+			expected: "- [x] **Done** task",
+		},
+		{
+			name:     "Nested checked task",
+			line:     "  [X] Nested task",
+			expected: "  - [x] Nested task",
+		},
 		{
 			name:     "Page link",
 			line:     "[Test Page]",
@@ -305,3 +1215,323 @@ func TestConvertLineToMarkdown(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertToMarkdownDivider(t *testing.T) {
+	page := &models.Page{
+		Title: "Divider",
+		Lines: []models.Line{
+			{Text: "Divider"},
+			{Text: "above"},
+			{Text: "[----]"},
+			{Text: "below"},
+		},
+	}
+
+	expected := "# Divider\n\nabove\n\n---\nbelow\n"
+	if result := New().ConvertToMarkdown(page); result != expected {
+		t.Errorf("ConvertToMarkdown() = %q, want %q", result, expected)
+	}
+}
+
+func TestInferCodeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"main.go":      "go",
+		"script.py":    "python",
+		"index.JS":     "javascript",
+		"lib.rs":       "rust",
+		"install.sh":   "shell",
+		"python":       "python",
+		" go ":         "go",
+		"notes.xyz":    "notes.xyz",
+		"":             "",
+		"dir/app.ts":   "typescript",
+		"config.yml":   "yaml",
+		"Makefile":     "Makefile",
+		"archive.tar.": "archive.tar.",
+	}
+
+	for name, expected := range tests {
+		t.Run(name, func(t *testing.T) {
+			if result := inferCodeLanguage(name); result != expected {
+				t.Errorf("inferCodeLanguage(%q) = %q, want %q", name, result, expected)
+			}
+		})
+	}
+}
+
+func TestTitleMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "titles.csv")
+	content := "scrapbox_title,notion_title,database\n# Renamed pages\nold name,New Name\nMemo,,Notes\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	mappings, err := LoadTitleMapping(path)
+	if err != nil {
+		t.Fatalf("LoadTitleMapping() error = %v", err)
+	}
+	if len(mappings) != 2 || mappings["Memo"].Database != "Notes" {
+		t.Fatalf("Unexpected mappings %+v", mappings)
+	}
+
+	p := New(WithTitleMapping(mappings))
+	renamed := p.ParseDocument(&models.Page{Title: "Old Name", Tags: []string{"go"}, Lines: []models.Line{{Text: "Old Name"}}})
+	if renamed.Title != "New Name" || len(renamed.Tags) != 1 || renamed.Tags[0] != "go" {
+		t.Errorf("Expected the page renamed keeping its tags, got %q %v", renamed.Title, renamed.Tags)
+	}
+
+	moved := p.ParseDocument(&models.Page{
+		Title:   "Memo",
+		Tags:    []string{"go"},
+		Lines:   []models.Line{{Text: "Memo"}, {Text: "see [old name]"}},
+		LinksLc: []string{"old_name"},
+	})
+	if moved.Title != "Memo" || len(moved.Tags) != 1 || moved.Tags[0] != "Notes" {
+		t.Errorf("Expected the page moved into Notes, got %q %v", moved.Title, moved.Tags)
+	}
+	link := moved.Blocks[0].Inline[1]
+	if link.Type != models.InlinePageLink || link.Text != "New Name" || link.URL != "new_name" {
+		t.Errorf("Expected the link renamed, got %+v", link)
+	}
+
+	os.WriteFile(path, []byte("a,b,c,d\n"), 0644)
+	if _, err := LoadTitleMapping(path); err == nil {
+		t.Errorf("Expected an error for a row with too many fields")
+	}
+}
+
+func TestRenameTitle(t *testing.T) {
+	// A later mapping of the title renames it, keeping the database of the first
+	p := New(
+		WithTitleMapping(map[string]TitleMapping{"Memo": {Title: "Note", Database: "Notes"}}),
+		WithTitleMapping(map[string]TitleMapping{"Memo": {Title: "Edited"}}),
+	)
+	doc := p.ParseDocument(&models.Page{Title: "Memo", Lines: []models.Line{{Text: "Memo"}}})
+	if doc.Title != "Edited" || len(doc.Tags) != 1 || doc.Tags[0] != "Notes" {
+		t.Errorf("Expected the mappings combined, got %q %v", doc.Title, doc.Tags)
+	}
+
+	p.RenameTitle("Memo", "Renamed")
+	linking := p.ParseDocument(&models.Page{Title: "Other", Lines: []models.Line{{Text: "Other"}, {Text: "see [Memo]"}}})
+	if link := linking.Blocks[0].Inline[1]; link.Text != "Renamed" {
+		t.Errorf("Expected links after the rename to follow it, got %+v", link)
+	}
+	if title := p.DocumentTitle("memo"); title != "Renamed" {
+		t.Errorf("DocumentTitle() = %q, want %q", title, "Renamed")
+	}
+	if title := p.DocumentTitle("Other"); title != "Other" {
+		t.Errorf("DocumentTitle() = %q, want %q", title, "Other")
+	}
+}
+
+func TestParseDocumentConcurrently(t *testing.T) {
+	p := New(WithUnlistedLinks())
+	var pages []models.Page
+	for i := range 20 {
+		title := fmt.Sprintf("Page %d", i)
+		pages = append(pages, models.Page{Title: title, LinksLc: []string{"page_0"}, Lines: []models.Line{{Text: title}, {Text: "see [Page 0] and [Page 1]"}}})
+	}
+	p.AddPages(pages...)
+
+	// Backlinks and titles are looked up by the first documents at the same time
+	docs := make([]*models.Document, len(pages))
+	var wg sync.WaitGroup
+	for i := range pages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			docs[i] = p.ParseDocument(&pages[i])
+		}()
+	}
+	wg.Wait()
+
+	if docs[0].Backlinks != 19 {
+		t.Errorf("Expected 19 backlinks, got %d", docs[0].Backlinks)
+	}
+	for _, doc := range docs {
+		if link := doc.Blocks[0].Inline[3]; link.URL != "page_1" {
+			t.Errorf("Expected the unlisted link resolved in %q, got %+v", doc.Title, link)
+		}
+	}
+}
+
+func TestSortPages(t *testing.T) {
+	pages := []models.Page{
+		{Title: "b", Created: 2, Updated: 9, Views: 5, Pin: 9},
+		{Title: "C", Created: 3, Updated: 1, Views: 5},
+		{Title: "a", Created: 1, Updated: 5, Views: 1, Pin: 4},
+	}
+
+	tests := map[PageOrder][]string{
+		OrderNone:    {"b", "C", "a"},
+		OrderCreated: {"a", "b", "C"},
+		OrderUpdated: {"C", "a", "b"},
+		OrderTitle:   {"a", "b", "C"},
+		OrderViews:   {"a", "b", "C"},
+		OrderPinned:  {"C", "a", "b"},
+	}
+	for order, expected := range tests {
+		t.Run(string(order), func(t *testing.T) {
+			sorted := SortPages(pages, order)
+			for i, title := range expected {
+				if sorted[i].Title != title {
+					t.Fatalf("Expected %v, got %+v", expected, sorted)
+				}
+			}
+		})
+	}
+	if pages[0].Title != "b" {
+		t.Errorf("Expected the pages left in their order")
+	}
+
+	if _, err := ParsePageOrder("random"); err == nil {
+		t.Errorf("Expected an error for an unknown order")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]struct {
+		text     string
+		form     UnicodeForm
+		expected string
+	}{
+		"Compose kana":       {text: "がぴ", form: FormNFC, expected: "がぴ"},
+		"Decompose kana":     {text: "がぴ", form: FormNFD, expected: "がぴ"},
+		"Compose accents":    {text: "Café ậ", form: FormNFC, expected: "Café ậ"},
+		"Decompose accents":  {text: "ậ", form: FormNFD, expected: "ậ"},
+		"Compose hangul":     {text: "한", form: FormNFC, expected: "한"},
+		"Decompose hangul":   {text: "한", form: FormNFD, expected: "한"},
+		"Reorder marks":      {text: "a\u0302\u0323", form: FormNFC, expected: "ậ"},
+		"Map ideographs":     {text: "\uf900", form: FormNFC, expected: "\u8c48"},
+		"Keep without form":  {text: "が", form: FormNone, expected: "が"},
+		"Keep composed text": {text: "が", form: FormNFC, expected: "が"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Normalize(tt.text, tt.form); got != tt.expected {
+				t.Errorf("Normalize(%q, %s) = %q, want %q", tt.text, tt.form, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ParseUnicodeForm("nfkc"); err == nil {
+		t.Errorf("Expected an error for an unsupported form")
+	}
+}
+
+func TestNormalizePages(t *testing.T) {
+	decomposed := "データ"
+	page := models.Page{
+		Title: decomposed,
+		Lines: []models.Line{
+			{Text: decomposed},
+			{Text: "　item"},
+			{Text: "　　nested [データ]"},
+			{Text: "text　with a space"},
+		},
+		LinksLc: []string{decomposed},
+	}
+
+	p := New(WithUnicodeForm(FormNFC), WithFullWidthIndent())
+	p.AddPages(page)
+	if page.Lines[1].Text != "　item" {
+		t.Errorf("Expected the added page left as it was")
+	}
+	pages := p.GetPages()
+	if pages[0].Title != "データ" || pages[0].LinksLc[0] != "データ" {
+		t.Errorf("Expected the title and links composed, got %q %v", pages[0].Title, pages[0].LinksLc)
+	}
+
+	doc := p.ParseDocument(&pages[0])
+	if doc.Blocks[0].Indent != 0 || doc.Blocks[1].Indent != 1 {
+		t.Errorf("Expected full-width spaces to indent, got %+v", doc.Blocks[:2])
+	}
+	if link := doc.Blocks[1].Inline[1]; link.Type != models.InlinePageLink || link.Text != "データ" {
+		t.Errorf("Expected the link composed, got %+v", link)
+	}
+	if doc.Blocks[2].Inline[0].Text != "text　with a space" {
+		t.Errorf("Expected full-width spaces inside lines kept, got %q", doc.Blocks[2].Inline[0].Text)
+	}
+}
+
+func TestPageLinkResolution(t *testing.T) {
+	ids := map[string]string{
+		"Go Lang":  "go_lang",
+		"İstanbul": "i̇stanbul",
+		"ΟΔΟΣ Σ":   "οδος_σ",
+		"日本語 メモ":   "日本語_メモ",
+	}
+	for title, expected := range ids {
+		if got := linkID(title); got != expected {
+			t.Errorf("linkID(%q) = %q, want %q", title, got, expected)
+		}
+	}
+
+	pages := []models.Page{
+		{Title: "Page", Lines: []models.Line{{Text: "Page"}, {Text: "[Go Lang] [Other Page] [Missing]"}}, LinksLc: []string{"go lang"}},
+		{Title: "Other page", Lines: []models.Line{{Text: "Other page"}}},
+	}
+	tests := map[string]struct {
+		opts     []Option
+		expected []string
+	}{
+		"Listed links only": {expected: []string{"go lang", "", ""}},
+		"Unlisted links":    {opts: []Option{WithUnlistedLinks()}, expected: []string{"go lang", "other_page", ""}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := New(tt.opts...)
+			p.AddPages(pages...)
+			doc := p.ParseDocument(&p.GetPages()[0])
+			var urls []string
+			for _, inline := range doc.Blocks[0].Inline {
+				if inline.Type == models.InlinePageLink {
+					urls = append(urls, inline.URL)
+				}
+			}
+			if !slices.Equal(urls, tt.expected) {
+				t.Errorf("Expected link URLs %q, got %q", tt.expected, urls)
+			}
+		})
+	}
+}
+
+func TestImageProbe(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/image" {
+			w.Header().Set("Content-Type", "image/png")
+		}
+	}))
+	defer server.Close()
+
+	p := New(WithImageProbe(ContentTypeProbe(server.Client())))
+	if got := p.convertLineToMarkdown("["+server.URL+"/image]", nil); got != "![image]("+server.URL+"/image)" {
+		t.Errorf("Expected the probed URL as an image, got %q", got)
+	}
+	if got := p.convertLineToMarkdown("["+server.URL+"/page]", nil); got != "["+server.URL+"/page]("+server.URL+"/page)" {
+		t.Errorf("Expected a link to the page, got %q", got)
+	}
+	p.convertLineToMarkdown("["+server.URL+"/image]", nil)
+	if requests != 2 {
+		t.Errorf("Expected each URL requested once, got %d requests", requests)
+	}
+}
+
+func TestCountTags(t *testing.T) {
+	docs := []*models.Document{
+		{Title: "A", Tags: []string{"go", "tips"}},
+		{Title: "B", Tags: []string{"go", "tips", "go"}},
+		{Title: "C", Tags: []string{"go", "memo"}},
+		{Title: "D", Tags: []string{"memo"}},
+		{Title: "E"},
+	}
+	expected := []models.TagStat{
+		{Tag: "go", Pages: 3, CoTags: []models.TagCount{{Tag: "tips", Pages: 2}, {Tag: "memo", Pages: 1}}},
+		{Tag: "memo", Pages: 2, CoTags: []models.TagCount{{Tag: "go", Pages: 1}}},
+		{Tag: "tips", Pages: 2, CoTags: []models.TagCount{{Tag: "go", Pages: 2}}},
+	}
+	if stats := models.CountTags(docs); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("CountTags() = %+v, want %+v", stats, expected)
+	}
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// SchemaVersion is the shape of a Scrapbox export, which depends on how it was exported
+type SchemaVersion string
+
+const (
+	// SchemaMetadata is an export with metadata, whose lines are objects
+	// holding their author and edit times
+	SchemaMetadata SchemaVersion = "metadata"
+	// SchemaPlain is an export without metadata, whose lines are strings
+	SchemaPlain SchemaVersion = "plain"
+)
+
+// ErrNotScrapboxExport is returned for input that doesn't look like a Scrapbox export
+var ErrNotScrapboxExport = errors.New("this doesn't look like a Scrapbox export")
+
+// WithForce converts input that doesn't look like a Scrapbox export anyway,
+// as far as it can be decoded
+func WithForce() Option {
+	return func(p *Parser) {
+		p.force = true
+	}
+}
+
+// DetectSchema returns the schema version of the export. It returns an error
+// wrapping ErrNotScrapboxExport, describing the structure found, when the data
+// lacks the pages of an export.
+func DetectSchema(data []byte) (SchemaVersion, error) {
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	object, ok := root.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("%w: expected an object with name and pages, found %s", ErrNotScrapboxExport, describeJSON(root))
+	}
+
+	var missing []string
+	for _, key := range []string{"name", "pages"} {
+		if _, ok := object[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	pages, ok := object["pages"].([]interface{})
+	if !ok {
+		return "", fmt.Errorf("%w: missing %s, found %s", ErrNotScrapboxExport, strings.Join(missing, " and "), describeJSON(root))
+	}
+
+	version := SchemaMetadata
+	for i, page := range pages {
+		fields, ok := page.(map[string]interface{})
+		if _, hasTitle := fields["title"]; !ok || !hasTitle {
+			return "", fmt.Errorf("%w: page #%d is not a page with a title, found %s", ErrNotScrapboxExport, i+1, describeJSON(page))
+		}
+		if lines, ok := fields["lines"].([]interface{}); ok && len(lines) > 0 {
+			if _, ok := lines[0].(string); ok {
+				version = SchemaPlain
+			}
+		}
+	}
+	// Exports have a name, but pages are enough to convert
+	if len(missing) > 0 {
+		logger.Warn("Export has no project name", nil)
+	}
+	return version, nil
+}
+
+// describeJSON describes the shape of a decoded JSON value for errors
+func describeJSON(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "an empty object"
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		if len(keys) > 10 {
+			keys = append(keys[:10], "...")
+		}
+		return "an object with keys " + strings.Join(keys, ", ")
+	case []interface{}:
+		return fmt.Sprintf("a list of %d items", len(v))
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// scrapboxURL is the address of Scrapbox projects
+const scrapboxURL = "https://scrapbox.io/"
+
+// WithProject sets the name of the Scrapbox project of the pages, for exports
+// whose name isn't the name in the URLs of the project and for pages read
+// through the Scrapbox API
+func WithProject(name string) Option {
+	return func(p *Parser) {
+		p.project = name
+	}
+}
+
+// Project returns the name of the Scrapbox project of the pages, set by
+// WithProject or else the name of the export, empty if unknown
+func (p *Parser) Project() string {
+	if p.project != "" || p.export == nil {
+		return p.project
+	}
+	return p.export.Name
+}
+
+// DisplayName returns the display name of the Scrapbox project of the export,
+// empty if unknown
+func (p *Parser) DisplayName() string {
+	if p.export == nil {
+		return ""
+	}
+	return p.export.DisplayName
+}
+
+// WithSourceURLs gives documents the URL of their page on Scrapbox, for pages
+// of an export with a project name
+func WithSourceURLs() Option {
+	return func(p *Parser) {
+		p.sourceURLs = true
+	}
+}
+
+// sourceURL returns the URL of the page on Scrapbox, or an empty string when
+// the project is unknown
+func (p *Parser) sourceURL(page *models.Page) string {
+	if p.Project() == "" {
+		return ""
+	}
+	return pageURL(p.Project(), page.Title)
+}
+
+// pageURL returns the URL of the page with the title on Scrapbox, which writes
+// spaces in titles as underscores
+func pageURL(project, title string) string {
+	return scrapboxURL + url.PathEscape(project) + "/" + url.PathEscape(strings.ReplaceAll(title, " ", "_"))
+}
+
+// projectLink converts a link to a page of a Scrapbox project, [/project/title],
+// or to a project, [/project]. Links to pages of the project of the pages are
+// page links, resolved against the titles of the export, and other links point
+// at Scrapbox.
+func (p *Parser) projectLink(content string) (models.Inline, bool) {
+	project, title, _ := strings.Cut(strings.TrimPrefix(content, "/"), "/")
+	if !strings.HasPrefix(content, "/") || !projectName(project) {
+		return models.Inline{}, false
+	}
+	if title != "" && strings.EqualFold(project, p.Project()) {
+		link := models.Inline{Type: models.InlinePageLink, Text: title}
+		if id := linkID(title); p.hasPage(id) {
+			link.URL = id
+		}
+		return link, true
+	}
+	target := scrapboxURL + url.PathEscape(project)
+	if title != "" {
+		target = pageURL(project, title)
+	}
+	return models.Inline{Type: models.InlineLink, Text: content, URL: target}, true
+}
+
+// projectName reports whether the name is one Scrapbox allows for projects,
+// of letters, digits and hyphens
+func projectName(name string) bool {
+	return name != "" && strings.IndexFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-')
+	}) == -1
+}
@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// tagTerminators end a hashtag in addition to white space
+const tagTerminators = "[]`"
+
+// tagTrailingPunctuation is sentence punctuation trimmed from the end of hashtags
+const tagTrailingPunctuation = ",.;:!?、。，．：；！？"
+
+// extractTags extracts tags from page lines and stores them in the Page struct
+func (p *Parser) extractTags(page *models.Page) {
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	inCode := false
+	codeIndent := 0
+	for _, line := range page.Lines {
+		// Hashtags in code blocks are code, not tags
+		if inCode {
+			if strings.TrimSpace(line.Text) == "" || indentWidth(line.Text) > codeIndent {
+				continue
+			}
+			inCode = false
+		}
+		if strings.HasPrefix(strings.TrimSpace(line.Text), "code:") {
+			inCode = true
+			codeIndent = indentWidth(line.Text)
+			continue
+		}
+
+		for _, tag := range hashtags(line.Text) {
+			add(tag)
+		}
+	}
+
+	if p.bracketTags {
+		for _, tag := range trailingBracketTags(page.Lines) {
+			add(tag)
+		}
+	}
+
+	if len(tags) == 0 && p.defaultTag != "" {
+		tags = []string{p.defaultTag}
+	}
+	page.Tags = tags
+}
+
+// hashtags returns the hashtags in a line. Hashtags inside code spans are ignored.
+func hashtags(line string) []string {
+	var tags []string
+	inCode := false
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '`':
+			inCode = !inCode
+		case !inCode && line[i] == '#':
+			tag, end, ok := scanHashtag(line, i)
+			if ok {
+				tags = append(tags, tag)
+				i = end - 1
+			}
+		}
+	}
+
+	return tags
+}
+
+// scanHashtag scans the hashtag starting with the # at start and returns the tag
+// and the index after it. A hashtag starts with # at the start of the line or after
+// white space and runs until white space or a bracket, so tags may contain CJK
+// characters and punctuation such as #C++ or #日本語.
+func scanHashtag(line string, start int) (string, int, bool) {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(line[:start]); !unicode.IsSpace(r) {
+			return "", start, false
+		}
+	}
+
+	end := start + 1
+	for end < len(line) {
+		r, size := utf8.DecodeRuneInString(line[end:])
+		if unicode.IsSpace(r) || strings.ContainsRune(tagTerminators, r) {
+			break
+		}
+		end += size
+	}
+
+	tag := strings.TrimRight(line[start+1:end], tagTrailingPunctuation)
+	if tag == "" {
+		return "", start, false
+	}
+	return tag, start + 1 + len(tag), true
+}
+
+// isTagLine reports whether a line consists only of hashtags
+func isTagLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	for _, field := range fields {
+		if tags := hashtags(field); len(tags) != 1 || "#"+tags[0] != field {
+			return false
+		}
+	}
+	return true
+}
+
+// trailingBracketTags returns the page links on the last lines of a page that
+// consist only of links and hashtags, the Scrapbox convention for tagging pages
+// with [tag] links at the bottom.
+func trailingBracketTags(lines []models.Line) []string {
+	var tags []string
+	for i := len(lines) - 1; i > 0; i-- {
+		text := strings.TrimSpace(lines[i].Text)
+		if text == "" {
+			continue
+		}
+		links, ok := linkOnlyLine(text)
+		if !ok {
+			break
+		}
+		// Collect lines bottom up but keep the order within the page
+		tags = append(links, tags...)
+	}
+	return tags
+}
+
+// linkOnlyLine returns the page links of a line made only of [page links] and
+// #hashtags, reporting false if the line contains anything else
+func linkOnlyLine(text string) ([]string, bool) {
+	var links []string
+	for _, field := range splitLinkFields(text) {
+		switch {
+		case strings.HasPrefix(field, "#"):
+			// Hashtags are found by hashtags already
+		case strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]"):
+			link := strings.TrimSpace(field[1 : len(field)-1])
+			if link == "" || isURL(link) || strings.ContainsAny(link[:1], "*/-$") || strings.ContainsAny(link, "[]") {
+				return nil, false
+			}
+			links = append(links, link)
+		default:
+			return nil, false
+		}
+	}
+	return links, len(links) > 0
+}
+
+// splitLinkFields splits a line into white space separated fields, keeping
+// bracketed links containing spaces together
+func splitLinkFields(text string) []string {
+	var fields []string
+	for len(text) > 0 {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" {
+			break
+		}
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if text[0] == '[' {
+			end = strings.IndexByte(text, ']') + 1
+		}
+		if end <= 0 {
+			end = len(text)
+		}
+		fields = append(fields, text[:end])
+		text = text[end:]
+	}
+	return fields
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// TitleMapping renames a page, and optionally moves it into another database
+type TitleMapping struct {
+	// Title is the new title of the page, empty to keep the title
+	Title string
+	// Database is the tag database the page goes into instead of those of its
+	// tags, empty to keep its tags
+	Database string
+}
+
+// LoadTitleMapping reads a CSV file mapping Scrapbox titles to new titles, with
+// a row per page of the Scrapbox title, the new title and an optional database.
+// A first row with the header scrapbox_title is skipped, as are lines starting
+// with #.
+func LoadTitleMapping(path string) (map[string]TitleMapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read title mapping: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse title mapping %s: %w", path, err)
+	}
+
+	mappings := make(map[string]TitleMapping)
+	for i, record := range records {
+		if i == 0 && strings.TrimSpace(record[0]) == "scrapbox_title" {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("invalid title mapping %s on line %d: must be SCRAPBOX_TITLE,NOTION_TITLE[,DATABASE]", path, i+1)
+		}
+		mapping := TitleMapping{Title: strings.TrimSpace(record[1])}
+		if len(record) == 3 {
+			mapping.Database = strings.TrimSpace(record[2])
+		}
+		mappings[strings.TrimSpace(record[0])] = mapping
+	}
+	return mappings, nil
+}
+
+// WithTitleMapping renames the pages of the mapping, along with the links to
+// them, and moves them into the databases it gives. Mappings given more than
+// once are combined, the title and database of later ones winning.
+func WithTitleMapping(mappings map[string]TitleMapping) Option {
+	return func(p *Parser) {
+		for title, mapping := range mappings {
+			p.mapTitle(title, mapping)
+		}
+	}
+}
+
+// RenameTitle renames the page of the title, along with the links to it, in
+// the documents converted after, as a title mapping does
+func (p *Parser) RenameTitle(title, newTitle string) {
+	p.mapTitle(title, TitleMapping{Title: newTitle})
+}
+
+// DocumentTitle returns the title ParseDocument gives the page of the title,
+// renamed by the title mapping
+func (p *Parser) DocumentTitle(title string) string {
+	if mapping, ok := p.titleMap[linkID(title)]; ok && mapping.Title != "" {
+		return mapping.Title
+	}
+	return title
+}
+
+// mapTitle adds a mapping of a title, keeping what an earlier mapping of the
+// title sets that it doesn't
+func (p *Parser) mapTitle(title string, mapping TitleMapping) {
+	if p.titleMap == nil {
+		p.titleMap = make(map[string]TitleMapping)
+	}
+	existing := p.titleMap[linkID(title)]
+	if mapping.Title != "" {
+		existing.Title = mapping.Title
+	}
+	if mapping.Database != "" {
+		existing.Database = mapping.Database
+	}
+	p.titleMap[linkID(title)] = existing
+}
+
+// applyTitleMapping renames the document and the page links in it by the title
+// mapping
+func (p *Parser) applyTitleMapping(doc *models.Document) {
+	if len(p.titleMap) == 0 {
+		return
+	}
+	if mapping, ok := p.titleMap[linkID(doc.Title)]; ok {
+		if mapping.Title != "" {
+			doc.Title = mapping.Title
+		}
+		if mapping.Database != "" {
+			doc.Tags = []string{mapping.Database}
+		}
+	}
+	for i := range doc.Blocks {
+		p.renameLinks(doc.Blocks[i].Inline)
+	}
+}
+
+// renameLinks renames the links to the pages of the title mapping
+func (p *Parser) renameLinks(inlines []models.Inline) {
+	for i := range inlines {
+		inline := &inlines[i]
+		if inline.Type == models.InlinePageLink {
+			if mapping, ok := p.titleMap[linkID(inline.Text)]; ok && mapping.Title != "" {
+				inline.Text = mapping.Title
+				if inline.URL != "" {
+					inline.URL = linkID(mapping.Title)
+				}
+			}
+		}
+		p.renameLinks(inline.Children)
+	}
+}
@@ -0,0 +1,97 @@
+// Package pathsafe turns page titles into file names that the file system of
+// the platform accepts.
+package pathsafe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxNameBytes is the longest file name most file systems accept
+const MaxNameBytes = 255
+
+// windows applies the file name rules of Windows, a variable so both rule sets
+// can be tested on any platform
+var windows = runtime.GOOS == "windows"
+
+// Namer returns file names no longer than a limit. The zero Namer returns
+// names of at most MaxNameBytes.
+type Namer struct {
+	// maxBytes is the longest name returned, extension included, 0 for MaxNameBytes
+	maxBytes int
+}
+
+// NewNamer returns a Namer shortening names longer than maxBytes, extension
+// included. Names are never longer than MaxNameBytes.
+func NewNamer(maxBytes int) Namer {
+	return Namer{maxBytes: min(maxBytes, MaxNameBytes)}
+}
+
+// reservedNames are the device names Windows does not allow as file names,
+// with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Name returns name, without its extension ext, as a file name the platform
+// accepts of at most MaxNameBytes, see Namer.Name
+func Name(name, ext string) string {
+	return Namer{}.Name(name, ext)
+}
+
+// Name returns name, without its extension ext, as a file name the platform
+// accepts: path separators and characters the platform forbids are replaced
+// with _, and names too long are shortened and given a hash of the full name
+// so that long titles sharing a prefix don't collide. On Windows, trailing dots
+// and spaces are removed and device names such as CON are prefixed with _.
+func (n Namer) Name(name, ext string) string {
+	forbidden := "/\x00"
+	if windows {
+		forbidden = `/\:*?"<>|` + "\x00"
+	}
+	safe := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(forbidden, r) || (windows && r < 0x20) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if windows {
+		safe = strings.TrimRight(safe, ". ")
+		base, _, _ := strings.Cut(safe, ".")
+		if reservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+			safe = "_" + safe
+		}
+	}
+	if safe == "" || safe == "." || safe == ".." {
+		safe = "_" + safe
+	}
+
+	maxBytes := MaxNameBytes
+	if n.maxBytes > 0 {
+		maxBytes = n.maxBytes
+	}
+	if limit := maxBytes - len(ext); len(safe) > limit {
+		sum := sha256.Sum256([]byte(name))
+		suffix := "-" + hex.EncodeToString(sum[:4])
+		safe = truncate(safe, limit-len(suffix)) + suffix
+	}
+	return safe + ext
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	for len(s) > n {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package pathsafe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	long := strings.Repeat("あ", 100)
+
+	tests := map[string]struct {
+		name     string
+		windows  bool
+		expected string
+	}{
+		"Plain":               {name: "Go Tips", expected: "Go Tips.md"},
+		"Separator":           {name: "a/b", expected: "a_b.md"},
+		"Colon on Unix":       {name: "Q: why?", expected: "Q: why?.md"},
+		"Colon on Windows":    {name: "Q: why?", windows: true, expected: "Q_ why_.md"},
+		"Trailing dot":        {name: "etc.", windows: true, expected: "etc.md"},
+		"Device name":         {name: "con", windows: true, expected: "_con.md"},
+		"Device name and ext": {name: "NUL.txt", windows: true, expected: "_NUL.txt.md"},
+		"Empty":               {name: "", expected: "_.md"},
+		"Dots":                {name: "..", expected: "_...md"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			windows = tt.windows
+			defer func() { windows = false }()
+			if got := Name(tt.name, ".md"); got != tt.expected {
+				t.Errorf("Name(%q) = %q, want %q", tt.name, got, tt.expected)
+			}
+		})
+	}
+
+	// Long names are shortened at a character boundary and told apart by a hash
+	a, b := Name(long+"a", ".md"), Name(long+"b", ".md")
+	if len(a) > MaxNameBytes || a == b || !strings.HasPrefix(a, strings.Repeat("あ", 80)) {
+		t.Errorf("Expected long names shortened to distinct names, got %q and %q", a, b)
+	}
+
+	if got := NewNamer(20).Name(long, ".md"); len(got) > 20 || !strings.HasSuffix(got, ".md") {
+		t.Errorf("Expected a name of at most 20 bytes, got %q", got)
+	}
+	if got := NewNamer(1000).Name(long, ".md"); len(got) > MaxNameBytes {
+		t.Errorf("Expected names never longer than %d bytes, got %q", MaxNameBytes, got)
+	}
+}
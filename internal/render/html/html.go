@@ -0,0 +1,348 @@
+// Package html writes documents as a browsable archive of HTML pages.
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/pathsafe"
+)
+
+// style is the stylesheet shared by every page of the archive
+const style = `body { max-width: 48rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; line-height: 1.6; color: #222; }
+a { color: #0969da; }
+a.missing { color: #888; text-decoration: none; }
+pre { background: #f6f8fa; padding: 1rem; overflow-x: auto; }
+code { background: #f6f8fa; padding: 0 .2rem; }
+img { max-width: 100%; }
+nav { margin-bottom: 1rem; }
+.tags { color: #555; }
+.history { color: #888; font-size: .85rem; }`
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+<nav><a href="index.html">Index</a></nav>
+<h1>{{.Title}}</h1>
+{{- if .Tags}}
+<p class="tags">{{range .Tags}}<span>#{{.}}</span> {{end}}</p>
+{{- end}}
+{{.Body}}
+</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+<h1>Index</h1>
+<ul>
+{{- range .Pages}}
+<li><a href="{{.URL}}">{{.Title}}</a></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// Archive writes one HTML file per page and an index linking to every page
+type Archive struct {
+	dir string
+	// mu guards titles, so pages can be written at the same time
+	mu     sync.Mutex
+	titles []string
+	// fileOpts are the options pages and the index are written with
+	fileOpts []atomicfile.Option
+	// names shortens the file names of long titles
+	names pathsafe.Namer
+}
+
+// Option configures optional behavior of the Archive
+type Option func(*Archive)
+
+// WithFileOptions sets the options pages and the index are written with, such as
+// atomicfile.SkipUnchanged
+func WithFileOptions(opts ...atomicfile.Option) Option {
+	return func(a *Archive) {
+		a.fileOpts = opts
+	}
+}
+
+// WithMaxNameBytes shortens the names of the files of titles longer than
+// maxBytes, links to them included
+func WithMaxNameBytes(maxBytes int) Option {
+	return func(a *Archive) {
+		a.names = pathsafe.NewNamer(maxBytes)
+	}
+}
+
+// NewArchive creates an Archive writing into dir
+func NewArchive(dir string, opts ...Option) *Archive {
+	a := &Archive{dir: dir}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Write writes the document as an HTML page and returns its path
+func (a *Archive) Write(doc *models.Document) (string, error) {
+	path := filepath.Join(a.dir, fileName(doc.Title, a.names))
+	var page bytes.Buffer
+	err := pageTemplate.Execute(&page, map[string]interface{}{
+		"Title": doc.Title,
+		"Tags":  doc.Tags,
+		"Style": template.CSS(style),
+		"Body":  template.HTML(render(doc, a.names)),
+	})
+	if err == nil {
+		err = atomicfile.WriteFile(path, page.Bytes(), 0644, a.fileOpts...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write page: %w", err)
+	}
+
+	a.mu.Lock()
+	a.titles = append(a.titles, doc.Title)
+	a.mu.Unlock()
+	return path, nil
+}
+
+// Close writes the index of the pages written so far
+func (a *Archive) Close() error {
+	titles := append([]string(nil), a.titles...)
+	sort.Strings(titles)
+	pages := make([]map[string]string, len(titles))
+	for i, title := range titles {
+		pages[i] = map[string]string{"Title": title, "URL": fileURL(title, a.names)}
+	}
+
+	var index bytes.Buffer
+	err := indexTemplate.Execute(&index, map[string]interface{}{
+		"Pages": pages,
+		"Style": template.CSS(style),
+	})
+	if err == nil {
+		err = atomicfile.WriteFile(filepath.Join(a.dir, "index.html"), index.Bytes(), 0644, a.fileOpts...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// FileName returns the name of the HTML file for a title. Files are named after
+// the lower case title with underscores for spaces, the form Scrapbox uses for
+// links, so that links match their page regardless of case.
+func FileName(title string) string {
+	return fileName(title, pathsafe.Namer{})
+}
+
+// fileName returns the name of the HTML file for a title, shortened by names
+func fileName(title string, names pathsafe.Namer) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.ToLower(strings.ReplaceAll(title, " ", "_")))
+	if name == "" || name == "index" {
+		name = "_" + name
+	}
+	return names.Name(name, ".html")
+}
+
+// fileURL returns the relative URL of the HTML file for a title
+func fileURL(title string, names pathsafe.Namer) string {
+	return url.PathEscape(fileName(title, names))
+}
+
+// Render renders the blocks of a document as an HTML fragment
+func Render(doc *models.Document) string {
+	return render(doc, pathsafe.Namer{})
+}
+
+// render renders the blocks of a document, linking to pages by the file names
+// of names
+func render(doc *models.Document, names pathsafe.Namer) string {
+	var b strings.Builder
+
+	// lists holds the closing tags of the lists currently open, innermost last
+	var lists []string
+	closeLists := func(depth int) {
+		for len(lists) > depth {
+			b.WriteString(lists[len(lists)-1] + "\n")
+			lists = lists[:len(lists)-1]
+		}
+	}
+
+	var notes []string
+	for _, block := range doc.Blocks {
+		element := renderBlock(block, names)
+		if block.History != nil {
+			switch doc.History {
+			case models.HistoryComment:
+				element += " <!-- " + strings.ReplaceAll(block.History.String(), "--", "- -") + " -->"
+			case models.HistoryFootnote:
+				notes = append(notes, html.EscapeString(block.History.String()))
+				element = withFootnote(block, element, len(notes))
+			}
+		}
+
+		var tag string
+		switch block.Type {
+		case models.BlockBullet, models.BlockToDo:
+			tag = "ul"
+		case models.BlockNumbered:
+			tag = "ol"
+		default:
+			closeLists(0)
+			b.WriteString(element + "\n")
+			continue
+		}
+
+		depth := block.Indent + 1
+		closeLists(depth)
+		if len(lists) == depth && lists[depth-1] != "</"+tag+">" {
+			closeLists(depth - 1)
+		}
+		for len(lists) < depth {
+			b.WriteString("<" + tag + ">\n")
+			lists = append(lists, "</"+tag+">")
+		}
+		b.WriteString(element + "\n")
+	}
+	closeLists(0)
+
+	if len(notes) > 0 {
+		b.WriteString(`<ol class="history">` + "\n")
+		for i, note := range notes {
+			b.WriteString(fmt.Sprintf(`<li id="history-%d">%s</li>`+"\n", i+1, note))
+		}
+		b.WriteString("</ol>\n")
+	}
+	return b.String()
+}
+
+// withFootnote adds a reference to the nth history footnote to the rendered
+// block, inside its element for text and after it for other blocks
+func withFootnote(block models.Block, element string, n int) string {
+	ref := fmt.Sprintf(`<sup><a href="#history-%d">%d</a></sup>`, n, n)
+	switch block.Type {
+	case models.BlockCode, models.BlockEquation, models.BlockDivider, models.BlockEmbed, models.BlockAttachment:
+		return element + ref
+	}
+	closing := strings.LastIndex(element, "</")
+	return element[:closing] + ref + element[closing:]
+}
+
+// renderBlock renders a single block
+func renderBlock(block models.Block, names pathsafe.Namer) string {
+	switch block.Type {
+	case models.BlockHeading:
+		// Page titles are h1, so headings start at h2
+		level := block.Level + 1
+		if level > 6 {
+			level = 6
+		}
+		return fmt.Sprintf("<h%d>%s</h%d>", level, renderInline(block.Inline, names), level)
+	case models.BlockBullet, models.BlockNumbered:
+		return "<li>" + renderInline(block.Inline, names) + "</li>"
+	case models.BlockToDo:
+		checked := ""
+		if block.Checked {
+			checked = " checked"
+		}
+		return fmt.Sprintf(`<li><input type="checkbox" disabled%s> %s</li>`, checked, renderInline(block.Inline, names))
+	case models.BlockCode:
+		class := ""
+		if block.Language != "" {
+			class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(block.Language))
+		}
+		return fmt.Sprintf("<pre><code%s>%s</code></pre>", class, html.EscapeString(block.Text))
+	case models.BlockEquation:
+		return `<p class="math">\[` + html.EscapeString(block.Text) + `\]</p>`
+	case models.BlockDivider:
+		return "<hr>"
+	case models.BlockQuote:
+		return "<blockquote>" + renderInline(block.Inline, names) + "</blockquote>"
+	case models.BlockCallout:
+		return fmt.Sprintf(`<aside class="callout">%s %s</aside>`, html.EscapeString(block.Icon), renderInline(block.Inline, names))
+	case models.BlockEmbed:
+		if player := block.Embed.PlayerURL(); player != "" {
+			return fmt.Sprintf(`<iframe src="%s" width="560" height="315" frameborder="0" allowfullscreen></iframe>`, html.EscapeString(player))
+		}
+		return "<p>" + renderInline(block.Inline, names) + "</p>"
+	case models.BlockAttachment:
+		switch block.Attachment.Kind {
+		case models.AttachmentAudio:
+			return fmt.Sprintf(`<audio controls src="%s"></audio>`, html.EscapeString(block.Attachment.URL))
+		case models.AttachmentVideo:
+			return fmt.Sprintf(`<video controls src="%s"></video>`, html.EscapeString(block.Attachment.URL))
+		case models.AttachmentPDF:
+			return fmt.Sprintf(`<embed src="%s" type="application/pdf" width="100%%" height="600">`, html.EscapeString(block.Attachment.URL))
+		}
+		return "<p>" + renderInline(block.Inline, names) + "</p>"
+	default:
+		return "<p>" + renderInline(block.Inline, names) + "</p>"
+	}
+}
+
+// renderInline renders inline spans
+func renderInline(inlines []models.Inline, names pathsafe.Namer) string {
+	var b strings.Builder
+	for _, inline := range inlines {
+		switch inline.Type {
+		case models.InlineBold:
+			b.WriteString("<strong>" + renderInline(inline.Children, names) + "</strong>")
+		case models.InlineItalic:
+			b.WriteString("<em>" + renderInline(inline.Children, names) + "</em>")
+		case models.InlineStrike:
+			b.WriteString("<del>" + renderInline(inline.Children, names) + "</del>")
+		case models.InlineCode:
+			b.WriteString("<code>" + html.EscapeString(inline.Text) + "</code>")
+		case models.InlineMath:
+			b.WriteString(`<span class="math">\(` + html.EscapeString(inline.Text) + `\)</span>`)
+		case models.InlinePageLink:
+			if inline.URL == "" {
+				// Pages missing from the export have no file to link to
+				b.WriteString(`<a class="missing">` + html.EscapeString(inline.Text) + "</a>")
+			} else {
+				b.WriteString(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(fileURL(inline.URL, names)), html.EscapeString(inline.Text)))
+			}
+		case models.InlineLink:
+			label := html.EscapeString(inline.Text)
+			switch {
+			case len(inline.Children) > 0:
+				label = renderInline(inline.Children, names)
+			case label == "":
+				label = html.EscapeString(inline.URL)
+			}
+			b.WriteString(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(inline.URL), label))
+		case models.InlineImage:
+			b.WriteString(fmt.Sprintf(`<img src="%s" alt="">`, html.EscapeString(inline.URL)))
+		default:
+			b.WriteString(html.EscapeString(inline.Text))
+		}
+	}
+	return b.String()
+}
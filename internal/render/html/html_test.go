@@ -0,0 +1,162 @@
+package html
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	text := func(s string) []models.Inline {
+		return []models.Inline{{Type: models.InlineText, Text: s}}
+	}
+
+	doc := &models.Document{
+		Title: "Page",
+		Blocks: []models.Block{
+			{Type: models.BlockHeading, Level: 1, Inline: text("Heading")},
+			{Type: models.BlockParagraph, Inline: []models.Inline{
+				{Type: models.InlineBold, Children: text("a < b")},
+				{Type: models.InlineText, Text: " "},
+				{Type: models.InlinePageLink, Text: "Other Page", URL: "other_page"},
+				{Type: models.InlineText, Text: " "},
+				{Type: models.InlinePageLink, Text: "Missing"},
+			}},
+			{Type: models.BlockBullet, Inline: text("one")},
+			{Type: models.BlockBullet, Indent: 1, Inline: text("nested")},
+			{Type: models.BlockNumbered, Number: 1, Inline: text("first")},
+			{Type: models.BlockToDo, Checked: true, Inline: text("done")},
+			{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineImage, URL: "https://example.com/a.png"}}},
+			{Type: models.BlockCode, Language: "go", Text: "if a < b {}"},
+		},
+	}
+
+	expected := `<h2>Heading</h2>
+<p><strong>a &lt; b</strong> <a href="other_page.html">Other Page</a> <a class="missing">Missing</a></p>
+<ul>
+<li>one</li>
+<ul>
+<li>nested</li>
+</ul>
+</ul>
+<ol>
+<li>first</li>
+</ol>
+<ul>
+<li><input type="checkbox" disabled checked> done</li>
+</ul>
+<p><img src="https://example.com/a.png" alt=""></p>
+<pre><code class="language-go">if a &lt; b {}</code></pre>
+`
+
+	if result := Render(doc); result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := map[string]string{
+		"Other Page": "other_page.html",
+		"a/b":        "a_b.html",
+		"Index":      "_index.html",
+	}
+	for title, expected := range tests {
+		if result := FileName(title); result != expected {
+			t.Errorf("FileName(%q) = %q, want %q", title, result, expected)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := NewArchive(dir)
+
+	for _, title := range []string{"Second", "First"} {
+		if _, err := archive.Write(&models.Document{Title: title, Tags: []string{"tag"}}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "first.html"))
+	if err != nil {
+		t.Fatalf("Failed to read page: %v", err)
+	}
+	if !strings.Contains(string(page), "<h1>First</h1>") || !strings.Contains(string(page), "#tag") {
+		t.Errorf("Unexpected page content: %s", page)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	first := strings.Index(string(index), `<a href="first.html">First</a>`)
+	second := strings.Index(string(index), `<a href="second.html">Second</a>`)
+	if first == -1 || second == -1 || first > second {
+		t.Errorf("Expected sorted links to every page, got %s", index)
+	}
+}
+
+func TestArchiveMaxNameBytes(t *testing.T) {
+	dir := t.TempDir()
+	archive := NewArchive(dir, WithMaxNameBytes(40))
+	long := strings.Repeat("long title ", 10)
+
+	path, err := archive.Write(&models.Document{Title: long})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if name := filepath.Base(path); len(name) > 40 {
+		t.Errorf("Expected a file name of at most 40 bytes, got %q", name)
+	}
+
+	// Links to the page point at the shortened file
+	linking, err := archive.Write(&models.Document{Title: "Other", Blocks: []models.Block{
+		{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlinePageLink, Text: long, URL: long}}},
+	}})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	for _, file := range []string{linking, filepath.Join(dir, "index.html")} {
+		content, _ := os.ReadFile(file)
+		if !strings.Contains(string(content), `href="`+filepath.Base(path)+`"`) {
+			t.Errorf("Expected %s to link to %s, got %s", file, filepath.Base(path), content)
+		}
+	}
+}
+
+func TestRenderHistory(t *testing.T) {
+	doc := &models.Document{
+		History: models.HistoryFootnote,
+		Blocks: []models.Block{
+			{Type: models.BlockBullet, Inline: []models.Inline{{Type: models.InlineText, Text: "item"}}, History: &models.LineHistory{Author: "bob", Updated: 0}},
+			{Type: models.BlockDivider, History: &models.LineHistory{Updated: 0}},
+		},
+	}
+
+	expected := `<ul>
+<li>item<sup><a href="#history-1">1</a></sup></li>
+</ul>
+<hr><sup><a href="#history-2">2</a></sup>
+<ol class="history">
+<li id="history-1">bob, 1970-01-01 00:00 UTC</li>
+<li id="history-2">1970-01-01 00:00 UTC</li>
+</ol>
+`
+	if result := Render(doc); result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+
+	doc.History = models.HistoryComment
+	if result := Render(doc); !strings.Contains(result, "<li>item</li> <!-- bob, 1970-01-01 00:00 UTC -->") {
+		t.Errorf("Expected the history in a comment, got %q", result)
+	}
+}
@@ -0,0 +1,253 @@
+// Package hugo writes documents as Hugo content bundles.
+package hugo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/pathsafe"
+	"github.com/takak2166/scrapbox2notion/internal/render/markdown"
+)
+
+// Section is the content section posts are written to
+const Section = "posts"
+
+// DraftTag marks a page as a draft instead of being published as a tag
+const DraftTag = "draft"
+
+// Site writes page bundles into the content directory of a Hugo site
+type Site struct {
+	dir string
+	// mu guards slugs and titles, so bundles can be written at the same time
+	mu sync.Mutex
+	// slugs maps the slugs in use to the title they were given to
+	slugs map[string]string
+	// titles maps the titles given a slug to their slug
+	titles map[string]string
+	// links maps the titles given a slug by titleKey to their slug, for links
+	// written in another case
+	links map[string]string
+	// fileOpts are the options bundles are written with
+	fileOpts []atomicfile.Option
+	// names shortens the slugs of long titles
+	names pathsafe.Namer
+}
+
+// Option configures optional behavior of the Site
+type Option func(*Site)
+
+// WithFileOptions sets the options bundles are written with, such as
+// atomicfile.SkipUnchanged
+func WithFileOptions(opts ...atomicfile.Option) Option {
+	return func(s *Site) {
+		s.fileOpts = opts
+	}
+}
+
+// WithMaxNameBytes shortens the slugs of titles longer than maxBytes, links to
+// them included
+func WithMaxNameBytes(maxBytes int) Option {
+	return func(s *Site) {
+		s.names = pathsafe.NewNamer(maxBytes)
+	}
+}
+
+// NewSite creates a Site writing into the Hugo site at dir
+func NewSite(dir string, opts ...Option) *Site {
+	s := &Site{
+		dir:    dir,
+		slugs:  make(map[string]string),
+		titles: make(map[string]string),
+		links:  make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reserve gives the titles their slugs in order, so which of the titles sharing
+// a slug gets a numbered one doesn't depend on the order their bundles are
+// written in, as when they are written at the same time. Titles not reserved
+// are given a slug when they are written.
+func (s *Site) Reserve(titles []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, title := range titles {
+		s.assign(title)
+	}
+}
+
+// assign returns the slug of the title, giving it one with a number added when
+// another title has its slug. s.mu must be held.
+func (s *Site) assign(title string) string {
+	if slug, ok := s.titles[title]; ok {
+		return slug
+	}
+	slug := titleSlug(title, s.names)
+	if _, ok := s.slugs[slug]; ok {
+		base := slug
+		for n := 2; ok; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+			_, ok = s.slugs[slug]
+		}
+		logger.Info("Renaming bundle with duplicate slug", map[string]interface{}{
+			"title": title,
+			"slug":  slug,
+		})
+	}
+	s.slugs[slug] = title
+	s.titles[title] = slug
+	if _, ok := s.links[titleKey(title)]; !ok {
+		s.links[titleKey(title)] = slug
+	}
+	return slug
+}
+
+// slug returns the slug a link to the title points to: the slug the title was
+// given, or the slug of the title when it has none yet
+func (s *Site) slug(title string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slug, ok := s.titles[title]; ok {
+		return slug
+	}
+	if slug, ok := s.links[titleKey(title)]; ok {
+		return slug
+	}
+	return titleSlug(title, s.names)
+}
+
+// Write writes the document as content/posts/<slug>/index.md and returns its path
+func (s *Site) Write(doc *models.Document) (string, error) {
+	s.mu.Lock()
+	slug := s.assign(doc.Title)
+	s.mu.Unlock()
+
+	bundle := filepath.Join(s.dir, "content", Section, slug)
+	if err := os.MkdirAll(bundle, 0755); err != nil {
+		return "", fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	path := filepath.Join(bundle, "index.md")
+	if err := atomicfile.WriteFile(path, []byte(render(doc, s.slug)), 0644, s.fileOpts...); err != nil {
+		return "", fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return path, nil
+}
+
+// Render renders a document as a Hugo page with front matter built from the
+// page metadata. Pages tagged #draft are marked as drafts.
+func Render(doc *models.Document) string {
+	return render(doc, Slug)
+}
+
+// render renders a document as a Hugo page, linking to pages by the slugs slug
+// returns for their titles
+func render(doc *models.Document, slug func(title string) string) string {
+	r := &markdown.Renderer{
+		PageLink: func(link models.Inline) string {
+			return ref(link, slug)
+		},
+		Embed: shortcode,
+	}
+
+	draft := false
+	var tags []string
+	for _, tag := range doc.Tags {
+		if strings.EqualFold(tag, DraftTag) {
+			draft = true
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	var page strings.Builder
+	page.WriteString("---\n")
+	page.WriteString(fmt.Sprintf("title: %s\n", quote(doc.Title)))
+	if doc.Created > 0 {
+		page.WriteString(fmt.Sprintf("date: %s\n", time.Unix(doc.Created, 0).UTC().Format(time.RFC3339)))
+	}
+	if doc.Updated > 0 {
+		page.WriteString(fmt.Sprintf("lastmod: %s\n", time.Unix(doc.Updated, 0).UTC().Format(time.RFC3339)))
+	}
+	if len(tags) > 0 {
+		page.WriteString("tags:\n")
+		for _, tag := range tags {
+			page.WriteString(fmt.Sprintf("  - %s\n", quote(tag)))
+		}
+	}
+	if doc.SourceURL != "" {
+		page.WriteString(fmt.Sprintf("source: %s\n", quote(doc.SourceURL)))
+	}
+	page.WriteString(fmt.Sprintf("draft: %t\n", draft))
+	page.WriteString("---\n\n")
+	page.WriteString(r.RenderBody(doc))
+	return page.String()
+}
+
+// shortcode renders embedded media through Hugo's built-in shortcodes
+func shortcode(embed *models.Embed) string {
+	switch embed.Provider {
+	case models.EmbedYouTube:
+		return fmt.Sprintf("{{< youtube %s >}}", embed.ID)
+	case models.EmbedVimeo:
+		return fmt.Sprintf("{{< vimeo %s >}}", embed.ID)
+	}
+	return fmt.Sprintf("[%s](%s)", embed.URL, embed.URL)
+}
+
+// ref renders a link to another page through Hugo's ref shortcode. Links to
+// pages missing from the export are kept as text so the site still builds.
+func ref(link models.Inline, slug func(title string) string) string {
+	if link.URL == "" {
+		return link.Text
+	}
+	return fmt.Sprintf(`[%s]({{< ref "/%s/%s" >}})`, link.Text, Section, slug(link.Text))
+}
+
+// Slug returns the URL slug for a title: lower case words joined by hyphens.
+// Letters of any script are kept.
+func Slug(title string) string {
+	return titleSlug(title, pathsafe.Namer{})
+}
+
+// titleSlug returns the URL slug for a title, shortened by names
+func titleSlug(title string, names pathsafe.Namer) string {
+	var slug strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	if slug.Len() == 0 {
+		return "page"
+	}
+	return names.Name(slug.String(), "")
+}
+
+// titleKey returns the key of a title in Site.links, the same for the
+// differences in case and spaces that links to the title may have
+func titleKey(title string) string {
+	return strings.ToLower(strings.ReplaceAll(title, " ", "_"))
+}
+
+// quote quotes a string for the YAML front matter
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
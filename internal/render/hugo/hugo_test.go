@@ -0,0 +1,133 @@
+package hugo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	doc := &models.Document{
+		Title:   `Hello "World"`,
+		Tags:    []string{"go", "Draft"},
+		Created: 1700000000,
+		Updated: 1700003600,
+		Blocks: []models.Block{
+			{Type: models.BlockParagraph, Inline: []models.Inline{
+				{Type: models.InlinePageLink, Text: "Other Page", URL: "other_page"},
+				{Type: models.InlineText, Text: " and "},
+				{Type: models.InlinePageLink, Text: "Missing"},
+			}},
+		},
+	}
+
+	expected := "---\n" +
+		"title: \"Hello \\\"World\\\"\"\n" +
+		"date: 2023-11-14T22:13:20Z\n" +
+		"lastmod: 2023-11-14T23:13:20Z\n" +
+		"tags:\n  - \"go\"\n" +
+		"draft: true\n" +
+		"---\n\n" +
+		"[Other Page]({{< ref \"/posts/other-page\" >}}) and Missing\n"
+
+	if result := Render(doc); result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"Hello World":          "hello-world",
+		"  Go: Tips & Tricks ": "go-tips-tricks",
+		"日本語 ページ":              "日本語-ページ",
+		"!!!":                  "page",
+	}
+	for title, expected := range tests {
+		if result := Slug(title); result != expected {
+			t.Errorf("Slug(%q) = %q, want %q", title, result, expected)
+		}
+	}
+}
+
+func TestSiteWrite(t *testing.T) {
+	dir := t.TempDir()
+	site := NewSite(dir)
+
+	first, err := site.Write(&models.Document{Title: "Hello World"})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if first != filepath.Join(dir, "content", "posts", "hello-world", "index.md") {
+		t.Errorf("Unexpected bundle path %s", first)
+	}
+
+	second, err := site.Write(&models.Document{Title: "hello world"})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if second != filepath.Join(dir, "content", "posts", "hello-world-2", "index.md") {
+		t.Errorf("Expected renamed bundle for duplicate slug, got %s", second)
+	}
+
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("Expected bundle to be written: %v", err)
+	}
+}
+
+func TestSiteReserve(t *testing.T) {
+	dir := t.TempDir()
+	site := NewSite(dir)
+	// Reserved titles keep their slugs whatever order they are written in
+	site.Reserve([]string{"Hello World", "Hello, World!"})
+
+	second, err := site.Write(&models.Document{Title: "Hello, World!"})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if second != filepath.Join(dir, "content", "posts", "hello-world-2", "index.md") {
+		t.Errorf("Expected the second title reserved to get the numbered slug, got %s", second)
+	}
+
+	linking := &models.Document{
+		Title:  "Index",
+		Blocks: []models.Block{{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlinePageLink, Text: "hello, world!", URL: "hello,_world!"}}}},
+	}
+	path, err := site.Write(linking)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+	if !strings.Contains(string(content), `{{< ref "/posts/hello-world-2" >}}`) {
+		t.Errorf("Expected the link to follow the reserved slug, got %q", content)
+	}
+}
+
+func TestSiteWriteSkipUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	site := NewSite(dir, WithFileOptions(atomicfile.SkipUnchanged()))
+	doc := &models.Document{Title: "Hello World"}
+
+	path, err := site.Write(doc)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	if _, err := site.Write(doc); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if info, _ := os.Stat(path); !info.ModTime().Equal(old) {
+		t.Errorf("Expected an unchanged bundle left untouched")
+	}
+}
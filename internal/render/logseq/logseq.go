@@ -0,0 +1,141 @@
+// Package logseq writes documents as pages of a Logseq graph.
+package logseq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/pathsafe"
+	"github.com/takak2166/scrapbox2notion/internal/render/markdown"
+)
+
+// PagesDir is the graph folder pages are written to
+const PagesDir = "pages"
+
+// Graph writes pages into the pages folder of a Logseq graph
+type Graph struct {
+	dir string
+	// fileOpts are the options pages are written with
+	fileOpts []atomicfile.Option
+	// names shortens the file names of long titles
+	names pathsafe.Namer
+}
+
+// Option configures optional behavior of the Graph
+type Option func(*Graph)
+
+// WithFileOptions sets the options pages are written with, such as
+// atomicfile.SkipUnchanged
+func WithFileOptions(opts ...atomicfile.Option) Option {
+	return func(g *Graph) {
+		g.fileOpts = opts
+	}
+}
+
+// WithMaxNameBytes shortens the names of the files of titles longer than maxBytes
+func WithMaxNameBytes(maxBytes int) Option {
+	return func(g *Graph) {
+		g.names = pathsafe.NewNamer(maxBytes)
+	}
+}
+
+// NewGraph creates a Graph writing into the graph at dir
+func NewGraph(dir string, opts ...Option) *Graph {
+	g := &Graph{dir: dir}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Write writes the document as a page and returns its path
+func (g *Graph) Write(doc *models.Document) (string, error) {
+	dir := filepath.Join(g.dir, PagesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create pages folder: %w", err)
+	}
+
+	path := filepath.Join(dir, fileName(doc.Title, g.names))
+	if err := atomicfile.WriteFile(path, []byte(Render(doc)), 0644, g.fileOpts...); err != nil {
+		return "", fmt.Errorf("failed to write page: %w", err)
+	}
+	return path, nil
+}
+
+// Render renders a document as a Logseq outline. Every block becomes a bullet:
+// paragraphs, headings and code at the top level and list items nested below them.
+func Render(doc *models.Document) string {
+	r := &markdown.Renderer{
+		PageLink: func(link models.Inline) string {
+			return "[[" + link.Text + "]]"
+		},
+		Embed: func(embed *models.Embed) string {
+			if embed.Provider == models.EmbedTwitter {
+				return "{{tweet " + embed.URL + "}}"
+			}
+			return "{{video " + embed.URL + "}}"
+		},
+	}
+
+	var page strings.Builder
+	if len(doc.Tags) > 0 {
+		page.WriteString("tags:: " + strings.Join(doc.Tags, ", ") + "\n\n")
+	}
+
+	for _, block := range doc.Blocks {
+		depth := 0
+		switch block.Type {
+		case models.BlockBullet, models.BlockNumbered, models.BlockToDo:
+			depth = block.Indent + 1
+		}
+		indent := strings.Repeat("\t", depth)
+
+		var content string
+		switch block.Type {
+		case models.BlockBullet:
+			content = r.RenderInline(block.Inline)
+		case models.BlockNumbered:
+			content = fmt.Sprintf("%d. %s", block.Number, r.RenderInline(block.Inline))
+		case models.BlockToDo:
+			marker := "TODO"
+			if block.Checked {
+				marker = "DONE"
+			}
+			content = marker + " " + r.RenderInline(block.Inline)
+		default:
+			block.Indent = 0
+			content = r.RenderBlock(block)
+		}
+
+		// Continuation lines of a block are indented past its bullet
+		content = strings.ReplaceAll(content, "\n", "\n"+indent+"  ")
+		page.WriteString(indent + "- " + content + "\n")
+	}
+
+	return page.String()
+}
+
+// FileName returns the file name of the page for a title. Logseq writes the
+// namespace separator / as ___ in file names.
+func FileName(title string) string {
+	return fileName(title, pathsafe.Namer{})
+}
+
+// fileName returns the file name of the page for a title, shortened by names
+func fileName(title string, names pathsafe.Namer) string {
+	name := strings.ReplaceAll(title, "/", "___")
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "_"
+	}
+	return names.Name(name, ".md")
+}
@@ -0,0 +1,54 @@
+package logseq
+
+import (
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	text := func(s string) []models.Inline {
+		return []models.Inline{{Type: models.InlineText, Text: s}}
+	}
+
+	doc := &models.Document{
+		Title: "Page",
+		Tags:  []string{"go", "notes"},
+		Blocks: []models.Block{
+			{Type: models.BlockHeading, Level: 2, Inline: text("Heading")},
+			{Type: models.BlockParagraph, Inline: []models.Inline{
+				{Type: models.InlineText, Text: "See "},
+				{Type: models.InlinePageLink, Text: "Other Page", URL: "other_page"},
+			}},
+			{Type: models.BlockBullet, Inline: text("item")},
+			{Type: models.BlockBullet, Indent: 1, Inline: text("nested")},
+			{Type: models.BlockToDo, Checked: true, Inline: text("done")},
+			{Type: models.BlockCode, Language: "go", Text: "a := 1\nb := 2"},
+		},
+	}
+
+	expected := "tags:: go, notes\n\n" +
+		"- ## Heading\n" +
+		"- See [[Other Page]]\n" +
+		"\t- item\n" +
+		"\t\t- nested\n" +
+		"\t- DONE done\n" +
+		"- ```go\n  a := 1\n  b := 2\n  ```\n"
+
+	if result := Render(doc); result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := map[string]string{
+		"Page":          "Page.md",
+		"Project/Notes": "Project___Notes.md",
+		"a:b":           "a_b.md",
+	}
+	for title, expected := range tests {
+		if result := FileName(title); result != expected {
+			t.Errorf("FileName(%q) = %q, want %q", title, result, expected)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package markdown renders documents as markdown.
+package markdown
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// Renderer renders documents as markdown. Other markdown flavors customize
+// how links to pages and images are written through its hooks.
+type Renderer struct {
+	// PageLink renders a link to another page, nil for relative markdown links
+	PageLink func(link models.Inline) string
+	// Image renders an image, nil for markdown images pointing at the URL
+	Image func(url string) string
+	// Embed renders embedded media, nil for an iframe of videos and a link to others
+	Embed func(embed *models.Embed) string
+	// Anchor renders a link to a heading of the page, nil for a link to its
+	// GitHub style anchor
+	Anchor func(anchor models.Inline) string
+}
+
+var defaultRenderer = &Renderer{}
+
+// Render renders a document as markdown, starting with its title as a heading
+func Render(doc *models.Document) string {
+	return defaultRenderer.Render(doc)
+}
+
+// RenderBlock renders a single block as markdown
+func RenderBlock(block models.Block) string {
+	return defaultRenderer.RenderBlock(block)
+}
+
+// RenderInline renders inline spans as markdown
+func RenderInline(inlines []models.Inline) string {
+	return defaultRenderer.RenderInline(inlines)
+}
+
+// Render renders a document, starting with its title as a heading, after front
+// matter of its Scrapbox URL when it has one
+func (r *Renderer) Render(doc *models.Document) string {
+	var frontMatter string
+	if doc.SourceURL != "" {
+		frontMatter = fmt.Sprintf("---\nsource: %s\n---\n\n", doc.SourceURL)
+	}
+	return frontMatter + fmt.Sprintf("# %s\n\n", doc.Title) + r.RenderBody(doc)
+}
+
+// RenderBody renders the blocks of a document with the history of their lines
+// in the style of the document, and its footer under a rule
+func (r *Renderer) RenderBody(doc *models.Document) string {
+	body := r.renderBlocks(doc.Blocks, doc.History)
+	if doc.TableOfContents {
+		body = TableOfContents(doc.Blocks) + "\n" + body
+	}
+	if doc.Footer != nil {
+		body += "\n---\n\n" + r.RenderInline(doc.Footer.Inline()) + "\n"
+	}
+	return body
+}
+
+// TableOfContents renders the headings of the blocks as a nested list linking
+// to the anchors GitHub gives them
+func TableOfContents(blocks []models.Block) string {
+	top := 0
+	for _, block := range blocks {
+		if block.Type == models.BlockHeading && (top == 0 || block.Level < top) {
+			top = block.Level
+		}
+	}
+
+	var toc strings.Builder
+	anchors := make(map[string]int)
+	for _, block := range blocks {
+		if block.Type != models.BlockHeading {
+			continue
+		}
+		text := models.PlainText(block.Inline)
+		anchor := headingAnchor(text)
+		if n := anchors[anchor]; n > 0 {
+			anchors[anchor]++
+			anchor = fmt.Sprintf("%s-%d", anchor, n)
+		} else {
+			anchors[anchor] = 1
+		}
+		label := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text)
+		toc.WriteString(fmt.Sprintf("%s- [%s](#%s)\n", strings.Repeat("  ", block.Level-top), label, anchor))
+	}
+	return toc.String()
+}
+
+// headingAnchor returns the anchor GitHub gives a heading with the text: lower
+// case, with spaces as hyphens and punctuation left out
+func headingAnchor(text string) string {
+	var anchor strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ':
+			anchor.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			anchor.WriteRune(r)
+		}
+	}
+	return anchor.String()
+}
+
+// RenderBlocks renders blocks, one line each
+func (r *Renderer) RenderBlocks(blocks []models.Block) string {
+	return r.renderBlocks(blocks, "")
+}
+
+// renderBlocks renders blocks, following each with its history in the style.
+// Footnotes are listed after the last block.
+func (r *Renderer) renderBlocks(blocks []models.Block, history models.HistoryStyle) string {
+	var md, notes strings.Builder
+	footnotes := 0
+
+	for i, block := range blocks {
+		if block.Type == models.BlockDivider && i > 0 && !strings.HasSuffix(md.String(), "\n\n") {
+			// Separate the rule from the previous line so it isn't read as a setext heading
+			md.WriteString("\n")
+		}
+		line := r.RenderBlock(block)
+		if block.History != nil && history != "" {
+			var note string
+			switch history {
+			case models.HistoryComment:
+				note = "<!-- " + block.History.String() + " -->"
+			case models.HistoryFootnote:
+				footnotes++
+				note = fmt.Sprintf("[^history-%d]", footnotes)
+				notes.WriteString(fmt.Sprintf("[^history-%d]: %s\n", footnotes, block.History))
+			}
+			if textBlock(block) {
+				line += " " + note
+			} else {
+				// Anything after a closing fence or a rule would change what it is
+				line += "\n" + note
+			}
+		}
+		md.WriteString(line + "\n")
+	}
+	if notes.Len() > 0 {
+		md.WriteString("\n" + notes.String())
+	}
+
+	return md.String()
+}
+
+// textBlock reports whether the block renders as a single line of text that
+// more text can follow
+func textBlock(block models.Block) bool {
+	switch block.Type {
+	case models.BlockCode, models.BlockEquation, models.BlockDivider, models.BlockEmbed, models.BlockAttachment:
+		return false
+	}
+	return true
+}
+
+// RenderBlock renders a single block
+func (r *Renderer) RenderBlock(block models.Block) string {
+	indent := strings.Repeat("  ", block.Indent)
+
+	switch block.Type {
+	case models.BlockHeading:
+		return strings.Repeat("#", block.Level) + " " + r.RenderInline(block.Inline)
+	case models.BlockBullet:
+		return indent + "- " + r.RenderInline(block.Inline)
+	case models.BlockNumbered:
+		return fmt.Sprintf("%s%d. %s", indent, block.Number, r.RenderInline(block.Inline))
+	case models.BlockToDo:
+		marker := "[ ]"
+		if block.Checked {
+			marker = "[x]"
+		}
+		return indent + "- " + marker + " " + r.RenderInline(block.Inline)
+	case models.BlockCode:
+		return fmt.Sprintf("```%s\n%s\n```", block.Language, block.Text)
+	case models.BlockEquation:
+		return "$$" + block.Text + "$$"
+	case models.BlockDivider:
+		return "---"
+	case models.BlockQuote:
+		return "> " + r.RenderInline(block.Inline)
+	case models.BlockCallout:
+		return "> " + block.Icon + " " + r.RenderInline(block.Inline)
+	case models.BlockEmbed:
+		if r.Embed != nil {
+			return r.Embed(block.Embed)
+		}
+		if player := block.Embed.PlayerURL(); player != "" {
+			return fmt.Sprintf(`<iframe src="%s" width="560" height="315" frameborder="0" allowfullscreen></iframe>`, player)
+		}
+		return r.RenderInline(block.Inline)
+	case models.BlockAttachment:
+		switch block.Attachment.Kind {
+		case models.AttachmentAudio:
+			return fmt.Sprintf(`<audio controls src="%s"></audio>`, block.Attachment.URL)
+		case models.AttachmentVideo:
+			return fmt.Sprintf(`<video controls src="%s"></video>`, block.Attachment.URL)
+		}
+		return r.RenderInline(block.Inline)
+	default:
+		return r.RenderInline(block.Inline)
+	}
+}
+
+// RenderInline renders inline spans
+func (r *Renderer) RenderInline(inlines []models.Inline) string {
+	var md strings.Builder
+	for _, inline := range inlines {
+		switch inline.Type {
+		case models.InlineBold:
+			md.WriteString("**" + r.RenderInline(inline.Children) + "**")
+		case models.InlineItalic:
+			md.WriteString("_" + r.RenderInline(inline.Children) + "_")
+		case models.InlineStrike:
+			md.WriteString("~~" + r.RenderInline(inline.Children) + "~~")
+		case models.InlineCode:
+			md.WriteString("`" + inline.Text + "`")
+		case models.InlineMath:
+			md.WriteString("$" + inline.Text + "$")
+		case models.InlinePageLink:
+			switch {
+			case r.PageLink != nil:
+				md.WriteString(r.PageLink(inline))
+			case inline.URL == "":
+				// Keep links to unknown pages as they were written
+				md.WriteString("[" + inline.Text + "]")
+			default:
+				md.WriteString(fmt.Sprintf("[%s](./%s.md)", inline.Text, inline.URL))
+			}
+		case models.InlineAnchor:
+			if r.Anchor != nil {
+				md.WriteString(r.Anchor(inline))
+			} else {
+				md.WriteString(fmt.Sprintf("[%s](#%s)", inline.Text, headingAnchor(inline.URL)))
+			}
+		case models.InlineLink:
+			label := inline.Text
+			switch {
+			case len(inline.Children) > 0:
+				label = r.RenderInline(inline.Children)
+			case label == "":
+				label = inline.URL
+			}
+			md.WriteString(fmt.Sprintf("[%s](%s)", label, inline.URL))
+		case models.InlineImage:
+			if r.Image != nil {
+				md.WriteString(r.Image(inline.URL))
+			} else {
+				md.WriteString(fmt.Sprintf("![image](%s)", inline.URL))
+			}
+		default:
+			md.WriteString(inline.Text)
+		}
+	}
+	return md.String()
+}
+
+// RenderIndex renders an index page listing the titles of every group under a
+// heading for its tag. path returns the path of the file of a title listed under
+// a tag, relative to the index. A nil path links to files named after the titles.
+func RenderIndex(groups []models.TagGroup, path func(title, tag string) string) string {
+	if path == nil {
+		path = func(title, tag string) string {
+			return title + ".md"
+		}
+	}
+
+	var md strings.Builder
+
+	md.WriteString("# Index\n")
+	for _, group := range groups {
+		tag := "#" + group.Tag
+		if group.Tag == "" {
+			tag = "Untagged"
+		}
+		md.WriteString(fmt.Sprintf("\n## %s\n\n", tag))
+		for _, title := range group.Titles {
+			md.WriteString(fmt.Sprintf("- [%s](./%s)\n", title, escapePath(path(title, group.Tag))))
+		}
+	}
+
+	return md.String()
+}
+
+// escapePath escapes each element of a slash separated path for use in a link
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
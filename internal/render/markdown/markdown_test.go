@@ -0,0 +1,182 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func TestRenderIndex(t *testing.T) {
+	docs := []*models.Document{
+		{Title: "Untagged Page"},
+		{Title: "Go Tips", Tags: []string{"go", "tips"}},
+		{Title: "Generics", Tags: []string{"go"}},
+	}
+
+	expected := "# Index\n" +
+		"\n## #go\n\n- [Go Tips](./Go%20Tips.md)\n- [Generics](./Generics.md)\n" +
+		"\n## #tips\n\n- [Go Tips](./Go%20Tips.md)\n" +
+		"\n## Untagged\n\n- [Untagged Page](./Untagged%20Page.md)\n"
+
+	if result := RenderIndex(models.GroupByTag(docs), nil); result != expected {
+		t.Errorf("RenderIndex() = %q, want %q", result, expected)
+	}
+
+	byTag := func(title, tag string) string {
+		if tag == "" {
+			return title + ".md"
+		}
+		return tag + "/" + title + ".md"
+	}
+	expected = "# Index\n" +
+		"\n## #go\n\n- [Go Tips](./go/Go%20Tips.md)\n- [Generics](./go/Generics.md)\n" +
+		"\n## #tips\n\n- [Go Tips](./tips/Go%20Tips.md)\n" +
+		"\n## Untagged\n\n- [Untagged Page](./Untagged%20Page.md)\n"
+	if result := RenderIndex(models.GroupByTag(docs), byTag); result != expected {
+		t.Errorf("RenderIndex() = %q, want %q", result, expected)
+	}
+}
+
+func TestRenderEmbed(t *testing.T) {
+	embed := func(provider models.EmbedProvider, id, url string) models.Block {
+		return models.Block{
+			Type:   models.BlockEmbed,
+			Embed:  &models.Embed{Provider: provider, ID: id, URL: url},
+			Inline: []models.Inline{{Type: models.InlineLink, URL: url}},
+		}
+	}
+
+	tests := map[string]struct {
+		block    models.Block
+		expected string
+	}{
+		"Video": {
+			block:    embed(models.EmbedVimeo, "76979871", "https://vimeo.com/76979871"),
+			expected: `<iframe src="https://player.vimeo.com/video/76979871" width="560" height="315" frameborder="0" allowfullscreen></iframe>`,
+		},
+		"Post": {
+			block:    embed(models.EmbedTwitter, "1", "https://x.com/golang/status/1"),
+			expected: "[https://x.com/golang/status/1](https://x.com/golang/status/1)",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if result := RenderBlock(tt.block); result != tt.expected {
+				t.Errorf("RenderBlock() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderFooter(t *testing.T) {
+	doc := &models.Document{
+		Title:  "Page",
+		Blocks: []models.Block{{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineText, Text: "text"}}}},
+		Footer: &models.Footer{Project: "project", URL: "https://scrapbox.io/project/Page", Version: "v1.0.0"},
+	}
+	expected := "# Page\n\ntext\n\n---\n\n_Source: [project](https://scrapbox.io/project/Page) · Migrated with scrapbox2notion v1.0.0_\n"
+	if result := Render(doc); result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+
+	doc.Footer = nil
+	doc.SourceURL = "https://scrapbox.io/project/Page"
+	if result := Render(doc); result != "---\nsource: https://scrapbox.io/project/Page\n---\n\n# Page\n\ntext\n" {
+		t.Errorf("Expected the Scrapbox URL in the front matter, got %q", result)
+	}
+}
+
+func TestRenderAttachment(t *testing.T) {
+	attachment := func(kind models.AttachmentKind, url string) models.Block {
+		return models.Block{
+			Type:       models.BlockAttachment,
+			Attachment: &models.Attachment{Kind: kind, URL: url},
+			Inline:     []models.Inline{{Type: models.InlineLink, URL: url}},
+		}
+	}
+
+	tests := map[string]struct {
+		block    models.Block
+		expected string
+	}{
+		"Audio": {
+			block:    attachment(models.AttachmentAudio, "https://example.com/talk.mp3"),
+			expected: `<audio controls src="https://example.com/talk.mp3"></audio>`,
+		},
+		"Video": {
+			block:    attachment(models.AttachmentVideo, "https://example.com/demo.mp4"),
+			expected: `<video controls src="https://example.com/demo.mp4"></video>`,
+		},
+		"PDF": {
+			block:    attachment(models.AttachmentPDF, "https://example.com/slides.pdf"),
+			expected: "[https://example.com/slides.pdf](https://example.com/slides.pdf)",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if result := RenderBlock(tt.block); result != tt.expected {
+				t.Errorf("RenderBlock() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderAnchor(t *testing.T) {
+	inlines := []models.Inline{{Type: models.InlineText, Text: "See "}, {Type: models.InlineAnchor, Text: "setup", URL: "Getting Started!"}}
+	if result := RenderInline(inlines); result != "See [setup](#getting-started)" {
+		t.Errorf("RenderInline() = %q", result)
+	}
+}
+
+func TestRenderHistory(t *testing.T) {
+	history := &models.LineHistory{Author: "alice", Updated: 1681398816}
+	doc := &models.Document{
+		Title: "Page",
+		Blocks: []models.Block{
+			{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineText, Text: "text"}}, History: history},
+			{Type: models.BlockCode, Text: "code", History: history},
+		},
+	}
+
+	tests := map[models.HistoryStyle]string{
+		"": "# Page\n\ntext\n```\ncode\n```\n",
+		models.HistoryComment: "# Page\n\ntext <!-- alice, 2023-04-13 15:13 UTC -->\n" +
+			"```\ncode\n```\n<!-- alice, 2023-04-13 15:13 UTC -->\n",
+		models.HistoryFootnote: "# Page\n\ntext [^history-1]\n```\ncode\n```\n[^history-2]\n" +
+			"\n[^history-1]: alice, 2023-04-13 15:13 UTC\n[^history-2]: alice, 2023-04-13 15:13 UTC\n",
+	}
+	for style, expected := range tests {
+		doc.History = style
+		if result := Render(doc); result != expected {
+			t.Errorf("Render() with history %q = %q, want %q", style, result, expected)
+		}
+	}
+}
+
+func TestRenderTableOfContents(t *testing.T) {
+	heading := func(level int, text string) models.Block {
+		return models.Block{Type: models.BlockHeading, Level: level, Inline: []models.Inline{{Type: models.InlineText, Text: text}}}
+	}
+	doc := &models.Document{
+		Title:           "Page",
+		TableOfContents: true,
+		Blocks: []models.Block{
+			heading(2, "Getting Started!"),
+			heading(3, "Install [Go]"),
+			heading(3, "日本語 メモ"),
+			heading(2, "Getting started"),
+		},
+	}
+
+	expected := "# Page\n\n" +
+		"- [Getting Started!](#getting-started)\n" +
+		"  - [Install \\[Go\\]](#install-go)\n" +
+		"  - [日本語 メモ](#日本語-メモ)\n" +
+		"- [Getting started](#getting-started-1)\n" +
+		"\n## Getting Started!\n### Install [Go]\n### 日本語 メモ\n## Getting started\n"
+	if result := Render(doc); result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+}
@@ -0,0 +1,290 @@
+// Package obsidian writes documents as notes of an Obsidian vault.
+package obsidian
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/pathsafe"
+	"github.com/takak2166/scrapbox2notion/internal/render/markdown"
+)
+
+// AssetsDir is the vault folder images are downloaded to
+const AssetsDir = "assets"
+
+// Vault writes notes and their attachments into a vault directory
+type Vault struct {
+	dir    string
+	client *http.Client
+	// mu guards assets and names, so notes can be written at the same time.
+	// It isn't held during downloads.
+	mu sync.Mutex
+	// assets maps image URLs to their download
+	assets map[string]*attachment
+	// names holds the asset file names in use
+	names map[string]bool
+	// fileOpts are the options notes and attachments are written with
+	fileOpts []atomicfile.Option
+	// namer shortens the file names of long titles and attachments
+	namer pathsafe.Namer
+}
+
+// attachment is an image downloaded once for every note embedding it
+type attachment struct {
+	once sync.Once
+	// path is the path of the image in the vault, empty when the download failed
+	path string
+}
+
+// Option configures optional behavior of the Vault
+type Option func(*Vault)
+
+// WithHTTPClient sets the HTTP client used to download attachments
+func WithHTTPClient(client *http.Client) Option {
+	return func(v *Vault) {
+		v.client = client
+	}
+}
+
+// WithFileOptions sets the options notes and attachments are written with, such as
+// atomicfile.SkipUnchanged
+func WithFileOptions(opts ...atomicfile.Option) Option {
+	return func(v *Vault) {
+		v.fileOpts = opts
+	}
+}
+
+// WithMaxNameBytes shortens the names of notes and attachments longer than
+// maxBytes, links to them included
+func WithMaxNameBytes(maxBytes int) Option {
+	return func(v *Vault) {
+		v.namer = pathsafe.NewNamer(maxBytes)
+	}
+}
+
+// NewVault creates a Vault writing into dir
+func NewVault(dir string, opts ...Option) *Vault {
+	v := &Vault{
+		dir:    dir,
+		client: http.DefaultClient,
+		assets: make(map[string]*attachment),
+		names:  make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Write downloads the images of the document into the assets folder and writes
+// it as a note. Titles containing slashes are written into sub folders.
+// It returns the path of the written note.
+func (v *Vault) Write(doc *models.Document) (string, error) {
+	assets := make(map[string]string)
+	for _, block := range doc.Blocks {
+		v.downloadImages(block.Inline, assets)
+	}
+	content := render(doc, assets, v.namer)
+
+	notePath := filepath.Join(v.dir, filepath.FromSlash(notePath(doc.Title, v.namer))+".md")
+	if err := os.MkdirAll(filepath.Dir(notePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create note folder: %w", err)
+	}
+	if err := atomicfile.WriteFile(notePath, []byte(content), 0644, v.fileOpts...); err != nil {
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+	return notePath, nil
+}
+
+// downloadImages downloads the images in inline spans into the vault and adds
+// their paths to assets
+func (v *Vault) downloadImages(inlines []models.Inline, assets map[string]string) {
+	for _, inline := range inlines {
+		if inline.Type == models.InlineImage {
+			if asset := v.attachment(inline.URL); asset != "" {
+				assets[inline.URL] = asset
+			}
+		}
+		v.downloadImages(inline.Children, assets)
+	}
+}
+
+// attachment returns the path in the vault of the image at rawURL, downloading
+// it the first time, or an empty path when it could not be downloaded. Notes
+// embedding the image at the same time wait for the same download.
+func (v *Vault) attachment(rawURL string) string {
+	v.mu.Lock()
+	a, ok := v.assets[rawURL]
+	if !ok {
+		a = &attachment{}
+		v.assets[rawURL] = a
+	}
+	v.mu.Unlock()
+
+	a.once.Do(func() {
+		asset, err := v.download(rawURL)
+		if err != nil {
+			// Keep linking to the remote image
+			logger.Error("Failed to download attachment", err, map[string]interface{}{
+				"url": rawURL,
+			})
+			return
+		}
+		a.path = asset
+	})
+	return a.path
+}
+
+// download saves the image at rawURL into the assets folder and returns its
+// path in the vault
+func (v *Vault) download(rawURL string) (string, error) {
+	resp, err := v.client.Get(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch image: %s", resp.Status)
+	}
+
+	dir := filepath.Join(v.dir, AssetsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create assets folder: %w", err)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch image: %w", err)
+	}
+	name := v.assetName(rawURL)
+	if err := atomicfile.WriteFile(filepath.Join(dir, name), data, 0644, v.fileOpts...); err != nil {
+		return "", fmt.Errorf("failed to write attachment: %w", err)
+	}
+	return AssetsDir + "/" + name, nil
+}
+
+// assetName claims an unused file name for the image at rawURL
+func (v *Vault) assetName(rawURL string) string {
+	name := "image"
+	if u, err := url.Parse(rawURL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = sanitize(path.Base(u.Path), v.namer)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; v.names[name]; n++ {
+		name = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	v.names[name] = true
+	return name
+}
+
+// Render renders a document as an Obsidian note with its tags and Scrapbox URL
+// in the front matter.
+// Images found in assets are embedded from the vault, others are linked by URL.
+func Render(doc *models.Document, assets map[string]string) string {
+	return render(doc, assets, pathsafe.Namer{})
+}
+
+// render renders a document as an Obsidian note, linking to notes by the file
+// names of names
+func render(doc *models.Document, assets map[string]string, names pathsafe.Namer) string {
+	r := &markdown.Renderer{
+		PageLink: func(link models.Inline) string {
+			return wikilink(link, names)
+		},
+		Anchor: headingLink,
+		Image: func(url string) string {
+			if asset, ok := assets[url]; ok {
+				return "![[" + asset + "]]"
+			}
+			return fmt.Sprintf("![](%s)", url)
+		},
+		// Obsidian embeds videos and posts written as external images
+		Embed: func(embed *models.Embed) string {
+			return fmt.Sprintf("![](%s)", embed.URL)
+		},
+	}
+
+	var note strings.Builder
+	if len(doc.Tags) > 0 || doc.SourceURL != "" {
+		note.WriteString("---\n")
+		if doc.SourceURL != "" {
+			note.WriteString(fmt.Sprintf("source: %s\n", doc.SourceURL))
+		}
+		if len(doc.Tags) > 0 {
+			// Obsidian reads front matter tags without the leading #
+			note.WriteString("tags:\n")
+			for _, tag := range doc.Tags {
+				note.WriteString(fmt.Sprintf("  - %s\n", tag))
+			}
+		}
+		note.WriteString("---\n\n")
+	}
+	note.WriteString(r.RenderBody(doc))
+	return note.String()
+}
+
+// wikilink renders a page link as [[Page Title]], aliasing it when the note
+// name differs from the title
+func wikilink(link models.Inline, names pathsafe.Namer) string {
+	target := notePath(link.Text, names)
+	if target == link.Text {
+		return "[[" + target + "]]"
+	}
+	return "[[" + target + "|" + link.Text + "]]"
+}
+
+// headingLink renders a link to a heading of the note as [[#Heading|label]]
+func headingLink(anchor models.Inline) string {
+	if anchor.Text == anchor.URL {
+		return "[[#" + anchor.URL + "]]"
+	}
+	return "[[#" + anchor.URL + "|" + anchor.Text + "]]"
+}
+
+// NotePath returns the path of the note for a title relative to the vault,
+// without the .md extension. Slashes separate folders.
+func NotePath(title string) string {
+	return notePath(title, pathsafe.Namer{})
+}
+
+// notePath returns the path of the note for a title, shortened by names
+func notePath(title string, names pathsafe.Namer) string {
+	parts := strings.Split(title, "/")
+	for i, part := range parts {
+		parts[i] = sanitize(part, names)
+	}
+	// Leave room for the extension in the name of the note
+	last := len(parts) - 1
+	parts[last] = strings.TrimSuffix(names.Name(parts[last], ".md"), ".md")
+	return strings.Join(parts, "/")
+}
+
+// sanitize replaces characters Obsidian does not allow in file names and
+// shortens them by names
+func sanitize(name string, names pathsafe.Namer) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\:*?"<>|#^[]`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return names.Name(name, "")
+}
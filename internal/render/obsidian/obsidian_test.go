@@ -0,0 +1,158 @@
+package obsidian
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	doc := &models.Document{
+		Title: "Page",
+		Tags:  []string{"go", "notes"},
+		Blocks: []models.Block{
+			{Type: models.BlockParagraph, Inline: []models.Inline{
+				{Type: models.InlineText, Text: "See "},
+				{Type: models.InlinePageLink, Text: "Other Page", URL: "other_page"},
+				{Type: models.InlineText, Text: " and "},
+				{Type: models.InlinePageLink, Text: "a:b"},
+			}},
+			{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineImage, URL: "https://example.com/a.png"}}},
+			{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineImage, URL: "https://example.com/b.png"}}},
+		},
+	}
+
+	expected := "---\ntags:\n  - go\n  - notes\n---\n\n" +
+		"See [[Other Page]] and [[a_b|a:b]]\n" +
+		"![[assets/a.png]]\n" +
+		"![](https://example.com/b.png)\n"
+
+	result := Render(doc, map[string]string{"https://example.com/a.png": "assets/a.png"})
+	if result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+
+	doc.SourceURL = "https://scrapbox.io/project/Page"
+	if result := Render(doc, nil); !strings.HasPrefix(result, "---\nsource: https://scrapbox.io/project/Page\ntags:\n") {
+		t.Errorf("Expected the Scrapbox URL in the front matter, got %q", result)
+	}
+}
+
+func TestNotePath(t *testing.T) {
+	tests := map[string]string{
+		"Page":          "Page",
+		"Project/Notes": "Project/Notes",
+		"What? Why:":    "What_ Why_",
+		"a/../b":        "a/_/b",
+	}
+	for title, expected := range tests {
+		if result := NotePath(title); result != expected {
+			t.Errorf("NotePath(%q) = %q, want %q", title, result, expected)
+		}
+	}
+}
+
+func TestVaultWrite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	image := func(url string) models.Block {
+		return models.Block{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineImage, URL: url}}}
+	}
+
+	dir := t.TempDir()
+	vault := NewVault(dir, WithHTTPClient(server.Client()))
+
+	notePath, err := vault.Write(&models.Document{
+		Title: "Project/Notes",
+		Blocks: []models.Block{
+			image(server.URL + "/a/image.png"),
+			image(server.URL + "/b/image.png"),
+			image(server.URL + "/missing.png"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if notePath != filepath.Join(dir, "Project", "Notes.md") {
+		t.Errorf("Expected note in sub folder, got %s", notePath)
+	}
+
+	content, err := os.ReadFile(notePath)
+	if err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	expected := "![[assets/image.png]]\n![[assets/image-2.png]]\n![](" + server.URL + "/missing.png)\n"
+	if string(content) != expected {
+		t.Errorf("Note = %q, want %q", content, expected)
+	}
+
+	for _, name := range []string{"image.png", "image-2.png"} {
+		if _, err := os.Stat(filepath.Join(dir, AssetsDir, name)); err != nil {
+			t.Errorf("Expected attachment %s: %v", name, err)
+		}
+	}
+}
+
+func TestVaultWriteConcurrently(t *testing.T) {
+	// Each image is only served once both notes are downloading, so the test
+	// hangs if downloads are serialized
+	var requests sync.Map
+	var both sync.WaitGroup
+	both.Add(2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, loaded := requests.LoadOrStore(r.URL.Path, true); loaded {
+			t.Errorf("Expected %s to be downloaded once", r.URL.Path)
+		}
+		if r.URL.Path != "/shared.png" {
+			both.Done()
+			both.Wait()
+		}
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	image := func(url string) models.Block {
+		return models.Block{Type: models.BlockParagraph, Inline: []models.Inline{{Type: models.InlineImage, URL: url}}}
+	}
+
+	dir := t.TempDir()
+	vault := NewVault(dir, WithHTTPClient(server.Client()))
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			notePath, err := vault.Write(&models.Document{
+				Title:  name,
+				Blocks: []models.Block{image(server.URL + "/" + name + ".png"), image(server.URL + "/shared.png")},
+			})
+			if err != nil {
+				t.Errorf("Write() error = %v", err)
+				return
+			}
+			content, err := os.ReadFile(notePath)
+			if err != nil {
+				t.Errorf("Failed to read note: %v", err)
+				return
+			}
+			expected := "![[assets/" + name + ".png]]\n![[assets/shared.png]]\n"
+			if string(content) != expected {
+				t.Errorf("Note = %q, want %q", content, expected)
+			}
+		}(name)
+	}
+	wg.Wait()
+}
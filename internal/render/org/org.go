@@ -0,0 +1,185 @@
+// Package org writes documents as Emacs org-mode files.
+package org
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/atomicfile"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/pathsafe"
+)
+
+// Writer writes each page as an .org file named after its title
+type Writer struct {
+	dir string
+	// fileOpts are the options org files are written with
+	fileOpts []atomicfile.Option
+	// names shortens the file names of long titles
+	names pathsafe.Namer
+}
+
+// Option configures optional behavior of the Writer
+type Option func(*Writer)
+
+// WithFileOptions sets the options org files are written with, such as
+// atomicfile.SkipUnchanged
+func WithFileOptions(opts ...atomicfile.Option) Option {
+	return func(w *Writer) {
+		w.fileOpts = opts
+	}
+}
+
+// WithMaxNameBytes shortens the names of the org files of titles longer than
+// maxBytes, links to them included
+func WithMaxNameBytes(maxBytes int) Option {
+	return func(w *Writer) {
+		w.names = pathsafe.NewNamer(maxBytes)
+	}
+}
+
+// NewWriter creates a Writer writing into dir
+func NewWriter(dir string, opts ...Option) *Writer {
+	w := &Writer{dir: dir}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Write writes the document as an org file and returns its path
+func (w *Writer) Write(doc *models.Document) (string, error) {
+	path := filepath.Join(w.dir, fileName(doc.Title, w.names))
+	if err := atomicfile.WriteFile(path, []byte(render(doc, w.names)), 0644, w.fileOpts...); err != nil {
+		return "", fmt.Errorf("failed to write org file: %w", err)
+	}
+	return path, nil
+}
+
+// Render renders a document as org-mode, with the title and tags as file keywords
+func Render(doc *models.Document) string {
+	return render(doc, pathsafe.Namer{})
+}
+
+// render renders a document as org-mode, linking to pages by the file names of names
+func render(doc *models.Document, names pathsafe.Namer) string {
+	var org strings.Builder
+
+	org.WriteString("#+TITLE: " + doc.Title + "\n")
+	if len(doc.Tags) > 0 {
+		org.WriteString("#+FILETAGS: :" + strings.Join(doc.Tags, ":") + ":\n")
+	}
+	org.WriteString("\n")
+
+	for _, block := range doc.Blocks {
+		org.WriteString(renderBlock(block, names) + "\n")
+	}
+
+	return org.String()
+}
+
+// RenderBlock renders a single block as org-mode
+func RenderBlock(block models.Block) string {
+	return renderBlock(block, pathsafe.Namer{})
+}
+
+// renderBlock renders a single block, linking to pages by the file names of names
+func renderBlock(block models.Block, names pathsafe.Namer) string {
+	indent := strings.Repeat("  ", block.Indent)
+
+	switch block.Type {
+	case models.BlockHeading:
+		return strings.Repeat("*", block.Level) + " " + renderInline(block.Inline, names)
+	case models.BlockBullet:
+		return indent + "- " + renderInline(block.Inline, names)
+	case models.BlockNumbered:
+		return fmt.Sprintf("%s%d. %s", indent, block.Number, renderInline(block.Inline, names))
+	case models.BlockToDo:
+		marker := "[ ]"
+		if block.Checked {
+			marker = "[X]"
+		}
+		return indent + "- " + marker + " " + renderInline(block.Inline, names)
+	case models.BlockCode:
+		return fmt.Sprintf("#+BEGIN_SRC %s\n%s\n#+END_SRC", block.Language, block.Text)
+	case models.BlockEquation:
+		return `\[` + block.Text + `\]`
+	case models.BlockDivider:
+		return "-----"
+	case models.BlockQuote:
+		return "#+BEGIN_QUOTE\n" + renderInline(block.Inline, names) + "\n#+END_QUOTE"
+	case models.BlockCallout:
+		return "#+BEGIN_QUOTE\n" + block.Icon + " " + renderInline(block.Inline, names) + "\n#+END_QUOTE"
+	default:
+		return renderInline(block.Inline, names)
+	}
+}
+
+// RenderInline renders inline spans as org-mode
+func RenderInline(inlines []models.Inline) string {
+	return renderInline(inlines, pathsafe.Namer{})
+}
+
+// renderInline renders inline spans, linking to pages by the file names of names
+func renderInline(inlines []models.Inline, names pathsafe.Namer) string {
+	var org strings.Builder
+	for _, inline := range inlines {
+		switch inline.Type {
+		case models.InlineBold:
+			org.WriteString("*" + renderInline(inline.Children, names) + "*")
+		case models.InlineItalic:
+			org.WriteString("/" + renderInline(inline.Children, names) + "/")
+		case models.InlineStrike:
+			org.WriteString("+" + renderInline(inline.Children, names) + "+")
+		case models.InlineCode:
+			org.WriteString("~" + inline.Text + "~")
+		case models.InlineMath:
+			org.WriteString(`\(` + inline.Text + `\)`)
+		case models.InlinePageLink:
+			if inline.URL == "" {
+				// Pages missing from the export have no file to link to
+				org.WriteString(inline.Text)
+			} else {
+				org.WriteString(fmt.Sprintf("[[file:%s][%s]]", fileName(inline.Text, names), inline.Text))
+			}
+		case models.InlineAnchor:
+			org.WriteString(fmt.Sprintf("[[*%s][%s]]", inline.URL, inline.Text))
+		case models.InlineLink:
+			switch {
+			case len(inline.Children) > 0:
+				// An image URL as the description shows the image
+				org.WriteString(fmt.Sprintf("[[%s][%s]]", inline.URL, models.PlainText(inline.Children)))
+			case inline.Text == "":
+				org.WriteString("[[" + inline.URL + "]]")
+			default:
+				org.WriteString(fmt.Sprintf("[[%s][%s]]", inline.URL, inline.Text))
+			}
+		case models.InlineImage:
+			// Links without a description are shown inline as images
+			org.WriteString("[[" + inline.URL + "]]")
+		default:
+			org.WriteString(inline.Text)
+		}
+	}
+	return org.String()
+}
+
+// FileName returns the name of the org file for a title
+func FileName(title string) string {
+	return fileName(title, pathsafe.Namer{})
+}
+
+// fileName returns the name of the org file for a title, shortened by names
+func fileName(title string, names pathsafe.Namer) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|[]`, r) {
+			return '_'
+		}
+		return r
+	}, title)
+	if name == "" {
+		name = "_"
+	}
+	return names.Name(name, ".org")
+}
@@ -0,0 +1,46 @@
+package org
+
+import (
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	text := func(s string) []models.Inline {
+		return []models.Inline{{Type: models.InlineText, Text: s}}
+	}
+
+	doc := &models.Document{
+		Title: "Page",
+		Tags:  []string{"go", "notes"},
+		Blocks: []models.Block{
+			{Type: models.BlockHeading, Level: 2, Inline: text("Heading")},
+			{Type: models.BlockParagraph, Inline: []models.Inline{
+				{Type: models.InlineBold, Children: text("bold")},
+				{Type: models.InlineText, Text: " "},
+				{Type: models.InlineCode, Text: "code"},
+				{Type: models.InlineText, Text: " "},
+				{Type: models.InlinePageLink, Text: "Other Page", URL: "other_page"},
+				{Type: models.InlineText, Text: " "},
+				{Type: models.InlineLink, Text: "site", URL: "https://example.com"},
+			}},
+			{Type: models.BlockBullet, Indent: 1, Inline: text("nested")},
+			{Type: models.BlockToDo, Checked: true, Inline: text("done")},
+			{Type: models.BlockCode, Language: "go", Text: "a := 1"},
+			{Type: models.BlockEquation, Text: "E = mc^2"},
+		},
+	}
+
+	expected := "#+TITLE: Page\n#+FILETAGS: :go:notes:\n\n" +
+		"** Heading\n" +
+		"*bold* ~code~ [[file:Other Page.org][Other Page]] [[https://example.com][site]]\n" +
+		"  - nested\n" +
+		"- [X] done\n" +
+		"#+BEGIN_SRC go\na := 1\n#+END_SRC\n" +
+		"\\[E = mc^2\\]\n"
+
+	if result := Render(doc); result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+}
@@ -0,0 +1,159 @@
+// Package scrapboxapi fetches the pages of a Scrapbox project through the
+// Scrapbox API, for syncing a project without exporting it by hand.
+package scrapboxapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// defaultBaseURL is the Scrapbox API endpoint
+const defaultBaseURL = "https://scrapbox.io/api"
+
+// listLimit is the most pages the Scrapbox API lists per request
+const listLimit = 1000
+
+// Client fetches the pages of a Scrapbox project
+type Client struct {
+	project    string
+	baseURL    string
+	sessionID  string
+	httpClient *http.Client
+	// pageLimit is the number of pages listed per request
+	pageLimit int
+}
+
+// Option configures optional behavior of the Client
+type Option func(*Client)
+
+// WithSessionID authenticates with the connect.sid cookie of a logged in user,
+// needed for private projects. The cookie is kept out of the logs.
+func WithSessionID(sid string) Option {
+	return func(c *Client) {
+		c.sessionID = sid
+		logger.AddSecret(sid)
+	}
+}
+
+// WithBaseURL sends the requests to baseURL instead of the Scrapbox API, such as
+// to a mock
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sends the requests with the given HTTP client
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// New creates a client for the project with the given name
+func New(project string, opts ...Option) *Client {
+	c := &Client{
+		project:    project,
+		baseURL:    defaultBaseURL,
+		httpClient: http.DefaultClient,
+		pageLimit:  listLimit,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// PageSummary is a page as listed by the Scrapbox API, without its lines
+type PageSummary struct {
+	Title   string `json:"title"`
+	Updated int64  `json:"updated"`
+	// ID stays the same when the page is renamed
+	ID string `json:"id"`
+}
+
+// ListPages returns every page of the project, most recently updated first
+func (c *Client) ListPages(ctx context.Context) ([]PageSummary, error) {
+	var pages []PageSummary
+	for skip := 0; ; skip += c.pageLimit {
+		var resp struct {
+			Count int           `json:"count"`
+			Pages []PageSummary `json:"pages"`
+		}
+		path := fmt.Sprintf("/pages/%s?sort=updated&limit=%d&skip=%d", url.PathEscape(c.project), c.pageLimit, skip)
+		if err := c.get(ctx, path, &resp); err != nil {
+			return nil, fmt.Errorf("failed to list pages: %w", err)
+		}
+		pages = append(pages, resp.Pages...)
+		if len(resp.Pages) == 0 || len(pages) >= resp.Count {
+			return pages, nil
+		}
+	}
+}
+
+// GetPage returns the page with the title, with its lines
+func (c *Client) GetPage(ctx context.Context, title string) (*models.Page, error) {
+	var raw json.RawMessage
+	path := fmt.Sprintf("/pages/%s/%s", url.PathEscape(c.project), url.PathEscape(title))
+	if err := c.get(ctx, path, &raw); err != nil {
+		return nil, fmt.Errorf("failed to get page %q: %w", title, err)
+	}
+
+	// The page is decoded on its own, as embedding it would let its
+	// UnmarshalJSON decode the whole response and drop the links
+	var page models.Page
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return nil, fmt.Errorf("failed to decode page %q: %w", title, err)
+	}
+	var resp struct {
+		// Links are the titles of the pages the page links to
+		Links []string `json:"links"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode page %q: %w", title, err)
+	}
+	if page.LinksLc == nil {
+		for _, link := range resp.Links {
+			page.LinksLc = append(page.LinksLc, strings.ToLower(strings.ReplaceAll(link, " ", "_")))
+		}
+	}
+	return &page, nil
+}
+
+// get sends a GET request to the API and decodes the JSON response into v
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.sessionID != "" {
+		req.AddCookie(&http.Cookie{Name: "connect.sid", Value: c.sessionID})
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("access to project %s was denied, check SCRAPBOX_SID: %s", c.project, resp.Status)
+	case resp.StatusCode == http.StatusNotFound && c.sessionID == "":
+		return fmt.Errorf("project %s or the page was not found, set SCRAPBOX_SID if the project is private: %s", c.project, resp.Status)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("scrapbox API returned %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
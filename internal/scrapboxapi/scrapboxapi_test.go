@@ -0,0 +1,75 @@
+package scrapboxapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pages/my-project" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if cookie, err := r.Cookie("connect.sid"); err != nil || cookie.Value != "sid" {
+			t.Errorf("Expected the session cookie, got %v", cookie)
+		}
+		// Two pages per request, three pages in total
+		skip := r.URL.Query().Get("skip")
+		switch skip {
+		case "0":
+			fmt.Fprint(w, `{"count": 3, "pages": [{"title": "A", "updated": 3, "id": "a1"}, {"title": "B", "updated": 2, "id": "b2"}]}`)
+		default:
+			fmt.Fprint(w, `{"count": 3, "pages": [{"title": "C", "updated": 1, "id": "c3"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	c := New("my-project", WithBaseURL(server.URL), WithSessionID("sid"))
+	c.pageLimit = 2
+	pages, err := c.ListPages(context.Background())
+	if err != nil {
+		t.Fatalf("ListPages() error = %v", err)
+	}
+	if len(pages) != 3 || pages[0].Title != "A" || pages[2].Updated != 1 || pages[2].ID != "c3" {
+		t.Errorf("Expected the pages of both requests, got %+v", pages)
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/pages/my-project/Go%20Tips" {
+			t.Errorf("Unexpected path %s", r.URL.EscapedPath())
+		}
+		fmt.Fprint(w, `{"title": "Go Tips", "updated": 5, "views": 7,
+			"lines": [{"text": "Go Tips", "userId": "u1"}, {"text": "see [Other Page]"}],
+			"links": ["Other Page"]}`)
+	}))
+	defer server.Close()
+
+	page, err := New("my-project", WithBaseURL(server.URL)).GetPage(context.Background(), "Go Tips")
+	if err != nil {
+		t.Fatalf("GetPage() error = %v", err)
+	}
+	if page.Title != "Go Tips" || page.Views != 7 || len(page.Lines) != 2 || page.Lines[0].UserID != "u1" {
+		t.Errorf("Expected the page with its lines, got %+v", page)
+	}
+	if len(page.LinksLc) != 1 || page.LinksLc[0] != "other_page" {
+		t.Errorf("Expected the links as link IDs, got %v", page.LinksLc)
+	}
+}
+
+func TestGetPageNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	_, err := New("private", WithBaseURL(server.URL)).GetPage(context.Background(), "Page")
+	if err == nil || !strings.Contains(err.Error(), "SCRAPBOX_SID") {
+		t.Errorf("Expected an error pointing at SCRAPBOX_SID, got %v", err)
+	}
+}
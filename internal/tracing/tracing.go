@@ -0,0 +1,362 @@
+// Package tracing records spans of the migration pipeline and exports them to
+// an OpenTelemetry collector with the OTLP/HTTP JSON protocol. Tracing is off
+// unless Init is given an endpoint, in which case spans cost nothing. Like the
+// batch span processor of the OpenTelemetry SDK, ended spans are exported in
+// the background every few seconds or once enough of them are waiting, and
+// dropped beyond a bounded queue when the collector can't keep up.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/takak2166/scrapbox2notion/internal/logger"
+)
+
+// The defaults of the batch span processor of the OpenTelemetry SDK
+const (
+	// exportInterval is how often the ended spans are exported
+	exportInterval = 5 * time.Second
+	// maxBatchSize is the most spans exported in one request, and the number
+	// of waiting spans that triggers an export before the interval
+	maxBatchSize = 512
+	// maxQueueSize is the most ended spans waiting to be exported, beyond
+	// which spans are dropped
+	maxQueueSize = 2048
+)
+
+// Kind is the role of a span
+type Kind int
+
+const (
+	// KindInternal is an operation within the tool
+	KindInternal Kind = 1
+	// KindClient is a request to a remote service
+	KindClient Kind = 3
+)
+
+// Span is a timed operation, such as converting a page or a Notion API call
+type Span struct {
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     Kind
+	start    time.Time
+	end      time.Time
+	attrs    map[string]string
+	err      error
+}
+
+// Tracer queues the ended spans and exports them in the background
+type Tracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	mu    sync.Mutex
+	spans []*Span
+	// dropped is the number of spans dropped since the last export
+	dropped int
+
+	// flush asks the exporter to export before the interval
+	flush chan struct{}
+	// stop stops the exporter, which closes stopped once it returns
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// tracer is the tracer spans are recorded by, nil when tracing is off
+var tracer atomic.Pointer[Tracer]
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// Init turns tracing on, exporting spans to the OTLP/HTTP traces endpoint of a
+// collector, such as http://localhost:4318/v1/traces, under the service name.
+// A tracer already on is shut down first.
+func Init(endpoint, service string) {
+	t := &Tracer{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	if old := tracer.Swap(t); old != nil {
+		old.shutdown(context.Background())
+	}
+}
+
+// InitFromEnv turns tracing on if the standard OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// or OTEL_EXPORTER_OTLP_ENDPOINT environment variable is set. OTEL_SERVICE_NAME
+// overrides the service name, scrapbox2notion by default.
+func InitFromEnv() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "scrapbox2notion"
+	}
+	Init(endpoint, service)
+}
+
+// Start starts a span as a child of the span of ctx, if any, and returns a
+// context holding it. The span is nil when tracing is off.
+func Start(ctx context.Context, name string, kind Kind, attrs map[string]string) (context.Context, *Span) {
+	if tracer.Load() == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		spanID: randomHex(8),
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		span.traceID = randomHex(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// WithRemoteParent returns a context whose spans are children of the span of a
+// W3C traceparent value, such as one passed by the process that started this
+// one. An empty or invalid value leaves the context as it is.
+func WithRemoteParent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || !isHex(parts[1]) || !isHex(parts[2]) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, &Span{traceID: parts[1], spanID: parts[2]})
+}
+
+// Traceparent returns the W3C traceparent value of the span, to continue the
+// trace in another process, or an empty string when tracing is off
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", s.traceID, s.spanID)
+}
+
+// isHex reports whether s is lowercase hex, as trace and span IDs are
+func isHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// SetAttribute sets an attribute of the span
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	if s.attrs == nil {
+		s.attrs = make(map[string]string)
+	}
+	s.attrs[key] = value
+}
+
+// End ends the span, marking it failed if err is not nil
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	t := tracer.Load()
+	if t == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	t.enqueue(s)
+}
+
+// Shutdown exports the ended spans and turns tracing off
+func Shutdown(ctx context.Context) error {
+	t := tracer.Swap(nil)
+	if t == nil {
+		return nil
+	}
+	return t.shutdown(ctx)
+}
+
+// enqueue queues an ended span for export, dropping it when the queue is full,
+// and asks for an export once a batch is waiting
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	if len(t.spans) >= maxQueueSize {
+		t.dropped++
+	} else {
+		t.spans = append(t.spans, s)
+	}
+	full := len(t.spans) >= maxBatchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports the ended spans every interval, or sooner when asked, until the
+// tracer is stopped
+func (t *Tracer) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		case <-t.flush:
+		}
+		if err := t.export(context.Background()); err != nil {
+			logger.Warn("Failed to export traces", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// shutdown stops the exporter and exports the spans still queued
+func (t *Tracer) shutdown(ctx context.Context) error {
+	close(t.stop)
+	<-t.stopped
+	return t.export(ctx)
+}
+
+// export sends the queued spans to the collector as OTLP/HTTP JSON requests of
+// at most a batch each. Spans of a failed request are dropped, so a collector
+// that is down doesn't hold them in memory.
+func (t *Tracer) export(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		n := min(len(t.spans), maxBatchSize)
+		spans := t.spans[:n:n]
+		t.spans = t.spans[n:]
+		dropped := t.dropped
+		t.dropped = 0
+		t.mu.Unlock()
+		if dropped > 0 {
+			logger.Warn("Dropped spans the collector couldn't keep up with", map[string]interface{}{
+				"dropped": dropped,
+			})
+		}
+		if len(spans) == 0 {
+			return nil
+		}
+		if err := t.exportBatch(ctx, spans); err != nil {
+			return err
+		}
+	}
+}
+
+// exportBatch sends spans to the collector as an OTLP/HTTP JSON request
+func (t *Tracer) exportBatch(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("failed to export spans: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// request returns the OTLP export request of the spans
+func (t *Tracer) request(spans []*Span) map[string]interface{} {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        attributes(s.attrs),
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		if s.err != nil {
+			// Status code 2 is an error
+			span["status"] = map[string]interface{}{"code": 2, "message": s.err.Error()}
+		}
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": attributes(map[string]string{"service.name": t.service}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "scrapbox2notion"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// attributes returns OTLP string attributes
+func attributes(attrs map[string]string) []interface{} {
+	list := make([]interface{}, 0, len(attrs))
+	for key, value := range attrs {
+		list = append(list, map[string]interface{}{
+			"key":   key,
+			"value": map[string]interface{}{"stringValue": value},
+		})
+	}
+	return list
+}
+
+// randomHex returns n random bytes in hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "page", KindInternal, nil)
+	if span != nil || ctx.Value(spanKey{}) != nil {
+		t.Errorf("Expected no span when tracing is off, got %+v", span)
+	}
+	span.SetAttribute("key", "value")
+	span.End(nil)
+	if err := Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected an OTLP JSON request, got %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL+"/")
+	InitFromEnv()
+
+	ctx, run := Start(context.Background(), "migrate", KindInternal, nil)
+	_, page := Start(ctx, "page", KindInternal, map[string]string{"page.title": "Home"})
+	page.End(errors.New("upload failed"))
+	run.End(nil)
+	if page.traceID != run.traceID || page.parentID != run.spanID {
+		t.Errorf("Expected the page span to be a child of the run span")
+	}
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if tracer.Load() != nil {
+		t.Error("Expected tracing to be off after Shutdown")
+	}
+
+	resourceSpans := received["resourceSpans"].([]interface{})
+	scopeSpans := resourceSpans[0].(map[string]interface{})["scopeSpans"].([]interface{})
+	spans := scopeSpans[0].(map[string]interface{})["spans"].([]interface{})
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	first := spans[0].(map[string]interface{})
+	if first["name"] != "page" || first["parentSpanId"] != run.spanID {
+		t.Errorf("Expected the page span first, got %v", first)
+	}
+	status, ok := first["status"].(map[string]interface{})
+	if !ok || status["code"] != float64(2) || status["message"] != "upload failed" {
+		t.Errorf("Expected an error status, got %v", first["status"])
+	}
+}
+
+func TestExportBatches(t *testing.T) {
+	received := make(chan int, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []json.RawMessage `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- len(body.ResourceSpans[0].ScopeSpans[0].Spans)
+	}))
+	defer server.Close()
+
+	Init(server.URL+"/v1/traces", "test")
+	ctx := context.Background()
+	for i := 0; i < maxBatchSize+1; i++ {
+		_, span := Start(ctx, "page", KindInternal, nil)
+		span.End(nil)
+	}
+
+	// A full batch is exported without waiting for the interval or Shutdown
+	select {
+	case n := <-received:
+		if n != maxBatchSize {
+			t.Errorf("Expected a batch of %d spans, got %d", maxBatchSize, n)
+		}
+	case <-time.After(exportInterval / 2):
+		t.Fatal("Expected a full batch to be exported before the interval")
+	}
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if n := <-received; n != 1 {
+		t.Errorf("Expected the rest exported on Shutdown, got %d spans", n)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	parent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	ctx := WithRemoteParent(context.Background(), parent)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	Init(server.URL, "test")
+	defer Shutdown(ctx)
+
+	_, span := Start(ctx, "migrate", KindInternal, nil)
+	if span.traceID != "0af7651916cd43dd8448eb211c80319c" || span.parentID != "b7ad6b7169203331" {
+		t.Errorf("Expected the span to continue the remote trace, got trace %s parent %s", span.traceID, span.parentID)
+	}
+	if expected := "00-0af7651916cd43dd8448eb211c80319c-" + span.spanID + "-01"; span.Traceparent() != expected {
+		t.Errorf("Expected traceparent %s, got %s", expected, span.Traceparent())
+	}
+
+	for _, invalid := range []string{"", "00-xyz-b7ad6b7169203331-01", "garbage"} {
+		if ctx := WithRemoteParent(context.Background(), invalid); ctx.Value(spanKey{}) != nil {
+			t.Errorf("Expected no parent for %q", invalid)
+		}
+	}
+}
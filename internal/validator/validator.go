@@ -0,0 +1,163 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+)
+
+// MaxBlocksPerRequest is the number of children Notion accepts in a single request
+const MaxBlocksPerRequest = 100
+
+// Severity describes how serious a validation issue is
+type Severity string
+
+const (
+	// SeverityError marks issues that will make the migration fail or lose content
+	SeverityError Severity = "error"
+	// SeverityWarning marks issues that may lead to unexpected results
+	SeverityWarning Severity = "warning"
+	// SeverityInfo marks notes about how the page is migrated
+	SeverityInfo Severity = "info"
+)
+
+// Issue is a single problem found in the export
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Page     string   `json:"page,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// PageStats holds the estimated Notion size of a page
+type PageStats struct {
+	Title  string `json:"title"`
+	Lines  int    `json:"lines"`
+	Blocks int    `json:"blocks"`
+}
+
+// Report is the result of validating an export
+type Report struct {
+	Issues []Issue     `json:"issues"`
+	Pages  []PageStats `json:"pages"`
+}
+
+// HasErrors reports whether the report contains any error level issues
+func (r *Report) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) addIssue(severity Severity, page string, line int, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{
+		Severity: severity,
+		Page:     page,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// pathUnsafeChars are characters that cannot be used in file names on common platforms
+const pathUnsafeChars = `/\:*?"<>|`
+
+// Validate checks a Scrapbox JSON export against the expected schema and estimates
+// the number of Notion blocks of each page. It only returns an error if data is not JSON.
+func Validate(data []byte) (*Report, error) {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	report := &Report{}
+	for _, key := range []string{"name", "pages"} {
+		if _, ok := root[key]; !ok {
+			report.addIssue(SeverityError, "", 0, "missing required field %q", key)
+		}
+	}
+
+	var rawPages []map[string]json.RawMessage
+	if raw, ok := root["pages"]; ok {
+		if err := json.Unmarshal(raw, &rawPages); err != nil {
+			report.addIssue(SeverityError, "", 0, "pages is not a list of page objects: %v", err)
+			return report, nil
+		}
+	}
+
+	p := parser.New()
+	titles := make(map[string]bool)
+	for i, rawPage := range rawPages {
+		var page models.Page
+		encoded, _ := json.Marshal(rawPage)
+		if err := json.Unmarshal(encoded, &page); err != nil {
+			report.addIssue(SeverityError, fmt.Sprintf("#%d", i+1), 0, "page cannot be decoded: %v", err)
+			continue
+		}
+
+		name := page.Title
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		for _, key := range []string{"title", "lines"} {
+			if _, ok := rawPage[key]; !ok {
+				report.addIssue(SeverityError, name, 0, "missing required field %q", key)
+			}
+		}
+		for _, key := range []string{"id", "created", "updated"} {
+			if _, ok := rawPage[key]; !ok {
+				report.addIssue(SeverityWarning, name, 0, "missing field %q", key)
+			}
+		}
+
+		validateTitle(report, name, page.Title, titles)
+		doc := p.ParseDocument(&page)
+		for _, warning := range doc.Warnings {
+			report.addIssue(SeverityWarning, name, warning.Line, "%s: %q", warning.Reason, warning.Text)
+		}
+
+		blocks := notion.CountBlocks(doc)
+		if blocks > MaxBlocksPerRequest {
+			report.addIssue(SeverityInfo, name, 0, "page converts to %d blocks, more than the %d Notion accepts in one request, and is uploaded in several", blocks, MaxBlocksPerRequest)
+		}
+		report.Pages = append(report.Pages, PageStats{
+			Title:  page.Title,
+			Lines:  len(page.Lines),
+			Blocks: blocks,
+		})
+	}
+
+	return report, nil
+}
+
+// validateTitle reports empty, duplicate and path-unsafe titles
+func validateTitle(report *Report, name, title string, titles map[string]bool) {
+	switch {
+	case strings.TrimSpace(title) == "":
+		report.addIssue(SeverityError, name, 0, "title is empty")
+		return
+	case titles[title]:
+		report.addIssue(SeverityError, name, 0, "duplicate title")
+	}
+	titles[title] = true
+
+	if strings.ContainsAny(title, pathUnsafeChars) {
+		report.addIssue(SeverityWarning, name, 0, "title contains characters that are unsafe in file names (%s)", pathUnsafeChars)
+	}
+	if title != strings.TrimSpace(title) || strings.HasSuffix(title, ".") {
+		report.addIssue(SeverityWarning, name, 0, "title has leading or trailing spaces or a trailing dot")
+	}
+	for _, r := range title {
+		if r < 0x20 || r == 0x7f {
+			report.addIssue(SeverityWarning, name, 0, "title contains control characters")
+			break
+		}
+	}
+}
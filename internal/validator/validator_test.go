@@ -0,0 +1,107 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		content          string
+		expectErrors     bool
+		expectedMessages []string
+	}{
+		"Valid export": {
+			content: `{"name": "test", "pages": [
+				{"id": "1", "title": "Page", "created": 1, "updated": 1, "lines": [{"text": "Page"}, {"text": "[* bold]"}]}
+			]}`,
+			expectErrors: false,
+		},
+		"Missing required fields": {
+			content:          `{"displayName": "test"}`,
+			expectErrors:     true,
+			expectedMessages: []string{`missing required field "name"`, `missing required field "pages"`},
+		},
+		"Missing page fields": {
+			content:          `{"name": "test", "pages": [{"title": "Page"}]}`,
+			expectErrors:     true,
+			expectedMessages: []string{`missing required field "lines"`, `missing field "id"`},
+		},
+		"Suspicious titles": {
+			content: `{"name": "test", "pages": [
+				{"id": "1", "title": "", "created": 1, "updated": 1, "lines": []},
+				{"id": "2", "title": "a/b", "created": 1, "updated": 1, "lines": []},
+				{"id": "3", "title": "a/b", "created": 1, "updated": 1, "lines": []}
+			]}`,
+			expectErrors:     true,
+			expectedMessages: []string{"title is empty", "duplicate title", "unsafe in file names"},
+		},
+		"Unbalanced brackets": {
+			content: `{"name": "test", "pages": [
+				{"id": "1", "title": "Page", "created": 1, "updated": 1, "lines": [{"text": "Page"}, {"text": "[* broken"}, {"text": "` + "`[literal`" + `"}]}
+			]}`,
+			expectErrors:     false,
+			expectedMessages: []string{"unbalanced brackets"},
+		},
+		"Pages is not a list": {
+			content:          `{"name": "test", "pages": "none"}`,
+			expectErrors:     true,
+			expectedMessages: []string{"pages is not a list"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			report, err := Validate([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if report.HasErrors() != tt.expectErrors {
+				t.Errorf("Expected HasErrors() = %v, got issues %+v", tt.expectErrors, report.Issues)
+			}
+			for _, message := range tt.expectedMessages {
+				found := false
+				for _, issue := range report.Issues {
+					if strings.Contains(issue.Message, message) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("Expected issue containing %q, got %+v", message, report.Issues)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBlockCount(t *testing.T) {
+	var lines []string
+	lines = append(lines, `{"text": "Long"}`)
+	for i := 0; i < 150; i++ {
+		lines = append(lines, fmt.Sprintf(`{"text": "line %d"}`, i))
+	}
+	content := `{"name": "test", "pages": [{"id": "1", "title": "Long", "created": 1, "updated": 1, "lines": [` + strings.Join(lines, ",") + `]}]}`
+
+	report, err := Validate([]byte(content))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(report.Pages) != 1 || report.Pages[0].Blocks != 150 {
+		t.Fatalf("Expected 150 blocks, got %+v", report.Pages)
+	}
+	// Pages are uploaded in several requests, so a long page is no error
+	if report.HasErrors() {
+		t.Errorf("Expected no error for a page exceeding the block limit, got %+v", report.Issues)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Severity != SeverityInfo || !strings.Contains(report.Issues[0].Message, "uploaded in several") {
+		t.Errorf("Expected a note on the requests of the page, got %+v", report.Issues)
+	}
+}
+
+func TestValidateInvalidJSON(t *testing.T) {
+	if _, err := Validate([]byte("not json")); err == nil {
+		t.Error("Expected error for invalid JSON, got nil")
+	}
+}
@@ -0,0 +1,70 @@
+// Package docmodel defines the documents of the public packages, which hold a
+// document model that is not part of the public API.
+package docmodel
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+)
+
+// ErrInvalidDocument is returned for a document that was not converted from a page
+var ErrInvalidDocument = errors.New("document was not converted from a page: use scrapbox.NewDocument or Reader.Document")
+
+// Document is a page converted to the format independent representation that
+// the markdown and notion packages render. Its zero value holds no page.
+type Document struct {
+	model *models.Document
+}
+
+// Warning is a line of a page that may not have converted as written
+type Warning struct {
+	// Line is the 1-based number of the line in the page, the title being line 1
+	Line int
+	// Text is the original text of the line
+	Text string
+	// Reason describes what could not be interpreted
+	Reason string
+}
+
+// New returns the document of a document model
+func New(model *models.Document) *Document {
+	return &Document{model: model}
+}
+
+// Model returns the document model of doc, or ErrInvalidDocument when doc was not made by New
+func Model(doc *Document) (*models.Document, error) {
+	if doc == nil || doc.model == nil {
+		return nil, ErrInvalidDocument
+	}
+	return doc.model, nil
+}
+
+// Title returns the title of the page
+func (d *Document) Title() string {
+	if d == nil || d.model == nil {
+		return ""
+	}
+	return d.model.Title
+}
+
+// Tags returns the hashtags of the page
+func (d *Document) Tags() []string {
+	if d == nil || d.model == nil {
+		return nil
+	}
+	return slices.Clone(d.model.Tags)
+}
+
+// Warnings returns the lines that may not have converted as written
+func (d *Document) Warnings() []Warning {
+	if d == nil || d.model == nil {
+		return nil
+	}
+	warnings := make([]Warning, 0, len(d.model.Warnings))
+	for _, warning := range d.model.Warnings {
+		warnings = append(warnings, Warning(warning))
+	}
+	return warnings
+}
@@ -0,0 +1,38 @@
+// Package markdown converts Scrapbox pages to markdown.
+package markdown
+
+import (
+	"github.com/takak2166/scrapbox2notion/internal/render/markdown"
+	"github.com/takak2166/scrapbox2notion/pkg/internal/docmodel"
+	"github.com/takak2166/scrapbox2notion/pkg/scrapbox"
+)
+
+// Converter converts Scrapbox pages to markdown
+type Converter interface {
+	// Convert returns the markdown for page, starting with its title as a heading
+	Convert(page *scrapbox.Page) string
+}
+
+type converter struct{}
+
+// NewConverter creates a Converter
+func NewConverter() Converter {
+	return &converter{}
+}
+
+func (c *converter) Convert(page *scrapbox.Page) string {
+	// NewDocument always converts the page, so it can't be invalid
+	result, _ := Render(scrapbox.NewDocument(page))
+	return result
+}
+
+// Render returns the markdown for doc, starting with its title as a heading.
+// It returns scrapbox.ErrInvalidDocument for a document not made by
+// scrapbox.NewDocument or Reader.Document.
+func Render(doc *scrapbox.Document) (string, error) {
+	model, err := docmodel.Model(doc)
+	if err != nil {
+		return "", err
+	}
+	return markdown.Render(model), nil
+}
@@ -0,0 +1,44 @@
+package markdown
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/pkg/scrapbox"
+)
+
+func TestConvert(t *testing.T) {
+	page := &scrapbox.Page{
+		Title: "Page",
+		Lines: []scrapbox.Line{
+			{Text: "Page"},
+			{Text: "[* bold]"},
+			{Text: " item"},
+		},
+	}
+
+	expected := "# Page\n\n**bold**\n- item\n"
+	if result := NewConverter().Convert(page); result != expected {
+		t.Errorf("Convert() = %q, want %q", result, expected)
+	}
+}
+
+func TestRender(t *testing.T) {
+	doc := scrapbox.NewDocument(&scrapbox.Page{
+		Title: "Page",
+		Lines: []scrapbox.Line{{Text: "Page"}, {Text: "[/ text]"}},
+	})
+
+	expected := "# Page\n\n_text_\n"
+	result, err := Render(doc)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if result != expected {
+		t.Errorf("Render() = %q, want %q", result, expected)
+	}
+
+	if _, err := Render(&scrapbox.Document{}); !errors.Is(err, scrapbox.ErrInvalidDocument) {
+		t.Errorf("Expected ErrInvalidDocument for a document not made from a page, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+// Package notion converts documents to Notion blocks and uploads pages to Notion.
+package notion
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/internal/notion"
+	"github.com/takak2166/scrapbox2notion/pkg/internal/docmodel"
+	"github.com/takak2166/scrapbox2notion/pkg/scrapbox"
+)
+
+// Options configures the conversion to Notion blocks and the upload
+type Options struct {
+	// APIKey is the Notion integration token, required for uploading
+	APIKey string
+	// ParentPageID is the page under which pages and tag databases are created, required for uploading
+	ParentPageID string
+	// URLStyle controls how lines consisting of a single URL are uploaded:
+	// "bookmark", "link" or "plain" (default)
+	URLStyle string
+	// ToggleDepth collapses list items nested at or beyond this depth into toggle blocks, 0 disables
+	ToggleDepth int
+	// Icon sets the page icon to the first emoji in the title
+	Icon bool
+	// DefaultIcon is the emoji used as the icon when the title has none
+	DefaultIcon string
+	// Cover sets the page cover to the first image in the page
+	Cover bool
+	// HTTPClient sends the requests to the Notion API, such as one going through
+	// a proxy or with a timeout. Nil uses the default client.
+	HTTPClient *http.Client
+	// RateLimit is the most Notion API requests sent per second, 0 for no limit
+	RateLimit float64
+	// APIVersion overrides the Notion-Version header of the requests
+	APIVersion string
+	// BaseURL overrides the Notion API endpoint, such as for a data residency
+	// endpoint or an API mock
+	BaseURL string
+	// Trace logs the method, path, status and payload sizes of every Notion API
+	// request at debug level
+	Trace bool
+	// TagMode is how tags are modeled: "databases" (default) creates a copy of
+	// the page in the database of each tag, "canonical" the page in the database
+	// of the first tag and links to it in the others, "synced" the content in a
+	// synced block of the page in the database of the first tag that the pages
+	// in the others show, and "relation" a Pages database whose rows relate to a
+	// Tags database
+	TagMode string
+	// TagHierarchy is how tags with levels such as work/projectX are organized:
+	// "flat" (default) as tags of their own, "nested" as a projectX database in a
+	// work page, and "property" in a "Parent tag" property
+	TagHierarchy string
+	// Users maps Scrapbox user IDs to the email or ID of Notion users, to fill
+	// the "Created by" property of pages with their writers
+	Users map[string]string
+	// Metrics fills the "Views" and "Backlinks" number properties of pages
+	Metrics bool
+}
+
+// Uploader creates Notion pages from documents
+type Uploader interface {
+	// Upload creates a page with the document content in the database of each tag,
+	// or under the parent page when there are no tags. It returns
+	// scrapbox.ErrInvalidDocument for a document not made by scrapbox.NewDocument
+	// or Reader.Document.
+	Upload(ctx context.Context, doc *scrapbox.Document, tags []string) error
+}
+
+// NewUploader creates an Uploader with the given options
+func NewUploader(opts Options) (Uploader, error) {
+	clientOpts, err := clientOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	client, err := notion.NewWithOptions(notion.Options{
+		APIKey:     opts.APIKey,
+		ParentID:   opts.ParentPageID,
+		HTTPClient: opts.HTTPClient,
+		RateLimit:  opts.RateLimit,
+		APIVersion: opts.APIVersion,
+		BaseURL:    opts.BaseURL,
+		Trace:      opts.Trace,
+	}, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &uploader{client: client}, nil
+}
+
+type uploader struct {
+	client *notion.Client
+}
+
+func (u *uploader) Upload(ctx context.Context, doc *scrapbox.Document, tags []string) error {
+	model, err := docmodel.Model(doc)
+	if err != nil {
+		return err
+	}
+	return u.client.CreatePage(ctx, model, tags)
+}
+
+// Blocks converts a document to Notion blocks. Credentials in opts are not used.
+func Blocks(doc *scrapbox.Document, opts Options) ([]notionapi.Block, error) {
+	model, err := docmodel.Model(doc)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts, err := clientOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return notion.ConvertDocument(model, clientOpts...), nil
+}
+
+// clientOptions converts the public options to client options
+func clientOptions(opts Options) ([]notion.Option, error) {
+	var clientOpts []notion.Option
+	if opts.URLStyle != "" {
+		style, err := notion.ParseURLStyle(opts.URLStyle)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = append(clientOpts, notion.WithURLStyle(style))
+	}
+	if opts.ToggleDepth > 0 {
+		clientOpts = append(clientOpts, notion.WithToggleDepth(opts.ToggleDepth))
+	}
+	if opts.Icon || opts.DefaultIcon != "" {
+		clientOpts = append(clientOpts, notion.WithPageIcon(opts.DefaultIcon))
+	}
+	if opts.Cover {
+		clientOpts = append(clientOpts, notion.WithPageCover())
+	}
+	if opts.TagMode != "" {
+		mode, err := notion.ParseTagMode(opts.TagMode)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = append(clientOpts, notion.WithTagMode(mode))
+	}
+	if opts.TagHierarchy != "" {
+		hierarchy, err := notion.ParseTagHierarchy(opts.TagHierarchy)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = append(clientOpts, notion.WithTagHierarchy(hierarchy))
+	}
+	if opts.Users != nil {
+		clientOpts = append(clientOpts, notion.WithUserMapping(opts.Users))
+	}
+	if opts.Metrics {
+		clientOpts = append(clientOpts, notion.WithPageMetrics())
+	}
+	return clientOpts, nil
+}
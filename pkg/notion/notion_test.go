@@ -0,0 +1,55 @@
+package notion
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/takak2166/scrapbox2notion/pkg/scrapbox"
+)
+
+func TestBlocks(t *testing.T) {
+	doc := scrapbox.NewDocument(&scrapbox.Page{
+		Title: "Title",
+		Lines: []scrapbox.Line{
+			{Text: "Title"},
+			{Text: "[***** Heading]"},
+			{Text: " item"},
+			{Text: "[https://example.com]"},
+		},
+	})
+
+	blocks, err := Blocks(doc, Options{URLStyle: "bookmark"})
+	if err != nil {
+		t.Fatalf("Blocks() error = %v", err)
+	}
+
+	expected := []notionapi.BlockType{notionapi.BlockTypeHeading1, notionapi.BlockTypeBulletedListItem, notionapi.BlockTypeBookmark}
+	if len(blocks) != len(expected) {
+		t.Fatalf("Expected %d blocks, got %d", len(expected), len(blocks))
+	}
+	for i, block := range blocks {
+		if block.GetType() != expected[i] {
+			t.Errorf("Expected block %d to be %s, got %s", i, expected[i], block.GetType())
+		}
+	}
+
+	if _, err := Blocks(doc, Options{URLStyle: "invalid"}); err == nil {
+		t.Error("Expected error for invalid URL style, got nil")
+	}
+	if _, err := Blocks(&scrapbox.Document{}, Options{}); !errors.Is(err, scrapbox.ErrInvalidDocument) {
+		t.Errorf("Expected ErrInvalidDocument for a document not made from a page, got %v", err)
+	}
+}
+
+func TestNewUploader(t *testing.T) {
+	if _, err := NewUploader(Options{ParentPageID: "parent"}); err == nil {
+		t.Error("Expected error for missing API key, got nil")
+	}
+	if _, err := NewUploader(Options{APIKey: "key"}); err == nil {
+		t.Error("Expected error for missing parent page ID, got nil")
+	}
+	if _, err := NewUploader(Options{APIKey: "key", ParentPageID: "parent"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
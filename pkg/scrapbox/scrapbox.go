@@ -0,0 +1,198 @@
+// Package scrapbox reads Scrapbox JSON exports.
+package scrapbox
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/takak2166/scrapbox2notion/internal/models"
+	"github.com/takak2166/scrapbox2notion/internal/parser"
+	"github.com/takak2166/scrapbox2notion/pkg/internal/docmodel"
+)
+
+// Page is a Scrapbox page
+type Page struct {
+	Title string
+	// Created and Updated are the Unix times of the page
+	Created int64
+	Updated int64
+	// ID is the Scrapbox ID of the page
+	ID string
+	// Views is the number of times the page was viewed on Scrapbox
+	Views int
+	Lines []Line
+	// Tags are the hashtags of the page
+	Tags []string
+
+	// model is the page as read, holding the export metadata Page leaves out
+	model *models.Page
+}
+
+// Line is a line of text in a Scrapbox page
+type Line struct {
+	Text string
+	// Created and Updated are the Unix times of the line
+	Created int64
+	Updated int64
+	// UserID is the Scrapbox user who last edited the line
+	UserID string
+}
+
+// Document is a page converted to the format independent representation that
+// the markdown and notion packages render. Documents are made by NewDocument
+// and Reader.Document; its Title, Tags and Warnings methods describe the page.
+type Document = docmodel.Document
+
+// Warning is a line of a page that may not have converted as written
+type Warning = docmodel.Warning
+
+// ErrInvalidDocument is returned by the markdown and notion packages for a Document
+// that was not made by NewDocument or Reader.Document
+var ErrInvalidDocument = docmodel.ErrInvalidDocument
+
+// newPage returns the public page of a page read by the parser
+func newPage(model models.Page) Page {
+	page := Page{
+		Title:   model.Title,
+		Created: model.Created,
+		Updated: model.Updated,
+		ID:      model.ID,
+		Views:   model.Views,
+		Lines:   make([]Line, 0, len(model.Lines)),
+		Tags:    model.Tags,
+		model:   &model,
+	}
+	for _, line := range model.Lines {
+		page.Lines = append(page.Lines, Line(line))
+	}
+	return page
+}
+
+// toModel returns the page for the parser, with the fields of the page as
+// changed by the caller over the page as read
+func (p *Page) toModel() *models.Page {
+	var model models.Page
+	if p.model != nil {
+		model = *p.model
+	}
+	model.Title = p.Title
+	model.Created = p.Created
+	model.Updated = p.Updated
+	model.ID = p.ID
+	model.Views = p.Views
+	model.Tags = p.Tags
+	model.Lines = make([]models.Line, 0, len(p.Lines))
+	for _, line := range p.Lines {
+		model.Lines = append(model.Lines, models.Line(line))
+	}
+	return &model
+}
+
+// Options configures how exports are read
+type Options struct {
+	// Duplicates decides how pages with the same title across several exports are merged:
+	// "newest" (default), "first" or "rename"
+	Duplicates string
+	// BracketTags also takes the [page links] on the last lines of a page as its tags
+	BracketTags bool
+	// TagLines decides what happens to lines consisting only of hashtags when pages
+	// are converted: "strip" (default), "keep" or "keep-and-link"
+	TagLines string
+	// Embeds turns lines consisting of a YouTube, Vimeo or Twitter URL into embed blocks
+	Embeds bool
+	// Callouts are rules turning lines starting with a marker into callouts,
+	// written as MARKER=ICON or MARKER=ICON,COLOR
+	Callouts []string
+	// DefaultCallouts turns lines starting with common note markers such as NOTE: into callouts
+	DefaultCallouts bool
+}
+
+// Reader reads one or more Scrapbox exports and returns their merged pages
+type Reader interface {
+	// Read parses an export from r and merges its pages with those read before
+	Read(r io.Reader) error
+	// ReadFile parses an export file and merges its pages with those read before
+	ReadFile(path string) error
+	// Pages returns every page read so far
+	Pages() []Page
+	// Document converts a page to a Document with the options of the reader
+	Document(page *Page) *Document
+}
+
+type reader struct {
+	parser *parser.Parser
+}
+
+// NewReader creates a Reader with the given options
+func NewReader(opts Options) (Reader, error) {
+	var parserOpts []parser.Option
+	if opts.Duplicates != "" {
+		policy, err := parser.ParseDuplicatePolicy(opts.Duplicates)
+		if err != nil {
+			return nil, err
+		}
+		parserOpts = append(parserOpts, parser.WithDuplicatePolicy(policy))
+	}
+	if opts.TagLines != "" {
+		mode, err := parser.ParseTagLineMode(opts.TagLines)
+		if err != nil {
+			return nil, err
+		}
+		parserOpts = append(parserOpts, parser.WithTagLineMode(mode))
+	}
+	if opts.BracketTags {
+		parserOpts = append(parserOpts, parser.WithBracketTags())
+	}
+	if opts.Embeds {
+		parserOpts = append(parserOpts, parser.WithEmbeds())
+	}
+	for _, spec := range opts.Callouts {
+		rule, err := parser.ParseCalloutRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		parserOpts = append(parserOpts, parser.WithCalloutRules(rule))
+	}
+	if opts.DefaultCallouts {
+		parserOpts = append(parserOpts, parser.WithCalloutRules(parser.DefaultCalloutRules...))
+	}
+	return &reader{parser: parser.New(parserOpts...)}, nil
+}
+
+func (r *reader) Read(in io.Reader) error {
+	return r.parser.Parse(in)
+}
+
+func (r *reader) ReadFile(path string) error {
+	return r.parser.ParseFile(path)
+}
+
+func (r *reader) Pages() []Page {
+	models := r.parser.GetPages()
+	pages := make([]Page, 0, len(models))
+	for _, model := range models {
+		pages = append(pages, newPage(model))
+	}
+	return pages
+}
+
+func (r *reader) Document(page *Page) *Document {
+	return docmodel.New(r.parser.ParseDocument(page.toModel()))
+}
+
+// Parse reads a single Scrapbox export from r
+func Parse(r io.Reader) ([]Page, error) {
+	rd, err := NewReader(Options{})
+	if err != nil {
+		return nil, err
+	}
+	if err := rd.Read(r); err != nil {
+		return nil, fmt.Errorf("failed to parse export: %w", err)
+	}
+	return rd.Pages(), nil
+}
+
+// NewDocument converts a page to a Document that output formats render, with the default options
+func NewDocument(page *Page) *Document {
+	return docmodel.New(parser.New().ParseDocument(page.toModel()))
+}
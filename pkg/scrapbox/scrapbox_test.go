@@ -0,0 +1,83 @@
+package scrapbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/takak2166/scrapbox2notion/pkg/internal/docmodel"
+)
+
+func TestParse(t *testing.T) {
+	content := `{"name": "test", "pages": [{"title": "Page", "lines": [{"text": "Page"}, {"text": "#tag"}]}]}`
+
+	pages, err := Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(pages) != 1 || pages[0].Title != "Page" {
+		t.Fatalf("Expected page 'Page', got %v", pages)
+	}
+	if len(pages[0].Tags) != 1 || pages[0].Tags[0] != "tag" {
+		t.Errorf("Expected tags [tag], got %v", pages[0].Tags)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	if _, err := NewReader(Options{Duplicates: "invalid"}); err == nil {
+		t.Error("Expected error for invalid duplicate policy, got nil")
+	}
+
+	r, err := NewReader(Options{Duplicates: "rename"})
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.Read(strings.NewReader(`{"name": "test", "pages": [{"title": "Page", "lines": []}]}`)); err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+	}
+	if pages := r.Pages(); len(pages) != 2 || pages[1].Title != "Page (2)" {
+		t.Errorf("Expected renamed duplicate, got %v", pages)
+	}
+
+	if _, err := NewReader(Options{TagLines: "invalid"}); err == nil {
+		t.Error("Expected error for invalid tag line mode, got nil")
+	}
+	r, err = NewReader(Options{TagLines: "keep"})
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	model, err := docmodel.Model(r.Document(&Page{Title: "Page", Lines: []Line{{Text: "Page"}, {Text: "#tag"}}}))
+	if err != nil {
+		t.Fatalf("Model() error = %v", err)
+	}
+	if len(model.Blocks) != 1 {
+		t.Errorf("Expected the tag line to be kept, got %+v", model.Blocks)
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	pages, err := Parse(strings.NewReader(`{"name": "test", "pages": [{"title": "Page", "lines": [{"text": "Page"}, {"text": "old"}, {"text": "#tag"}]}]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// Changes to a page read from an export are converted
+	page := pages[0]
+	page.Title = "Renamed"
+	page.Lines = []Line{{Text: "Renamed"}, {Text: "new"}}
+	doc := NewDocument(&page)
+	if doc.Title() != "Renamed" {
+		t.Errorf("Expected title 'Renamed', got '%s'", doc.Title())
+	}
+	if tags := doc.Tags(); len(tags) != 1 || tags[0] != "tag" {
+		t.Errorf("Expected tags [tag], got %v", tags)
+	}
+	model, err := docmodel.Model(doc)
+	if err != nil {
+		t.Fatalf("Model() error = %v", err)
+	}
+	if len(model.Blocks) != 1 || model.Blocks[0].Inline[0].Text != "new" {
+		t.Errorf("Expected the changed lines converted, got %+v", model.Blocks)
+	}
+}